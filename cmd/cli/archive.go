@@ -0,0 +1,232 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/store"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+// Block output formats selected by --archive
+const (
+	archiveNone = ""
+	archiveTar  = "tar"
+	archiveZip  = "zip"
+)
+
+// blockOutputOptions controls how block results are written
+type blockOutputOptions struct {
+	gzip    bool   // --gzip: compress each JSON file, or the whole tar stream
+	archive string // --archive tar|zip: bundle the run with a manifest
+}
+
+// blockWriter writes block results as they are produced
+type blockWriter interface {
+	write(block *types.BlockOutput) error
+	Close() error
+}
+
+// newBlockWriter returns a writer that puts one JSON file per block into
+// dir, either as plain/gzipped files or bundled into a single archive, or
+// into the result store when one is open
+func newBlockWriter(dir string, st *store.Store, out blockOutputOptions, global globalOptions) blockWriter {
+	if st != nil {
+		return &storeBlockWriter{st: st, global: global}
+	}
+	if out.archive == archiveNone {
+		return &fileBlockWriter{dir: dir, gzip: out.gzip, global: global}
+	}
+	return &archiveBlockWriter{dir: dir, out: out, global: global}
+}
+
+// fileBlockWriter writes each block to <hash>.json or <hash>.json.gz
+type fileBlockWriter struct {
+	dir    string
+	gzip   bool
+	global globalOptions
+}
+
+func (w *fileBlockWriter) write(block *types.BlockOutput) error {
+	path := filepath.Join(w.dir, block.BlockHeader.BlockHash+".json")
+	if w.gzip {
+		return writeGzipFile(path+".gz", block, w.global)
+	}
+	return writeOutputFile(path, block, w.global, false)
+}
+
+func (w *fileBlockWriter) Close() error { return nil }
+
+// writeGzipFile writes a result as a gzip-compressed JSON file
+func writeGzipFile(path string, v interface{}, global globalOptions) error {
+	f, err := createOutputFile(path)
+	if f == nil {
+		return err
+	}
+	zw := gzip.NewWriter(f)
+	if err := writeOutput(zw, v, global); err != nil {
+		f.abort()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		f.abort()
+		return err
+	}
+	return f.commit()
+}
+
+// archiveWriter adds named files to a tar or zip archive
+type archiveWriter interface {
+	add(name string, data []byte) error
+	Close() error
+}
+
+type tarArchive struct{ tw *tar.Writer }
+
+func (a tarArchive) add(name string, data []byte) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := a.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := a.tw.Write(data)
+	return err
+}
+
+func (a tarArchive) Close() error { return a.tw.Close() }
+
+type zipArchive struct{ zw *zip.Writer }
+
+func (a zipArchive) add(name string, data []byte) error {
+	w, err := a.zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (a zipArchive) Close() error { return a.zw.Close() }
+
+// archiveBlockWriter bundles all block results plus manifest.json into a
+// single archive named after the first block: <hash>.tar, <hash>.tar.gz or
+// <hash>.zip. The archive is created on the first write and only appears
+// under its name once Close has finished it.
+type archiveBlockWriter struct {
+	dir    string
+	out    blockOutputOptions
+	global globalOptions
+
+	name     string
+	f        *outputFile
+	skipped  bool // the archive exists and the overwrite policy keeps it
+	zw       *gzip.Writer
+	aw       archiveWriter
+	manifest types.ArchiveManifest
+}
+
+func (w *archiveBlockWriter) open(first *types.BlockOutput) error {
+	w.name = first.BlockHeader.BlockHash + "." + w.out.archive
+	if w.out.gzip {
+		w.name += ".gz"
+	}
+	f, err := createOutputFile(filepath.Join(w.dir, w.name))
+	if err != nil {
+		return err
+	}
+	if f == nil {
+		w.skipped = true
+		return nil
+	}
+	w.f = f
+
+	// Writers are closed innermost first: archive, then gzip, then file
+	var sink io.Writer = f
+	if w.out.gzip {
+		w.zw = gzip.NewWriter(f)
+		sink = w.zw
+	}
+	if w.out.archive == archiveZip {
+		w.aw = zipArchive{zip.NewWriter(sink)}
+	} else {
+		w.aw = tarArchive{tar.NewWriter(sink)}
+	}
+	w.manifest = types.ArchiveManifest{CreatedAt: time.Now().UTC(), Files: make([]types.ArchiveEntry, 0)}
+	return nil
+}
+
+func (w *archiveBlockWriter) write(block *types.BlockOutput) error {
+	if w.f == nil && !w.skipped {
+		if err := w.open(block); err != nil {
+			return err
+		}
+	}
+	if w.skipped {
+		return nil
+	}
+
+	// Tar headers need the size up front, so each result is encoded to
+	// memory before being added
+	var buf bytes.Buffer
+	if err := writeOutput(&buf, block, w.global); err != nil {
+		return err
+	}
+	entryName := block.BlockHeader.BlockHash + ".json"
+	if err := w.aw.add(entryName, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write archive %s: %w", w.name, err)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	w.manifest.Files = append(w.manifest.Files, types.ArchiveEntry{
+		Name:      entryName,
+		BlockHash: block.BlockHeader.BlockHash,
+		TxCount:   block.TxCount,
+		Bytes:     int64(buf.Len()),
+		SHA256:    hex.EncodeToString(sum[:]),
+	})
+	return nil
+}
+
+// Close adds the manifest and finishes the archive
+func (w *archiveBlockWriter) Close() error {
+	if w.f == nil {
+		return nil
+	}
+	err := func() error {
+		data, err := json.MarshalIndent(w.manifest, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := w.aw.add("manifest.json", append(data, '\n')); err != nil {
+			return err
+		}
+		if err := w.aw.Close(); err != nil {
+			return err
+		}
+		if w.zw != nil {
+			return w.zw.Close()
+		}
+		return nil
+	}()
+	if err != nil {
+		w.f.abort()
+		return fmt.Errorf("failed to write archive %s: %w", w.name, err)
+	}
+	return w.f.commit()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/analyzer"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+// handleBlockDiffMode compares two block results written by block mode
+// (<result dir>/<hash>.json, or .json.gz with --gzip) and prints the diff
+func handleBlockDiffMode(pathA, pathB string, global globalOptions) {
+	var blocks [2]*types.BlockOutput
+	for i, path := range []string{pathA, pathB} {
+		block, err := readBlockResult(path)
+		if err != nil {
+			printError("INVALID_FIXTURE", fmt.Sprintf("%s: %v", path, err))
+			os.Exit(1)
+		}
+		blocks[i] = block
+	}
+	result, err := analyzer.DiffBlocks(blocks[0], blocks[1])
+	if err != nil {
+		printError("INVALID_ARGS", err.Error())
+		os.Exit(1)
+	}
+	if err := writeOutput(os.Stdout, result, global); err != nil {
+		printError("IO_ERROR", err.Error())
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// readBlockResult loads a block result file, gunzipping .gz files
+func readBlockResult(path string) (*types.BlockOutput, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	var block types.BlockOutput
+	if err := json.NewDecoder(r).Decode(&block); err != nil {
+		return nil, fmt.Errorf("failed to parse block result: %w", err)
+	}
+	if block.Mode != "block" {
+		return nil, fmt.Errorf("not a block result (mode %q)", block.Mode)
+	}
+	return &block, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/parser"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+// handleBlocksDirMode analyzes every block of a Bitcoin Core blocks
+// directory, pairing each blk*.dat with its rev*.dat and removing the
+// obfuscation with the directory's xor.dat when there is one (nodes before
+// v28 write none). Blocks are written as in block mode, and progress is
+// reported on stderr after each file.
+func handleBlocksDirMode(dir string, opts parser.BlockOptions, output blockOutputOptions, global globalOptions) {
+	blkPaths, err := parser.BlockFilesInDir(dir)
+	if err != nil {
+		printError("FILE_NOT_FOUND", err.Error())
+		os.Exit(1)
+	}
+	files, err := parser.PairRevFiles(blkPaths)
+	if err != nil {
+		printError("FILE_NOT_FOUND", err.Error())
+		os.Exit(1)
+	}
+	xorPath := filepath.Join(dir, "xor.dat")
+	if _, err := os.Stat(xorPath); os.IsNotExist(err) {
+		xorPath = ""
+	}
+
+	start := time.Now()
+	var blocks, txs int
+	opts.AllBlocks = true
+	opts.OnBlock = func(block *types.BlockOutput) error {
+		blocks++
+		txs += block.TxCount
+		return nil
+	}
+	opts.FileProgress = func(done, total int) {
+		fmt.Fprintf(os.Stderr, "[%d/%d] %s: %d blocks, %d transactions, %s\n",
+			done, total, filepath.Base(files[done-1].Blk), blocks, txs, time.Since(start).Round(time.Second))
+	}
+	handleBlockMode(files, xorPath, opts, output, global)
+}
@@ -1,112 +1,1069 @@
 package main
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
+	"strconv"
+	"strings"
 
-	"chain-lens/pkg/parser"
-	"chain-lens/pkg/types"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/analyzer"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/backend"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/config"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/extension"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/parser"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/utils"
 )
 
+// globalOptions holds flags accepted in every mode
+type globalOptions struct {
+	verbosity   string
+	profile     bool
+	canonical   bool            // from --canonical: RFC 8785 JSON instead of indented
+	concurrency int             // from --concurrency; 0 keeps the configured value
+	configPath  string          // from --config; empty falls back to $CHAIN_LENS_CONFIG
+	stages      map[string]bool // from --stage name=on|off
+	exactVsize  bool            // from --exact-vsize: fee rates over weight/4
+	storeDir    string          // from --store: content-addressed result store
+	overwrite   string          // from --force / --skip-existing; empty keeps the configured policy
+	writeReport bool            // from --write-report: list written files on stderr
+	encoding    string          // from --encoding: raw transaction input encoding; empty reads JSON fixtures
+	rpcURL      string          // from --rpc-url: bitcoind node for missing prevouts
+	rpcCookie   string          // from --rpc-cookie: that node's cookie file
+	esploraURL  string          // from --esplora-url: Esplora API for missing prevouts
+	electrum    string          // from --electrum: Electrum server for missing prevouts
+	snapshot    string          // from --snapshot-prevouts: UTXO snapshot for missing prevouts
+}
+
+// cfg is the configuration file and environment settings, with CLI flags
+// applied on top
+var cfg *config.Config
+
 func main() {
+	// Strip global flags; the remaining args select the mode
+	args, global, err := parseGlobalFlags(os.Args[1:])
+	if err != nil {
+		printError("INVALID_ARGS", err.Error())
+		os.Exit(1)
+	}
+
+	// Load configuration; flags take precedence over file and environment
+	cfg, err = config.Load(global.configPath)
+	if err != nil {
+		printError("INVALID_CONFIG", err.Error())
+		os.Exit(1)
+	}
+	if global.concurrency > 0 {
+		cfg.Concurrency = global.concurrency
+	}
+	if global.overwrite != "" {
+		cfg.Storage.Overwrite = global.overwrite
+	}
+	// A backend chosen on the command line replaces the configured one
+	if global.rpcURL != "" || global.esploraURL != "" || global.electrum != "" || global.snapshot != "" {
+		cfg.RPC.URL, cfg.Esplora.URL, cfg.Electrum.Server = global.rpcURL, global.esploraURL, global.electrum
+		cfg.UTXOSnapshot.Path = global.snapshot
+	}
+	if global.rpcCookie != "" {
+		cfg.RPC.CookieFile = global.rpcCookie
+		cfg.RPC.User, cfg.RPC.Password = "", ""
+	}
+	cfg.Apply()
+	if err := extension.Register(cfg.Extensions); err != nil {
+		printError("INVALID_CONFIG", err.Error())
+		os.Exit(1)
+	}
+	if err := backend.Register(cfg); err != nil {
+		printError("INVALID_CONFIG", err.Error())
+		os.Exit(1)
+	}
+
 	// Check arguments
-	if len(os.Args) < 2 {
-		printError("INVALID_ARGS", "Usage: cli <fixture.json> or cli --block <blk.dat> <rev.dat> <xor.dat>")
+	if len(args) < 1 {
+		printError("INVALID_ARGS", "Usage: cli [--config <file>] [--verbosity summary|standard|full] [--concurrency <n>] [--stage <name>=on|off] [--exact-vsize] [--canonical] [--profile] [--store <dir>] [--force | --skip-existing] [--write-report] [--encoding auto|hex|base64|binary] [--rpc-url <url> [--rpc-cookie <file>] | --esplora-url <url> | --electrum ssl://host:port | --snapshot-prevouts <utxo snapshot>] <fixture.json|raw tx file|->, cli --address <address> [network], cli --uri <bitcoin:uri> [network], cli --compact <fixture.json>, cli --template <fixture.json>, cli --merkle-proof <fixture.json>, cli --merkle-branch <blk.dat|blocks dir> <xor.dat> <txid>..., cli --bloom <fixture.json>, cli --p2pmsg <hex> [command], cli --header <hex>... [--height <first height>], cli --mempool <mempool.dat> [network], cli --peers <peers.dat>, cli --anchors <anchors.dat>, cli --utxo-snapshot <file> [--coins <out.ndjson>], cli --headers-only <blk.dat|dir> <xor.dat>, cli --check-chain <blk.dat|blocks dir|headers dump>... [--xor <xor.dat>] [--network <name>] [--height <first height>], cli --block <blk.dat> <rev.dat> <xor.dat> [--network <name>] [--signet-challenge <hex> | --signet-magic <hex>] [--all-blocks] [--add-files <blk.dat> <rev.dat>]... [--top-movers <n>] [--gzip] [--archive tar|zip], cli --block <blocks dir> [--network <name>] [--top-movers <n>] [--gzip] [--archive tar|zip], cli --block-diff <a.json> <b.json>, cli --store-prune <dir> [--max-age <duration>] [--max-bytes <n>], cli --stats <blk.dat|blocks dir>... [--xor <xor.dat>] [--by-day] [--network <name>] or cli --timeseries <blk.dat> <rev.dat> <xor.dat> [--format csv|json] [--heights <from>-<to>] [--network <name>] [--add-files <blk.dat> <rev.dat>]...")
 		os.Exit(1)
 	}
 
+	// Address mode
+	if args[0] == "--address" {
+		if len(args) < 2 {
+			printError("INVALID_ARGS", "Address mode requires: --address <address> [network]")
+			os.Exit(1)
+		}
+		network := cfg.Network
+		if len(args) > 2 {
+			network = args[2]
+		}
+		handleAddressMode(args[1], network)
+		return
+	}
+
+	// BIP21 payment URI mode
+	if args[0] == "--uri" {
+		if len(args) < 2 {
+			printError("INVALID_ARGS", "URI mode requires: --uri <bitcoin:uri> [network]")
+			os.Exit(1)
+		}
+		network := cfg.Network
+		if len(args) > 2 {
+			network = args[2]
+		}
+		handlePaymentURIMode(args[1], network, global)
+		return
+	}
+
 	// Block mode
-	if os.Args[1] == "--block" {
-		if len(os.Args) < 5 {
-			printError("INVALID_ARGS", "Block mode requires: --block <blk.dat> <rev.dat> <xor.dat>")
+	if args[0] == "--block" {
+		if len(args) >= 2 {
+			if info, err := os.Stat(args[1]); err == nil && info.IsDir() {
+				opts, output, extra, err := parseBlockFlags(args[2:])
+				if err == nil && len(extra) > 0 {
+					err = fmt.Errorf("--add-files does not apply to a blocks directory")
+				}
+				if err != nil {
+					printError("INVALID_ARGS", err.Error())
+					os.Exit(1)
+				}
+				handleBlocksDirMode(args[1], opts, output, global)
+				return
+			}
+		}
+		if len(args) < 4 {
+			printError("INVALID_ARGS", "Block mode requires: --block <blk.dat> <rev.dat> <xor.dat> or --block <blocks dir>")
+			os.Exit(1)
+		}
+		opts, output, extra, err := parseBlockFlags(args[4:])
+		if err != nil {
+			printError("INVALID_ARGS", err.Error())
 			os.Exit(1)
 		}
-		handleBlockMode(os.Args[2], os.Args[3], os.Args[4])
+		files := append([]parser.BlockFile{{Blk: args[1], Rev: args[2]}}, extra...)
+		handleBlockMode(files, args[3], opts, output, global)
+		return
+	}
+
+	// Multi-block time series mode
+	if args[0] == "--timeseries" {
+		if len(args) < 4 {
+			printError("INVALID_ARGS", "Time series mode requires: --timeseries <blk.dat> <rev.dat> <xor.dat>")
+			os.Exit(1)
+		}
+		series, rest, err := parseSeriesFlags(args[4:])
+		if err != nil {
+			printError("INVALID_ARGS", err.Error())
+			os.Exit(1)
+		}
+		opts, output, extra, err := parseBlockFlags(rest)
+		if err == nil && (output.gzip || output.archive != archiveNone) {
+			err = fmt.Errorf("--gzip and --archive do not apply to --timeseries")
+		}
+		if err != nil {
+			printError("INVALID_ARGS", err.Error())
+			os.Exit(1)
+		}
+		files := append([]parser.BlockFile{{Blk: args[1], Rev: args[2]}}, extra...)
+		handleTimeSeriesMode(files, args[3], opts, series, global)
+		return
+	}
+
+	// Block comparison mode
+	if args[0] == "--block-diff" {
+		if len(args) < 3 {
+			printError("INVALID_ARGS", "Block diff mode requires: --block-diff <a.json> <b.json>")
+			os.Exit(1)
+		}
+		handleBlockDiffMode(args[1], args[2], global)
+		return
+	}
+
+	// Result store maintenance
+	if args[0] == "--store-prune" {
+		if len(args) < 2 {
+			printError("INVALID_ARGS", "Store prune mode requires: --store-prune <store dir> [--max-age <duration>] [--max-bytes <n>]")
+			os.Exit(1)
+		}
+		handleStorePruneMode(args[1], args[2:], global)
+		return
+	}
+
+	// Aggregate statistics over many block files
+	if args[0] == "--stats" {
+		opts, err := parseStatsArgs(args[1:])
+		if err != nil {
+			printError("INVALID_ARGS", err.Error())
+			os.Exit(1)
+		}
+		handleStatsMode(opts, global)
+		return
+	}
+
+	// Header chain integrity mode
+	if args[0] == "--check-chain" {
+		opts, err := parseChainCheckArgs(args[1:])
+		if err != nil {
+			printError("INVALID_ARGS", err.Error())
+			os.Exit(1)
+		}
+		handleChainCheckMode(opts, global)
+		return
+	}
+
+	// Mempool snapshot mode
+	if args[0] == "--mempool" {
+		if len(args) < 2 {
+			printError("INVALID_ARGS", "Mempool mode requires: --mempool <mempool.dat> [network]")
+			os.Exit(1)
+		}
+		network := cfg.Network
+		if len(args) > 2 {
+			network = args[2]
+		}
+		handleMempoolMode(args[1], network, global)
+		return
+	}
+
+	// Node address file modes
+	if args[0] == "--peers" || args[0] == "--anchors" {
+		if len(args) < 2 {
+			printError("INVALID_ARGS", "Peers mode requires: --peers <peers.dat> or --anchors <anchors.dat>")
+			os.Exit(1)
+		}
+		handlePeersMode(args[0] == "--anchors", args[1], global)
+		return
+	}
+
+	// UTXO snapshot mode
+	if args[0] == "--utxo-snapshot" {
+		if len(args) != 2 && (len(args) != 4 || args[2] != "--coins") {
+			printError("INVALID_ARGS", "UTXO snapshot mode requires: --utxo-snapshot <file> [--coins <out.ndjson>]")
+			os.Exit(1)
+		}
+		coinsPath := ""
+		if len(args) == 4 {
+			coinsPath = args[3]
+		}
+		handleUTXOSnapshotMode(args[1], coinsPath, global)
+		return
+	}
+
+	// Headers-only block file index mode
+	if args[0] == "--headers-only" {
+		if len(args) < 3 {
+			printError("INVALID_ARGS", "Headers-only mode requires: --headers-only <blk.dat|blocks dir> <xor.dat>")
+			os.Exit(1)
+		}
+		handleHeadersOnlyMode(args[1], args[2], global)
+		return
+	}
+
+	// P2P message decode mode
+	if args[0] == "--p2pmsg" {
+		if len(args) < 2 {
+			printError("INVALID_ARGS", "P2P message mode requires: --p2pmsg <hex> [command]")
+			os.Exit(1)
+		}
+		command := ""
+		if len(args) > 2 {
+			command = args[2]
+		}
+		handleP2PMessageMode(args[1], command, global)
+		return
+	}
+
+	// Block header decode mode
+	if args[0] == "--header" {
+		if len(args) < 2 {
+			printError("INVALID_ARGS", "Header mode requires: --header <80-byte header hex>... [--height <first height>]")
+			os.Exit(1)
+		}
+		handleHeaderMode(args[1:], global)
+		return
+	}
+
+	// SPV merkle proof verification mode
+	if args[0] == "--merkle-proof" {
+		if len(args) < 2 {
+			printError("INVALID_ARGS", "Merkle proof mode requires: --merkle-proof <fixture.json>")
+			os.Exit(1)
+		}
+		handleMerkleProofMode(args[1], global)
+		return
+	}
+
+	// Merkle proof generation from block files
+	if args[0] == "--merkle-branch" {
+		if len(args) < 4 {
+			printError("INVALID_ARGS", "Merkle branch mode requires: --merkle-branch <blk.dat|blocks dir> <xor.dat> <txid>...")
+			os.Exit(1)
+		}
+		handleMerkleBranchMode(args[1], args[2], args[3:], global)
+		return
+	}
+
+	// BIP37 bloom filter mode
+	if args[0] == "--bloom" {
+		if len(args) < 2 {
+			printError("INVALID_ARGS", "Bloom mode requires: --bloom <fixture.json>")
+			os.Exit(1)
+		}
+		handleBloomMode(args[1], global)
+		return
+	}
+
+	// Compact block mode
+	if args[0] == "--compact" {
+		if len(args) < 2 {
+			printError("INVALID_ARGS", "Compact block mode requires: --compact <fixture.json>")
+			os.Exit(1)
+		}
+		handleCompactMode(args[1], global)
+		return
+	}
+
+	// Block vs template comparison mode
+	if args[0] == "--template" {
+		if len(args) < 2 {
+			printError("INVALID_ARGS", "Template mode requires: --template <fixture.json>")
+			os.Exit(1)
+		}
+		handleTemplateMode(args[1], global)
 		return
 	}
 
 	// Transaction mode
-	handleTransactionMode(os.Args[1])
+	handleTransactionMode(args[0], global)
+}
+
+// parseGlobalFlags removes global flags from args, wherever they appear
+func parseGlobalFlags(args []string) ([]string, globalOptions, error) {
+	var global globalOptions
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--verbosity":
+			if i+1 >= len(args) {
+				return nil, global, fmt.Errorf("flag %s requires a value", args[i])
+			}
+			if err := parser.ValidateVerbosity(args[i+1]); err != nil {
+				return nil, global, err
+			}
+			global.verbosity = args[i+1]
+			i++
+		case "--canonical":
+			global.canonical = true
+		case "--exact-vsize":
+			global.exactVsize = true
+		case "--profile":
+			global.profile = true
+			utils.EnableProfiling()
+		case "--concurrency":
+			if i+1 >= len(args) {
+				return nil, global, fmt.Errorf("flag %s requires a value", args[i])
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 1 {
+				return nil, global, fmt.Errorf("invalid concurrency %q: want a positive integer", args[i+1])
+			}
+			global.concurrency = n
+			i++
+		case "--stage":
+			if i+1 >= len(args) {
+				return nil, global, fmt.Errorf("flag %s requires a value", args[i])
+			}
+			name, state, _ := strings.Cut(args[i+1], "=")
+			if state != "on" && state != "off" {
+				return nil, global, fmt.Errorf("invalid --stage %q: want <name>=on or <name>=off", args[i+1])
+			}
+			if global.stages == nil {
+				global.stages = make(map[string]bool)
+			}
+			global.stages[name] = state == "on"
+			i++
+		case "--config":
+			if i+1 >= len(args) {
+				return nil, global, fmt.Errorf("flag %s requires a value", args[i])
+			}
+			global.configPath = args[i+1]
+			i++
+		case "--force", "--skip-existing":
+			policy := config.OverwriteReplace
+			if args[i] == "--skip-existing" {
+				policy = config.OverwriteSkip
+			}
+			if global.overwrite != "" && global.overwrite != policy {
+				return nil, global, fmt.Errorf("--force and --skip-existing cannot be combined")
+			}
+			global.overwrite = policy
+		case "--write-report":
+			global.writeReport = true
+		case "--encoding":
+			if i+1 >= len(args) {
+				return nil, global, fmt.Errorf("flag %s requires a value", args[i])
+			}
+			switch args[i+1] {
+			case parser.EncodingAuto, parser.EncodingHex, parser.EncodingBase64, parser.EncodingBinary:
+			default:
+				return nil, global, fmt.Errorf("invalid encoding %q: want auto, hex, base64 or binary", args[i+1])
+			}
+			global.encoding = args[i+1]
+			i++
+		case "--store":
+			if i+1 >= len(args) {
+				return nil, global, fmt.Errorf("flag %s requires a value", args[i])
+			}
+			global.storeDir = args[i+1]
+			i++
+		case "--rpc-url", "--rpc-cookie", "--esplora-url", "--electrum", "--snapshot-prevouts":
+			if i+1 >= len(args) {
+				return nil, global, fmt.Errorf("flag %s requires a value", args[i])
+			}
+			switch args[i] {
+			case "--rpc-url":
+				global.rpcURL = args[i+1]
+			case "--rpc-cookie":
+				global.rpcCookie = args[i+1]
+			case "--esplora-url":
+				global.esploraURL = args[i+1]
+			case "--electrum":
+				global.electrum = args[i+1]
+			default:
+				global.snapshot = args[i+1]
+			}
+			i++
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	backends := 0
+	for _, set := range []string{global.rpcURL, global.esploraURL, global.electrum, global.snapshot} {
+		if set != "" {
+			backends++
+		}
+	}
+	if backends > 1 {
+		return nil, global, fmt.Errorf("--rpc-url, --esplora-url, --electrum and --snapshot-prevouts cannot be combined")
+	}
+	return rest, global, nil
+}
+
+// writeOutput streams a result as indented JSON at the requested verbosity.
+// Hex fields are encoded as they are written rather than buffered up front.
+// With --canonical the result is written as byte-stable canonical JSON.
+func writeOutput(w io.Writer, v interface{}, global globalOptions) error {
+	projected, err := parser.ApplyVerbosity(v, global.verbosity)
+	if err != nil {
+		return err
+	}
+	defer utils.TimeStage(utils.StageJSONEncode)()
+	if global.canonical {
+		data, err := utils.CanonicalJSON(projected)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(projected)
+}
+
+// writeOutputFile writes a result to path, optionally mirroring it to stdout.
+// The file is replaced atomically, or kept, according to the overwrite
+// policy; a kept file is still echoed.
+func writeOutputFile(path string, v interface{}, global globalOptions, echo bool) error {
+	f, err := createOutputFile(path)
+	if err != nil {
+		return err
+	}
+	if f == nil {
+		if echo {
+			return writeOutput(os.Stdout, v, global)
+		}
+		return nil
+	}
+	var w io.Writer = f
+	if echo {
+		w = io.MultiWriter(f, os.Stdout)
+	}
+	if err := writeOutput(w, v, global); err != nil {
+		f.abort()
+		return err
+	}
+	return f.commit()
+}
+
+// readStdin reads all of stdin, decompressing gzip or zstd input
+func readStdin() ([]byte, error) {
+	r, _, err := utils.NewDecompressReader(os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
 }
 
-func handleTransactionMode(fixturePath string) {
-	// Read fixture file
-	fixtureData, err := os.ReadFile(fixturePath)
+func handleTransactionMode(fixturePath string, global globalOptions) {
+	// Read fixture file, or stdin for "-"
+	var fixtureData []byte
+	var err error
+	if fixturePath == "-" {
+		fixtureData, err = readStdin()
+	} else {
+		fixtureData, err = utils.ReadInput(fixturePath)
+	}
 	if err != nil {
 		printError("FILE_NOT_FOUND", fmt.Sprintf("Failed to read fixture: %v", err))
 		os.Exit(1)
 	}
 
-	// Parse fixture JSON
+	// Parse fixture JSON. Anything else is a PSBT, or a bare raw
+	// transaction in hex, base64 or binary analyzed with only the prevouts
+	// --rpc-url, --esplora-url, --electrum or --snapshot-prevouts finds.
 	var fixture types.Fixture
+	if global.encoding == "" && bytes.HasPrefix(bytes.TrimSpace(fixtureData), []byte("{")) {
+		if err := json.Unmarshal(fixtureData, &fixture); err != nil {
+			printError("INVALID_FIXTURE", fmt.Sprintf("Failed to parse fixture JSON: %v", err))
+			os.Exit(1)
+		}
+	} else if parser.IsPSBT(fixtureData) {
+		// A binary PSBT starts with "psbt" and 0xff; text is passed as is
+		text := string(bytes.TrimSpace(fixtureData))
+		if bytes.HasPrefix(fixtureData, []byte("psbt\xff")) {
+			text = base64.StdEncoding.EncodeToString(fixtureData)
+		}
+		fixture = types.Fixture{PSBT: text}
+	} else {
+		raw, _, err := parser.DecodeRawTx(fixtureData, global.encoding)
+		if err != nil {
+			printError(parser.ErrorCode(err, "INVALID_TX"), err.Error())
+			os.Exit(1)
+		}
+		fixture = types.Fixture{RawTx: hex.EncodeToString(raw), AllowMissingPrevouts: true}
+	}
+
+	// Parse transaction
+	if fixture.Network == "" {
+		fixture.Network = cfg.Network
+	}
+	for name, on := range global.stages {
+		if fixture.Stages == nil {
+			fixture.Stages = make(map[string]bool)
+		}
+		fixture.Stages[name] = on
+	}
+	if global.exactVsize {
+		fixture.ExactVsize = true
+	}
+	result, err := parser.ParseTransaction(fixture)
+	if err != nil {
+		printError(parser.ErrorCode(err, "INVALID_TX"), err.Error())
+		os.Exit(1)
+	}
+
+	// Write to out/ (or the result store) and stdout
+	st, err := openResultStore(global, false)
+	if err != nil {
+		printError("IO_ERROR", err.Error())
+		os.Exit(1)
+	}
+	if err := saveResult(st, result.Txid, "tx", result.Txid, result, global, true); err != nil {
+		printError("IO_ERROR", fmt.Sprintf("Failed to write output file: %v", err))
+		os.Exit(1)
+	}
+	printProfile(global)
+	printWriteReport(global)
+	os.Exit(0)
+}
+
+// handleP2PMessageMode decodes one wire message and prints it to stdout
+func handleP2PMessageMode(rawHex, command string, global globalOptions) {
+	result, err := parser.DecodeP2PMessage(rawHex, command)
+	if err != nil {
+		printError("INVALID_MESSAGE", err.Error())
+		os.Exit(1)
+	}
+	if err := writeOutput(os.Stdout, result, global); err != nil {
+		printError("IO_ERROR", err.Error())
+		os.Exit(1)
+	}
+	if !result.OK {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// handleHeadersOnlyMode prints a per-block index of one blk*.dat file or of
+// every blk*.dat file in a directory
+func handleHeadersOnlyMode(path, xorPath string, global globalOptions) {
+	info, err := os.Stat(path)
+	if err != nil {
+		printError("FILE_NOT_FOUND", fmt.Sprintf("File not found: %s", path))
+		os.Exit(1)
+	}
+	paths := []string{path}
+	if info.IsDir() {
+		if paths, err = parser.BlockFilesInDir(path); err != nil {
+			printError("FILE_NOT_FOUND", err.Error())
+			os.Exit(1)
+		}
+	}
+	if _, err := os.Stat(xorPath); os.IsNotExist(err) {
+		printError("FILE_NOT_FOUND", fmt.Sprintf("File not found: %s", xorPath))
+		os.Exit(1)
+	}
+
+	result, err := parser.ScanBlockHeaders(paths, xorPath)
+	if err != nil {
+		printError("INVALID_BLOCK", err.Error())
+		os.Exit(1)
+	}
+	if err := writeOutput(os.Stdout, result, global); err != nil {
+		printError("IO_ERROR", err.Error())
+		os.Exit(1)
+	}
+	printProfile(global)
+	os.Exit(0)
+}
+
+// handleMempoolMode analyzes every transaction of a mempool.dat file and
+// prints them with the aggregate statistics
+func handleMempoolMode(path, network string, global globalOptions) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		printError("FILE_NOT_FOUND", fmt.Sprintf("File not found: %s", path))
+		os.Exit(1)
+	}
+	result, err := parser.ParseMempoolDat(path, parser.MempoolOptions{
+		Network:    network,
+		Stages:     global.stages,
+		ExactVsize: global.exactVsize,
+	})
+	if err != nil {
+		printError(parser.ErrorCode(err, "INVALID_MEMPOOL"), err.Error())
+		os.Exit(1)
+	}
+	if err := writeOutput(os.Stdout, result, global); err != nil {
+		printError("IO_ERROR", err.Error())
+		os.Exit(1)
+	}
+	printProfile(global)
+	os.Exit(0)
+}
+
+// handlePeersMode decodes a node's peers.dat, or its anchors.dat when
+// anchors is set
+func handlePeersMode(anchors bool, path string, global globalOptions) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		printError("FILE_NOT_FOUND", fmt.Sprintf("File not found: %s", path))
+		os.Exit(1)
+	}
+	var result interface{}
+	var err error
+	code := "INVALID_PEERS"
+	if anchors {
+		result, err = parser.ParseAnchorsDat(path)
+		code = "INVALID_ANCHORS"
+	} else {
+		result, err = parser.ParsePeersDat(path)
+	}
+	if err != nil {
+		printError(code, err.Error())
+		os.Exit(1)
+	}
+	if err := writeOutput(os.Stdout, result, global); err != nil {
+		printError("IO_ERROR", err.Error())
+		os.Exit(1)
+	}
+	printProfile(global)
+	os.Exit(0)
+}
+
+// handleHeaderMode decodes block headers and prints them to stdout. A
+// single header is printed on its own; several must form a chain and are
+// printed with an estimate of the next difficulty adjustment.
+func handleHeaderMode(args []string, global globalOptions) {
+	var hexes []string
+	var firstHeight *int64
+	for i := 0; i < len(args); i++ {
+		if args[i] != "--height" {
+			hexes = append(hexes, args[i])
+			continue
+		}
+		if i+1 >= len(args) {
+			printError("INVALID_ARGS", "--height requires a block height")
+			os.Exit(1)
+		}
+		height, err := strconv.ParseInt(args[i+1], 10, 64)
+		if err == nil && height < 0 {
+			err = fmt.Errorf("must not be negative")
+		}
+		if err != nil {
+			printError("INVALID_ARGS", fmt.Sprintf("invalid --height %q: %v", args[i+1], err))
+			os.Exit(1)
+		}
+		firstHeight = &height
+		i++
+	}
+
+	var result any
+	var err error
+	if len(hexes) == 1 && firstHeight == nil {
+		result, err = parser.DecodeBlockHeader(hexes[0])
+	} else {
+		result, err = parser.DecodeHeaderChain(hexes, firstHeight)
+	}
+	if err != nil {
+		printError("INVALID_HEADER", err.Error())
+		os.Exit(1)
+	}
+	if err := writeOutput(os.Stdout, result, global); err != nil {
+		printError("IO_ERROR", err.Error())
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func handleMerkleProofMode(fixturePath string, global globalOptions) {
+	fixtureData, err := utils.ReadInput(fixturePath)
+	if err != nil {
+		printError("FILE_NOT_FOUND", fmt.Sprintf("Failed to read fixture: %v", err))
+		os.Exit(1)
+	}
+
+	var fixture types.MerkleProofFixture
 	if err := json.Unmarshal(fixtureData, &fixture); err != nil {
 		printError("INVALID_FIXTURE", fmt.Sprintf("Failed to parse fixture JSON: %v", err))
 		os.Exit(1)
 	}
+	result, err := parser.VerifyMerkleProof(fixture)
+	if err != nil {
+		printError(parser.ErrorCode(err, "INVALID_PROOF"), err.Error())
+		os.Exit(1)
+	}
+	if err := writeOutput(os.Stdout, result, global); err != nil {
+		printError("IO_ERROR", err.Error())
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
 
-	// Parse transaction
-	result, err := parser.ParseTransaction(fixture)
+// handleMerkleBranchMode finds transactions in block files and prints an
+// inclusion proof for each
+func handleMerkleBranchMode(path, xorPath string, txids []string, global globalOptions) {
+	for _, txid := range txids {
+		if raw, err := hex.DecodeString(txid); err != nil || len(raw) != 32 {
+			printError("INVALID_ARGS", fmt.Sprintf("Invalid txid: %s", txid))
+			os.Exit(1)
+		}
+	}
+	info, err := os.Stat(path)
 	if err != nil {
-		printError("INVALID_TX", err.Error())
+		printError("FILE_NOT_FOUND", fmt.Sprintf("File not found: %s", path))
+		os.Exit(1)
+	}
+	paths := []string{path}
+	if info.IsDir() {
+		if paths, err = parser.BlockFilesInDir(path); err != nil {
+			printError("FILE_NOT_FOUND", err.Error())
+			os.Exit(1)
+		}
+	}
+	if _, err := os.Stat(xorPath); os.IsNotExist(err) {
+		printError("FILE_NOT_FOUND", fmt.Sprintf("File not found: %s", xorPath))
 		os.Exit(1)
 	}
 
-	// Create output directory
-	if err := os.MkdirAll("out", 0755); err != nil {
-		printError("IO_ERROR", fmt.Sprintf("Failed to create output directory: %v", err))
+	result, err := parser.GenerateMerkleProofs(paths, xorPath, txids)
+	if err != nil {
+		printError(parser.ErrorCode(err, "INVALID_BLOCK"), err.Error())
 		os.Exit(1)
 	}
+	if err := writeOutput(os.Stdout, result, global); err != nil {
+		printError("IO_ERROR", err.Error())
+		os.Exit(1)
+	}
+	printProfile(global)
+	os.Exit(0)
+}
 
-	// Write to file
-	outputPath := filepath.Join("out", result.Txid+".json")
-	outputJSON, _ := json.MarshalIndent(result, "", "  ")
-	if err := os.WriteFile(outputPath, outputJSON, 0644); err != nil {
+func handleBloomMode(fixturePath string, global globalOptions) {
+	fixtureData, err := utils.ReadInput(fixturePath)
+	if err != nil {
+		printError("FILE_NOT_FOUND", fmt.Sprintf("Failed to read fixture: %v", err))
+		os.Exit(1)
+	}
+
+	var fixture types.BloomFixture
+	if err := json.Unmarshal(fixtureData, &fixture); err != nil {
+		printError("INVALID_FIXTURE", fmt.Sprintf("Failed to parse fixture JSON: %v", err))
+		os.Exit(1)
+	}
+	result, err := parser.MatchBloomFilter(fixture)
+	if err != nil {
+		printError(parser.ErrorCode(err, "INVALID_FILTER"), err.Error())
+		os.Exit(1)
+	}
+	if err := writeOutput(os.Stdout, result, global); err != nil {
+		printError("IO_ERROR", err.Error())
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func handleCompactMode(fixturePath string, global globalOptions) {
+	fixtureData, err := utils.ReadInput(fixturePath)
+	if err != nil {
+		printError("FILE_NOT_FOUND", fmt.Sprintf("Failed to read fixture: %v", err))
+		os.Exit(1)
+	}
+
+	var fixture types.CompactBlockFixture
+	if err := json.Unmarshal(fixtureData, &fixture); err != nil {
+		printError("INVALID_FIXTURE", fmt.Sprintf("Failed to parse fixture JSON: %v", err))
+		os.Exit(1)
+	}
+	if fixture.Network == "" {
+		fixture.Network = cfg.Network
+	}
+
+	result, err := parser.ParseCompactBlock(fixture)
+	if err != nil {
+		printError(parser.ErrorCode(err, "INVALID_COMPACT_BLOCK"), err.Error())
+		os.Exit(1)
+	}
+
+	st, err := openResultStore(global, false)
+	if err != nil {
+		printError("IO_ERROR", err.Error())
+		os.Exit(1)
+	}
+	hash := result.BlockHeader.BlockHash
+	if err := saveResult(st, hash, "compact_block", hash, result, global, true); err != nil {
 		printError("IO_ERROR", fmt.Sprintf("Failed to write output file: %v", err))
 		os.Exit(1)
 	}
+	printProfile(global)
+	printWriteReport(global)
+	os.Exit(0)
+}
 
-	// Print to stdout
-	fmt.Println(string(outputJSON))
+func handleTemplateMode(fixturePath string, global globalOptions) {
+	fixtureData, err := utils.ReadInput(fixturePath)
+	if err != nil {
+		printError("FILE_NOT_FOUND", fmt.Sprintf("Failed to read fixture: %v", err))
+		os.Exit(1)
+	}
+
+	var fixture types.TemplateFixture
+	if err := json.Unmarshal(fixtureData, &fixture); err != nil {
+		printError("INVALID_FIXTURE", fmt.Sprintf("Failed to parse fixture JSON: %v", err))
+		os.Exit(1)
+	}
+	if fixture.Network == "" {
+		fixture.Network = cfg.Network
+	}
+
+	result, err := parser.CompareBlockTemplate(fixture)
+	if err != nil {
+		printError(parser.ErrorCode(err, "INVALID_TEMPLATE"), err.Error())
+		os.Exit(1)
+	}
+
+	st, err := openResultStore(global, false)
+	if err != nil {
+		printError("IO_ERROR", err.Error())
+		os.Exit(1)
+	}
+	name := result.BlockHeader.BlockHash + ".template"
+	if err := saveResult(st, name, "template", name, result, global, true); err != nil {
+		printError("IO_ERROR", fmt.Sprintf("Failed to write output file: %v", err))
+		os.Exit(1)
+	}
+	printProfile(global)
+	printWriteReport(global)
 	os.Exit(0)
 }
 
-func handleBlockMode(blkPath, revPath, xorPath string) {
+// parseBlockFlags parses the optional flags that follow the block mode paths,
+// including further blk/rev pairs given with --add-files
+func parseBlockFlags(args []string) (parser.BlockOptions, blockOutputOptions, []parser.BlockFile, error) {
+	var opts parser.BlockOptions
+	var output blockOutputOptions
+	var extra []parser.BlockFile
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--gzip" {
+			output.gzip = true
+			continue
+		}
+		if args[i] == "--all-blocks" {
+			opts.AllBlocks = true
+			continue
+		}
+		if args[i] == "--add-files" {
+			if i+2 >= len(args) {
+				return opts, output, nil, fmt.Errorf("flag --add-files requires <blk.dat> <rev.dat>")
+			}
+			extra = append(extra, parser.BlockFile{Blk: args[i+1], Rev: args[i+2]})
+			opts.AllBlocks = true
+			i += 2
+			continue
+		}
+		if i+1 >= len(args) {
+			return opts, output, nil, fmt.Errorf("flag %s requires a value", args[i])
+		}
+		switch args[i] {
+		case "--archive":
+			if args[i+1] != archiveTar && args[i+1] != archiveZip {
+				return opts, output, nil, fmt.Errorf("invalid archive format %q: want tar or zip", args[i+1])
+			}
+			output.archive = args[i+1]
+		case "--network":
+			opts.Network = args[i+1]
+		case "--top-movers":
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 1 {
+				return opts, output, nil, fmt.Errorf("invalid --top-movers %q: want a positive count", args[i+1])
+			}
+			opts.TopMovers = n
+		case "--signet-challenge":
+			challenge, err := utils.HexToBytes(args[i+1])
+			if err != nil {
+				return opts, output, nil, fmt.Errorf("invalid signet challenge: %v", err)
+			}
+			magic := analyzer.SignetMagic(challenge)
+			opts.CustomMagic = &magic
+		case "--signet-magic":
+			magic, err := analyzer.ParseMagic(args[i+1])
+			if err != nil {
+				return opts, output, nil, err
+			}
+			opts.CustomMagic = &magic
+		default:
+			return opts, output, nil, fmt.Errorf("unknown flag: %s", args[i])
+		}
+		i++
+	}
+	if output.gzip && output.archive == archiveZip {
+		return opts, output, nil, fmt.Errorf("--gzip cannot be combined with --archive zip; zip entries are already compressed")
+	}
+	return opts, output, extra, nil
+}
+
+func handleBlockMode(files []parser.BlockFile, xorPath string, opts parser.BlockOptions, output blockOutputOptions, global globalOptions) {
 	// Validate files exist
-	for _, path := range []string{blkPath, revPath, xorPath} {
+	var paths []string
+	if xorPath != "" {
+		paths = append(paths, xorPath)
+	}
+	for _, f := range files {
+		paths = append(paths, f.Blk, f.Rev)
+	}
+	for _, path := range paths {
 		if _, err := os.Stat(path); os.IsNotExist(err) {
 			printError("FILE_NOT_FOUND", fmt.Sprintf("File not found: %s", path))
 			os.Exit(1)
 		}
 	}
 
-	// Parse blocks
-	blocks, err := parser.ParseBlock(blkPath, revPath, xorPath)
+	// Parse blocks, writing each one (optionally gzipped, archived or into
+	// the result store) as soon as it is analyzed when whole files are scanned
+	st, err := openResultStore(global, output.gzip)
 	if err != nil {
-		printError("INVALID_BLOCK", err.Error())
+		printError("IO_ERROR", err.Error())
 		os.Exit(1)
 	}
-
-	// Create output directory
-	if err := os.MkdirAll("out", 0755); err != nil {
-		printError("IO_ERROR", fmt.Sprintf("Failed to create output directory: %v", err))
+	if st != nil && output.archive != archiveNone {
+		printError("INVALID_ARGS", "--archive cannot be combined with a result store")
+		os.Exit(1)
+	}
+	if st == nil {
+		if err := os.MkdirAll(resultDir(), 0755); err != nil {
+			printError("IO_ERROR", fmt.Sprintf("Failed to create output directory: %v", err))
+			os.Exit(1)
+		}
+	}
+	writer := newBlockWriter(resultDir(), st, output, global)
+	opts.Stages = global.stages
+	opts.ExactVsize = global.exactVsize
+	var blocks []*types.BlockOutput
+	if opts.AllBlocks {
+		if onBlock := opts.OnBlock; onBlock != nil {
+			opts.OnBlock = func(block *types.BlockOutput) error {
+				if err := onBlock(block); err != nil {
+					return err
+				}
+				return writer.write(block)
+			}
+		} else {
+			opts.OnBlock = writer.write
+		}
+		blocks, err = parser.ParseBlockFiles(files, xorPath, opts)
+	} else {
+		blocks, err = parser.ParseBlockWithOptions(files[0].Blk, files[0].Rev, xorPath, opts)
+	}
+	if err != nil {
+		writer.Close()
+		printError(parser.ErrorCode(err, "INVALID_BLOCK"), err.Error())
 		os.Exit(1)
 	}
-
-	// Write each block to file
 	for _, block := range blocks {
-		outputPath := filepath.Join("out", block.BlockHeader.BlockHash+".json")
-		outputJSON, _ := json.MarshalIndent(block, "", "  ")
-		if err := os.WriteFile(outputPath, outputJSON, 0644); err != nil {
+		if err := writer.write(block); err != nil {
 			printError("IO_ERROR", fmt.Sprintf("Failed to write block output: %v", err))
 			os.Exit(1)
 		}
 	}
+	if err := writer.Close(); err != nil {
+		printError("IO_ERROR", fmt.Sprintf("Failed to write block output: %v", err))
+		os.Exit(1)
+	}
+
+	printProfile(global)
+	printWriteReport(global)
+	os.Exit(0)
+}
 
+func handleAddressMode(address, network string) {
+	result := analyzer.AnalyzeAddress(address, network)
+	outputJSON, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(outputJSON))
+	if !result.OK {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", result.Error.Message)
+		os.Exit(1)
+	}
 	os.Exit(0)
 }
 
+// handlePaymentURIMode parses a BIP21 URI and prints it to stdout
+func handlePaymentURIMode(uri, network string, global globalOptions) {
+	result := analyzer.ParsePaymentURI(uri, network)
+	if err := writeOutput(os.Stdout, result, global); err != nil {
+		printError("IO_ERROR", err.Error())
+		os.Exit(1)
+	}
+	if !result.OK {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", result.Error.Message)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// printProfile writes the per-stage timing report to stderr when --profile
+// is set, keeping stdout reserved for the analysis JSON
+func printProfile(global globalOptions) {
+	if !global.profile {
+		return
+	}
+	reportJSON, _ := json.MarshalIndent(map[string]interface{}{
+		"profile": utils.ProfileReport(),
+	}, "", "  ")
+	fmt.Fprintln(os.Stderr, string(reportJSON))
+}
+
 func printError(code, message string) {
 	type errorOutput struct {
 		OK    bool             `json:"ok"`
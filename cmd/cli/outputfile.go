@@ -0,0 +1,145 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/config"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+// outputFile is an output file being written. Data goes to a temporary file
+// in the same directory that commit renames into place, so readers and
+// concurrent runs never see a truncated result.
+type outputFile struct {
+	*os.File
+	path   string
+	policy string
+	sum    hash.Hash
+	bytes  int64
+}
+
+// createOutputFile starts writing path under the overwrite policy. It
+// returns nil when the file exists and the policy is to skip it; the skip is
+// recorded in the write report.
+func createOutputFile(path string) (*outputFile, error) {
+	policy := cfg.Storage.Overwrite
+	if policy != config.OverwriteReplace {
+		if info, err := os.Stat(path); err == nil {
+			return nil, existingOutput(path, policy, info.Size())
+		}
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &outputFile{File: f, path: path, policy: policy, sum: sha256.New()}, nil
+}
+
+// existingOutput handles an output file that already exists: nil after
+// recording the skip, or an error under the error policy
+func existingOutput(path, policy string, size int64) error {
+	if policy == config.OverwriteSkip {
+		recordWrite(types.OutputWrite{Path: path, Status: "skipped", Bytes: size})
+		return nil
+	}
+	return fmt.Errorf("%s already exists (use --force to replace it or --skip-existing to keep it)", path)
+}
+
+func (f *outputFile) Write(p []byte) (int, error) {
+	n, err := f.File.Write(p)
+	f.sum.Write(p[:n])
+	f.bytes += int64(n)
+	return n, err
+}
+
+// commit moves the finished file into place. Under the skip and error
+// policies it is hard-linked instead, which fails rather than replacing a
+// file another run created in the meantime.
+func (f *outputFile) commit() error {
+	tmp := f.Name()
+	defer os.Remove(tmp)
+	if err := f.File.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, 0644); err != nil {
+		return err
+	}
+	status := "created"
+	if f.policy == config.OverwriteReplace {
+		if _, err := os.Stat(f.path); err == nil {
+			status = "replaced"
+		}
+		if err := os.Rename(tmp, f.path); err != nil {
+			return err
+		}
+	} else if err := os.Link(tmp, f.path); err != nil {
+		if !os.IsExist(err) {
+			return err
+		}
+		info, statErr := os.Stat(f.path)
+		if statErr != nil {
+			return err
+		}
+		return existingOutput(f.path, f.policy, info.Size())
+	}
+	recordWrite(types.OutputWrite{
+		Path:   f.path,
+		Status: status,
+		Bytes:  f.bytes,
+		SHA256: hex.EncodeToString(f.sum.Sum(nil)),
+	})
+	return nil
+}
+
+// abort discards a partially written file
+func (f *outputFile) abort() {
+	f.File.Close()
+	os.Remove(f.Name())
+}
+
+// writeLog collects the files written by this run for --write-report
+var writeLog struct {
+	sync.Mutex
+	files []types.OutputWrite
+}
+
+func recordWrite(w types.OutputWrite) {
+	writeLog.Lock()
+	writeLog.files = append(writeLog.files, w)
+	writeLog.Unlock()
+}
+
+// printWriteReport writes the list of output files to stderr when
+// --write-report is set, keeping stdout reserved for the analysis JSON
+func printWriteReport(global globalOptions) {
+	if !global.writeReport {
+		return
+	}
+	writeLog.Lock()
+	defer writeLog.Unlock()
+	report := types.WriteReport{
+		OK:     true,
+		Mode:   "write_report",
+		Policy: cfg.Storage.Overwrite,
+		Files:  make([]types.OutputWrite, 0, len(writeLog.files)),
+	}
+	for _, w := range writeLog.files {
+		report.Files = append(report.Files, w)
+		switch w.Status {
+		case "skipped", "deduped":
+			report.Skipped++
+		default:
+			report.Written++
+			report.Bytes += w.Bytes
+		}
+	}
+	reportJSON, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Fprintln(os.Stderr, string(reportJSON))
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/store"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+// openResultStore opens the content-addressed store named by --store or
+// storage.result_store, or returns nil when results go to flat files in
+// the result directory
+func openResultStore(global globalOptions, compress bool) (*store.Store, error) {
+	dir := global.storeDir
+	if dir == "" {
+		dir = cfg.Storage.ResultStore
+	}
+	if dir == "" {
+		return nil, nil
+	}
+	return store.Open(dir, compress || cfg.Storage.ResultStoreGzip)
+}
+
+// resultDir is where results go without a result store:
+// storage.result_dir, which the web server browses, or ./out when unset
+func resultDir() string {
+	if cfg.Storage.ResultDir != "" {
+		return cfg.Storage.ResultDir
+	}
+	return "out"
+}
+
+// saveResult writes a result to the store under key, or to
+// <result dir>/<name>.json without one, optionally mirroring it to stdout
+func saveResult(st *store.Store, key, kind, name string, v interface{}, global globalOptions, echo bool) error {
+	if st == nil {
+		if err := os.MkdirAll(resultDir(), 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		return writeOutputFile(filepath.Join(resultDir(), name+".json"), v, global, echo)
+	}
+	var buf bytes.Buffer
+	if err := writeOutput(&buf, v, global); err != nil {
+		return err
+	}
+	entry, deduped, err := st.Put(key, kind, buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to store result: %w", err)
+	}
+	status := "stored"
+	if deduped {
+		status = "deduped"
+	}
+	recordWrite(types.OutputWrite{
+		Path:   filepath.Join(st.Root(), entry.Path),
+		Status: status,
+		Bytes:  entry.StoredBytes,
+		SHA256: entry.SHA256,
+	})
+	if echo {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return nil
+}
+
+// storeBlockWriter puts each block result into a result store
+type storeBlockWriter struct {
+	st     *store.Store
+	global globalOptions
+}
+
+func (w *storeBlockWriter) write(block *types.BlockOutput) error {
+	hash := block.BlockHeader.BlockHash
+	return saveResult(w.st, hash, "block", hash, block, w.global, false)
+}
+
+func (w *storeBlockWriter) Close() error { return nil }
+
+// handleStorePruneMode removes results from a store by age and total size
+// and prints what was removed
+func handleStorePruneMode(dir string, args []string, global globalOptions) {
+	var maxAge time.Duration
+	var maxBytes int64
+	for i := 0; i < len(args); i++ {
+		if i+1 >= len(args) {
+			printError("INVALID_ARGS", fmt.Sprintf("flag %s requires a value", args[i]))
+			os.Exit(1)
+		}
+		var err error
+		switch args[i] {
+		case "--max-age":
+			maxAge, err = time.ParseDuration(args[i+1])
+			if err == nil && maxAge <= 0 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "--max-bytes":
+			maxBytes, err = strconv.ParseInt(args[i+1], 10, 64)
+			if err == nil && maxBytes <= 0 {
+				err = fmt.Errorf("must be positive")
+			}
+		default:
+			printError("INVALID_ARGS", fmt.Sprintf("unknown flag: %s", args[i]))
+			os.Exit(1)
+		}
+		if err != nil {
+			printError("INVALID_ARGS", fmt.Sprintf("invalid %s %q: %v", args[i], args[i+1], err))
+			os.Exit(1)
+		}
+		i++
+	}
+	if maxAge == 0 && maxBytes == 0 {
+		printError("INVALID_ARGS", "store prune needs --max-age <duration> and/or --max-bytes <n>")
+		os.Exit(1)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		printError("FILE_NOT_FOUND", fmt.Sprintf("Store not found: %s", dir))
+		os.Exit(1)
+	}
+
+	st, err := store.Open(dir, false)
+	if err != nil {
+		printError("IO_ERROR", err.Error())
+		os.Exit(1)
+	}
+	result, err := st.Prune(maxAge, maxBytes)
+	if err != nil {
+		printError("IO_ERROR", err.Error())
+		os.Exit(1)
+	}
+	if err := writeOutput(os.Stdout, result, global); err != nil {
+		printError("IO_ERROR", err.Error())
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
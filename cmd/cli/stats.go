@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/analyzer"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/parser"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+// statsOptions holds the arguments of --stats
+type statsOptions struct {
+	paths   []string // blk*.dat files or blocks directories
+	xorPath string   // --xor; defaults to xor.dat beside the first blk file
+	byDay   bool     // --by-day
+	network string   // --network
+}
+
+func parseStatsArgs(args []string) (statsOptions, error) {
+	var opts statsOptions
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--by-day":
+			opts.byDay = true
+		case "--xor", "--network":
+			if i+1 >= len(args) {
+				return opts, fmt.Errorf("flag %s requires a value", args[i])
+			}
+			if args[i] == "--xor" {
+				opts.xorPath = args[i+1]
+			} else {
+				opts.network = args[i+1]
+			}
+			i++
+		default:
+			opts.paths = append(opts.paths, args[i])
+		}
+	}
+	if len(opts.paths) == 0 {
+		return opts, fmt.Errorf("stats mode requires: --stats <blk.dat|blocks dir>... [--xor <xor.dat>] [--by-day]")
+	}
+	return opts, nil
+}
+
+// handleStatsMode parses every block of the given files and prints one
+// aggregate report. Each rev*.dat is found beside its blk*.dat, as in a
+// Bitcoin Core blocks directory. Blocks are folded into the totals as soon
+// as they are analyzed and never written out.
+func handleStatsMode(opts statsOptions, global globalOptions) {
+	var blkPaths []string
+	for _, path := range opts.paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			printError("FILE_NOT_FOUND", fmt.Sprintf("File not found: %s", path))
+			os.Exit(1)
+		}
+		if !info.IsDir() {
+			blkPaths = append(blkPaths, path)
+			continue
+		}
+		inDir, err := parser.BlockFilesInDir(path)
+		if err != nil {
+			printError("FILE_NOT_FOUND", err.Error())
+			os.Exit(1)
+		}
+		blkPaths = append(blkPaths, inDir...)
+	}
+	files, err := parser.PairRevFiles(blkPaths)
+	if err != nil {
+		printError("FILE_NOT_FOUND", err.Error())
+		os.Exit(1)
+	}
+	xorPath := opts.xorPath
+	if xorPath == "" {
+		xorPath = filepath.Join(filepath.Dir(blkPaths[0]), "xor.dat")
+	}
+	if _, err := os.Stat(xorPath); os.IsNotExist(err) {
+		printError("FILE_NOT_FOUND", fmt.Sprintf("File not found: %s (pass --xor <xor.dat>)", xorPath))
+		os.Exit(1)
+	}
+
+	agg := analyzer.NewStatsAggregator(opts.byDay)
+	blockOpts := parser.BlockOptions{
+		Network:    opts.network,
+		Stages:     global.stages,
+		ExactVsize: global.exactVsize,
+		AllBlocks:  true,
+		OnBlock: func(block *types.BlockOutput) error {
+			agg.Add(block)
+			return nil
+		},
+	}
+	if _, err := parser.ParseBlockFiles(files, xorPath, blockOpts); err != nil {
+		printError(parser.ErrorCode(err, "INVALID_BLOCK"), err.Error())
+		os.Exit(1)
+	}
+
+	stats, days := agg.Report()
+	result := types.AggregateStatsOutput{OK: true, Mode: "stats", FileCount: len(files), Stats: stats, Days: days}
+	if err := writeOutput(os.Stdout, result, global); err != nil {
+		printError("IO_ERROR", err.Error())
+		os.Exit(1)
+	}
+	printProfile(global)
+	os.Exit(0)
+}
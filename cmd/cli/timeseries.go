@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/analyzer"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/parser"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+// Time series formats selected by --format
+const (
+	seriesJSON = "json"
+	seriesCSV  = "csv"
+)
+
+// seriesOptions holds the flags specific to --timeseries
+type seriesOptions struct {
+	format     string
+	fromHeight int64 // --heights <from>-<to>; 0 leaves a side open
+	toHeight   int64
+}
+
+// parseSeriesFlags takes --format and --heights out of args and returns the
+// rest for parseBlockFlags
+func parseSeriesFlags(args []string) (seriesOptions, []string, error) {
+	opts := seriesOptions{format: seriesCSV}
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		if args[i] != "--format" && args[i] != "--heights" {
+			rest = append(rest, args[i])
+			continue
+		}
+		if i+1 >= len(args) {
+			return opts, nil, fmt.Errorf("flag %s requires a value", args[i])
+		}
+		value := args[i+1]
+		i++
+		if args[i-1] == "--format" {
+			if value != seriesJSON && value != seriesCSV {
+				return opts, nil, fmt.Errorf("invalid time series format %q: want csv or json", value)
+			}
+			opts.format = value
+			continue
+		}
+		from, to, ok := strings.Cut(value, "-")
+		var err error
+		if from != "" {
+			if opts.fromHeight, err = strconv.ParseInt(from, 10, 64); err != nil {
+				ok = false
+			}
+		}
+		if to != "" {
+			if opts.toHeight, err = strconv.ParseInt(to, 10, 64); err != nil {
+				ok = false
+			}
+		}
+		if !ok {
+			return opts, nil, fmt.Errorf("invalid height range %q: want <from>-<to>", value)
+		}
+	}
+	return opts, rest, nil
+}
+
+// handleTimeSeriesMode parses every block in the given files and prints one
+// row per block, ordered by height, as CSV or JSON. Blocks are reduced to
+// their row as soon as they are analyzed, so whole files fit in memory.
+func handleTimeSeriesMode(files []parser.BlockFile, xorPath string, opts parser.BlockOptions, series seriesOptions, global globalOptions) {
+	paths := []string{xorPath}
+	for _, f := range files {
+		paths = append(paths, f.Blk, f.Rev)
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			printError("FILE_NOT_FOUND", fmt.Sprintf("File not found: %s", path))
+			os.Exit(1)
+		}
+	}
+
+	var points []types.BlockSeriesPoint
+	opts.Stages = global.stages
+	opts.ExactVsize = global.exactVsize
+	opts.OnBlock = func(block *types.BlockOutput) error {
+		point := analyzer.BlockSeriesPoint(block)
+		if (series.fromHeight > 0 && point.Height < series.fromHeight) || (series.toHeight > 0 && point.Height > series.toHeight) {
+			return nil
+		}
+		points = append(points, point)
+		return nil
+	}
+	if _, err := parser.ParseBlockFiles(files, xorPath, opts); err != nil {
+		printError(parser.ErrorCode(err, "INVALID_BLOCK"), err.Error())
+		os.Exit(1)
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Height < points[j].Height })
+
+	var err error
+	if series.format == seriesCSV {
+		err = writeSeriesCSV(os.Stdout, points)
+	} else {
+		if points == nil {
+			points = make([]types.BlockSeriesPoint, 0)
+		}
+		err = writeOutput(os.Stdout, types.BlockSeriesOutput{OK: true, Mode: "timeseries", BlockCount: len(points), Points: points}, global)
+	}
+	if err != nil {
+		printError("IO_ERROR", err.Error())
+		os.Exit(1)
+	}
+	printProfile(global)
+	os.Exit(0)
+}
+
+// writeSeriesCSV writes the series with one outputs_<type> column per script
+// type seen anywhere in the range, so every row has the same columns
+func writeSeriesCSV(w io.Writer, points []types.BlockSeriesPoint) error {
+	typeSet := make(map[string]bool)
+	for _, p := range points {
+		for t := range p.ScriptTypes {
+			typeSet[t] = true
+		}
+	}
+	scriptTypes := make([]string, 0, len(typeSet))
+	for t := range typeSet {
+		scriptTypes = append(scriptTypes, t)
+	}
+	sort.Strings(scriptTypes)
+
+	header := []string{
+		"height", "block_hash", "timestamp", "stale", "tx_count", "total_fees_sats", "total_weight",
+		"avg_fee_rate_sat_vb", "median_fee_rate_sat_vb", "segwit_tx_pct", "taproot_input_pct",
+		"taproot_output_pct", "coin_days_destroyed",
+	}
+	for _, t := range scriptTypes {
+		header = append(header, "outputs_"+t)
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	float := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+	for _, p := range points {
+		row := []string{
+			strconv.FormatInt(p.Height, 10), p.BlockHash, strconv.FormatUint(uint64(p.Timestamp), 10),
+			strconv.FormatBool(p.Stale), strconv.Itoa(p.TxCount), strconv.FormatInt(p.TotalFeesSats, 10),
+			strconv.Itoa(p.TotalWeight), float(p.AvgFeeRateSatVb), float(p.MedianFeeRateSatVb),
+			float(p.SegwitTxPct), float(p.TaprootInputPct), float(p.TaprootOutputPct), float(p.CoinDaysDestroyed),
+		}
+		for _, t := range scriptTypes {
+			row = append(row, strconv.Itoa(p.ScriptTypes[t]))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
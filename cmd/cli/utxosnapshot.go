@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/parser"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+// handleUTXOSnapshotMode summarizes a dumptxoutset snapshot on stdout. With
+// coinsPath set, every coin is also written there as one JSON line, in
+// snapshot order, under the overwrite policy.
+func handleUTXOSnapshotMode(path, coinsPath string, global globalOptions) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		printError("FILE_NOT_FOUND", fmt.Sprintf("File not found: %s", path))
+		os.Exit(1)
+	}
+
+	var opts parser.UTXOSnapshotOptions
+	var coins *outputFile
+	if coinsPath != "" {
+		var err error
+		if coins, err = createOutputFile(coinsPath); err != nil {
+			printError("IO_ERROR", err.Error())
+			os.Exit(1)
+		}
+	}
+	var buf *bufio.Writer
+	if coins != nil {
+		buf = bufio.NewWriterSize(coins, 1<<20)
+		enc := json.NewEncoder(buf)
+		opts.OnCoin = func(coin *types.UTXOCoin) error {
+			return enc.Encode(coin)
+		}
+	}
+
+	result, err := parser.ParseUTXOSnapshot(path, opts)
+	if err != nil {
+		if coins != nil {
+			coins.abort()
+		}
+		printError("INVALID_UTXO_SNAPSHOT", err.Error())
+		os.Exit(1)
+	}
+	if coins != nil {
+		if err := buf.Flush(); err != nil {
+			coins.abort()
+			printError("IO_ERROR", err.Error())
+			os.Exit(1)
+		}
+		if err := coins.commit(); err != nil {
+			printError("IO_ERROR", err.Error())
+			os.Exit(1)
+		}
+	}
+	if err := writeOutput(os.Stdout, result, global); err != nil {
+		printError("IO_ERROR", err.Error())
+		os.Exit(1)
+	}
+	printProfile(global)
+	printWriteReport(global)
+	os.Exit(0)
+}
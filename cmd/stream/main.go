@@ -0,0 +1,262 @@
+// Command stream subscribes to bitcoind's ZMQ notifications and writes an
+// analysis of every transaction they announce to stdout as NDJSON, one
+// types.StreamItem per line, for mempool monitoring:
+//
+//	stream [--config <file>] [--verbosity summary|standard|full] [--rawtx <endpoint>] [--rawblock <endpoint>]
+//
+// bitcoind publishes on the endpoints given by its -zmqpubrawtx and
+// -zmqpubrawblock options, e.g. tcp://127.0.0.1:28332. Transactions get
+// their prevouts from the configured backend (rpc, esplora, electrum or a
+// UTXO snapshot) when there is one and are otherwise analyzed without fees;
+// those of new blocks also from earlier transactions of the block, with one
+// lookup per block. Gaps in bitcoind's sequence
+// numbers, meaning notifications were dropped, are reported on stderr.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/backend"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/config"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/extension"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/parser"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/utils"
+
+	"github.com/go-zeromq/zmq4"
+)
+
+// ZMQ topics bitcoind publishes raw transactions and blocks under
+const (
+	topicRawTx    = "rawtx"
+	topicRawBlock = "rawblock"
+)
+
+const usage = "Usage: stream [--config <file>] [--verbosity summary|standard|full] [--rawtx <endpoint>] [--rawblock <endpoint>]"
+
+// options are the command-line flags
+type options struct {
+	configPath string
+	verbosity  string
+	endpoints  map[string]string // topic -> endpoint
+}
+
+// cfg is the configuration file and environment settings
+var cfg *config.Config
+
+func main() {
+	opts, err := parseFlags(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n%s\n", err, usage)
+		os.Exit(1)
+	}
+	cfg, err = config.Load(opts.configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
+		os.Exit(1)
+	}
+	cfg.Apply()
+	if err := extension.Register(cfg.Extensions); err != nil {
+		fmt.Fprintf(os.Stderr, "extensions: %v\n", err)
+		os.Exit(1)
+	}
+	if err := backend.Register(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "prevout backend: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "stream: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func parseFlags(args []string) (options, error) {
+	opts := options{endpoints: make(map[string]string)}
+	for i := 0; i < len(args); i++ {
+		if i+1 >= len(args) {
+			return opts, fmt.Errorf("flag %s requires a value", args[i])
+		}
+		switch args[i] {
+		case "--config":
+			opts.configPath = args[i+1]
+		case "--verbosity":
+			if err := parser.ValidateVerbosity(args[i+1]); err != nil {
+				return opts, err
+			}
+			opts.verbosity = args[i+1]
+		case "--rawtx":
+			opts.endpoints[topicRawTx] = args[i+1]
+		case "--rawblock":
+			opts.endpoints[topicRawBlock] = args[i+1]
+		default:
+			return opts, fmt.Errorf("unknown flag %s", args[i])
+		}
+		i++
+	}
+	if len(opts.endpoints) == 0 {
+		return opts, fmt.Errorf("at least one of --rawtx and --rawblock is required")
+	}
+	return opts, nil
+}
+
+// run subscribes to the requested topics and writes an item per announced
+// transaction until ctx is cancelled
+func run(ctx context.Context, opts options) error {
+	// Keep redialing a node that is down or restarts
+	sub := zmq4.NewSub(ctx, zmq4.WithAutomaticReconnect(true), zmq4.WithDialerMaxRetries(-1))
+	defer sub.Close()
+	// Recv does not watch ctx; closing the socket unblocks it
+	go func() {
+		<-ctx.Done()
+		sub.Close()
+	}()
+
+	dialed := make(map[string]bool)
+	for topic, endpoint := range opts.endpoints {
+		if !dialed[endpoint] {
+			if err := sub.Dial(endpoint); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return fmt.Errorf("dial %s: %w", endpoint, err)
+			}
+			dialed[endpoint] = true
+		}
+		if err := sub.SetOption(zmq4.OptionSubscribe, topic); err != nil {
+			return err
+		}
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	enc := json.NewEncoder(out)
+	lastSeq := make(map[string]uint32)
+	for {
+		msg, err := sub.Recv()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			// The connection dropped; the socket redials by itself, and a
+			// restarted node numbers its notifications from 0 again
+			fmt.Fprintf(os.Stderr, "stream: receive: %v\n", err)
+			clear(lastSeq)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		// bitcoind sends three frames: topic, body and a little-endian
+		// sequence number
+		if len(msg.Frames) != 3 || len(msg.Frames[2]) != 4 {
+			continue
+		}
+		topic, body := string(msg.Frames[0]), msg.Frames[1]
+		seq := binary.LittleEndian.Uint32(msg.Frames[2])
+		if last, ok := lastSeq[topic]; ok && seq > last+1 {
+			fmt.Fprintf(os.Stderr, "stream: %s: missed %d notifications\n", topic, seq-last-1)
+		}
+		lastSeq[topic] = seq
+
+		var items []types.StreamItem
+		switch topic {
+		case topicRawTx:
+			items = []types.StreamItem{{Topic: topic, Sequence: seq, ReceivedAt: time.Now().UTC(), Tx: analyzeTx(body, opts.verbosity)}}
+		case topicRawBlock:
+			if items, err = analyzeBlock(body, seq, opts.verbosity); err != nil {
+				fmt.Fprintf(os.Stderr, "stream: rawblock %d: %v\n", seq, err)
+				continue
+			}
+		default:
+			continue
+		}
+		for _, item := range items {
+			if err := enc.Encode(item); err != nil {
+				return err
+			}
+		}
+		// Flush per notification so consumers see each one immediately
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+// analyzeTx analyzes a mempool transaction, looking up its prevouts with
+// the configured backend
+func analyzeTx(raw []byte, verbosity string) interface{} {
+	result, err := parser.ParseTransaction(types.Fixture{
+		Network:              cfg.Network,
+		RawTx:                hex.EncodeToString(raw),
+		AllowMissingPrevouts: true,
+	})
+	return project(result, err, verbosity)
+}
+
+// analyzeBlock analyzes every transaction of a new block, in block order.
+// A failed prevout lookup is reported on every transaction, as analyzeTx
+// reports it on one.
+func analyzeBlock(raw []byte, seq uint32, verbosity string) ([]types.StreamItem, error) {
+	block, err := parser.DeserializeBlock(raw)
+	if err != nil {
+		return nil, err
+	}
+	prevouts, lookupErr := parser.ResolveBlockPrevouts(block.Transactions)
+	hash := block.BlockHash().String()
+	now := time.Now().UTC()
+	items := make([]types.StreamItem, len(block.Transactions))
+	utils.ForEach(len(items), func(i int) error {
+		fixture := types.Fixture{
+			Network:              cfg.Network,
+			AllowMissingPrevouts: true,
+		}
+		if prevouts != nil {
+			fixture.Prevouts = prevouts[i]
+		}
+		var result *types.TransactionOutput
+		err := lookupErr
+		if err == nil {
+			result, err = parser.AnalyzeParsedTransaction(block.Transactions[i], fixture)
+		}
+		index := i
+		items[i] = types.StreamItem{
+			Topic:      topicRawBlock,
+			Sequence:   seq,
+			ReceivedAt: now,
+			BlockHash:  hash,
+			Index:      &index,
+			Tx:         project(result, err, verbosity),
+		}
+		return nil
+	})
+	return items, nil
+}
+
+// project reduces a result to the requested verbosity, or describes the
+// error that prevented it
+func project(result *types.TransactionOutput, err error, verbosity string) interface{} {
+	if err != nil {
+		return &types.ErrorOutput{
+			OK:    false,
+			Error: &types.ErrorInfo{Code: parser.ErrorCode(err, "INVALID_TX"), Message: err.Error()},
+		}
+	}
+	projected, err := parser.ApplyVerbosity(result, verbosity)
+	if err != nil {
+		return result
+	}
+	return projected
+}
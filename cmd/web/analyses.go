@@ -0,0 +1,452 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/analyzer"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/parser"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// saveAnalysisRequest is the body of POST /api/analyses. Exactly one of
+// Fixture (analyzed now) or JobID (a finished block job) is required.
+type saveAnalysisRequest struct {
+	Name    string         `json:"name"`
+	Tags    []string       `json:"tags"`
+	Fixture *types.Fixture `json:"fixture"`
+	JobID   string         `json:"job_id"`
+}
+
+// analysisStore persists saved analyses on disk. It keeps an index of
+// each analysis's metadata and of the blocks it holds, built when the store
+// opens and kept up to date by Save, so listing and searching read no
+// files; only Get and FindBlock load a result.
+type analysisStore struct {
+	mu    sync.Mutex
+	dir   string
+	index map[string]*indexedAnalysis
+}
+
+// indexedAnalysis is what the index keeps of a saved analysis: its
+// metadata, with Result and Fixture cleared, and for block analyses the
+// blocks in its result
+type indexedAnalysis struct {
+	meta   types.SavedAnalysis
+	blocks []payoutBlock
+}
+
+// payoutBlock is the part of a saved block result the index keeps
+type payoutBlock struct {
+	BlockHeader struct {
+		BlockHash string `json:"block_hash"`
+	} `json:"block_header"`
+	Coinbase struct {
+		Message string                 `json:"coinbase_message"`
+		Payouts *types.CoinbasePayouts `json:"payouts"`
+	} `json:"coinbase"`
+}
+
+// newAnalysisStore opens the workspace in dir, one JSON file per analysis,
+// and indexes it; a file that cannot be read or parsed is reported and
+// skipped. It returns nil when dir is empty (storage not enabled).
+func newAnalysisStore(dir string) (*analysisStore, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create analysis directory: %w", err)
+	}
+	s := &analysisStore{dir: dir, index: make(map[string]*indexedAnalysis)}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "skipping analysis %s: %v\n", filepath.Base(path), err)
+			continue
+		}
+		var a types.SavedAnalysis
+		if err := json.Unmarshal(data, &a); err != nil || a.ID == "" {
+			fmt.Fprintf(os.Stderr, "skipping analysis %s: not an analysis record\n", filepath.Base(path))
+			continue
+		}
+		entry, err := indexAnalysis(&a)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "skipping analysis %s: %v\n", filepath.Base(path), err)
+			continue
+		}
+		s.index[a.ID] = entry
+	}
+	return s, nil
+}
+
+// indexAnalysis builds the index entry of an analysis
+func indexAnalysis(a *types.SavedAnalysis) (*indexedAnalysis, error) {
+	entry := &indexedAnalysis{meta: *a}
+	entry.meta.Result, entry.meta.Fixture = nil, nil
+	if a.Kind == "block" {
+		if err := json.Unmarshal(a.Result, &entry.blocks); err != nil {
+			return nil, fmt.Errorf("failed to parse result of %s: %w", a.ID, err)
+		}
+	}
+	return entry, nil
+}
+
+// Save writes a new analysis record and indexes it
+func (s *analysisStore) Save(a *types.SavedAnalysis) error {
+	entry, err := indexAnalysis(a)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := filepath.Join(s.dir, a.ID+".json")
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	s.index[a.ID] = entry
+	return nil
+}
+
+// Get loads one analysis with its result
+func (s *analysisStore) Get(id string) (*types.SavedAnalysis, error) {
+	// IDs are hex; anything else cannot name a stored file
+	if id == "" || strings.Trim(id, "0123456789abcdef") != "" {
+		return nil, os.ErrNotExist
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, id+".json"))
+	if err != nil {
+		return nil, err
+	}
+	var a types.SavedAnalysis
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// List returns saved analyses carrying tag (all when empty), newest first,
+// without their results
+func (s *analysisStore) List(tag string) ([]types.SavedAnalysis, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]types.SavedAnalysis, 0, len(s.index))
+	for _, entry := range s.index {
+		if tag != "" && !hasTag(entry.meta.Tags, tag) {
+			continue
+		}
+		list = append(list, entry.meta)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.After(list[j].CreatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+// PayoutHistory groups the blocks of all saved block analyses by their
+// coinbase's primary payout address, most blocks first. Blocks saved more
+// than once are counted once.
+func (s *analysisStore) PayoutHistory() ([]types.PayoutHistory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Walk analyses oldest first so each address lists its blocks in the
+	// order they were saved
+	entries := make([]*indexedAnalysis, 0, len(s.index))
+	for _, entry := range s.index {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].meta.CreatedAt.Equal(entries[j].meta.CreatedAt) {
+			return entries[i].meta.CreatedAt.Before(entries[j].meta.CreatedAt)
+		}
+		return entries[i].meta.ID < entries[j].meta.ID
+	})
+
+	byAddress := make(map[string]*types.PayoutHistory)
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		for _, b := range entry.blocks {
+			p := b.Coinbase.Payouts
+			if p == nil || p.PrimaryAddress == nil || seen[b.BlockHeader.BlockHash] {
+				continue
+			}
+			seen[b.BlockHeader.BlockHash] = true
+			h := byAddress[*p.PrimaryAddress]
+			if h == nil {
+				h = &types.PayoutHistory{
+					Address:     *p.PrimaryAddress,
+					BlockHashes: []string{},
+					Patterns:    []string{},
+					Messages:    []string{},
+				}
+				byAddress[h.Address] = h
+			}
+			h.BlockCount++
+			h.BlockHashes = append(h.BlockHashes, b.BlockHeader.BlockHash)
+			if !slices.Contains(h.Patterns, p.Pattern) {
+				h.Patterns = append(h.Patterns, p.Pattern)
+			}
+			if msg := b.Coinbase.Message; msg != "" && !slices.Contains(h.Messages, msg) {
+				h.Messages = append(h.Messages, msg)
+			}
+		}
+	}
+
+	history := make([]types.PayoutHistory, 0, len(byAddress))
+	for _, h := range byAddress {
+		history = append(history, *h)
+	}
+	sort.Slice(history, func(i, j int) bool {
+		if history[i].BlockCount != history[j].BlockCount {
+			return history[i].BlockCount > history[j].BlockCount
+		}
+		return history[i].Address < history[j].Address
+	})
+	return history, nil
+}
+
+// FindBlock returns the analyzed block with the given hash from the saved
+// block analyses, or nil if none holds it. Only the analysis the index
+// names is read.
+func (s *analysisStore) FindBlock(hash string) (*types.BlockOutput, error) {
+	s.mu.Lock()
+	id := ""
+	for _, entry := range s.index {
+		for _, b := range entry.blocks {
+			if b.BlockHeader.BlockHash == hash {
+				id = entry.meta.ID
+				break
+			}
+		}
+		if id != "" {
+			break
+		}
+	}
+	s.mu.Unlock()
+	if id == "" {
+		return nil, nil
+	}
+
+	a, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	var blocks []*types.BlockOutput
+	if err := json.Unmarshal(a.Result, &blocks); err != nil {
+		return nil, fmt.Errorf("failed to parse result of %s: %w", a.ID, err)
+	}
+	for _, b := range blocks {
+		if b.BlockHeader.BlockHash == hash {
+			return b, nil
+		}
+	}
+	return nil, nil
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func storageDisabled(c *gin.Context) {
+	c.JSON(503, gin.H{"ok": false, "error": types.ErrorInfo{
+		Code:    "STORAGE_DISABLED",
+		Message: "saved analyses require storage.analysis_dir (or CHAIN_LENS_STORE_DIR) to be set",
+	}})
+}
+
+// handleSaveAnalysis analyzes a fixture (or takes a finished job's result)
+// and stores it under the given name and tags
+func handleSaveAnalysis(s *analysisStore, q *jobQueue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s == nil {
+			storageDisabled(c)
+			return
+		}
+		var req saveAnalysisRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			if rejectLargeBody(c, err) {
+				return
+			}
+			c.JSON(400, gin.H{"ok": false, "error": types.ErrorInfo{Code: "INVALID_JSON", Message: "Failed to parse JSON"}})
+			return
+		}
+		if strings.TrimSpace(req.Name) == "" || (req.Fixture == nil) == (req.JobID == "") {
+			c.JSON(400, gin.H{"ok": false, "error": types.ErrorInfo{
+				Code:    "INVALID_REQUEST",
+				Message: "name and exactly one of fixture or job_id are required",
+			}})
+			return
+		}
+
+		id, err := newID()
+		if err != nil {
+			c.JSON(500, gin.H{"ok": false, "error": types.ErrorInfo{Code: "IO_ERROR", Message: err.Error()}})
+			return
+		}
+		a := &types.SavedAnalysis{
+			ID:        id,
+			Name:      req.Name,
+			Tags:      req.Tags,
+			CreatedAt: time.Now().UTC(),
+			Fixture:   req.Fixture,
+			JobID:     req.JobID,
+		}
+		if a.Tags == nil {
+			a.Tags = []string{}
+		}
+
+		var result interface{}
+		if req.Fixture != nil {
+			a.Kind = "transaction"
+			if req.Fixture.Network == "" {
+				req.Fixture.Network = cfg.Network
+			}
+			out, err := parser.ParseTransaction(*req.Fixture)
+			if err != nil {
+				c.JSON(400, gin.H{"ok": false, "error": types.ErrorInfo{Code: parser.ErrorCode(err, "PARSE_ERROR"), Message: err.Error()}})
+				return
+			}
+			feed.publishTx(out, feedSourceAPI)
+			result = out
+		} else {
+			status, ok := q.Get(req.JobID)
+			if !ok || status.Status != JobDone {
+				c.JSON(400, gin.H{"ok": false, "error": types.ErrorInfo{
+					Code:    "JOB_NOT_DONE",
+					Message: "job_id must name a finished job",
+				}})
+				return
+			}
+			a.Kind = status.Kind
+			if result, err = q.Result(req.JobID); err != nil {
+				c.JSON(500, gin.H{"ok": false, "error": types.ErrorInfo{Code: "IO_ERROR", Message: err.Error()}})
+				return
+			}
+		}
+		if a.Result, err = json.Marshal(result); err != nil {
+			c.JSON(500, gin.H{"ok": false, "error": types.ErrorInfo{Code: "IO_ERROR", Message: err.Error()}})
+			return
+		}
+
+		if err := s.Save(a); err != nil {
+			c.JSON(500, gin.H{"ok": false, "error": types.ErrorInfo{Code: "IO_ERROR", Message: err.Error()}})
+			return
+		}
+		a.Result, a.Fixture = nil, nil
+		c.JSON(201, a)
+	}
+}
+
+// handleListAnalyses lists saved analyses, optionally filtered by ?tag=
+func handleListAnalyses(s *analysisStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s == nil {
+			storageDisabled(c)
+			return
+		}
+		list, err := s.List(c.Query("tag"))
+		if err != nil {
+			c.JSON(500, gin.H{"ok": false, "error": types.ErrorInfo{Code: "IO_ERROR", Message: err.Error()}})
+			return
+		}
+		c.JSON(200, gin.H{"ok": true, "analyses": list})
+	}
+}
+
+// handleGetAnalysis reloads one saved analysis with its full result
+func handleGetAnalysis(s *analysisStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s == nil {
+			storageDisabled(c)
+			return
+		}
+		a, err := s.Get(c.Param("id"))
+		if err != nil {
+			if os.IsNotExist(err) {
+				c.JSON(404, gin.H{"ok": false, "error": types.ErrorInfo{Code: "NOT_FOUND", Message: "no such analysis"}})
+				return
+			}
+			c.JSON(500, gin.H{"ok": false, "error": types.ErrorInfo{Code: "IO_ERROR", Message: err.Error()}})
+			return
+		}
+		c.JSON(200, a)
+	}
+}
+
+// handleBlockDiff compares two saved blocks, ?a=<block hash>&b=<block hash>
+func handleBlockDiff(s *analysisStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s == nil {
+			storageDisabled(c)
+			return
+		}
+		var blocks [2]*types.BlockOutput
+		for i, side := range []string{"a", "b"} {
+			hash := c.Query(side)
+			if hash == "" {
+				c.JSON(400, gin.H{"ok": false, "error": types.ErrorInfo{Code: "INVALID_REQUEST", Message: "both ?a= and ?b= block hashes are required"}})
+				return
+			}
+			block, err := s.FindBlock(hash)
+			if err != nil {
+				c.JSON(500, gin.H{"ok": false, "error": types.ErrorInfo{Code: "IO_ERROR", Message: err.Error()}})
+				return
+			}
+			if block == nil {
+				c.JSON(404, gin.H{"ok": false, "error": types.ErrorInfo{Code: "NOT_FOUND", Message: fmt.Sprintf("no saved analysis holds block %s", hash)}})
+				return
+			}
+			blocks[i] = block
+		}
+		result, err := analyzer.DiffBlocks(blocks[0], blocks[1])
+		if err != nil {
+			c.JSON(400, gin.H{"ok": false, "error": types.ErrorInfo{Code: "INVALID_REQUEST", Message: err.Error()}})
+			return
+		}
+		writeResult(c, result)
+	}
+}
+
+// handlePayoutHistory reports coinbase payout addresses across the saved
+// block analyses
+func handlePayoutHistory(s *analysisStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s == nil {
+			storageDisabled(c)
+			return
+		}
+		history, err := s.PayoutHistory()
+		if err != nil {
+			c.JSON(500, gin.H{"ok": false, "error": types.ErrorInfo{Code: "IO_ERROR", Message: err.Error()}})
+			return
+		}
+		c.JSON(200, gin.H{"ok": true, "payouts": history})
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/parser"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Request body caps, derived from the parse limits so that a body the
+// parser would reject anyway is not read into memory first
+const (
+	prevoutJSONBytes  = 512       // one prevout entry of a fixture, script included
+	bodySlackBytes    = 64 << 10  // field names, options and whitespace
+	blockFileMaxBytes = 128 << 20 // MAX_BLOCKFILE_SIZE, the largest blk or rev file a node writes
+)
+
+// txBodyBytes caps a fixture: its transaction as hex (two characters a
+// byte) and a prevout entry per input
+func txBodyBytes() int64 {
+	l := parser.CurrentParseLimits()
+	return 2*int64(l.MaxTxBytes) + int64(l.MaxInputs)*prevoutJSONBytes + bodySlackBytes
+}
+
+// blockBodyBytes caps a request carrying a block as hex, with as much
+// again for a template's transaction list
+func blockBodyBytes() int64 {
+	l := parser.CurrentParseLimits()
+	return 4*int64(l.MaxBlockBytes) + bodySlackBytes
+}
+
+// jobUploadBytes caps a block job's blk, rev and xor upload
+func jobUploadBytes() int64 {
+	return 2*blockFileMaxBytes + bodySlackBytes
+}
+
+// limitBody rejects a request whose declared body is over limit and caps
+// the reads of the rest, so that a handler's read fails with
+// *http.MaxBytesError once limit bytes are exceeded
+func limitBody(limit func() int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		n := limit()
+		if c.Request.ContentLength > n {
+			bodyTooLarge(c, n)
+			c.Abort()
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, n)
+		c.Next()
+	}
+}
+
+// rejectLargeBody answers 413 LIMIT_EXCEEDED and returns true when err
+// comes from reading past a body cap
+func rejectLargeBody(c *gin.Context, err error) bool {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		return false
+	}
+	bodyTooLarge(c, tooLarge.Limit)
+	return true
+}
+
+func bodyTooLarge(c *gin.Context, limit int64) {
+	c.JSON(http.StatusRequestEntityTooLarge, gin.H{"ok": false, "error": types.ErrorInfo{
+		Code:    "LIMIT_EXCEEDED",
+		Message: fmt.Sprintf("request body exceeds %d bytes", limit),
+	}})
+}
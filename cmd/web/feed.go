@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// feedClientBuffer is how many messages a /api/ws client may fall
+	// behind by before it is disconnected
+	feedClientBuffer = 64
+	// feedWriteTimeout bounds a single message write to a client
+	feedWriteTimeout = 10 * time.Second
+)
+
+// Feed event sources
+const (
+	feedSourceAPI     = "api"
+	feedSourceGraphQL = "graphql"
+	feedSourceJob     = "job"
+)
+
+// feed is the live feed of analyses served on /api/ws
+var feed *liveFeed
+
+// liveFeed keeps the most recent analyses and running totals, and pushes
+// each new analysis to the connected /api/ws clients. A client that cannot
+// keep up is disconnected rather than slowing down the handlers publishing.
+type liveFeed struct {
+	mu      sync.Mutex
+	size    int
+	events  []types.FeedEvent // oldest first, at most size
+	stats   types.FeedStats
+	vbytes  int64 // of the analyses counted in stats.TotalFeesSats
+	clients map[chan types.FeedMessage]struct{}
+}
+
+func newLiveFeed(size int) *liveFeed {
+	return &liveFeed{
+		size:    size,
+		stats:   types.FeedStats{Since: time.Now().UTC()},
+		clients: make(map[chan types.FeedMessage]struct{}),
+	}
+}
+
+// publishTx adds a successful transaction analysis to the feed
+func (f *liveFeed) publishTx(out *types.TransactionOutput, source string) {
+	if out == nil || !out.OK {
+		return
+	}
+	f.publish(types.FeedEvent{
+		Kind:         "tx",
+		Source:       source,
+		ID:           out.Txid,
+		Network:      out.Network,
+		Vbytes:       out.Vbytes,
+		Weight:       out.Weight,
+		FeeSats:      out.FeeSats,
+		FeeRateSatVb: out.FeeRateSatVb,
+		Warnings:     len(out.Warnings),
+	})
+}
+
+// publishBlock adds a successful block analysis to the feed
+func (f *liveFeed) publishBlock(b *types.BlockOutput, source string) {
+	if b == nil || !b.OK {
+		return
+	}
+	fees := b.BlockStats.TotalFeesSats
+	feeRate := b.BlockStats.AvgFeeRateSatVb
+	f.publish(types.FeedEvent{
+		Kind:         "block",
+		Source:       source,
+		ID:           b.BlockHeader.BlockHash,
+		Height:       b.Coinbase.Bip34Height,
+		TxCount:      b.TxCount,
+		Vbytes:       (b.BlockStats.TotalWeight + 3) / 4,
+		Weight:       b.BlockStats.TotalWeight,
+		FeeSats:      &fees,
+		FeeRateSatVb: &feeRate,
+	})
+}
+
+func (f *liveFeed) publish(ev types.FeedEvent) {
+	ev.Time = time.Now().UTC()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.events) == f.size {
+		copy(f.events, f.events[1:])
+		f.events = f.events[:f.size-1]
+	}
+	f.events = append(f.events, ev)
+
+	if ev.Kind == "block" {
+		f.stats.Blocks++
+	} else {
+		f.stats.Transactions++
+	}
+	if ev.FeeSats != nil && ev.Vbytes > 0 {
+		f.stats.TotalFeesSats += *ev.FeeSats
+		f.vbytes += int64(ev.Vbytes)
+		f.stats.AvgFeeRateSatVb = float64(f.stats.TotalFeesSats) / float64(f.vbytes)
+	}
+	if ev.FeeRateSatVb != nil && *ev.FeeRateSatVb > f.stats.MaxFeeRateSatVb {
+		f.stats.MaxFeeRateSatVb = *ev.FeeRateSatVb
+	}
+
+	msg := types.FeedMessage{Type: "event", Event: &ev, Stats: f.stats}
+	for ch := range f.clients {
+		select {
+		case ch <- msg:
+		default:
+			delete(f.clients, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe registers a client and returns its message channel with the
+// snapshot to send first
+func (f *liveFeed) subscribe() (chan types.FeedMessage, types.FeedMessage) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch := make(chan types.FeedMessage, feedClientBuffer)
+	f.clients[ch] = struct{}{}
+	snapshot := types.FeedMessage{
+		Type:   "snapshot",
+		Events: append([]types.FeedEvent{}, f.events...),
+		Stats:  f.stats,
+	}
+	return ch, snapshot
+}
+
+func (f *liveFeed) unsubscribe(ch chan types.FeedMessage) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.clients[ch]; ok {
+		delete(f.clients, ch)
+		close(ch)
+	}
+}
+
+// serve streams the feed to one WebSocket client until it disconnects or
+// falls behind
+func (f *liveFeed) serve(ws *websocket.Conn) {
+	defer ws.Close()
+	ch, snapshot := f.subscribe()
+	defer f.unsubscribe(ch)
+
+	// The feed is one-way; reading only notices the client going away
+	gone := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, ws)
+		close(gone)
+	}()
+
+	msg, ok := snapshot, true
+	for {
+		ws.SetWriteDeadline(time.Now().Add(feedWriteTimeout))
+		if err := websocket.JSON.Send(ws, msg); err != nil {
+			return
+		}
+		select {
+		case msg, ok = <-ch:
+			if !ok {
+				return // dropped for falling behind
+			}
+		case <-gone:
+			return
+		}
+	}
+}
+
+// handleFeed upgrades GET /api/ws to a WebSocket carrying the live feed
+func handleFeed(f *liveFeed) gin.HandlerFunc {
+	server := websocket.Server{
+		Handshake: checkFeedOrigin,
+		Handler:   f.serve,
+	}
+	return gin.WrapH(server)
+}
+
+// checkFeedOrigin admits browsers from the configured CORS origins or the
+// server's own host; clients that send no Origin are not browsers and are
+// always admitted
+func checkFeedOrigin(config *websocket.Config, req *http.Request) error {
+	origin, err := websocket.Origin(config, req)
+	if err != nil || origin == nil {
+		return err
+	}
+	config.Origin = origin
+	if origin.Host == req.Host {
+		return nil
+	}
+	for _, allowed := range cfg.Server.CORSOrigins {
+		if allowed == "*" || allowed == origin.Scheme+"://"+origin.Host {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %s not allowed", origin)
+}
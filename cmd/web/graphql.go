@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/analyzer"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/parser"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// graphqlRequest is the standard GraphQL-over-HTTP POST body
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// jsonScalar passes arbitrary JSON values through unchanged. It is used for
+// map-valued output fields and for the transaction fixture argument.
+var jsonScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "JSON",
+	Description: "Arbitrary JSON value",
+	Serialize:   func(v interface{}) interface{} { return v },
+	ParseValue:  func(v interface{}) interface{} { return v },
+	ParseLiteral: func(v ast.Value) interface{} {
+		return parseJSONLiteral(v)
+	},
+})
+
+// parseJSONLiteral converts an inline GraphQL literal into a JSON value
+func parseJSONLiteral(v ast.Value) interface{} {
+	switch val := v.(type) {
+	case *ast.StringValue:
+		return val.Value
+	case *ast.BooleanValue:
+		return val.Value
+	case *ast.IntValue:
+		return json.Number(val.Value)
+	case *ast.FloatValue:
+		return json.Number(val.Value)
+	case *ast.ListValue:
+		list := make([]interface{}, len(val.Values))
+		for i, item := range val.Values {
+			list[i] = parseJSONLiteral(item)
+		}
+		return list
+	case *ast.ObjectValue:
+		obj := make(map[string]interface{}, len(val.Fields))
+		for _, f := range val.Fields {
+			obj[f.Name.Value] = parseJSONLiteral(f.Value)
+		}
+		return obj
+	}
+	return nil
+}
+
+// graphqlObjects caches the object type built for each Go struct so shared
+// structs (e.g. ErrorInfo) are defined once in the schema
+var graphqlObjects = map[reflect.Type]*graphql.Object{}
+
+// graphqlOutputType maps a Go type from pkg/types to a GraphQL output type.
+// Field names follow the JSON tags, so a query selects exactly the keys the
+// REST endpoint would return. 64-bit and unsigned integers are exposed as
+// Float because GraphQL Int is limited to 32 bits.
+func graphqlOutputType(t reflect.Type) graphql.Output {
+	if t == reflect.TypeOf(types.HexBytes(nil)) {
+		return graphql.String
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return graphqlOutputType(t.Elem())
+	case reflect.Bool:
+		return graphql.Boolean
+	case reflect.String:
+		return graphql.String
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return graphql.Int
+	case reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return graphql.Float
+	case reflect.Slice:
+		return graphql.NewList(graphqlOutputType(t.Elem()))
+	case reflect.Struct:
+		return graphqlObject(t)
+	}
+	return jsonScalar
+}
+
+// graphqlObject builds (or returns the cached) object type for a struct
+func graphqlObject(t reflect.Type) *graphql.Object {
+	if obj, ok := graphqlObjects[t]; ok {
+		return obj
+	}
+	obj := graphql.NewObject(graphql.ObjectConfig{
+		Name: t.Name(),
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			fields := graphql.Fields{}
+			for i := 0; i < t.NumField(); i++ {
+				f := t.Field(i)
+				name := strings.Split(f.Tag.Get("json"), ",")[0]
+				if name == "" || name == "-" {
+					continue
+				}
+				fields[name] = &graphql.Field{Type: graphqlOutputType(f.Type)}
+			}
+			return fields
+		}),
+	})
+	graphqlObjects[t] = obj
+	return obj
+}
+
+// toGraphQLValue converts an analyzer result into the generic JSON form the
+// default resolvers read from, so hex and nullable fields match the REST output
+func toGraphQLValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+	return generic, nil
+}
+
+// newGraphQLSchema builds the query schema served at /api/graphql.
+// All fields run the same analyzer as the REST endpoints.
+func newGraphQLSchema() (graphql.Schema, error) {
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"transaction": &graphql.Field{
+				Type:        graphqlObject(reflect.TypeOf(types.TransactionOutput{})),
+				Description: "Analyze a transaction fixture (same body as POST /api/analyze)",
+				Args: graphql.FieldConfigArgument{
+					"fixture": &graphql.ArgumentConfig{Type: graphql.NewNonNull(jsonScalar)},
+				},
+				Resolve: resolveTransaction,
+			},
+			"address": &graphql.Field{
+				Type:        graphqlObject(reflect.TypeOf(types.AddressOutput{})),
+				Description: "Decode an address to its scriptPubKey",
+				Args: graphql.FieldConfigArgument{
+					"address": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"network": &graphql.ArgumentConfig{
+						Type:         graphql.String,
+						DefaultValue: cfg.Network,
+					},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					address, _ := p.Args["address"].(string)
+					network, _ := p.Args["network"].(string)
+					return toGraphQLValue(analyzer.AnalyzeAddress(address, network))
+				},
+			},
+			"paymentUri": &graphql.Field{
+				Type:        graphqlObject(reflect.TypeOf(types.PaymentURIOutput{})),
+				Description: "Parse a BIP21 bitcoin: payment URI",
+				Args: graphql.FieldConfigArgument{
+					"uri": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"network": &graphql.ArgumentConfig{
+						Type:         graphql.String,
+						DefaultValue: cfg.Network,
+					},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					uri, _ := p.Args["uri"].(string)
+					network, _ := p.Args["network"].(string)
+					return toGraphQLValue(analyzer.ParsePaymentURI(uri, network))
+				},
+			},
+		},
+	})
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
+
+func resolveTransaction(p graphql.ResolveParams) (interface{}, error) {
+	data, err := json.Marshal(p.Args["fixture"])
+	if err != nil {
+		return nil, err
+	}
+	var fixture types.Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("invalid fixture: %v", err)
+	}
+	if fixture.Network == "" {
+		fixture.Network = cfg.Network
+	}
+	result, err := parser.ParseTransaction(fixture)
+	if err != nil {
+		return toGraphQLValue(types.ErrorOutput{
+			OK:    false,
+			Error: &types.ErrorInfo{Code: parser.ErrorCode(err, "PARSE_ERROR"), Message: err.Error()},
+		})
+	}
+	feed.publishTx(result, feedSourceGraphQL)
+	return toGraphQLValue(result)
+}
+
+// graphqlHandler serves GraphQL queries over POST (JSON body) and GET (?query=)
+func graphqlHandler(schema graphql.Schema) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req graphqlRequest
+		if c.Request.Method == "GET" {
+			req.Query = c.Query("query")
+			req.OperationName = c.Query("operationName")
+			if vars := c.Query("variables"); vars != "" {
+				if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+					c.JSON(400, gin.H{"errors": []gin.H{{"message": "invalid variables JSON"}}})
+					return
+				}
+			}
+		} else if err := c.ShouldBindJSON(&req); err != nil {
+			if rejectLargeBody(c, err) {
+				return
+			}
+			c.JSON(400, gin.H{"errors": []gin.H{{"message": "failed to parse JSON"}}})
+			return
+		}
+
+		result := graphql.Do(graphql.Params{
+			Schema:         schema,
+			RequestString:  req.Query,
+			OperationName:  req.OperationName,
+			VariableValues: req.Variables,
+			Context:        c.Request.Context(),
+		})
+		c.JSON(200, result)
+	}
+}
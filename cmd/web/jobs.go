@@ -0,0 +1,389 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/parser"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Job status values
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// Finished jobs kept in memory. A block result runs to megabytes, so once
+// a job is persisted only its status stays and the result is read back from
+// the job directory; results that could not be persisted are dropped after
+// finishedJobTTL or once more than maxFinishedJobs are held.
+const (
+	finishedJobTTL  = time.Hour
+	maxFinishedJobs = 16
+)
+
+var errQueueFull = errors.New("job queue is full")
+
+// job is a queued analysis. run is called once by the worker and returns
+// the result served by GET /api/jobs/:id/result.
+type job struct {
+	types.Job
+	run       func(j *job) (interface{}, error)
+	result    interface{}
+	persisted bool // result is in the job directory rather than memory
+}
+
+// persistedJob is the on-disk form of a finished job
+type persistedJob struct {
+	Job    types.Job       `json:"job"`
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// jobQueue runs expensive analyses in the background. The queue is bounded:
+// submissions beyond its capacity are rejected rather than buffered without
+// limit. Jobs run one at a time; each block analysis already uses the
+// shared worker pool internally.
+type jobQueue struct {
+	mu      sync.Mutex
+	jobs    map[string]*job
+	pending chan *job
+	dir     string
+}
+
+// newJobQueue creates a queue holding up to size waiting jobs, reloads jobs
+// persisted in dir (when set) and starts the worker
+func newJobQueue(size int, dir string) (*jobQueue, error) {
+	q := &jobQueue{
+		jobs:    make(map[string]*job),
+		pending: make(chan *job, size),
+		dir:     dir,
+	}
+	if q.dir != "" {
+		if err := os.MkdirAll(q.dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create job directory: %w", err)
+		}
+		if err := q.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	go q.worker()
+	return q, nil
+}
+
+// Submit queues a job and returns its initial status
+func (q *jobQueue) Submit(kind string, run func(j *job) (interface{}, error)) (types.Job, error) {
+	id, err := newID()
+	if err != nil {
+		return types.Job{}, fmt.Errorf("failed to create job id: %w", err)
+	}
+	j := &job{
+		Job: types.Job{ID: id, Kind: kind, Status: JobQueued, CreatedAt: time.Now().UTC()},
+		run: run,
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.evict(time.Now())
+	select {
+	case q.pending <- j:
+	default:
+		return types.Job{}, errQueueFull
+	}
+	q.jobs[id] = j
+	return j.Job, nil
+}
+
+// Get returns a snapshot of a job's status
+func (q *jobQueue) Get(id string) (types.Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return types.Job{}, false
+	}
+	status := j.Job
+	if j.Progress != nil {
+		p := *j.Progress
+		status.Progress = &p
+	}
+	return status, true
+}
+
+// Result returns a finished job's result, from memory or, once persisted,
+// as raw JSON from the job directory. It is nil for a job that is not done
+// or is no longer held.
+func (q *jobQueue) Result(id string) (interface{}, error) {
+	q.mu.Lock()
+	j, ok := q.jobs[id]
+	if !ok {
+		q.mu.Unlock()
+		return nil, nil
+	}
+	result, persisted := j.result, j.persisted
+	q.mu.Unlock()
+	if !persisted {
+		return result, nil
+	}
+
+	data, err := os.ReadFile(q.jobPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job %s: %w", id, err)
+	}
+	var rec persistedJob
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("failed to parse job %s: %w", id, err)
+	}
+	if rec.Result == nil {
+		return nil, nil
+	}
+	return rec.Result, nil
+}
+
+// SetProgress records a running job's progress
+func (q *jobQueue) SetProgress(j *job, done, total int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j.Progress = &types.JobProgress{Done: done, Total: total}
+}
+
+func (q *jobQueue) worker() {
+	for j := range q.pending {
+		q.mu.Lock()
+		started := time.Now().UTC()
+		j.Status, j.StartedAt = JobRunning, &started
+		q.mu.Unlock()
+
+		result, err := j.run(j)
+
+		q.mu.Lock()
+		finished := time.Now().UTC()
+		j.FinishedAt = &finished
+		if err != nil {
+			j.Status = JobFailed
+			j.Error = &types.ErrorInfo{Code: "JOB_FAILED", Message: err.Error()}
+		} else {
+			j.Status, j.result = JobDone, result
+		}
+		j.run = nil
+		q.mu.Unlock()
+
+		if q.dir != "" {
+			if err := q.persist(j); err != nil {
+				fmt.Fprintf(os.Stderr, "job %s: failed to persist: %v\n", j.ID, err)
+			} else {
+				q.mu.Lock()
+				j.result, j.persisted = nil, true
+				q.mu.Unlock()
+			}
+		}
+		q.mu.Lock()
+		q.evict(time.Now())
+		q.mu.Unlock()
+	}
+}
+
+// evict drops finished jobs whose result is held only in memory once they
+// are older than finishedJobTTL, then the oldest of them beyond
+// maxFinishedJobs. Persisted jobs keep their status, a few hundred bytes.
+// Callers hold q.mu.
+func (q *jobQueue) evict(now time.Time) {
+	var held []*job
+	for id, j := range q.jobs {
+		if j.FinishedAt == nil || j.persisted {
+			continue
+		}
+		if now.Sub(*j.FinishedAt) > finishedJobTTL {
+			delete(q.jobs, id)
+			continue
+		}
+		held = append(held, j)
+	}
+	if len(held) <= maxFinishedJobs {
+		return
+	}
+	sort.Slice(held, func(a, b int) bool { return held[a].FinishedAt.Before(*held[b].FinishedAt) })
+	for _, j := range held[:len(held)-maxFinishedJobs] {
+		delete(q.jobs, j.ID)
+	}
+}
+
+func (q *jobQueue) jobPath(id string) string {
+	return filepath.Join(q.dir, id+".json")
+}
+
+// persist writes a finished job and its result to the job directory
+func (q *jobQueue) persist(j *job) error {
+	status, _ := q.Get(j.ID)
+	q.mu.Lock()
+	result := j.result
+	q.mu.Unlock()
+	rec := persistedJob{Job: status}
+	if result != nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		rec.Result = data
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	// Write then rename so a crash never leaves a truncated record
+	path := q.jobPath(j.ID)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// load restores the status of finished jobs persisted by a previous run.
+// Their results stay on disk and are served unchanged by Result; a record
+// that cannot be read is skipped.
+func (q *jobQueue) load() error {
+	paths, err := filepath.Glob(filepath.Join(q.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "skipping job %s: %v\n", path, err)
+			continue
+		}
+		var rec persistedJob
+		if err := json.Unmarshal(data, &rec); err != nil || rec.Job.ID == "" {
+			fmt.Fprintf(os.Stderr, "skipping job %s: not a job record\n", path)
+			continue
+		}
+		q.jobs[rec.Job.ID] = &job{Job: rec.Job, persisted: true}
+	}
+	return nil
+}
+
+// newID returns a random 128-bit hex identifier for jobs and saved analyses
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// handleSubmitBlockJob accepts a multipart upload of blk, rev and xor files
+// (plus optional network and exact_vsize fields) and queues the block analysis
+func handleSubmitBlockJob(q *jobQueue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		dir, err := os.MkdirTemp("", "chain-lens-job-")
+		if err != nil {
+			c.JSON(500, gin.H{"ok": false, "error": types.ErrorInfo{Code: "IO_ERROR", Message: err.Error()}})
+			return
+		}
+
+		paths := make(map[string]string, 3)
+		for _, field := range []string{"blk", "rev", "xor"} {
+			file, err := c.FormFile(field)
+			if err != nil {
+				os.RemoveAll(dir)
+				if rejectLargeBody(c, err) {
+					return
+				}
+				c.JSON(400, gin.H{"ok": false, "error": types.ErrorInfo{
+					Code:    "INVALID_REQUEST",
+					Message: fmt.Sprintf("missing %q file in multipart upload", field),
+				}})
+				return
+			}
+			paths[field] = filepath.Join(dir, field+".dat")
+			if err := c.SaveUploadedFile(file, paths[field]); err != nil {
+				os.RemoveAll(dir)
+				c.JSON(500, gin.H{"ok": false, "error": types.ErrorInfo{Code: "IO_ERROR", Message: err.Error()}})
+				return
+			}
+		}
+		opts := parser.BlockOptions{
+			Network:    c.PostForm("network"),
+			ExactVsize: c.PostForm("exact_vsize") == "true",
+		}
+
+		status, err := q.Submit("block", func(j *job) (interface{}, error) {
+			defer os.RemoveAll(dir)
+			opts.Progress = func(done, total int) { q.SetProgress(j, done, total) }
+			blocks, err := parser.ParseBlockWithOptions(paths["blk"], paths["rev"], paths["xor"], opts)
+			for _, b := range blocks {
+				feed.publishBlock(b, feedSourceJob)
+			}
+			return blocks, err
+		})
+		if err != nil {
+			os.RemoveAll(dir)
+			if errors.Is(err, errQueueFull) {
+				c.JSON(503, gin.H{"ok": false, "error": types.ErrorInfo{Code: "QUEUE_FULL", Message: err.Error()}})
+				return
+			}
+			c.JSON(500, gin.H{"ok": false, "error": types.ErrorInfo{Code: "IO_ERROR", Message: err.Error()}})
+			return
+		}
+		c.JSON(202, status)
+	}
+}
+
+// handleGetJob reports a job's status and progress
+func handleGetJob(q *jobQueue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status, ok := q.Get(c.Param("id"))
+		if !ok {
+			c.JSON(404, gin.H{"ok": false, "error": types.ErrorInfo{Code: "JOB_NOT_FOUND", Message: "no such job"}})
+			return
+		}
+		c.JSON(200, status)
+	}
+}
+
+// handleGetJobResult returns a finished job's result (?verbosity= applies)
+func handleGetJobResult(q *jobQueue) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status, ok := q.Get(c.Param("id"))
+		if !ok {
+			c.JSON(404, gin.H{"ok": false, "error": types.ErrorInfo{Code: "JOB_NOT_FOUND", Message: "no such job"}})
+			return
+		}
+		switch status.Status {
+		case JobFailed:
+			c.JSON(200, gin.H{"ok": false, "error": status.Error})
+			return
+		case JobQueued, JobRunning:
+			c.JSON(409, gin.H{"ok": false, "error": types.ErrorInfo{
+				Code:    "JOB_NOT_DONE",
+				Message: fmt.Sprintf("job is %s", status.Status),
+			}})
+			return
+		}
+
+		result, err := q.Result(status.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"ok": false, "error": types.ErrorInfo{Code: "IO_ERROR", Message: err.Error()}})
+			return
+		}
+		projected, err := parser.ApplyVerbosity(result, c.Query("verbosity"))
+		if err != nil {
+			c.JSON(400, gin.H{"ok": false, "error": types.ErrorInfo{Code: "INVALID_REQUEST", Message: err.Error()}})
+			return
+		}
+		writeResult(c, projected)
+	}
+}
@@ -4,20 +4,46 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
+	_ "net/http/pprof" // registers /debug/pprof handlers on http.DefaultServeMux
 	"os"
+	"strconv"
+	"strings"
 
-	"chain-lens/pkg/parser"
-	"chain-lens/pkg/types"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/analyzer"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/backend"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/config"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/extension"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/parser"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/utils"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
+// cfg is the server configuration, loaded once at startup
+var cfg *config.Config
+
 func main() {
-	// Get port from environment or default to 3000
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "3000"
+	// Load config file ($CHAIN_LENS_CONFIG) and environment overrides
+	var err error
+	cfg, err = config.Load("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
+		os.Exit(1)
+	}
+	cfg.Apply()
+	if err := extension.Register(cfg.Extensions); err != nil {
+		fmt.Fprintf(os.Stderr, "extensions: %v\n", err)
+		os.Exit(1)
+	}
+	// Missing prevouts in /api/analyze requests are fetched from a node, an
+	// Esplora API, an Electrum server or a UTXO snapshot, whichever is
+	// configured
+	if err := backend.Register(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "prevout backend: %v\n", err)
+		os.Exit(1)
 	}
 
 	// Create Gin router
@@ -26,7 +52,7 @@ func main() {
 
 	// Enable CORS for React frontend
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
+		AllowOrigins:     cfg.Server.CORSOrigins,
 		AllowMethods:     []string{"GET", "POST", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type"},
 		AllowCredentials: true,
@@ -37,8 +63,89 @@ func main() {
 		c.JSON(200, gin.H{"ok": true})
 	})
 
+	// pprof endpoints are opt-in: they expose process internals
+	if cfg.Server.Pprof {
+		r.GET("/debug/pprof/*any", gin.WrapH(http.DefaultServeMux))
+	}
+
 	// Analyze transaction endpoint
-	r.POST("/api/analyze", handleAnalyze)
+	r.POST("/api/analyze", limitBody(txBodyBytes), handleAnalyze)
+
+	// BIP152 compact block decode and reconstruction
+	r.POST("/api/compact", limitBody(blockBodyBytes), handleCompact)
+
+	// Block vs getblocktemplate comparison
+	r.POST("/api/template", limitBody(blockBodyBytes), handleTemplate)
+
+	// SPV merkle proof verification
+	r.POST("/api/merkle-proof", limitBody(txBodyBytes), handleMerkleProof)
+
+	// SPV merkle proof generation for transactions of a raw block
+	r.POST("/api/merkle-branch", limitBody(blockBodyBytes), handleMerkleBranch)
+
+	// BIP37 bloom filter construction and matching
+	r.POST("/api/bloom", limitBody(blockBodyBytes), handleBloom)
+
+	// Standalone 80-byte block header decode
+	r.GET("/api/header/:hex", handleHeader)
+
+	// Consecutive headers with a next difficulty adjustment estimate
+	r.POST("/api/header-chain", limitBody(txBodyBytes), handleHeaderChain)
+
+	// P2P wire message decoder
+	r.POST("/api/p2pmsg", limitBody(blockBodyBytes), handleP2PMessage)
+
+	// Analysis stages that a fixture's "stages" field can turn on or off
+	r.GET("/api/stages", func(c *gin.Context) {
+		c.JSON(200, gin.H{"ok": true, "stages": parser.StageNames()})
+	})
+
+	// Live dashboard feed of analyses made through this server
+	feed = newLiveFeed(cfg.Server.FeedSize)
+	r.GET("/api/ws", handleFeed(feed))
+
+	// Address-to-script lookup endpoint
+	r.GET("/api/address/:address", handleAddress)
+	r.GET("/api/uri", handlePaymentURI)
+	r.GET("/api/descriptor", handleDescriptor)
+
+	// GraphQL endpoint: query only the analysis fields you need
+	schema, err := newGraphQLSchema()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "graphql schema: %v\n", err)
+		os.Exit(1)
+	}
+	r.POST("/api/graphql", limitBody(txBodyBytes), graphqlHandler(schema))
+	r.GET("/api/graphql", graphqlHandler(schema))
+
+	// Background jobs for block analyses too slow for a synchronous request
+	jobs, err := newJobQueue(cfg.Storage.JobQueueSize, cfg.Storage.JobDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "job queue: %v\n", err)
+		os.Exit(1)
+	}
+	r.POST("/api/jobs/block", limitBody(jobUploadBytes), handleSubmitBlockJob(jobs))
+	r.GET("/api/jobs/:id", handleGetJob(jobs))
+	r.GET("/api/jobs/:id/result", handleGetJobResult(jobs))
+
+	// Saved-analysis workspace (enabled by storage.analysis_dir)
+	store, err := newAnalysisStore(cfg.Storage.AnalysisDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "analysis store: %v\n", err)
+		os.Exit(1)
+	}
+	r.POST("/api/analyses", limitBody(txBodyBytes), handleSaveAnalysis(store, jobs))
+	r.GET("/api/analyses", handleListAnalyses(store))
+	r.GET("/api/analyses/:id", handleGetAnalysis(store))
+	r.GET("/api/payouts", handlePayoutHistory(store))
+	r.GET("/api/block-diff", handleBlockDiff(store))
+
+	// Browsing of earlier results: CLI output files, the result store and
+	// saved analyses
+	results := newResultIndex(cfg.Storage.ResultDir, cfg.Storage.ResultStore, store)
+	go results.refresh() // index large block results before the first request
+	r.GET("/api/results", handleListResults(results))
+	r.GET("/api/results/:id", handleGetResult(results))
 
 	// Serve React build (if exists)
 	if _, err := os.Stat("web/build"); err == nil {
@@ -55,15 +162,18 @@ func main() {
 	}
 
 	// Print URL and start server
-	fmt.Printf("http://127.0.0.1:%s\n", port)
-	r.Run(":" + port)
+	fmt.Printf("http://127.0.0.1:%s\n", cfg.Server.Port)
+	r.Run(":" + cfg.Server.Port)
 }
 
 func handleAnalyze(c *gin.Context) {
 	// Read request body
 	body, err := io.ReadAll(c.Request.Body)
 	if err != nil {
-		c.JSON(400, types.TransactionOutput{
+		if rejectLargeBody(c, err) {
+			return
+		}
+		c.JSON(400, types.ErrorOutput{
 			OK:    false,
 			Error: &types.ErrorInfo{Code: "INVALID_REQUEST", Message: "Failed to read request body"},
 		})
@@ -73,7 +183,7 @@ func handleAnalyze(c *gin.Context) {
 	// Parse fixture
 	var fixture types.Fixture
 	if err := json.Unmarshal(body, &fixture); err != nil {
-		c.JSON(400, types.TransactionOutput{
+		c.JSON(400, types.ErrorOutput{
 			OK:    false,
 			Error: &types.ErrorInfo{Code: "INVALID_JSON", Message: "Failed to parse JSON"},
 		})
@@ -81,18 +191,320 @@ func handleAnalyze(c *gin.Context) {
 	}
 
 	// Parse transaction
+	if fixture.Network == "" {
+		fixture.Network = cfg.Network
+	}
 	result, err := parser.ParseTransaction(fixture)
 	if err != nil {
-		c.JSON(400, types.TransactionOutput{
+		// A node that cannot be reached is not the request's fault
+		code, status := parser.ErrorCode(err, "PARSE_ERROR"), 400
+		if code == "PREVOUT_LOOKUP_FAILED" {
+			status = 502
+		}
+		c.JSON(status, types.ErrorOutput{
+			OK:    false,
+			Error: &types.ErrorInfo{Code: code, Message: err.Error()},
+		})
+		return
+	}
+	feed.publishTx(result, feedSourceAPI)
+
+	// Reduce output to the requested verbosity (?verbosity=summary|standard|full)
+	projected, err := parser.ApplyVerbosity(result, c.Query("verbosity"))
+	if err != nil {
+		c.JSON(400, types.ErrorOutput{
+			OK:    false,
+			Error: &types.ErrorInfo{Code: "INVALID_REQUEST", Message: err.Error()},
+		})
+		return
+	}
+
+	writeResult(c, projected)
+}
+
+func handleCompact(c *gin.Context) {
+	var fixture types.CompactBlockFixture
+	if err := c.ShouldBindJSON(&fixture); err != nil {
+		if rejectLargeBody(c, err) {
+			return
+		}
+		c.JSON(400, types.CompactBlockOutput{
+			OK:    false,
+			Mode:  "compact_block",
+			Error: &types.ErrorInfo{Code: "INVALID_JSON", Message: "Failed to parse JSON"},
+		})
+		return
+	}
+	if fixture.Network == "" {
+		fixture.Network = cfg.Network
+	}
+
+	result, err := parser.ParseCompactBlock(fixture)
+	if err != nil {
+		c.JSON(400, types.CompactBlockOutput{
+			OK:    false,
+			Mode:  "compact_block",
+			Error: &types.ErrorInfo{Code: parser.ErrorCode(err, "INVALID_COMPACT_BLOCK"), Message: err.Error()},
+		})
+		return
+	}
+
+	projected, err := parser.ApplyVerbosity(result, c.Query("verbosity"))
+	if err != nil {
+		c.JSON(400, gin.H{"ok": false, "error": types.ErrorInfo{Code: "INVALID_REQUEST", Message: err.Error()}})
+		return
+	}
+	writeResult(c, projected)
+}
+
+func handleTemplate(c *gin.Context) {
+	var fixture types.TemplateFixture
+	if err := c.ShouldBindJSON(&fixture); err != nil {
+		if rejectLargeBody(c, err) {
+			return
+		}
+		c.JSON(400, types.TemplateComparisonOutput{
+			OK:    false,
+			Mode:  "template_compare",
+			Error: &types.ErrorInfo{Code: "INVALID_JSON", Message: "Failed to parse JSON"},
+		})
+		return
+	}
+	if fixture.Network == "" {
+		fixture.Network = cfg.Network
+	}
+
+	result, err := parser.CompareBlockTemplate(fixture)
+	if err != nil {
+		c.JSON(400, types.TemplateComparisonOutput{
+			OK:    false,
+			Mode:  "template_compare",
+			Error: &types.ErrorInfo{Code: parser.ErrorCode(err, "INVALID_TEMPLATE"), Message: err.Error()},
+		})
+		return
+	}
+	writeResult(c, result)
+}
+
+func handleMerkleProof(c *gin.Context) {
+	var fixture types.MerkleProofFixture
+	if err := c.ShouldBindJSON(&fixture); err != nil {
+		if rejectLargeBody(c, err) {
+			return
+		}
+		c.JSON(400, types.MerkleProofOutput{
+			OK:    false,
+			Mode:  "merkle_proof",
+			Error: &types.ErrorInfo{Code: "INVALID_JSON", Message: "Failed to parse JSON"},
+		})
+		return
+	}
+	result, err := parser.VerifyMerkleProof(fixture)
+	if err != nil {
+		c.JSON(400, types.MerkleProofOutput{
+			OK:    false,
+			Mode:  "merkle_proof",
+			Error: &types.ErrorInfo{Code: parser.ErrorCode(err, "INVALID_PROOF"), Message: err.Error()},
+		})
+		return
+	}
+	writeResult(c, result)
+}
+
+func handleMerkleBranch(c *gin.Context) {
+	var fixture types.MerkleBranchFixture
+	if err := c.ShouldBindJSON(&fixture); err != nil {
+		if rejectLargeBody(c, err) {
+			return
+		}
+		c.JSON(400, types.MerkleBranchOutput{
+			OK:    false,
+			Mode:  "merkle_branch",
+			Error: &types.ErrorInfo{Code: "INVALID_JSON", Message: "Failed to parse JSON"},
+		})
+		return
+	}
+	result, err := parser.GenerateBlockMerkleProofs(fixture)
+	if err != nil {
+		c.JSON(400, types.MerkleBranchOutput{
 			OK:    false,
-			Error: &types.ErrorInfo{Code: "PARSE_ERROR", Message: err.Error()},
+			Mode:  "merkle_branch",
+			Error: &types.ErrorInfo{Code: parser.ErrorCode(err, "INVALID_BLOCK"), Message: err.Error()},
 		})
 		return
 	}
+	writeResult(c, result)
+}
 
+func handleBloom(c *gin.Context) {
+	var fixture types.BloomFixture
+	if err := c.ShouldBindJSON(&fixture); err != nil {
+		if rejectLargeBody(c, err) {
+			return
+		}
+		c.JSON(400, types.BloomOutput{
+			OK:    false,
+			Mode:  "bloom",
+			Error: &types.ErrorInfo{Code: "INVALID_JSON", Message: "Failed to parse JSON"},
+		})
+		return
+	}
+	result, err := parser.MatchBloomFilter(fixture)
+	if err != nil {
+		c.JSON(400, types.BloomOutput{
+			OK:    false,
+			Mode:  "bloom",
+			Error: &types.ErrorInfo{Code: parser.ErrorCode(err, "INVALID_FILTER"), Message: err.Error()},
+		})
+		return
+	}
+	writeResult(c, result)
+}
+
+func handleHeader(c *gin.Context) {
+	result, err := parser.DecodeBlockHeader(c.Param("hex"))
+	if err != nil {
+		c.JSON(400, types.HeaderOutput{
+			OK:    false,
+			Mode:  "header",
+			Error: &types.ErrorInfo{Code: "INVALID_HEADER", Message: err.Error()},
+		})
+		return
+	}
+	writeResult(c, result)
+}
+
+// headerChainRequest is the body of POST /api/header-chain; Height is that
+// of the first header, when known
+type headerChainRequest struct {
+	Headers []string `json:"headers"`
+	Height  *int64   `json:"height"`
+}
+
+func handleHeaderChain(c *gin.Context) {
+	var req headerChainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if rejectLargeBody(c, err) {
+			return
+		}
+		c.JSON(400, types.HeaderChainOutput{
+			OK:    false,
+			Mode:  "header_chain",
+			Error: &types.ErrorInfo{Code: "INVALID_JSON", Message: "Failed to parse JSON"},
+		})
+		return
+	}
+	result, err := parser.DecodeHeaderChain(req.Headers, req.Height)
+	if err != nil {
+		c.JSON(400, types.HeaderChainOutput{
+			OK:    false,
+			Mode:  "header_chain",
+			Error: &types.ErrorInfo{Code: "INVALID_HEADER", Message: err.Error()},
+		})
+		return
+	}
+	writeResult(c, result)
+}
+
+// p2pMessageRequest is the body of POST /api/p2pmsg; Command is only needed
+// when Hex is a bare payload without the message header
+type p2pMessageRequest struct {
+	Hex     string `json:"hex"`
+	Command string `json:"command"`
+}
+
+func handleP2PMessage(c *gin.Context) {
+	var req p2pMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if rejectLargeBody(c, err) {
+			return
+		}
+		c.JSON(400, types.P2PMessageOutput{
+			OK:    false,
+			Mode:  "p2pmsg",
+			Error: &types.ErrorInfo{Code: "INVALID_JSON", Message: "Failed to parse JSON"},
+		})
+		return
+	}
+	result, err := parser.DecodeP2PMessage(req.Hex, req.Command)
+	if err != nil {
+		c.JSON(400, types.P2PMessageOutput{
+			OK:    false,
+			Mode:  "p2pmsg",
+			Error: &types.ErrorInfo{Code: "INVALID_MESSAGE", Message: err.Error()},
+		})
+		return
+	}
+	if !result.OK {
+		c.JSON(400, result)
+		return
+	}
+	writeResult(c, result)
+}
+
+// writeResult sends an analysis result, as canonical RFC 8785 JSON when the
+// request asks for it with ?canonical=1
+func writeResult(c *gin.Context, v interface{}) {
+	if canonical, _ := strconv.ParseBool(c.Query("canonical")); canonical {
+		data, err := utils.CanonicalJSON(v)
+		if err != nil {
+			c.JSON(500, gin.H{"ok": false, "error": types.ErrorInfo{Code: "INTERNAL_ERROR", Message: err.Error()}})
+			return
+		}
+		c.Data(200, "application/json", data)
+		return
+	}
+	c.JSON(200, v)
+}
+
+func handleAddress(c *gin.Context) {
+	network := c.DefaultQuery("network", cfg.Network)
+	result := analyzer.AnalyzeAddress(c.Param("address"), network)
+	if !result.OK {
+		c.JSON(400, result)
+		return
+	}
 	c.JSON(200, result)
 }
 
+// handlePaymentURI parses the BIP21 URI in ?uri=, which must be
+// percent-encoded as a query value
+func handlePaymentURI(c *gin.Context) {
+	uri, ok := c.GetQuery("uri")
+	if !ok {
+		c.JSON(400, gin.H{"ok": false, "error": types.ErrorInfo{Code: "INVALID_ARGS", Message: "uri query parameter is required"}})
+		return
+	}
+	network := c.DefaultQuery("network", cfg.Network)
+	result := analyzer.ParsePaymentURI(uri, network)
+	if !result.OK {
+		c.JSON(400, result)
+		return
+	}
+	writeResult(c, result)
+}
+
+// handleDescriptor checks the BIP380 checksum of the output descriptor in
+// ?desc=, or adds one when it has none
+func handleDescriptor(c *gin.Context) {
+	desc, ok := c.GetQuery("desc")
+	if !ok {
+		c.JSON(400, gin.H{"ok": false, "error": types.ErrorInfo{Code: "INVALID_ARGS", Message: "desc query parameter is required"}})
+		return
+	}
+	withChecksum, err := analyzer.AddDescriptorChecksum(desc)
+	if err != nil {
+		c.JSON(400, gin.H{"ok": false, "error": types.ErrorInfo{Code: "INVALID_DESCRIPTOR", Message: err.Error()}})
+		return
+	}
+	c.JSON(200, gin.H{
+		"ok":           true,
+		"descriptor":   withChecksum,
+		"checksum":     withChecksum[strings.LastIndexByte(withChecksum, '#')+1:],
+		"had_checksum": strings.Contains(desc, "#"),
+	})
+}
+
 const fallbackHTML = `<!DOCTYPE html>
 <html>
 <head>
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/parser"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Result sources listed by /api/results
+const (
+	sourceResultDir = "out"      // files the CLI wrote to storage.result_dir
+	sourceAnalysis  = "analysis" // the saved-analysis workspace
+	sourceStore     = "store"    // objects in the CLI's storage.result_store
+)
+
+// maxSearchMatches bounds the matched terms reported per result
+const maxSearchMatches = 10
+
+// resultIndex makes earlier results browsable: the CLI's result directory
+// (<txid>.json, <block hash>.json[.gz], <hash>.template.json), the result
+// store's objects (<key>-<sha256 prefix>.json[.gz]) and the saved analyses.
+// Directories are rescanned on every request; a file is only
+// decoded again when its size or modification time changes.
+type resultIndex struct {
+	resultDir   string
+	storeDir    string
+	analysisDir string
+
+	mu    sync.Mutex
+	files map[string]*indexedResult // by path
+}
+
+// indexedResult is one result file with its search terms: warning codes,
+// then addresses
+type indexedResult struct {
+	entry   types.ResultEntry
+	path    string
+	size    int64
+	modTime time.Time
+	terms   []string
+}
+
+// resultDoc decodes only the fields of a transaction, block or template
+// result that the index needs
+type resultDoc struct {
+	Mode     string `json:"mode"`
+	Txid     string `json:"txid"`
+	Warnings []struct {
+		Code string `json:"code"`
+	} `json:"warnings"`
+	Vin []struct {
+		Address *string `json:"address"`
+	} `json:"vin"`
+	Vout []struct {
+		Address *string `json:"address"`
+	} `json:"vout"`
+	BlockHeader *struct {
+		BlockHash string `json:"block_hash"`
+		Timestamp uint32 `json:"timestamp"`
+	} `json:"block_header"`
+	Coinbase struct {
+		Bip34Height int64 `json:"bip34_height"`
+	} `json:"coinbase"`
+	TxCount      int         `json:"tx_count"`
+	Transactions []resultDoc `json:"transactions"`
+}
+
+func newResultIndex(resultDir, storeDir string, store *analysisStore) *resultIndex {
+	x := &resultIndex{resultDir: resultDir, storeDir: storeDir, files: make(map[string]*indexedResult)}
+	if store != nil {
+		x.analysisDir = store.dir
+	}
+	return x
+}
+
+// refresh rescans all directories and returns the current results
+func (x *resultIndex) refresh() ([]*indexedResult, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	var results []*indexedResult
+	seen := make(map[string]bool)
+	scan := func(dir, source string, patterns ...string) error {
+		if dir == "" {
+			return nil
+		}
+		for _, pattern := range patterns {
+			paths, err := filepath.Glob(filepath.Join(dir, pattern))
+			if err != nil {
+				return err
+			}
+			for _, path := range paths {
+				info, err := os.Stat(path)
+				if err != nil || info.IsDir() {
+					continue
+				}
+				seen[path] = true
+				r := x.files[path]
+				if r == nil || r.size != info.Size() || !r.modTime.Equal(info.ModTime()) {
+					if r, err = indexResultFile(path, source, info); err != nil {
+						// A file being written or not a result; skip it
+						continue
+					}
+					x.files[path] = r
+				}
+				results = append(results, r)
+			}
+		}
+		return nil
+	}
+	if err := scan(x.resultDir, sourceResultDir, "*.json", "*.json.gz"); err != nil {
+		return nil, err
+	}
+	// Store objects are sharded by key prefix; index.json stays at the root
+	if err := scan(x.storeDir, sourceStore, "*/*.json", "*/*.json.gz"); err != nil {
+		return nil, err
+	}
+	if err := scan(x.analysisDir, sourceAnalysis, "*.json"); err != nil {
+		return nil, err
+	}
+	for path := range x.files {
+		if !seen[path] {
+			delete(x.files, path)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if !results[i].modTime.Equal(results[j].modTime) {
+			return results[i].modTime.After(results[j].modTime)
+		}
+		return results[i].entry.ID < results[j].entry.ID
+	})
+	return results, nil
+}
+
+func indexResultFile(path, source string, info os.FileInfo) (*indexedResult, error) {
+	data, err := readResultFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &indexedResult{path: path, size: info.Size(), modTime: info.ModTime()}
+	r.entry = types.ResultEntry{Source: source, ModifiedAt: info.ModTime().UTC()}
+
+	var docs []resultDoc
+	if source == sourceAnalysis {
+		var a types.SavedAnalysis
+		if err := json.Unmarshal(data, &a); err != nil {
+			return nil, err
+		}
+		r.entry.ID = a.ID
+		r.entry.Name = a.Name
+		r.entry.Kind = a.Kind
+		// Block analyses hold a list of blocks, transaction analyses one result
+		if err := json.Unmarshal(a.Result, &docs); err != nil {
+			var doc resultDoc
+			if err := json.Unmarshal(a.Result, &doc); err != nil {
+				return nil, err
+			}
+			docs = []resultDoc{doc}
+		}
+	} else {
+		var doc resultDoc
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		r.entry.ID = strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".gz"), ".json")
+		docs = []resultDoc{doc}
+	}
+
+	codes, addresses := make(map[string]bool), make(map[string]bool)
+	for _, doc := range docs {
+		switch {
+		case doc.Mode == "block":
+			r.entry.Kind = "block"
+			if doc.BlockHeader != nil {
+				r.entry.BlockHashes = append(r.entry.BlockHashes, doc.BlockHeader.BlockHash)
+				r.entry.Timestamp = doc.BlockHeader.Timestamp
+			}
+			r.entry.Height = doc.Coinbase.Bip34Height
+			r.entry.TxCount += doc.TxCount
+			for _, tx := range doc.Transactions {
+				collectTerms(tx, codes, addresses)
+			}
+		case doc.Txid != "":
+			r.entry.Kind = "tx"
+			r.entry.Txid = doc.Txid
+			collectTerms(doc, codes, addresses)
+		case doc.Mode != "":
+			r.entry.Kind = doc.Mode
+			if doc.BlockHeader != nil {
+				r.entry.BlockHashes = append(r.entry.BlockHashes, doc.BlockHeader.BlockHash)
+			}
+		default:
+			return nil, fmt.Errorf("%s is not a result", path)
+		}
+	}
+	r.entry.WarningCodes = make([]string, 0, len(codes))
+	for code := range codes {
+		r.entry.WarningCodes = append(r.entry.WarningCodes, code)
+	}
+	sort.Strings(r.entry.WarningCodes)
+	r.terms = append(r.terms, r.entry.WarningCodes...)
+	for address := range addresses {
+		r.terms = append(r.terms, address)
+	}
+	return r, nil
+}
+
+// collectTerms adds a transaction's warning codes and addresses
+func collectTerms(tx resultDoc, codes, addresses map[string]bool) {
+	for _, w := range tx.Warnings {
+		codes[w.Code] = true
+	}
+	for _, in := range tx.Vin {
+		if in.Address != nil {
+			addresses[*in.Address] = true
+		}
+	}
+	for _, out := range tx.Vout {
+		if out.Address != nil {
+			addresses[*out.Address] = true
+		}
+	}
+}
+
+// readResultFile reads a result file, gunzipping .gz files
+func readResultFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.HasSuffix(path, ".gz") {
+		return data, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// handleListResults lists stored results, most recent first. ?kind= filters
+// by result kind (tx, block, ...), ?q= keeps results with a warning code,
+// address, txid or block hash containing the query (case-insensitive), and
+// ?limit= caps the list (default 50).
+func handleListResults(x *resultIndex) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := 50
+		if v := c.Query("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				c.JSON(400, gin.H{"ok": false, "error": types.ErrorInfo{Code: "INVALID_REQUEST", Message: "limit must be a positive integer"}})
+				return
+			}
+			limit = n
+		}
+		results, err := x.refresh()
+		if err != nil {
+			c.JSON(500, gin.H{"ok": false, "error": types.ErrorInfo{Code: "IO_ERROR", Message: err.Error()}})
+			return
+		}
+
+		kind := c.Query("kind")
+		query := strings.ToLower(c.Query("q"))
+		entries := make([]types.ResultEntry, 0)
+		total := 0
+		for _, r := range results {
+			if kind != "" && r.entry.Kind != kind {
+				continue
+			}
+			entry := r.entry
+			if query != "" {
+				if entry.Matches = r.search(query); entry.Matches == nil {
+					continue
+				}
+			}
+			total++
+			if len(entries) < limit {
+				entries = append(entries, entry)
+			}
+		}
+		c.JSON(200, gin.H{"ok": true, "total": total, "results": entries})
+	}
+}
+
+// search returns the identifiers and terms of a result that contain query,
+// or nil when none does
+func (r *indexedResult) search(query string) []string {
+	var matches []string
+	candidates := append([]string{r.entry.ID, r.entry.Txid}, r.entry.BlockHashes...)
+	for _, t := range append(candidates, r.terms...) {
+		if t != "" && strings.Contains(strings.ToLower(t), query) {
+			matches = append(matches, t)
+			if len(matches) == maxSearchMatches {
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// handleGetResult returns a stored result by its id (?verbosity= applies).
+// Saved analyses return their result, as first produced.
+func handleGetResult(x *resultIndex) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		results, err := x.refresh()
+		if err != nil {
+			c.JSON(500, gin.H{"ok": false, "error": types.ErrorInfo{Code: "IO_ERROR", Message: err.Error()}})
+			return
+		}
+		id := c.Param("id")
+		for _, r := range results {
+			if r.entry.ID != id {
+				continue
+			}
+			data, err := readResultFile(r.path)
+			if err != nil {
+				c.JSON(500, gin.H{"ok": false, "error": types.ErrorInfo{Code: "IO_ERROR", Message: err.Error()}})
+				return
+			}
+			if r.entry.Source == sourceAnalysis {
+				var a types.SavedAnalysis
+				if err := json.Unmarshal(data, &a); err != nil {
+					c.JSON(500, gin.H{"ok": false, "error": types.ErrorInfo{Code: "IO_ERROR", Message: err.Error()}})
+					return
+				}
+				data = a.Result
+			}
+			var result interface{}
+			dec := json.NewDecoder(bytes.NewReader(data))
+			dec.UseNumber()
+			if err := dec.Decode(&result); err != nil {
+				c.JSON(500, gin.H{"ok": false, "error": types.ErrorInfo{Code: "IO_ERROR", Message: err.Error()}})
+				return
+			}
+			projected, err := parser.ApplyVerbosity(result, c.Query("verbosity"))
+			if err != nil {
+				c.JSON(400, gin.H{"ok": false, "error": types.ErrorInfo{Code: "INVALID_REQUEST", Message: err.Error()}})
+				return
+			}
+			writeResult(c, projected)
+			return
+		}
+		c.JSON(404, gin.H{"ok": false, "error": types.ErrorInfo{Code: "NOT_FOUND", Message: "no such result"}})
+	}
+}
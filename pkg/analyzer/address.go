@@ -1,21 +1,27 @@
 package analyzer
 
 import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
 )
 
+// ErrNetworkMismatch is returned when an address belongs to a different
+// network than the one requested
+var ErrNetworkMismatch = errors.New("network mismatch")
+
 // GetAddressFromScript derives a Bitcoin address from a scriptPubKey
 // Returns nil if script type doesn't have an address (e.g., OP_RETURN, unknown)
 func GetAddressFromScript(scriptPubkey []byte, network string) *string {
 	scriptType := ClassifyOutputScript(scriptPubkey)
 
-	var netParams *chaincfg.Params
-	if network == "mainnet" {
-		netParams = &chaincfg.MainNetParams
-	} else {
-		netParams = &chaincfg.TestNet3Params
-	}
+	netParams := GetNetworkParams(network)
 
 	var addr btcutil.Address
 	var err error
@@ -35,7 +41,7 @@ func GetAddressFromScript(scriptPubkey []byte, network string) *string {
 			return nil
 		}
 		hash := scriptPubkey[2:22]
-		addr, err = btcutil.NewAddressScriptHash(hash, netParams)
+		addr, err = btcutil.NewAddressScriptHashFromHash(hash, netParams)
 
 	case "p2wpkh":
 		// Extract 20-byte hash (bytes 2-21)
@@ -72,3 +78,111 @@ func GetAddressFromScript(scriptPubkey []byte, network string) *string {
 	addrStr := addr.EncodeAddress()
 	return &addrStr
 }
+
+// GetScriptFromAddress converts an address back into its scriptPubKey bytes
+// and script type. It is the inverse of GetAddressFromScript and supports the
+// same address types (p2pkh, p2sh, p2wpkh, p2wsh, p2tr).
+// An address that is valid for another network yields ErrNetworkMismatch.
+func GetScriptFromAddress(address, network string) ([]byte, string, error) {
+	netParams := GetNetworkParams(network)
+
+	addr, err := btcutil.DecodeAddress(address, netParams)
+	if err == nil && !addr.IsForNet(netParams) {
+		err = ErrNetworkMismatch
+	}
+	if err != nil {
+		if other := addressNetwork(address); other != nil && !sameAddressPrefixes(other, netParams) {
+			return nil, "", fmt.Errorf("%w: address is for %s, not %s", ErrNetworkMismatch, other.Name, network)
+		}
+		if diag := diagnoseBech32Address(address, netParams); diag != nil {
+			return nil, "", fmt.Errorf("invalid address: %w", diag)
+		}
+		return nil, "", fmt.Errorf("invalid address: %w", err)
+	}
+
+	// Raw hex pubkeys decode as AddressPubKey but are not addresses
+	if _, ok := addr.(*btcutil.AddressPubKey); ok {
+		return nil, "", errors.New("invalid address: raw public keys are not addresses")
+	}
+
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, "", fmt.Errorf("unsupported address: %w", err)
+	}
+	return script, ClassifyOutputScript(script), nil
+}
+
+// AnalyzeAddress builds the address lookup report used by the CLI and web API
+func AnalyzeAddress(address, network string) *types.AddressOutput {
+	script, scriptType, err := GetScriptFromAddress(address, network)
+	if err != nil {
+		code := "INVALID_ADDRESS"
+		if errors.Is(err, ErrNetworkMismatch) {
+			code = "NETWORK_MISMATCH"
+		}
+		return &types.AddressOutput{
+			OK:      false,
+			Network: network,
+			Address: address,
+			Error:   &types.ErrorInfo{Code: code, Message: err.Error()},
+		}
+	}
+	return &types.AddressOutput{
+		OK:              true,
+		Network:         network,
+		Address:         address,
+		ScriptType:      scriptType,
+		ScriptPubkeyHex: hex.EncodeToString(script),
+		ScriptAsm:       DisassembleScript(script),
+	}
+}
+
+// addressNetwork finds a known network the address decodes for, if any
+func addressNetwork(address string) *chaincfg.Params {
+	for _, params := range []*chaincfg.Params{&chaincfg.MainNetParams, &chaincfg.TestNet3Params} {
+		addr, err := btcutil.DecodeAddress(address, params)
+		if err == nil && addr.IsForNet(params) {
+			return params
+		}
+	}
+	return nil
+}
+
+// sameAddressPrefixes reports whether two networks encode addresses
+// identically (testnet3, testnet4 and signet all share prefixes)
+func sameAddressPrefixes(a, b *chaincfg.Params) bool {
+	return a.Bech32HRPSegwit == b.Bech32HRPSegwit &&
+		a.PubKeyHashAddrID == b.PubKeyHashAddrID &&
+		a.ScriptHashAddrID == b.ScriptHashAddrID
+}
+
+// GetP2PKInfo extracts the public key from a pay-to-pubkey script
+// (<33|65-byte pubkey> OP_CHECKSIG). P2PK has no address of its own, so the
+// P2PKH address of the same key is reported as a derived address, the way
+// block explorers display early coinbase outputs.
+// Returns nil if the script is not P2PK or the key is not a valid point.
+func GetP2PKInfo(scriptPubkey []byte, network string) *types.P2PKInfo {
+	var pubkey []byte
+	var format string
+	switch {
+	case len(scriptPubkey) == 35 && scriptPubkey[0] == 0x21 && scriptPubkey[34] == 0xac:
+		pubkey = scriptPubkey[1:34]
+		format = "compressed"
+	case len(scriptPubkey) == 67 && scriptPubkey[0] == 0x41 && scriptPubkey[66] == 0xac:
+		pubkey = scriptPubkey[1:66]
+		format = "uncompressed"
+	default:
+		return nil
+	}
+
+	addr, err := btcutil.NewAddressPubKey(pubkey, GetNetworkParams(network))
+	if err != nil {
+		return nil
+	}
+
+	return &types.P2PKInfo{
+		PubkeyHex:      hex.EncodeToString(pubkey),
+		PubkeyFormat:   format,
+		DerivedAddress: addr.AddressPubKeyHash().EncodeAddress(),
+	}
+}
@@ -0,0 +1,35 @@
+package analyzer
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestGetAddressFromScript(t *testing.T) {
+	for _, tt := range []struct {
+		script  string
+		network string
+		want    string
+	}{
+		// P2SH encodes the script hash as is; hashing it again gave 3... addresses nothing pays to
+		{"a914748284390f9e263a4b766a75d0633c50426eb87587", "mainnet", "3CK4fEwbMP7heJarmU4eqA3sMbVJyEnU3V"},
+		{"a914748284390f9e263a4b766a75d0633c50426eb87587", "testnet", "2N3sGiyscxqd3r6DQSbgXT738ZwhUpBqkej"},
+		{"76a91462e907b15cbf27d5425399ebf6f0fb50ebb88f1888ac", "mainnet", "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"},
+		{"0014751e76e8199196d454941c45d1b3a323f1433bd6", "mainnet", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+	} {
+		script, err := hex.DecodeString(tt.script)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := "<nil>"
+		if addr := GetAddressFromScript(script, tt.network); addr != nil {
+			got = *addr
+		}
+		if got != tt.want {
+			t.Errorf("GetAddressFromScript(%s, %s) = %s, want %s", tt.script, tt.network, got, tt.want)
+		}
+	}
+	if got := GetAddressFromScript([]byte{0x6a, 0x01, 0x00}, "mainnet"); got != nil {
+		t.Errorf("OP_RETURN script got address %s", *got)
+	}
+}
@@ -0,0 +1,87 @@
+package analyzer
+
+import (
+	"sort"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+// DefaultTopMovers is how many addresses a block's delta report lists when
+// no limit is given
+const DefaultTopMovers = 10
+
+// AddressDeltas nets, per address, what a block's transactions paid to it
+// against the prevouts they spent from it, and returns the addresses with
+// the largest absolute net change (ties by address). Coinbase outputs count
+// as received. Scripts without an address (OP_RETURN, bare multisig,
+// non-standard) are left out; a P2PK key counts under its derived address.
+// Returns nil for a block that touches no address.
+func AddressDeltas(txs []types.TransactionOutput, limit int) *types.AddressDeltaReport {
+	if limit <= 0 {
+		limit = DefaultTopMovers
+	}
+	deltas := make(map[string]*types.AddressDelta)
+	entry := func(address string) *types.AddressDelta {
+		d, ok := deltas[address]
+		if !ok {
+			d = &types.AddressDelta{Address: address}
+			deltas[address] = d
+		}
+		return d
+	}
+
+	for i, tx := range txs {
+		if i > 0 {
+			for _, in := range tx.Vin {
+				if in.Address == nil || in.PrevoutMissing {
+					continue
+				}
+				d := entry(*in.Address)
+				d.SpentSats += in.Prevout.ValueSats
+				d.InputCount++
+			}
+		}
+		for _, out := range tx.Vout {
+			if out.Address == nil {
+				continue
+			}
+			d := entry(*out.Address)
+			d.ReceivedSats += out.ValueSats
+			d.OutputCount++
+		}
+	}
+	if len(deltas) == 0 {
+		return nil
+	}
+
+	report := &types.AddressDeltaReport{AddressCount: len(deltas)}
+	all := make([]types.AddressDelta, 0, len(deltas))
+	for _, d := range deltas {
+		d.NetSats = d.ReceivedSats - d.SpentSats
+		if d.NetSats > 0 {
+			report.Gainers++
+		} else if d.NetSats < 0 {
+			report.Losers++
+		}
+		all = append(all, *d)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		ai, aj := abs64(all[i].NetSats), abs64(all[j].NetSats)
+		if ai != aj {
+			return ai > aj
+		}
+		return all[i].Address < all[j].Address
+	})
+	if len(all) > limit {
+		all = all[:limit]
+	}
+	report.TopMovers = all
+	return report
+}
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,61 @@
+package analyzer
+
+import (
+	"math"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+// blocksPerDay is the expected block rate at the 10-minute target spacing
+const blocksPerDay = 144
+
+// GetCoinAge computes how many blocks a prevout sat unspent before being
+// spent at blockHeight. The prevout height is preferred; its confirmation
+// count is used when no height is available.
+// Returns nil if neither is known.
+func GetCoinAge(prevout types.PrevoutInput, blockHeight *int64) *types.CoinAge {
+	var blocks int64
+	switch {
+	case prevout.Height != nil && blockHeight != nil:
+		blocks = *blockHeight - *prevout.Height
+	case prevout.Confirmations != nil:
+		blocks = *prevout.Confirmations
+	default:
+		return nil
+	}
+	if blocks < 0 {
+		blocks = 0
+	}
+	return &types.CoinAge{
+		Blocks: blocks,
+		Days:   blocksToDays(float64(blocks)),
+	}
+}
+
+// GetInputAge averages the coin age of all inputs that have one.
+// Returns nil if no input age is known.
+func GetInputAge(inputs []types.Input) *types.InputAge {
+	var count int
+	var total int64
+	for _, in := range inputs {
+		if in.CoinAge == nil {
+			continue
+		}
+		count++
+		total += in.CoinAge.Blocks
+	}
+	if count == 0 {
+		return nil
+	}
+	avg := float64(total) / float64(count)
+	return &types.InputAge{
+		InputsWithAge: count,
+		AvgBlocks:     math.Round(avg*100) / 100,
+		AvgDays:       blocksToDays(avg),
+	}
+}
+
+// blocksToDays converts a block count to days, rounded to 2 decimal places
+func blocksToDays(blocks float64) float64 {
+	return math.Round(blocks/blocksPerDay*100) / 100
+}
@@ -0,0 +1,166 @@
+package analyzer
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+// feeRateBucketEdges are the lower bounds (sat/vB) of the fee-rate
+// histogram buckets; the last bucket is open-ended
+var feeRateBucketEdges = []float64{0, 1, 2, 5, 10, 20, 50, 100, 200, 500}
+
+// StatsAggregator accumulates block results into one report, optionally
+// also per UTC day of the block timestamp. Stale blocks are counted but
+// left out of the totals, since their transactions are not in the chain.
+type StatsAggregator struct {
+	total *blockAggregate
+	days  map[string]*blockAggregate // nil unless grouping by day
+}
+
+// blockAggregate holds the running totals of one group. Fee rates are kept
+// as a count per 0.1 sat/vB, which bounds memory over any number of blocks
+// while keeping percentiles exact to that resolution.
+type blockAggregate struct {
+	stats    types.AggregateStats
+	feeRates map[int64]int
+	signals  *VersionBitsCounter
+}
+
+// NewStatsAggregator returns an empty aggregator
+func NewStatsAggregator(byDay bool) *StatsAggregator {
+	a := &StatsAggregator{total: newBlockAggregate()}
+	if byDay {
+		a.days = make(map[string]*blockAggregate)
+	}
+	return a
+}
+
+func newBlockAggregate() *blockAggregate {
+	return &blockAggregate{
+		stats:    types.AggregateStats{ScriptTypeTotals: make(map[string]int)},
+		feeRates: make(map[int64]int),
+		signals:  NewVersionBitsCounter(),
+	}
+}
+
+// Add folds one block into the totals
+func (a *StatsAggregator) Add(block *types.BlockOutput) {
+	a.total.add(block)
+	if a.days != nil {
+		day := time.Unix(int64(block.BlockHeader.Timestamp), 0).UTC().Format("2006-01-02")
+		agg, ok := a.days[day]
+		if !ok {
+			agg = newBlockAggregate()
+			a.days[day] = agg
+		}
+		agg.add(block)
+	}
+}
+
+func (g *blockAggregate) add(block *types.BlockOutput) {
+	s := &g.stats
+	if block.Stale {
+		s.StaleBlocks++
+		return
+	}
+	s.Blocks++
+	height := block.Coinbase.Bip34Height
+	if s.FirstHeight == 0 || height < s.FirstHeight {
+		s.FirstHeight = height
+	}
+	if height > s.LastHeight {
+		s.LastHeight = height
+	}
+	ts := block.BlockHeader.Timestamp
+	if s.FirstTimestamp == 0 || ts < s.FirstTimestamp {
+		s.FirstTimestamp = ts
+	}
+	if ts > s.LastTimestamp {
+		s.LastTimestamp = ts
+	}
+
+	g.signals.Add(block.BlockHeader.VersionBits, ts)
+
+	s.TxCount += block.TxCount
+	s.TotalFeesSats += block.BlockStats.TotalFeesSats
+	s.TotalWeight += int64(block.BlockStats.TotalWeight)
+	for t, n := range block.BlockStats.ScriptTypeSummary {
+		s.ScriptTypeTotals[t] += n
+	}
+	for i, tx := range block.Transactions {
+		if i > 0 && tx.FeeRateSatVb != nil {
+			g.feeRates[int64(math.Round(*tx.FeeRateSatVb*10))]++
+		}
+	}
+}
+
+// Report returns the overall totals and, when grouping by day, one entry
+// per day in date order
+func (a *StatsAggregator) Report() (types.AggregateStats, []types.DayStats) {
+	total := a.total.finish()
+	if a.days == nil {
+		return total, nil
+	}
+	days := make([]types.DayStats, 0, len(a.days))
+	for day, agg := range a.days {
+		days = append(days, types.DayStats{Date: day, AggregateStats: agg.finish()})
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return total, days
+}
+
+func (g *blockAggregate) finish() types.AggregateStats {
+	s := g.stats
+	if s.TotalWeight > 0 {
+		vbytes := (s.TotalWeight + 3) / 4
+		s.AvgFeeRateSatVb = math.Round(float64(s.TotalFeesSats)/float64(vbytes)*100) / 100
+	}
+	s.FeeRates = feeRateDistribution(g.feeRates)
+	s.Signals = g.signals.Report()
+	return s
+}
+
+// feeRateDistribution summarizes per-transaction fee rates (in 0.1 sat/vB
+// steps) as percentiles and a histogram
+func feeRateDistribution(counts map[int64]int) types.FeeRateDistribution {
+	rates := make([]int64, 0, len(counts))
+	total := 0
+	for r, n := range counts {
+		rates = append(rates, r)
+		total += n
+	}
+	sort.Slice(rates, func(i, j int) bool { return rates[i] < rates[j] })
+
+	dist := types.FeeRateDistribution{TxCount: total, Buckets: make([]types.FeeRateBucket, len(feeRateBucketEdges))}
+	for i, edge := range feeRateBucketEdges {
+		dist.Buckets[i].MinSatVb = edge
+		if i+1 < len(feeRateBucketEdges) {
+			upper := feeRateBucketEdges[i+1]
+			dist.Buckets[i].MaxSatVb = &upper
+		}
+	}
+	if total == 0 {
+		return dist
+	}
+
+	// Nearest-rank percentiles over the sorted rate counts
+	targets := []float64{0.10, 0.25, 0.50, 0.75, 0.90}
+	values := make([]float64, len(targets))
+	seen, t := 0, 0
+	for _, r := range rates {
+		seen += counts[r]
+		for t < len(targets) && float64(seen) >= math.Ceil(targets[t]*float64(total)) {
+			values[t] = float64(r) / 10
+			t++
+		}
+		b := sort.Search(len(feeRateBucketEdges), func(i int) bool { return feeRateBucketEdges[i]*10 > float64(r) }) - 1
+		dist.Buckets[b].TxCount += counts[r]
+	}
+	dist.MinSatVb = float64(rates[0]) / 10
+	dist.MaxSatVb = float64(rates[len(rates)-1]) / 10
+	dist.P10, dist.P25, dist.P50, dist.P75, dist.P90 = values[0], values[1], values[2], values[3], values[4]
+	return dist
+}
@@ -0,0 +1,166 @@
+package analyzer
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+// AnnotateScript disassembles a script into a structured token array, one
+// token per opcode or data push. Pushes carry an inferred meaning such as
+// "<20-byte hash160>" or "<signature SIGHASH_ALL>" so the script is readable
+// without knowing the raw encodings. The tokens mirror DisassembleScript.
+func AnnotateScript(script []byte) []types.ScriptToken {
+	tokens := make([]types.ScriptToken, 0)
+	i := 0
+	for i < len(script) {
+		op := script[i]
+		i++
+
+		var name string
+		var n int
+		switch {
+		case op == 0x00:
+			tokens = append(tokens, types.ScriptToken{Op: "OP_0"})
+			continue
+		case op >= 0x01 && op <= 0x4b:
+			name = opcodeNames[op]
+			n = int(op)
+		case op == 0x4c: // OP_PUSHDATA1
+			name = opcodeNames[op]
+			if i >= len(script) {
+				tokens = append(tokens, types.ScriptToken{Op: name})
+				continue
+			}
+			n = int(script[i])
+			i++
+		case op == 0x4d: // OP_PUSHDATA2
+			name = opcodeNames[op]
+			if i+1 >= len(script) {
+				tokens = append(tokens, types.ScriptToken{Op: name})
+				i = len(script)
+				continue
+			}
+			n = int(binary.LittleEndian.Uint16(script[i : i+2]))
+			i += 2
+		case op == 0x4e: // OP_PUSHDATA4
+			name = opcodeNames[op]
+			if i+3 >= len(script) {
+				tokens = append(tokens, types.ScriptToken{Op: name})
+				i = len(script)
+				continue
+			}
+			n = int(binary.LittleEndian.Uint32(script[i : i+4]))
+			i += 4
+		default:
+			tokens = append(tokens, types.ScriptToken{Op: opcodeToName(op)})
+			continue
+		}
+
+		// Truncated push — emit the opcode alone, consistent with DisassembleScript
+		if i+n > len(script) {
+			if op <= 0x4b {
+				tokens = append(tokens, types.ScriptToken{Op: name})
+				i = len(script)
+				continue
+			}
+			n = len(script) - i
+		}
+
+		data := script[i : i+n]
+		tokens = append(tokens, types.ScriptToken{
+			Op:         name,
+			DataHex:    hex.EncodeToString(data),
+			Annotation: AnnotatePush(data),
+		})
+		i += n
+	}
+	return tokens
+}
+
+// AnnotatePush infers the meaning of a single pushed data element.
+// The result is a short human-readable label in angle brackets.
+func AnnotatePush(data []byte) string {
+	n := len(data)
+	switch {
+	case n == 0:
+		return "<empty>"
+	case isDERSignature(data):
+		return fmt.Sprintf("<signature %s>", SighashTypeName(data[n-1]))
+	case n == 33 && (data[0] == 0x02 || data[0] == 0x03):
+		return fmt.Sprintf("<33-byte pubkey %02x…>", data[0])
+	case n == 65 && data[0] == 0x04:
+		return "<65-byte uncompressed pubkey 04…>"
+	case n == 20:
+		return "<20-byte hash160>"
+	case n == 32:
+		return "<32-byte hash or x-only pubkey>"
+	case n == 64:
+		return "<schnorr signature SIGHASH_DEFAULT>"
+	case n == 65:
+		return fmt.Sprintf("<schnorr signature %s>", SighashTypeName(data[64]))
+	case n <= 4:
+		return fmt.Sprintf("<number %d>", decodeScriptNum(data))
+	}
+	return fmt.Sprintf("<%d-byte data>", n)
+}
+
+// SighashTypeName returns the canonical name of a sighash flag byte,
+// e.g. 0x01 → "SIGHASH_ALL", 0x83 → "SIGHASH_SINGLE|ANYONECANPAY".
+func SighashTypeName(b byte) string {
+	var base string
+	switch b & 0x1f {
+	case 0x01:
+		base = "SIGHASH_ALL"
+	case 0x02:
+		base = "SIGHASH_NONE"
+	case 0x03:
+		base = "SIGHASH_SINGLE"
+	default:
+		return fmt.Sprintf("SIGHASH_UNKNOWN_0x%02x", b)
+	}
+	if b&0x80 != 0 {
+		return base + "|ANYONECANPAY"
+	}
+	return base
+}
+
+// isDERSignature checks for a strict DER-encoded ECDSA signature followed by
+// a sighash byte (BIP66 layout: 0x30 <len> 0x02 <rlen> <r> 0x02 <slen> <s> <hashtype>).
+func isDERSignature(sig []byte) bool {
+	if len(sig) < 9 || len(sig) > 73 {
+		return false
+	}
+	if sig[0] != 0x30 || int(sig[1]) != len(sig)-3 {
+		return false
+	}
+	rLen := int(sig[3])
+	if sig[2] != 0x02 || rLen == 0 || 5+rLen >= len(sig) {
+		return false
+	}
+	sLen := int(sig[5+rLen])
+	if sig[4+rLen] != 0x02 || sLen == 0 || rLen+sLen+7 != len(sig) {
+		return false
+	}
+	return true
+}
+
+// decodeScriptNum decodes a minimally-encoded script number (little-endian,
+// sign bit in the most significant byte).
+func decodeScriptNum(data []byte) int64 {
+	if len(data) == 0 {
+		return 0
+	}
+	var v int64
+	for i, b := range data {
+		v |= int64(b) << (8 * i)
+	}
+	last := data[len(data)-1]
+	if last&0x80 != 0 {
+		v &^= int64(0x80) << (8 * (len(data) - 1))
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,101 @@
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// knownSegwitHRPs lists the human-readable parts diagnoseBech32Address treats
+// as an attempt at a segwit address
+var knownSegwitHRPs = []string{"bc", "tb", "bcrt"}
+
+// diagnoseBech32Address explains why a segwit address failed to decode:
+// mixed case, a bad checksum (with the expected one), the wrong HRP for the
+// network, the wrong checksum variant for the witness version, or an invalid
+// witness version/program length.
+// Returns nil if the address does not look like a segwit address or no
+// specific problem could be identified.
+func diagnoseBech32Address(address string, netParams *chaincfg.Params) error {
+	sep := strings.LastIndexByte(address, '1')
+	if sep < 1 {
+		return nil
+	}
+	hrp := strings.ToLower(address[:sep])
+	known := hrp == netParams.Bech32HRPSegwit
+	for _, h := range knownSegwitHRPs {
+		known = known || hrp == h
+	}
+	if !known {
+		return nil
+	}
+
+	_, data, version, err := bech32.DecodeGeneric(address)
+	if err != nil {
+		var checksumErr bech32.ErrInvalidChecksum
+		var charErr bech32.ErrNonCharsetChar
+		var lenErr bech32.ErrInvalidLength
+		switch {
+		case errors.As(err, &bech32.ErrMixedCase{}):
+			return errors.New("bech32 address mixes upper and lower case")
+		case errors.As(err, &checksumErr):
+			// The first data character is the witness version: v0 uses
+			// bech32, v1+ uses bech32m (BIP350). btcutil appends the
+			// bech32m checksum to the bech32 one in ExpectedM, so only its
+			// last 6 characters are the bech32m value.
+			expected := checksumErr.ExpectedM
+			if len(expected) > 6 {
+				expected = expected[len(expected)-6:]
+			}
+			variant := "bech32m"
+			if sep+1 < len(address) && strings.ToLower(address[sep+1:sep+2]) == "q" {
+				expected, variant = checksumErr.Expected, "bech32"
+			}
+			return fmt.Errorf("bad %s checksum %q, expected %q", variant, checksumErr.Actual, expected)
+		case errors.As(err, &charErr):
+			return fmt.Errorf("bech32 address contains invalid character %q", rune(charErr))
+		case errors.As(err, &lenErr):
+			return fmt.Errorf("bech32 address has invalid length %d", int(lenErr))
+		}
+		return fmt.Errorf("malformed bech32 address: %v", err)
+	}
+
+	if hrp != netParams.Bech32HRPSegwit {
+		return fmt.Errorf("wrong HRP %q for %s, expected %q", hrp, netParams.Name, netParams.Bech32HRPSegwit)
+	}
+	if len(data) == 0 {
+		return errors.New("bech32 address has no witness version")
+	}
+
+	witnessVersion := data[0]
+	if witnessVersion > 16 {
+		return fmt.Errorf("invalid witness version %d", witnessVersion)
+	}
+	if witnessVersion == 0 && version != bech32.Version0 {
+		return errors.New("witness version 0 must use bech32, not bech32m")
+	}
+	if witnessVersion != 0 && version != bech32.VersionM {
+		return fmt.Errorf("witness version %d must use bech32m, not bech32", witnessVersion)
+	}
+
+	program, err := bech32.ConvertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return fmt.Errorf("invalid witness program padding: %v", err)
+	}
+	if len(program) < 2 || len(program) > 40 {
+		return fmt.Errorf("invalid witness program length %d (must be 2-40 bytes)", len(program))
+	}
+	if witnessVersion == 0 && len(program) != 20 && len(program) != 32 {
+		return fmt.Errorf("invalid witness v0 program length %d (must be 20 or 32 bytes)", len(program))
+	}
+	if witnessVersion > 1 {
+		return fmt.Errorf("unsupported witness version %d", witnessVersion)
+	}
+	if witnessVersion == 1 && len(program) != 32 {
+		return fmt.Errorf("invalid witness v1 program length %d (taproot requires 32 bytes)", len(program))
+	}
+	return nil
+}
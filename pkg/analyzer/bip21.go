@@ -0,0 +1,319 @@
+package analyzer
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/bech32"
+)
+
+// bip21Scheme is the URI scheme of BIP21 payment requests
+const bip21Scheme = "bitcoin:"
+
+// lightningPrefixes maps BOLT11 invoice prefixes to networks, longest first
+// so that lnbcrt and lntbs are not taken for lnbc and lntb
+var lightningPrefixes = []struct{ prefix, network string }{
+	{"lnbcrt", "regtest"},
+	{"lntbs", NetworkSignet},
+	{"lntb", NetworkTestnet},
+	{"lnbc", NetworkMainnet},
+}
+
+// uriError is a payment URI problem with the error code reported for it
+type uriError struct {
+	code string
+	err  error
+}
+
+func (e *uriError) Error() string { return e.err.Error() }
+
+func uriErrorf(code, format string, args ...interface{}) *uriError {
+	return &uriError{code: code, err: fmt.Errorf(format, args...)}
+}
+
+// ParsePaymentURI parses a BIP21 bitcoin: URI, validating the address for
+// network and converting the amount to sats. Recognized parameters are
+// amount, label, message, lightning (a BOLT11 fallback invoice) and the
+// BIP78 payjoin parameters pj and pjos; other optional parameters are passed
+// through and unknown req- parameters make the URI invalid.
+func ParsePaymentURI(uri, network string) *types.PaymentURIOutput {
+	result := &types.PaymentURIOutput{OK: true, Network: network, URI: uri}
+	if err := parsePaymentURI(uri, network, result); err != nil {
+		var uerr *uriError
+		code := "INVALID_URI"
+		if errors.As(err, &uerr) {
+			code = uerr.code
+		}
+		return &types.PaymentURIOutput{
+			OK:      false,
+			Network: network,
+			URI:     uri,
+			Error:   &types.ErrorInfo{Code: code, Message: err.Error()},
+		}
+	}
+	return result
+}
+
+func parsePaymentURI(uri, network string, result *types.PaymentURIOutput) error {
+	uri = strings.TrimSpace(uri)
+	if len(uri) < len(bip21Scheme) || !strings.EqualFold(uri[:len(bip21Scheme)], bip21Scheme) {
+		return uriErrorf("INVALID_URI", "not a bitcoin: URI")
+	}
+	address, query, _ := strings.Cut(uri[len(bip21Scheme):], "?")
+
+	seen := make(map[string]bool)
+	if query != "" {
+		for _, param := range strings.Split(query, "&") {
+			if param == "" {
+				continue
+			}
+			rawKey, rawValue, _ := strings.Cut(param, "=")
+			key, err := url.PathUnescape(rawKey)
+			if err != nil {
+				return uriErrorf("INVALID_URI", "invalid parameter name %q: %v", rawKey, err)
+			}
+			// Percent-encoding only: '+' is a literal plus, not a space
+			value, err := url.PathUnescape(rawValue)
+			if err != nil {
+				return uriErrorf("INVALID_URI", "invalid %s value: %v", key, err)
+			}
+			key = strings.ToLower(key)
+			if seen[key] {
+				return uriErrorf("INVALID_URI", "parameter %s appears more than once", key)
+			}
+			seen[key] = true
+			if err := applyURIParam(key, value, network, result); err != nil {
+				return err
+			}
+		}
+	}
+
+	if address == "" {
+		// Address-less URIs only make sense with a lightning invoice to pay
+		if result.Lightning == nil {
+			return uriErrorf("INVALID_URI", "URI has neither an address nor a lightning invoice")
+		}
+	} else {
+		if err := applyURIAddress(address, network, result); err != nil {
+			return err
+		}
+	}
+	if result.Payjoin != nil {
+		if result.Payjoin.Endpoint == "" {
+			return uriErrorf("INVALID_PAYJOIN", "pjos requires pj")
+		}
+		if address == "" {
+			return uriErrorf("INVALID_PAYJOIN", "pj requires an address to fall back to")
+		}
+	}
+	return nil
+}
+
+// applyURIAddress validates the URI's address for network. QR codes carry
+// bech32 addresses in upper case, so an all upper-case address is tried
+// lower-cased first and reported that way.
+func applyURIAddress(address, network string, result *types.PaymentURIOutput) error {
+	decoded, err := url.PathUnescape(address)
+	if err != nil {
+		return uriErrorf("INVALID_ADDRESS", "invalid address: %v", err)
+	}
+	if lower := strings.ToLower(decoded); decoded == strings.ToUpper(decoded) && lower != decoded {
+		if script, scriptType, err := GetScriptFromAddress(lower, network); err == nil {
+			result.Address = lower
+			result.ScriptType = scriptType
+			result.ScriptPubkeyHex = hex.EncodeToString(script)
+			return nil
+		}
+	}
+	script, scriptType, err := GetScriptFromAddress(decoded, network)
+	if err != nil {
+		code := "INVALID_ADDRESS"
+		if errors.Is(err, ErrNetworkMismatch) {
+			code = "NETWORK_MISMATCH"
+		}
+		return &uriError{code: code, err: err}
+	}
+	result.Address = decoded
+	result.ScriptType = scriptType
+	result.ScriptPubkeyHex = hex.EncodeToString(script)
+	return nil
+}
+
+func applyURIParam(key, value, network string, result *types.PaymentURIOutput) error {
+	switch key {
+	case "amount":
+		sats, err := parseBTCAmount(value)
+		if err != nil {
+			return uriErrorf("INVALID_AMOUNT", "invalid amount %q: %v", value, err)
+		}
+		result.AmountBTC = value
+		result.AmountSats = &sats
+	case "label":
+		result.Label = value
+	case "message":
+		result.Message = value
+	case "lightning":
+		ln, err := parseLightningFallback(value, network)
+		if err != nil {
+			return err
+		}
+		result.Lightning = ln
+	case "pj":
+		endpoint, err := url.Parse(value)
+		if err != nil || endpoint.Host == "" {
+			return uriErrorf("INVALID_PAYJOIN", "invalid pj endpoint %q", value)
+		}
+		// BIP78: https, or plain http to a Tor hidden service
+		onion := strings.HasSuffix(endpoint.Hostname(), ".onion")
+		if endpoint.Scheme != "https" && !(endpoint.Scheme == "http" && onion) {
+			return uriErrorf("INVALID_PAYJOIN", "pj endpoint must use https (or http to a .onion host)")
+		}
+		if result.Payjoin == nil {
+			result.Payjoin = &types.PayjoinParams{OutputSubstitution: true}
+		}
+		result.Payjoin.Endpoint = value
+	case "pjos":
+		if value != "0" && value != "1" {
+			return uriErrorf("INVALID_PAYJOIN", "invalid pjos %q: want 0 or 1", value)
+		}
+		if result.Payjoin == nil {
+			result.Payjoin = &types.PayjoinParams{}
+		}
+		result.Payjoin.OutputSubstitution = value == "1"
+	default:
+		if strings.HasPrefix(key, "req-") {
+			return uriErrorf("UNSUPPORTED_REQUIRED_PARAM", "unsupported required parameter %s", key)
+		}
+		if result.Params == nil {
+			result.Params = make(map[string]string)
+		}
+		result.Params[key] = value
+	}
+	return nil
+}
+
+// parseBTCAmount converts a decimal BTC amount to sats without going
+// through floating point. BIP21 amounts have no exponent or separators.
+func parseBTCAmount(value string) (int64, error) {
+	whole, frac, hasDot := strings.Cut(value, ".")
+	if whole == "" && frac == "" {
+		return 0, errors.New("empty amount")
+	}
+	for _, part := range []string{whole, frac} {
+		for i := 0; i < len(part); i++ {
+			if part[i] < '0' || part[i] > '9' {
+				return 0, errors.New("want a decimal BTC amount")
+			}
+		}
+	}
+	if hasDot && len(frac) > 8 {
+		return 0, errors.New("more than 8 decimal places")
+	}
+	if len(strings.TrimLeft(whole, "0")) > 8 {
+		return 0, errors.New("exceeds the 21 million BTC supply")
+	}
+	var sats int64
+	if whole != "" {
+		w, err := strconv.ParseInt(whole, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		sats = w * btcutil.SatoshiPerBitcoin
+	}
+	if frac != "" {
+		f, err := strconv.ParseInt(frac+strings.Repeat("0", 8-len(frac)), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		sats += f
+	}
+	if sats > btcutil.MaxSatoshi {
+		return 0, errors.New("exceeds the 21 million BTC supply")
+	}
+	return sats, nil
+}
+
+// parseLightningFallback checks a BOLT11 invoice's bech32 encoding and that
+// its prefix matches network
+func parseLightningFallback(invoice, network string) (*types.LightningFallback, error) {
+	hrp, _, err := bech32.DecodeNoLimit(strings.ToLower(invoice))
+	if err != nil {
+		return nil, uriErrorf("INVALID_LIGHTNING", "invalid lightning invoice: %v", err)
+	}
+	ln := &types.LightningFallback{Invoice: invoice}
+	for _, p := range lightningPrefixes {
+		if strings.HasPrefix(hrp, p.prefix) {
+			ln.Network = p.network
+			break
+		}
+	}
+	if ln.Network == "" {
+		return nil, uriErrorf("INVALID_LIGHTNING", "invalid lightning invoice: unknown prefix %q", hrp)
+	}
+	if amount := strings.TrimPrefix(hrp, lightningPrefix(ln.Network)); amount != "" {
+		msat, err := parseBolt11Amount(amount)
+		if err != nil {
+			return nil, uriErrorf("INVALID_LIGHTNING", "invalid lightning invoice amount %q: %v", amount, err)
+		}
+		ln.AmountMsat = &msat
+	}
+	want := network
+	if network == NetworkTestnet4 {
+		want = NetworkTestnet // BOLT11 has one prefix for all testnets
+	}
+	if ln.Network != want {
+		return nil, uriErrorf("NETWORK_MISMATCH", "lightning invoice is for %s, not %s", ln.Network, network)
+	}
+	return ln, nil
+}
+
+// parseBolt11Amount converts a BOLT11 amount (digits and an optional m, u,
+// n or p multiplier of one BTC) to millisatoshis
+func parseBolt11Amount(amount string) (int64, error) {
+	const msatPerBTC = 100_000_000_000
+	digits, divisor := amount, int64(1)
+	switch amount[len(amount)-1] {
+	case 'm':
+		divisor = 1_000
+	case 'u':
+		divisor = 1_000_000
+	case 'n':
+		divisor = 1_000_000_000
+	case 'p':
+		divisor = 1_000_000_000_000
+	}
+	if divisor != 1 {
+		digits = amount[:len(amount)-1]
+	}
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || n <= 0 || digits[0] == '0' {
+		return 0, errors.New("want a positive amount without leading zeros")
+	}
+	// Pico-BTC amounts are tenths of a millisatoshi and must end in 0
+	if divisor > msatPerBTC {
+		if n%(divisor/msatPerBTC) != 0 {
+			return 0, errors.New("sub-millisatoshi amount")
+		}
+		return n / (divisor / msatPerBTC), nil
+	}
+	if n > btcutil.MaxSatoshi*1000/(msatPerBTC/divisor) {
+		return 0, errors.New("exceeds the 21 million BTC supply")
+	}
+	return n * (msatPerBTC / divisor), nil
+}
+
+func lightningPrefix(network string) string {
+	for _, p := range lightningPrefixes {
+		if p.network == network {
+			return p.prefix
+		}
+	}
+	return ""
+}
@@ -0,0 +1,100 @@
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+// DiffBlocks compares two analyzed blocks, typically a stale block and the
+// one that replaced it: which transactions they share, which only one
+// confirmed, how their fees differ, and which outpoints each spent in a
+// different transaction (the double spends a reorg would reverse). Shared
+// transactions whose wtxid differs carried a different witness. Coinbase
+// transactions are compared only through their payout totals.
+func DiffBlocks(a, b *types.BlockOutput) (*types.BlockDiffOutput, error) {
+	for _, block := range []*types.BlockOutput{a, b} {
+		if len(block.Transactions) == 0 {
+			return nil, fmt.Errorf("block %s has no transactions; analyze it at standard or full verbosity", block.BlockHeader.BlockHash)
+		}
+	}
+	if a.BlockHeader.BlockHash == b.BlockHeader.BlockHash {
+		return nil, errors.New("both sides are the same block")
+	}
+
+	out := &types.BlockDiffOutput{
+		OK:         true,
+		Mode:       "block_diff",
+		A:          blockDiffSide(a),
+		B:          blockDiffSide(b),
+		SameParent: a.BlockHeader.PrevBlockHash == b.BlockHeader.PrevBlockHash,
+		OnlyInA:    make([]types.BlockDiffTx, 0),
+		OnlyInB:    make([]types.BlockDiffTx, 0),
+	}
+	out.FeeDiffSats = out.B.TotalFeesSats - out.A.TotalFeesSats
+	out.CoinbaseDiffSats = out.B.CoinbaseOutputSats - out.A.CoinbaseOutputSats
+
+	inB := make(map[string]*types.TransactionOutput, len(b.Transactions))
+	for i := range b.Transactions[1:] {
+		tx := &b.Transactions[i+1]
+		inB[tx.Txid] = tx
+	}
+
+	// Outpoints spent by A, to find the transactions of B that conflict
+	spentByA := make(map[string]string)
+	inA := make(map[string]bool, len(a.Transactions))
+	for _, tx := range a.Transactions[1:] {
+		inA[tx.Txid] = true
+		if other, ok := inB[tx.Txid]; ok {
+			out.SharedCount++
+			if tx.Wtxid != nil && other.Wtxid != nil && *tx.Wtxid != *other.Wtxid {
+				out.WitnessChanged = append(out.WitnessChanged, tx.Txid)
+			}
+			continue
+		}
+		out.OnlyInA = append(out.OnlyInA, blockDiffTx(tx))
+		out.ExclusiveFeesA += feeOrZero(tx)
+		for _, in := range tx.Vin {
+			spentByA[fmt.Sprintf("%s:%d", in.Txid, in.Vout)] = tx.Txid
+		}
+	}
+	for _, tx := range b.Transactions[1:] {
+		if inA[tx.Txid] {
+			continue
+		}
+		out.OnlyInB = append(out.OnlyInB, blockDiffTx(tx))
+		out.ExclusiveFeesB += feeOrZero(tx)
+		for _, in := range tx.Vin {
+			outpoint := fmt.Sprintf("%s:%d", in.Txid, in.Vout)
+			if txidA, ok := spentByA[outpoint]; ok {
+				out.DoubleSpends = append(out.DoubleSpends, types.BlockDoubleSpend{Outpoint: outpoint, TxidA: txidA, TxidB: tx.Txid})
+			}
+		}
+	}
+	return out, nil
+}
+
+func blockDiffSide(block *types.BlockOutput) types.BlockDiffSide {
+	return types.BlockDiffSide{
+		BlockHash:          block.BlockHeader.BlockHash,
+		PrevBlockHash:      block.BlockHeader.PrevBlockHash,
+		Height:             block.Coinbase.Bip34Height,
+		Timestamp:          block.BlockHeader.Timestamp,
+		Stale:              block.Stale,
+		TxCount:            block.TxCount,
+		TotalFeesSats:      block.BlockStats.TotalFeesSats,
+		CoinbaseOutputSats: block.Coinbase.TotalOutputSats,
+	}
+}
+
+func blockDiffTx(tx types.TransactionOutput) types.BlockDiffTx {
+	return types.BlockDiffTx{Txid: tx.Txid, FeeSats: tx.FeeSats, FeeRateSatVb: tx.FeeRateSatVb}
+}
+
+func feeOrZero(tx types.TransactionOutput) int64 {
+	if tx.FeeSats == nil {
+		return 0
+	}
+	return *tx.FeeSats
+}
@@ -0,0 +1,211 @@
+package analyzer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/utils"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// BIP37 filter update modes, applied when an output matches
+const (
+	BloomUpdateNone         = "none"          // never add outpoints
+	BloomUpdateAll          = "all"           // add the outpoint of every matching output
+	BloomUpdateP2PubkeyOnly = "p2pubkey_only" // only for pay-to-pubkey and bare multisig outputs
+)
+
+// bloomSeedMultiplier spaces the murmur seeds of the filter's hash functions
+const bloomSeedMultiplier = 0xfba4c795
+
+// BloomFilter is a BIP37 filter as sent in a filterload message
+type BloomFilter struct {
+	Data      []byte
+	HashFuncs uint32
+	Tweak     uint32
+	Flags     wire.BloomUpdateType
+}
+
+// NewBloomFilter sizes a filter for the given number of elements and false
+// positive rate the way Bitcoin Core does, capped at the protocol limits of
+// 36,000 bytes and 50 hash functions
+func NewBloomFilter(elements int, fpRate float64, tweak uint32, flags wire.BloomUpdateType) (*BloomFilter, error) {
+	if fpRate <= 0 || fpRate >= 1 {
+		return nil, fmt.Errorf("false positive rate %g must be between 0 and 1", fpRate)
+	}
+	if elements < 1 {
+		elements = 1
+	}
+	ln2 := math.Ln2
+	size := -1 / (ln2 * ln2) * float64(elements) * math.Log(fpRate) / 8
+	size = math.Min(size, wire.MaxFilterLoadFilterSize)
+	data := make([]byte, int(math.Max(size, 1)))
+	funcs := math.Min(float64(len(data)*8)/float64(elements)*ln2, wire.MaxFilterLoadHashFuncs)
+	return &BloomFilter{Data: data, HashFuncs: uint32(math.Max(funcs, 1)), Tweak: tweak, Flags: flags}, nil
+}
+
+// BloomFilterFromLoad wraps a decoded filterload message
+func BloomFilterFromLoad(msg *wire.MsgFilterLoad) (*BloomFilter, error) {
+	if len(msg.Filter) == 0 {
+		return nil, errors.New("filterload has an empty filter")
+	}
+	if msg.Flags > wire.BloomUpdateP2PubkeyOnly {
+		return nil, fmt.Errorf("unknown filterload flags %d", msg.Flags)
+	}
+	return &BloomFilter{Data: msg.Filter, HashFuncs: msg.HashFuncs, Tweak: msg.Tweak, Flags: msg.Flags}, nil
+}
+
+// ParseBloomUpdate maps an update mode name to its filterload flag value
+func ParseBloomUpdate(name string) (wire.BloomUpdateType, error) {
+	switch name {
+	case "", BloomUpdateNone:
+		return wire.BloomUpdateNone, nil
+	case BloomUpdateAll:
+		return wire.BloomUpdateAll, nil
+	case BloomUpdateP2PubkeyOnly:
+		return wire.BloomUpdateP2PubkeyOnly, nil
+	}
+	return 0, fmt.Errorf("unknown bloom update mode %q: want none, all or p2pubkey_only", name)
+}
+
+// BloomUpdateName is the inverse of ParseBloomUpdate
+func BloomUpdateName(flags wire.BloomUpdateType) string {
+	switch flags {
+	case wire.BloomUpdateAll:
+		return BloomUpdateAll
+	case wire.BloomUpdateP2PubkeyOnly:
+		return BloomUpdateP2PubkeyOnly
+	}
+	return BloomUpdateNone
+}
+
+// MsgFilterLoad returns the filter as a filterload message
+func (f *BloomFilter) MsgFilterLoad() *wire.MsgFilterLoad {
+	return wire.NewMsgFilterLoad(f.Data, f.HashFuncs, f.Tweak, f.Flags)
+}
+
+func (f *BloomFilter) bit(n uint32, data []byte) uint32 {
+	return utils.Murmur3(n*bloomSeedMultiplier+f.Tweak, data) % uint32(len(f.Data)*8)
+}
+
+// Add inserts an element into the filter
+func (f *BloomFilter) Add(data []byte) {
+	for n := uint32(0); n < f.HashFuncs; n++ {
+		b := f.bit(n, data)
+		f.Data[b>>3] |= 1 << (b & 7)
+	}
+}
+
+// Contains reports whether an element may be in the filter
+func (f *BloomFilter) Contains(data []byte) bool {
+	for n := uint32(0); n < f.HashFuncs; n++ {
+		b := f.bit(n, data)
+		if f.Data[b>>3]&(1<<(b&7)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// AddOutPoint inserts an outpoint in its serialized form (txid || vout)
+func (f *BloomFilter) AddOutPoint(op wire.OutPoint) {
+	f.Add(serializeOutPoint(op))
+}
+
+// AddScriptPushes inserts every data push of a script; for an address's
+// scriptPubKey that is the hash, witness program or key a node matches on
+func (f *BloomFilter) AddScriptPushes(script []byte) {
+	for _, data := range bloomElements(script) {
+		f.Add(data)
+	}
+}
+
+// FillRatio is the fraction of filter bits that are set
+func (f *BloomFilter) FillRatio() float64 {
+	set := 0
+	for _, b := range f.Data {
+		for ; b != 0; b &= b - 1 {
+			set++
+		}
+	}
+	return float64(set) / float64(len(f.Data)*8)
+}
+
+// MatchTransaction tests a transaction the way a BIP37 node does before
+// relaying it to a filtered peer, and returns why it matched: "txid",
+// "output:<n>", "outpoint:<n>" or "input_script:<n>". Matching outputs add
+// their outpoint to the filter according to its update flags, so later
+// spends of them match too; transactions must be fed in block order.
+// Witness data is never examined.
+func (f *BloomFilter) MatchTransaction(tx *wire.MsgTx) []string {
+	var reasons []string
+	txid := tx.TxHash()
+	if f.Contains(txid[:]) {
+		reasons = append(reasons, "txid")
+	}
+
+	for i, out := range tx.TxOut {
+		for _, data := range bloomElements(out.PkScript) {
+			if !f.Contains(data) {
+				continue
+			}
+			reasons = append(reasons, fmt.Sprintf("output:%d", i))
+			if f.updates(out.PkScript) {
+				f.AddOutPoint(wire.OutPoint{Hash: txid, Index: uint32(i)})
+			}
+			break
+		}
+	}
+
+	// A node stops at the first match; the inputs are still reported here
+	// since they never change the filter
+	for i, in := range tx.TxIn {
+		if f.Contains(serializeOutPoint(in.PreviousOutPoint)) {
+			reasons = append(reasons, fmt.Sprintf("outpoint:%d", i))
+			continue
+		}
+		for _, data := range bloomElements(in.SignatureScript) {
+			if f.Contains(data) {
+				reasons = append(reasons, fmt.Sprintf("input_script:%d", i))
+				break
+			}
+		}
+	}
+	return reasons
+}
+
+func (f *BloomFilter) updates(pkScript []byte) bool {
+	switch f.Flags {
+	case wire.BloomUpdateAll:
+		return true
+	case wire.BloomUpdateP2PubkeyOnly:
+		class := txscript.GetScriptClass(pkScript)
+		return class == txscript.PubKeyTy || class == txscript.MultiSigTy
+	}
+	return false
+}
+
+func serializeOutPoint(op wire.OutPoint) []byte {
+	var buf bytes.Buffer
+	buf.Write(op.Hash[:])
+	binary.Write(&buf, binary.LittleEndian, op.Index)
+	return buf.Bytes()
+}
+
+// bloomElements returns the non-empty data pushes of a script, stopping at
+// the first malformed opcode as Core does
+func bloomElements(script []byte) [][]byte {
+	var pushes [][]byte
+	tok := txscript.MakeScriptTokenizer(0, script)
+	for tok.Next() {
+		if data := tok.Data(); len(data) > 0 {
+			pushes = append(pushes, data)
+		}
+	}
+	return pushes
+}
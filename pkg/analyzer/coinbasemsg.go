@@ -0,0 +1,56 @@
+package analyzer
+
+import (
+	"encoding/hex"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+// minMessageRun is the shortest printable run reported as part of a coinbase
+// message; shorter runs are mostly chance bytes in extranonces and hashes
+const minMessageRun = 4
+
+// CoinbaseMessage extracts the human-readable parts of a coinbase scriptSig:
+// runs of printable UTF-8 of at least minMessageRun bytes, starting after
+// the first skip bytes (the BIP34 height push). The message joins the runs
+// with spaces; each run is also returned with its offset and raw hex.
+func CoinbaseMessage(scriptSig []byte, skip int) (string, []types.CoinbaseMessageSegment) {
+	segments := make([]types.CoinbaseMessageSegment, 0)
+	if skip > len(scriptSig) {
+		return "", segments
+	}
+
+	flush := func(start, end int) {
+		run := scriptSig[start:end]
+		if len(run) < minMessageRun || strings.TrimSpace(string(run)) == "" {
+			return
+		}
+		segments = append(segments, types.CoinbaseMessageSegment{
+			Offset: start,
+			Hex:    hex.EncodeToString(run),
+			Text:   string(run),
+		})
+	}
+
+	start := skip
+	for i := skip; i < len(scriptSig); {
+		r, size := utf8.DecodeRune(scriptSig[i:])
+		if (r == utf8.RuneError && size <= 1) || !unicode.IsPrint(r) {
+			flush(start, i)
+			i += max(size, 1)
+			start = i
+			continue
+		}
+		i += size
+	}
+	flush(start, len(scriptSig))
+
+	texts := make([]string, len(segments))
+	for i, s := range segments {
+		texts[i] = s.Text
+	}
+	return strings.Join(texts, " "), segments
+}
@@ -0,0 +1,139 @@
+package analyzer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// Kinds of coinbase scriptSig layout parts
+const (
+	CoinbasePartHeight     = "height"
+	CoinbasePartCommitment = "commitment"
+	CoinbasePartTimestamp  = "timestamp"
+	CoinbasePartExtranonce = "extranonce"
+	CoinbasePartText       = "text"
+	CoinbasePartData       = "data"
+)
+
+// maxCoinbaseTimeDrift is how far from the block time a pushed number may
+// lie to be taken as a timestamp. Pools stamp the time the job was built,
+// which is rarely more than minutes from the header time.
+const maxCoinbaseTimeDrift = 24 * 60 * 60
+
+// coinbaseTimeUnits are the resolutions tried for 8-byte timestamps, as
+// divisors to seconds
+var coinbaseTimeUnits = []struct {
+	name    string
+	divisor uint64
+}{{"s", 1}, {"ms", 1e3}, {"us", 1e6}, {"ns", 1e9}}
+
+// FindCoinbaseTimestamps finds the Unix times pools push in a coinbase
+// scriptSig after the first skip bytes (the BIP34 height push): 4-byte
+// pushes of little-endian seconds and 8-byte pushes of seconds,
+// milliseconds, microseconds or nanoseconds, within a day of blockTime.
+// Pushes are read until the script stops parsing, as raw tag bytes often
+// make it do.
+func FindCoinbaseTimestamps(scriptSig []byte, skip int, blockTime uint32) []types.CoinbaseTimestamp {
+	timestamps := make([]types.CoinbaseTimestamp, 0)
+	if skip >= len(scriptSig) {
+		return timestamps
+	}
+	tokenizer := txscript.MakeScriptTokenizer(0, scriptSig[skip:])
+	for tokenizer.Next() {
+		data := tokenizer.Data()
+		offset := skip + int(tokenizer.ByteIndex()) - len(data)
+		var unit string
+		var unix int64
+		switch len(data) {
+		case 4:
+			unit, unix = "s", int64(binary.LittleEndian.Uint32(data))
+		case 8:
+			v := binary.LittleEndian.Uint64(data)
+			for _, u := range coinbaseTimeUnits {
+				if secs := int64(v / u.divisor); abs(secs-int64(blockTime)) <= maxCoinbaseTimeDrift {
+					unit, unix = u.name, secs
+					break
+				}
+			}
+		}
+		if unit == "" || abs(unix-int64(blockTime)) > maxCoinbaseTimeDrift {
+			continue
+		}
+		timestamps = append(timestamps, types.CoinbaseTimestamp{
+			Offset:    offset,
+			Length:    len(data),
+			Unit:      unit,
+			Unix:      unix,
+			DeltaSecs: unix - int64(blockTime),
+		})
+	}
+	return timestamps
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// CoinbaseLayout splits a coinbase scriptSig into consecutive parts by what
+// each byte was found to be: the height push (its first skip bytes),
+// merged-mining commitments, timestamps, the extranonce and message text,
+// in that order of precedence. Bytes none of these claim, push opcodes
+// among them, are "data" parts.
+func CoinbaseLayout(scriptSig []byte, skip int, segments []types.CoinbaseMessageSegment, extranonce *types.Extranonce, timestamps []types.CoinbaseTimestamp) []types.CoinbaseScriptPart {
+	kinds := make([]string, len(scriptSig))
+	claim := func(kind string, offset, length int) {
+		for i := max(offset, 0); i < offset+length && i < len(kinds); i++ {
+			if kinds[i] == "" {
+				kinds[i] = kind
+			}
+		}
+	}
+	claim(CoinbasePartHeight, 0, skip)
+	if i := bytes.Index(scriptSig, auxPoWMagic); i >= 0 {
+		claim(CoinbasePartCommitment, i, auxPoWCommitmentSize)
+	}
+	if i := bytes.Index(scriptSig, hathorMagic); i >= 0 && i+4+32 <= len(scriptSig) {
+		claim(CoinbasePartCommitment, i, 4+32)
+	}
+	for _, ts := range timestamps {
+		claim(CoinbasePartTimestamp, ts.Offset, ts.Length)
+	}
+	if extranonce != nil {
+		claim(CoinbasePartExtranonce, extranonce.Offset, extranonce.Length)
+	}
+	for _, seg := range segments {
+		claim(CoinbasePartText, seg.Offset, len(seg.Hex)/2)
+	}
+
+	parts := make([]types.CoinbaseScriptPart, 0)
+	for start := 0; start < len(scriptSig); {
+		kind := kinds[start]
+		end := start + 1
+		for end < len(scriptSig) && kinds[end] == kind {
+			end++
+		}
+		part := types.CoinbaseScriptPart{
+			Kind:   kind,
+			Offset: start,
+			Length: end - start,
+			Hex:    hex.EncodeToString(scriptSig[start:end]),
+		}
+		switch kind {
+		case "":
+			part.Kind = CoinbasePartData
+		case CoinbasePartText:
+			part.Text = string(scriptSig[start:end])
+		}
+		parts = append(parts, part)
+		start = end
+	}
+	return parts
+}
@@ -0,0 +1,125 @@
+package analyzer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+// Coinbase commitment types
+const (
+	CommitmentWitness = "witness" // BIP141 witness commitment
+	CommitmentRSK     = "rsk"     // Rootstock merged mining
+	CommitmentAuxPoW  = "auxpow"  // Namecoin-style merged mining (Namecoin, Dogecoin, Syscoin, Elastos, ...)
+	CommitmentHathor  = "hathor"  // Hathor merged mining
+	CommitmentCoreDAO = "coredao" // CoreDAO validator delegation
+	CommitmentStacks  = "stacks"  // Stacks leader block commit
+)
+
+var (
+	witnessCommitmentPrefix = []byte{0x6a, 0x24, 0xaa, 0x21, 0xa9, 0xed}
+	rskMagic                = []byte("RSKBLOCK:")
+	auxPoWMagic             = []byte{0xfa, 0xbe, 'm', 'm'}
+	hathorMagic             = []byte("Hath")
+	coreDAOMagic            = []byte("CORE")
+	stacksBlockCommitMagic  = []byte("X2[")
+)
+
+// FindCoinbaseCommitments scans a coinbase scriptSig and its output scripts
+// for commitments other chains and protocols place there. Only well-known
+// magic bytes are matched; each hit is reported with its location and the
+// committed hash where the format has one.
+func FindCoinbaseCommitments(scriptSig []byte, outputs [][]byte) []types.CoinbaseCommitment {
+	commitments := make([]types.CoinbaseCommitment, 0)
+
+	// AuxPoW: fabe6d6d <32-byte aux merkle root> <4-byte tree size> <4-byte nonce>
+	if i := bytes.Index(scriptSig, auxPoWMagic); i >= 0 && i+4+32 <= len(scriptSig) {
+		data := scriptSig[i+4:]
+		c := types.CoinbaseCommitment{
+			Type:     CommitmentAuxPoW,
+			Location: "script_sig",
+			Hash:     hex.EncodeToString(data[:32]),
+		}
+		if len(data) >= 40 {
+			c.Fields = map[string]uint32{
+				"merkle_size":  binary.LittleEndian.Uint32(data[32:36]),
+				"merkle_nonce": binary.LittleEndian.Uint32(data[36:40]),
+			}
+			data = data[:40]
+		} else {
+			data = data[:32]
+		}
+		c.DataHex = hex.EncodeToString(data)
+		commitments = append(commitments, c)
+	}
+
+	// Hathor: "Hath" <32-byte block hash>
+	if i := bytes.Index(scriptSig, hathorMagic); i >= 0 && i+4+32 <= len(scriptSig) {
+		hash := scriptSig[i+4 : i+4+32]
+		commitments = append(commitments, types.CoinbaseCommitment{
+			Type:     CommitmentHathor,
+			Location: "script_sig",
+			Hash:     hex.EncodeToString(hash),
+			DataHex:  hex.EncodeToString(hash),
+		})
+	}
+
+	for n, script := range outputs {
+		if len(script) == 0 || script[0] != 0x6a {
+			continue
+		}
+		vout := n
+		switch {
+		case len(script) >= 38 && bytes.HasPrefix(script, witnessCommitmentPrefix):
+			commitments = append(commitments, types.CoinbaseCommitment{
+				Type:     CommitmentWitness,
+				Location: "output",
+				Vout:     &vout,
+				Hash:     hex.EncodeToString(script[6:38]),
+				DataHex:  hex.EncodeToString(script[2:38]),
+			})
+			continue
+		}
+
+		data, _, _ := ParseOpReturn(script)
+		payload, _ := hex.DecodeString(data)
+		switch {
+		case bytes.Contains(payload, rskMagic):
+			i := bytes.Index(payload, rskMagic) + len(rskMagic)
+			c := types.CoinbaseCommitment{Type: CommitmentRSK, Location: "output", Vout: &vout, DataHex: hex.EncodeToString(payload)}
+			if i+32 <= len(payload) {
+				c.Hash = hex.EncodeToString(payload[i : i+32])
+			}
+			commitments = append(commitments, c)
+		case bytes.HasPrefix(payload, coreDAOMagic):
+			commitments = append(commitments, types.CoinbaseCommitment{
+				Type:     CommitmentCoreDAO,
+				Location: "output",
+				Vout:     &vout,
+				DataHex:  hex.EncodeToString(payload),
+			})
+		case bytes.HasPrefix(payload, stacksBlockCommitMagic):
+			commitments = append(commitments, types.CoinbaseCommitment{
+				Type:     CommitmentStacks,
+				Location: "output",
+				Vout:     &vout,
+				DataHex:  hex.EncodeToString(payload),
+			})
+		}
+	}
+	return commitments
+}
+
+// WitnessCommitment returns the BIP141 witness commitment among a coinbase's
+// output scripts. Consensus takes the last output matching the pattern, so
+// that is the one returned; ok is false when none matches.
+func WitnessCommitment(outputs [][]byte) (hash []byte, ok bool) {
+	for n := len(outputs) - 1; n >= 0; n-- {
+		if script := outputs[n]; len(script) >= 38 && bytes.HasPrefix(script, witnessCommitmentPrefix) {
+			return script[6:38], true
+		}
+	}
+	return nil, false
+}
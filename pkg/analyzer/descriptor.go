@@ -0,0 +1,114 @@
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// BIP380 descriptor checksum character sets.
+// Every descriptor character maps into descriptorInputCharset; its position
+// is split into a 5-bit symbol and a 2-bit group folded in every 3 characters.
+const (
+	descriptorInputCharset    = "0123456789()[],'/*abcdefgh@:$%{}IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+	descriptorChecksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	descriptorChecksumLen     = 8
+)
+
+// descriptorGenerator is the BCH code generator used by descriptorPolymod
+var descriptorGenerator = [5]uint64{0xf5dee51989, 0xa9fdca3312, 0x1bab10e32d, 0x3706b1677a, 0x644d626ffd}
+
+// DescriptorChecksum computes the 8-character BIP380 checksum of a descriptor.
+// The descriptor must not already carry a "#checksum" suffix.
+func DescriptorChecksum(desc string) (string, error) {
+	symbols, err := descriptorExpand(desc)
+	if err != nil {
+		return "", err
+	}
+	symbols = append(symbols, make([]uint64, descriptorChecksumLen)...)
+	c := descriptorPolymod(symbols) ^ 1
+
+	var sb strings.Builder
+	for i := 0; i < descriptorChecksumLen; i++ {
+		sb.WriteByte(descriptorChecksumCharset[(c>>(5*(7-i)))&31])
+	}
+	return sb.String(), nil
+}
+
+// AddDescriptorChecksum appends "#<checksum>" to a descriptor that lacks one.
+// A descriptor that already has a checksum is verified and returned unchanged.
+func AddDescriptorChecksum(desc string) (string, error) {
+	if strings.Contains(desc, "#") {
+		if err := VerifyDescriptorChecksum(desc); err != nil {
+			return "", err
+		}
+		return desc, nil
+	}
+	checksum, err := DescriptorChecksum(desc)
+	if err != nil {
+		return "", err
+	}
+	return desc + "#" + checksum, nil
+}
+
+// VerifyDescriptorChecksum checks the "#checksum" suffix of a descriptor.
+// Descriptors without a checksum are rejected, matching Bitcoin Core.
+func VerifyDescriptorChecksum(desc string) error {
+	idx := strings.LastIndexByte(desc, '#')
+	if idx < 0 {
+		return errors.New("descriptor is missing checksum")
+	}
+	body, checksum := desc[:idx], desc[idx+1:]
+	if len(checksum) != descriptorChecksumLen {
+		return fmt.Errorf("descriptor checksum has length %d, want %d", len(checksum), descriptorChecksumLen)
+	}
+
+	expected, err := DescriptorChecksum(body)
+	if err != nil {
+		return err
+	}
+	if checksum != expected {
+		return fmt.Errorf("descriptor checksum mismatch: got %s, expected %s", checksum, expected)
+	}
+	return nil
+}
+
+// descriptorExpand converts descriptor characters into checksum symbols
+func descriptorExpand(desc string) ([]uint64, error) {
+	symbols := make([]uint64, 0, len(desc)+len(desc)/3+1)
+	var groups []uint64
+	for i := 0; i < len(desc); i++ {
+		v := strings.IndexByte(descriptorInputCharset, desc[i])
+		if v < 0 {
+			return nil, fmt.Errorf("invalid descriptor character %q at position %d", desc[i], i)
+		}
+		symbols = append(symbols, uint64(v&31))
+		groups = append(groups, uint64(v>>5))
+		if len(groups) == 3 {
+			symbols = append(symbols, groups[0]*9+groups[1]*3+groups[2])
+			groups = groups[:0]
+		}
+	}
+	switch len(groups) {
+	case 1:
+		symbols = append(symbols, groups[0])
+	case 2:
+		symbols = append(symbols, groups[0]*3+groups[1])
+	}
+	return symbols, nil
+}
+
+// descriptorPolymod evaluates the BIP380 checksum polynomial
+func descriptorPolymod(symbols []uint64) uint64 {
+	c := uint64(1)
+	for _, v := range symbols {
+		top := c >> 35
+		c = (c&0x7ffffffff)<<5 ^ v
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 != 0 {
+				c ^= descriptorGenerator[i]
+			}
+		}
+	}
+	return c
+}
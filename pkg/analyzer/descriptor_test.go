@@ -0,0 +1,46 @@
+package analyzer
+
+import "testing"
+
+// BIP380's checksum test vectors
+func TestVerifyDescriptorChecksum(t *testing.T) {
+	for _, tt := range []struct {
+		desc  string
+		valid bool
+	}{
+		{"raw(deadbeef)#89f8spxm", true},
+		{"raw(deadbeef)", false},           // no checksum
+		{"raw(deadbeef)#", false},          // missing checksum
+		{"raw(deadbeef)#89f8spxmx", false}, // too long
+		{"raw(deadbeef)#89f8spx", false},   // too short
+		{"raw(deadbeef)#89f8spxx", false},  // error in checksum
+		{"raw(deedbeef)#89f8spxm", false},  // error in payload
+		{"raw(deadbeef)##9f8spxm", false},  // stray '#'
+		{"raw(Ü)#00000000", false},         // invalid character
+	} {
+		err := VerifyDescriptorChecksum(tt.desc)
+		if (err == nil) != tt.valid {
+			t.Errorf("VerifyDescriptorChecksum(%q) = %v, want valid=%v", tt.desc, err, tt.valid)
+		}
+	}
+}
+
+func TestAddDescriptorChecksum(t *testing.T) {
+	for _, tt := range []struct {
+		desc, want string
+	}{
+		{"raw(deadbeef)", "raw(deadbeef)#89f8spxm"},
+		{"raw(deadbeef)#89f8spxm", "raw(deadbeef)#89f8spxm"},
+	} {
+		got, err := AddDescriptorChecksum(tt.desc)
+		if err != nil || got != tt.want {
+			t.Errorf("AddDescriptorChecksum(%q) = %q, %v; want %q", tt.desc, got, err, tt.want)
+		}
+	}
+	if _, err := AddDescriptorChecksum("raw(deadbeef)#89f8spxx"); err == nil {
+		t.Error("AddDescriptorChecksum accepted a wrong checksum")
+	}
+	if _, err := DescriptorChecksum("raw(Ü)"); err == nil {
+		t.Error("DescriptorChecksum accepted a character outside the input charset")
+	}
+}
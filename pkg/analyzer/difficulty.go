@@ -0,0 +1,127 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// BIP9 reserves the top three version bits: 001 marks a header whose low 29
+// bits are deployment signals
+const (
+	versionBitsTopMask = 0xe0000000
+	versionBitsTopBits = 0x20000000
+	versionBitsNum     = 29
+)
+
+// TargetFromBits expands a compact nBits value into the 256-bit target,
+// formatted as 64 hex digits
+func TargetFromBits(bits uint32) string {
+	return fmt.Sprintf("%064x", blockchain.CompactToBig(bits))
+}
+
+// DifficultyFromBits returns the difficulty for nBits: the mainnet proof of
+// work limit in its compact form (0x1d00ffff, difficulty 1) divided by the
+// target
+func DifficultyFromBits(bits uint32) float64 {
+	target := blockchain.CompactToBig(bits)
+	if target.Sign() <= 0 {
+		return 0
+	}
+	diff := new(big.Float).Quo(
+		new(big.Float).SetInt(blockchain.CompactToBig(chaincfg.MainNetParams.PowLimitBits)),
+		new(big.Float).SetInt(target),
+	)
+	f, _ := diff.Float64()
+	return f
+}
+
+// ExpectedHashesFromBits returns the number of hashes needed on average to
+// find a block at this target, 2^256 / (target + 1), as a decimal string
+func ExpectedHashesFromBits(bits uint32) string {
+	return blockchain.CalcWork(bits).String()
+}
+
+// CheckProofOfWork reports whether the block hash, read as a little-endian
+// number, is at or below the target encoded by nBits
+func CheckProofOfWork(hash chainhash.Hash, bits uint32) bool {
+	target := blockchain.CompactToBig(bits)
+	if target.Sign() <= 0 {
+		return false
+	}
+	return blockchain.HashToBig(&hash).Cmp(target) <= 0
+}
+
+// SignalledVersionBits returns the BIP9 deployment bits set in a block
+// version, or nil when the version does not use the BIP9 top bits
+func SignalledVersionBits(version int32) []int {
+	v := uint32(version)
+	if v&versionBitsTopMask != versionBitsTopBits {
+		return nil
+	}
+	bits := make([]int, 0)
+	for i := 0; i < versionBitsNum; i++ {
+		if v&(1<<i) != 0 {
+			bits = append(bits, i)
+		}
+	}
+	return bits
+}
+
+// Difficulty adjustment parameters of mainnet (and testnet, whose
+// minimum-difficulty exceptions are not modeled)
+const (
+	RetargetInterval  = 2016
+	targetBlockSecs   = 600
+	maxRetargetFactor = 4
+)
+
+// EstimateRetarget projects the next difficulty adjustment from
+// consecutive headers. Only the headers of the current period count: those
+// after the last change of bits or, when firstHeight is given, after the
+// last period boundary. It returns nil when fewer than two headers remain
+// or their timestamps do not advance.
+func EstimateRetarget(headers []*wire.BlockHeader, firstHeight *int64) *types.RetargetEstimate {
+	if len(headers) == 0 {
+		return nil
+	}
+	last := len(headers) - 1
+	start := last
+	for start > 0 && headers[start-1].Bits == headers[last].Bits {
+		if firstHeight != nil && (*firstHeight+int64(start))%RetargetInterval == 0 {
+			break
+		}
+		start--
+	}
+	used := headers[start:]
+	elapsed := used[len(used)-1].Timestamp.Unix() - used[0].Timestamp.Unix()
+	if len(used) < 2 || elapsed <= 0 {
+		return nil
+	}
+
+	avg := float64(elapsed) / float64(len(used)-1)
+	factor := math.Max(1.0/maxRetargetFactor, math.Min(maxRetargetFactor, targetBlockSecs/avg))
+	current := DifficultyFromBits(headers[last].Bits)
+	est := &types.RetargetEstimate{
+		HeadersUsed:          len(used),
+		AvgBlockIntervalSecs: math.Round(avg*100) / 100,
+		CurrentDifficulty:    current,
+		EstimatedDifficulty:  current * factor,
+		ChangePercent:        math.Round((factor-1)*10000) / 100,
+	}
+	if firstHeight != nil {
+		height := *firstHeight + int64(last)
+		next := (height/RetargetInterval + 1) * RetargetInterval
+		remaining := next - height
+		at := headers[last].Timestamp.Unix() + int64(math.Round(float64(remaining)*avg))
+		est.NextRetargetHeight, est.BlocksUntilRetarget, est.EstimatedRetargetTime = &next, &remaining, &at
+	}
+	return est
+}
@@ -0,0 +1,128 @@
+package analyzer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"sync/atomic"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+// EnvelopeScanner configures data-envelope detection in tapscripts. An
+// envelope is OP_FALSE OP_IF <pushes...> OP_ENDIF: code that never runs and
+// exists only to carry data, as ordinals inscriptions and similar protocols
+// do. The first push is taken as the protocol marker.
+type EnvelopeScanner struct {
+	// Markers restricts detection to envelopes whose first push equals one
+	// of these byte strings; empty reports every envelope
+	Markers [][]byte
+	// MinBytes ignores envelopes carrying fewer data bytes than this
+	MinBytes int
+}
+
+var envelopeScanner atomic.Pointer[EnvelopeScanner]
+
+func init() {
+	SetEnvelopeScanner(EnvelopeScanner{})
+}
+
+// SetEnvelopeScanner replaces the process-wide envelope scanner settings
+func SetEnvelopeScanner(s EnvelopeScanner) {
+	envelopeScanner.Store(&s)
+}
+
+// FindEnvelopes returns the data envelopes in a tapscript that match the
+// current scanner settings. DataBytes counts all pushed bytes after the
+// marker.
+func FindEnvelopes(script []byte) []types.DataEnvelope {
+	s := envelopeScanner.Load()
+	var envelopes []types.DataEnvelope
+	for i := 0; i+1 < len(script); {
+		if script[i] != 0x00 || script[i+1] != 0x63 { // OP_FALSE OP_IF
+			i = skipOp(script, i)
+			continue
+		}
+		env, end, ok := readEnvelope(script, i+2)
+		i = end
+		if !ok || env.DataBytes < s.MinBytes || !markerAllowed(s.Markers, env.MarkerHex) {
+			continue
+		}
+		envelopes = append(envelopes, env)
+	}
+	return envelopes
+}
+
+// readEnvelope reads the pushes of an envelope body up to OP_ENDIF. ok is
+// false when a non-push opcode or the end of the script comes first.
+func readEnvelope(script []byte, pos int) (env types.DataEnvelope, end int, ok bool) {
+	first := true
+	for pos < len(script) {
+		op := script[pos]
+		if op == 0x68 { // OP_ENDIF
+			return env, pos + 1, true
+		}
+		data, next, isPush := readPush(script, pos)
+		if !isPush {
+			return env, next, false
+		}
+		if first {
+			env.MarkerHex = hex.EncodeToString(data)
+			first = false
+		} else {
+			env.DataBytes += len(data)
+		}
+		env.Pushes++
+		pos = next
+	}
+	return env, pos, false
+}
+
+// readPush decodes the push at pos; OP_0 and OP_1..OP_16 push small values
+func readPush(script []byte, pos int) (data []byte, next int, ok bool) {
+	op := script[pos]
+	pos++
+	var n int
+	switch {
+	case op == 0x00 || op == 0x4f || (op >= 0x51 && op <= 0x60):
+		return nil, pos, true
+	case op <= 0x4b:
+		n = int(op)
+	case op == 0x4c && pos < len(script):
+		n = int(script[pos])
+		pos++
+	case op == 0x4d && pos+2 <= len(script):
+		n = int(binary.LittleEndian.Uint16(script[pos:]))
+		pos += 2
+	case op == 0x4e && pos+4 <= len(script):
+		n = int(binary.LittleEndian.Uint32(script[pos:]))
+		pos += 4
+	default:
+		return nil, pos, false
+	}
+	if n > len(script)-pos {
+		return nil, len(script), false
+	}
+	return script[pos : pos+n], pos + n, true
+}
+
+// skipOp returns the position after the opcode (and its data) at pos
+func skipOp(script []byte, pos int) int {
+	if _, next, ok := readPush(script, pos); ok {
+		return next
+	}
+	return pos + 1
+}
+
+func markerAllowed(markers [][]byte, markerHex string) bool {
+	if len(markers) == 0 {
+		return true
+	}
+	marker, _ := hex.DecodeString(markerHex)
+	for _, m := range markers {
+		if bytes.Equal(m, marker) {
+			return true
+		}
+	}
+	return false
+}
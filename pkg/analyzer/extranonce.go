@@ -0,0 +1,74 @@
+package analyzer
+
+import (
+	"bytes"
+	"encoding/hex"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+// Extranonce layouts
+const (
+	ExtranoncePushed   = "pushed"   // its own push right after the height push
+	ExtranonceTrailing = "trailing" // raw bytes after the pool tag and commitments
+)
+
+// auxPoWCommitmentSize is magic (4) + aux merkle root (32) + tree size (4) + nonce (4)
+const auxPoWCommitmentSize = 44
+
+// FindExtranonce locates the extranonce region of a coinbase scriptSig.
+// skip is the length of the height push and segments the message runs
+// from CoinbaseMessage. Pools either push the extranonce right after the
+// height or append it after their tag (and any AuxPoW commitment); the
+// first layout found wins. Regions of 8, 12 or 16 bytes are also split the
+// way stratum pools usually size extranonce1 and extranonce2. Returns nil
+// when neither layout matches.
+func FindExtranonce(scriptSig []byte, skip int, segments []types.CoinbaseMessageSegment) *types.Extranonce {
+	if skip >= len(scriptSig) {
+		return nil
+	}
+
+	// A 4-16 byte push holding no text (a pushed pool tag is not a nonce)
+	if n := int(scriptSig[skip]); n >= 4 && n <= 16 && skip+1+n <= len(scriptSig) {
+		data := scriptSig[skip+1 : skip+1+n]
+		if _, text := CoinbaseMessage(data, 0); len(text) == 0 {
+			return newExtranonce(ExtranoncePushed, skip+1, data)
+		}
+	}
+
+	// Otherwise whatever follows the last tag or commitment
+	end := skip
+	if len(segments) > 0 {
+		last := segments[len(segments)-1]
+		end = max(end, last.Offset+len(last.Hex)/2)
+	}
+	if i := bytes.LastIndex(scriptSig, auxPoWMagic); i >= 0 {
+		end = max(end, i+auxPoWCommitmentSize)
+	}
+	if tail := len(scriptSig) - end; tail >= 4 && tail <= 32 {
+		return newExtranonce(ExtranonceTrailing, end, scriptSig[end:])
+	}
+	return nil
+}
+
+func newExtranonce(layout string, offset int, data []byte) *types.Extranonce {
+	e := &types.Extranonce{
+		Layout: layout,
+		Offset: offset,
+		Length: len(data),
+		Hex:    hex.EncodeToString(data),
+	}
+	// Common stratum sizes: extranonce1 is 4 bytes (8 for 16-byte regions)
+	split := 0
+	switch len(data) {
+	case 8, 12:
+		split = 4
+	case 16:
+		split = 8
+	}
+	if split > 0 {
+		e.Extranonce1Hex = hex.EncodeToString(data[:split])
+		e.Extranonce2Hex = hex.EncodeToString(data[split:])
+	}
+	return e
+}
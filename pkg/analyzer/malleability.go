@@ -0,0 +1,252 @@
+package analyzer
+
+import (
+	"encoding/binary"
+	"math/big"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+	btcec "github.com/btcsuite/btcd/btcec/v2"
+)
+
+// Malleability vectors reported per input
+const (
+	// Any scriptSig push can be re-encoded (OP_PUSHDATA1 for a short push)
+	// and dummy pushes can be prepended; neither is covered by a signature
+	MalleabilityScriptSigPush = "scriptsig_push"
+	// The scriptSig runs opcodes other than pushes, so e.g. OP_NOP can be added
+	MalleabilityNonPushScriptSig = "non_push_scriptsig"
+	// A push is already encoded non-minimally
+	MalleabilityNonMinimalPush = "non_minimal_push"
+	// An ECDSA signature whose S can be replaced by n-S
+	MalleabilitySignatureSFlip = "signature_s_flip"
+	// A signature is already in its high-S form
+	MalleabilityHighS = "high_s"
+	// Signatures that leave inputs or outputs uncommitted
+	MalleabilitySighashNone         = "sighash_none"
+	MalleabilitySighashSingle       = "sighash_single"
+	MalleabilitySighashAnyoneCanPay = "sighash_anyonecanpay"
+)
+
+// Sighash base types and flag
+const (
+	sighashNone         = 0x02
+	sighashSingle       = 0x03
+	sighashAnyoneCanPay = 0x80
+)
+
+// secp256k1 half order; a signature with S above it is high-S
+var halfOrder = new(big.Int).Rsh(btcec.S256().N, 1)
+
+// InputSigning is what malleability analysis needs to know about one input
+type InputSigning struct {
+	ScriptSig  []byte
+	Witness    [][]byte
+	ScriptType string
+	Coinbase   bool
+}
+
+// AnalyzeMalleability reports which malleability vectors apply to each input
+// and whether a third party could change the txid before confirmation.
+//
+// Legacy scriptSigs are not covered by any signature, so every non-empty
+// one can be re-encoded and every ECDSA signature in it S-flipped. Witness
+// data is not part of the txid, so segwit inputs are only affected by their
+// sighash flags. Those matter to the txid when every signed input permits
+// the same change: all ANYONECANPAY lets anyone add an input, and all
+// NONE/SINGLE lets anyone add or change outputs.
+func AnalyzeMalleability(inputs []InputSigning) *types.MalleabilityReport {
+	report := &types.MalleabilityReport{Inputs: make([]types.InputMalleability, 0, len(inputs))}
+	signed, allACP, allUncommittedOutputs := 0, true, true
+
+	for i, in := range inputs {
+		m := types.InputMalleability{
+			Vin:          i,
+			Segwit:       len(in.Witness) > 0,
+			Vectors:      make([]string, 0),
+			SighashTypes: make([]string, 0),
+		}
+		if in.Coinbase {
+			report.Inputs = append(report.Inputs, m)
+			continue
+		}
+
+		var sighashes []byte
+		if !m.Segwit && len(in.ScriptSig) > 0 {
+			pushes, pushOnly, minimal := scriptPushes(in.ScriptSig)
+			m.Vectors = append(m.Vectors, MalleabilityScriptSigPush)
+			if !pushOnly {
+				m.Vectors = append(m.Vectors, MalleabilityNonPushScriptSig)
+			}
+			if !minimal {
+				m.Vectors = append(m.Vectors, MalleabilityNonMinimalPush)
+			}
+			highS := false
+			for _, data := range pushes {
+				if !isDERSignature(data) {
+					continue
+				}
+				sighashes = append(sighashes, data[len(data)-1])
+				if isHighS(data) {
+					highS = true
+				}
+			}
+			if len(sighashes) > 0 {
+				m.Vectors = append(m.Vectors, MalleabilitySignatureSFlip)
+			}
+			if highS {
+				m.Vectors = append(m.Vectors, MalleabilityHighS)
+			}
+			report.TxidMalleable = true
+		} else if m.Segwit {
+			sighashes = witnessSighashes(in.Witness, in.ScriptType)
+		}
+
+		// An input permits a change only if all of its signatures do
+		inputACP, inputOutputs := len(sighashes) > 0, len(sighashes) > 0
+		seen := make(map[string]bool)
+		for _, h := range sighashes {
+			name := SighashTypeName(h)
+			if !seen[name] {
+				seen[name] = true
+				m.SighashTypes = append(m.SighashTypes, name)
+			}
+			inputACP = inputACP && h&sighashAnyoneCanPay != 0
+			base := h & 0x1f
+			inputOutputs = inputOutputs && (base == sighashNone || base == sighashSingle)
+		}
+		for _, h := range sighashes {
+			if h&sighashAnyoneCanPay != 0 {
+				m.Vectors = appendOnce(m.Vectors, MalleabilitySighashAnyoneCanPay)
+			}
+			switch h & 0x1f {
+			case sighashNone:
+				m.Vectors = appendOnce(m.Vectors, MalleabilitySighashNone)
+			case sighashSingle:
+				m.Vectors = appendOnce(m.Vectors, MalleabilitySighashSingle)
+			}
+		}
+		if len(sighashes) > 0 {
+			signed++
+			allACP = allACP && inputACP
+			allUncommittedOutputs = allUncommittedOutputs && inputOutputs
+		}
+		report.Inputs = append(report.Inputs, m)
+	}
+
+	if signed > 0 && (allACP || allUncommittedOutputs) {
+		report.TxidMalleable = true
+	}
+	return report
+}
+
+// scriptPushes returns the data pushed by a script, whether it consists of
+// pushes only, and whether every push uses its minimal encoding
+func scriptPushes(script []byte) (pushes [][]byte, pushOnly, minimal bool) {
+	pushOnly, minimal = true, true
+	for i := 0; i < len(script); {
+		op := script[i]
+		i++
+		var n int
+		switch {
+		case op == 0x00:
+			pushes = append(pushes, []byte{})
+			continue
+		case op <= 0x4b:
+			n = int(op)
+		case op == 0x4c && i < len(script):
+			n = int(script[i])
+			i++
+		case op == 0x4d && i+2 <= len(script):
+			n = int(binary.LittleEndian.Uint16(script[i:]))
+			i += 2
+		case op == 0x4e && i+4 <= len(script):
+			n = int(binary.LittleEndian.Uint32(script[i:]))
+			i += 4
+		case op == 0x4f || (op >= 0x51 && op <= 0x60): // OP_1NEGATE, OP_1..OP_16
+			continue
+		default:
+			pushOnly = false
+			continue
+		}
+		if n > len(script)-i {
+			return pushes, false, minimal
+		}
+		data := script[i : i+n]
+		if !isMinimalPush(op, data) {
+			minimal = false
+		}
+		pushes = append(pushes, data)
+		i += n
+	}
+	return pushes, pushOnly, minimal
+}
+
+// isMinimalPush applies the BIP62 minimal push rules to a data push
+func isMinimalPush(op byte, data []byte) bool {
+	switch n := len(data); {
+	case n == 0:
+		return op == 0x00
+	case n == 1 && data[0] >= 1 && data[0] <= 16:
+		return false // OP_1..OP_16
+	case n == 1 && data[0] == 0x81:
+		return false // OP_1NEGATE
+	case n <= 0x4b:
+		return int(op) == n
+	case n <= 0xff:
+		return op == 0x4c
+	case n <= 0xffff:
+		return op == 0x4d
+	}
+	return true
+}
+
+// isHighS reports whether a DER signature (with trailing sighash byte) has
+// S above half the curve order
+func isHighS(sig []byte) bool {
+	rLen := int(sig[3])
+	sLen := int(sig[5+rLen])
+	s := new(big.Int).SetBytes(sig[6+rLen : 6+rLen+sLen])
+	return s.Cmp(halfOrder) > 0
+}
+
+// witnessSighashes returns the sighash bytes of the signatures in a witness:
+// DER items for segwit v0, and for taproot the key-path signature or the
+// 64/65-byte stack items below the script and control block. A 64-byte
+// schnorr signature means SIGHASH_DEFAULT, which commits like SIGHASH_ALL.
+func witnessSighashes(witness [][]byte, scriptType string) []byte {
+	var sighashes []byte
+	schnorr := func(sig []byte) {
+		switch len(sig) {
+		case 64:
+			sighashes = append(sighashes, 0x01)
+		case 65:
+			sighashes = append(sighashes, sig[64])
+		}
+	}
+	switch scriptType {
+	case "p2tr_keypath":
+		schnorr(witness[0])
+		return sighashes
+	case "p2tr_scriptpath":
+		for _, item := range witness[:len(witness)-2] {
+			schnorr(item)
+		}
+		return sighashes
+	}
+	for _, item := range witness {
+		if isDERSignature(item) {
+			sighashes = append(sighashes, item[len(item)-1])
+		}
+	}
+	return sighashes
+}
+
+func appendOnce(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,68 @@
+package analyzer
+
+import (
+	"math"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+// SummarizeMempool totals the entries of a mempool.dat file. Fee rates are
+// those of the transactions alone; fee deltas only count the entries that
+// have one, since they change mining priority and not what is paid.
+func SummarizeMempool(entries []types.MempoolEntry) types.MempoolStats {
+	s := types.MempoolStats{TxCount: len(entries), ScriptTypeTotals: make(map[string]int)}
+	inMempool := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		if e.Tx.OK {
+			inMempool[e.Tx.Txid] = true
+		}
+	}
+
+	feeRates := make(map[int64]int)
+	var feeWeight int64
+	for _, e := range entries {
+		if s.FirstTime == 0 || e.Time < s.FirstTime {
+			s.FirstTime = e.Time
+		}
+		if e.Time > s.LastTime {
+			s.LastTime = e.Time
+		}
+		if e.FeeDeltaSats != 0 {
+			s.PrioritisedTxs++
+		}
+		tx := e.Tx
+		if !tx.OK {
+			s.InvalidTxs++
+			continue
+		}
+		s.TotalVbytes += int64(tx.Vbytes)
+		s.TotalWeight += int64(tx.Weight)
+		if tx.Segwit {
+			s.SegwitTxs++
+		}
+		if tx.RbfSignaling {
+			s.RbfSignalingTxs++
+		}
+		for _, t := range tx.VoutScriptTypes {
+			s.ScriptTypeTotals[t]++
+		}
+		for _, in := range tx.Vin {
+			if inMempool[in.Txid] {
+				s.ChildTxs++
+				break
+			}
+		}
+		if tx.FeeSats != nil && tx.FeeRateSatVb != nil {
+			s.FeeKnownTxs++
+			s.TotalFeesSats += *tx.FeeSats
+			feeWeight += int64(tx.Weight)
+			feeRates[int64(math.Round(*tx.FeeRateSatVb*10))]++
+		}
+	}
+	if feeWeight > 0 {
+		vbytes := (feeWeight + 3) / 4
+		s.AvgFeeRateSatVb = math.Round(float64(s.TotalFeesSats)/float64(vbytes)*100) / 100
+	}
+	s.FeeRates = feeRateDistribution(feeRates)
+	return s
+}
@@ -0,0 +1,81 @@
+package analyzer
+
+import (
+	"encoding/hex"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// Which script of an input holds its multisig template
+const (
+	MultisigFromPrevout       = "prevout" // bare multisig
+	MultisigFromRedeemScript  = "redeem_script"
+	MultisigFromWitnessScript = "witness_script"
+)
+
+// ParseMultisig decodes an m-of-n template, <m> <key>... <n>
+// OP_CHECKMULTISIG, as Core's Solver recognizes it: 1 <= m <= n <= 16 and
+// every key shaped like a public key. Returns nil for any other script.
+func ParseMultisig(script []byte) *types.MultisigInfo {
+	if len(script) < 3 || script[len(script)-1] != txscript.OP_CHECKMULTISIG {
+		return nil
+	}
+	tok := txscript.MakeScriptTokenizer(0, script)
+	if !tok.Next() || !txscript.IsSmallInt(tok.Opcode()) {
+		return nil
+	}
+	required := txscript.AsSmallInt(tok.Opcode())
+
+	var keys []string
+	for tok.Next() && !txscript.IsSmallInt(tok.Opcode()) {
+		if _, ok := pubkeyInfo("", tok.Data()); !ok {
+			return nil
+		}
+		keys = append(keys, hex.EncodeToString(tok.Data()))
+	}
+	if tok.Done() || txscript.AsSmallInt(tok.Opcode()) != len(keys) {
+		return nil
+	}
+	// Only the OP_CHECKMULTISIG checked above may follow
+	if int(tok.ByteIndex()) != len(script)-1 {
+		return nil
+	}
+	if required < 1 || required > len(keys) {
+		return nil
+	}
+	return &types.MultisigInfo{Required: required, Total: len(keys), Pubkeys: keys}
+}
+
+// InputMultisig finds the multisig template an input spends: its prevout
+// script, the redeem script its scriptSig pushes last, or the witness
+// script its witness ends with, whichever the prevout calls for. With the
+// prevout missing, the redeem and witness scripts are tried on their
+// shape alone. Returns nil when none is a multisig template.
+func InputMultisig(scriptSig []byte, witness [][]byte, prevoutScript []byte) *types.MultisigInfo {
+	if ms := ParseMultisig(prevoutScript); ms != nil {
+		ms.Source = MultisigFromPrevout
+		return ms
+	}
+	known := len(prevoutScript) > 0
+
+	program := prevoutScript
+	if pushes, pushOnly, _ := scriptPushes(scriptSig); pushOnly && len(pushes) > 0 &&
+		(!known || txscript.IsPayToScriptHash(prevoutScript)) {
+		redeem := pushes[len(pushes)-1]
+		if ms := ParseMultisig(redeem); ms != nil {
+			ms.Source = MultisigFromRedeemScript
+			return ms
+		}
+		program = redeem
+	}
+
+	if len(witness) > 0 && (!known || txscript.IsPayToWitnessScriptHash(program)) {
+		if ms := ParseMultisig(witness[len(witness)-1]); ms != nil {
+			ms.Source = MultisigFromWitnessScript
+			return ms
+		}
+	}
+	return nil
+}
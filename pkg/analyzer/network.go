@@ -0,0 +1,80 @@
+package analyzer
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Network names accepted in fixtures and reported in output
+const (
+	NetworkMainnet  = "mainnet"
+	NetworkTestnet  = "testnet"
+	NetworkTestnet4 = "testnet4"
+	NetworkSignet   = "signet"
+)
+
+// GetNetworkParams resolves a fixture network name to its chain parameters.
+// Unrecognized names fall back to testnet3, matching the historical behavior
+// of treating anything that is not "mainnet" as a test network.
+func GetNetworkParams(network string) *chaincfg.Params {
+	switch network {
+	case NetworkMainnet:
+		return &chaincfg.MainNetParams
+	case NetworkTestnet4:
+		return &chaincfg.TestNet4Params
+	case NetworkSignet:
+		return &chaincfg.SigNetParams
+	default:
+		return &chaincfg.TestNet3Params
+	}
+}
+
+// NetworkFromMagic maps the 4-byte message start that precedes each block in
+// blk*.dat to a network name. Returns false for an unrecognized magic.
+//
+// On disk the magic is stored as raw bytes (e.g. f9 be b4 d9 for mainnet),
+// which is the little-endian encoding of wire.BitcoinNet.
+func NetworkFromMagic(magic [4]byte) (string, bool) {
+	switch wire.BitcoinNet(binary.LittleEndian.Uint32(magic[:])) {
+	case wire.MainNet:
+		return NetworkMainnet, true
+	case wire.TestNet3:
+		return NetworkTestnet, true
+	case wire.TestNet4:
+		return NetworkTestnet4, true
+	case wire.SigNet:
+		return NetworkSignet, true
+	}
+	return "", false
+}
+
+// GetSignetParams builds chain parameters for a custom (private) signet from
+// its block challenge script. Signets share the testnet address prefixes, so
+// only the network magic differs from the default signet.
+func GetSignetParams(challenge []byte) *chaincfg.Params {
+	params := chaincfg.CustomSignetParams(challenge, nil)
+	return &params
+}
+
+// SignetMagic returns the on-disk blk*.dat magic for a signet challenge:
+// the first four bytes of sha256d(len(challenge) || challenge).
+func SignetMagic(challenge []byte) [4]byte {
+	var magic [4]byte
+	binary.LittleEndian.PutUint32(magic[:], uint32(GetSignetParams(challenge).Net))
+	return magic
+}
+
+// ParseMagic decodes a 4-byte network magic given as hex (e.g. "0a03cf40")
+func ParseMagic(magicHex string) ([4]byte, error) {
+	var magic [4]byte
+	b, err := hex.DecodeString(magicHex)
+	if err != nil || len(b) != 4 {
+		return magic, fmt.Errorf("invalid network magic %q: want 4 bytes of hex", magicHex)
+	}
+	copy(magic[:], b)
+	return magic, nil
+}
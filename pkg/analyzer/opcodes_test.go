@@ -0,0 +1,115 @@
+package analyzer
+
+import (
+	"fmt"
+	"testing"
+)
+
+// coreOpNames is what Bitcoin Core's GetOpName (script/script.cpp) returns
+// for each opcode it names; every other byte is "OP_UNKNOWN"
+var coreOpNames = map[byte]string{
+	0x00: "0",
+	0x4c: "OP_PUSHDATA1", 0x4d: "OP_PUSHDATA2", 0x4e: "OP_PUSHDATA4",
+	0x4f: "-1", 0x50: "OP_RESERVED",
+	0x51: "1", 0x52: "2", 0x53: "3", 0x54: "4", 0x55: "5", 0x56: "6", 0x57: "7", 0x58: "8",
+	0x59: "9", 0x5a: "10", 0x5b: "11", 0x5c: "12", 0x5d: "13", 0x5e: "14", 0x5f: "15", 0x60: "16",
+
+	0x61: "OP_NOP", 0x62: "OP_VER", 0x63: "OP_IF", 0x64: "OP_NOTIF", 0x65: "OP_VERIF",
+	0x66: "OP_VERNOTIF", 0x67: "OP_ELSE", 0x68: "OP_ENDIF", 0x69: "OP_VERIFY", 0x6a: "OP_RETURN",
+
+	0x6b: "OP_TOALTSTACK", 0x6c: "OP_FROMALTSTACK", 0x6d: "OP_2DROP", 0x6e: "OP_2DUP",
+	0x6f: "OP_3DUP", 0x70: "OP_2OVER", 0x71: "OP_2ROT", 0x72: "OP_2SWAP", 0x73: "OP_IFDUP",
+	0x74: "OP_DEPTH", 0x75: "OP_DROP", 0x76: "OP_DUP", 0x77: "OP_NIP", 0x78: "OP_OVER",
+	0x79: "OP_PICK", 0x7a: "OP_ROLL", 0x7b: "OP_ROT", 0x7c: "OP_SWAP", 0x7d: "OP_TUCK",
+
+	0x7e: "OP_CAT", 0x7f: "OP_SUBSTR", 0x80: "OP_LEFT", 0x81: "OP_RIGHT", 0x82: "OP_SIZE",
+
+	0x83: "OP_INVERT", 0x84: "OP_AND", 0x85: "OP_OR", 0x86: "OP_XOR", 0x87: "OP_EQUAL",
+	0x88: "OP_EQUALVERIFY", 0x89: "OP_RESERVED1", 0x8a: "OP_RESERVED2",
+
+	0x8b: "OP_1ADD", 0x8c: "OP_1SUB", 0x8d: "OP_2MUL", 0x8e: "OP_2DIV", 0x8f: "OP_NEGATE",
+	0x90: "OP_ABS", 0x91: "OP_NOT", 0x92: "OP_0NOTEQUAL", 0x93: "OP_ADD", 0x94: "OP_SUB",
+	0x95: "OP_MUL", 0x96: "OP_DIV", 0x97: "OP_MOD", 0x98: "OP_LSHIFT", 0x99: "OP_RSHIFT",
+	0x9a: "OP_BOOLAND", 0x9b: "OP_BOOLOR", 0x9c: "OP_NUMEQUAL", 0x9d: "OP_NUMEQUALVERIFY",
+	0x9e: "OP_NUMNOTEQUAL", 0x9f: "OP_LESSTHAN", 0xa0: "OP_GREATERTHAN",
+	0xa1: "OP_LESSTHANOREQUAL", 0xa2: "OP_GREATERTHANOREQUAL", 0xa3: "OP_MIN", 0xa4: "OP_MAX",
+	0xa5: "OP_WITHIN",
+
+	0xa6: "OP_RIPEMD160", 0xa7: "OP_SHA1", 0xa8: "OP_SHA256", 0xa9: "OP_HASH160",
+	0xaa: "OP_HASH256", 0xab: "OP_CODESEPARATOR", 0xac: "OP_CHECKSIG",
+	0xad: "OP_CHECKSIGVERIFY", 0xae: "OP_CHECKMULTISIG", 0xaf: "OP_CHECKMULTISIGVERIFY",
+
+	0xb0: "OP_NOP1", 0xb1: "OP_CHECKLOCKTIMEVERIFY", 0xb2: "OP_CHECKSEQUENCEVERIFY",
+	0xb3: "OP_NOP4", 0xb4: "OP_NOP5", 0xb5: "OP_NOP6", 0xb6: "OP_NOP7", 0xb7: "OP_NOP8",
+	0xb8: "OP_NOP9", 0xb9: "OP_NOP10",
+
+	0xba: "OP_CHECKSIGADD",
+
+	0xff: "OP_INVALIDOPCODE",
+}
+
+// expectedOpName is Core's name for op in this package's spelling: small
+// integers as OP_<n>, pushes as OP_PUSHBYTES_<n> and unassigned bytes as
+// OP_UNKNOWN_0x<nn>. 0xfd and 0xfe keep the template names Core dropped
+// and btcd still uses.
+func expectedOpName(op byte) string {
+	switch {
+	case op == 0x00:
+		return "OP_0"
+	case op >= 0x01 && op <= 0x4b:
+		return fmt.Sprintf("OP_PUSHBYTES_%d", op)
+	case op == 0x4f:
+		return "OP_1NEGATE"
+	case op >= 0x51 && op <= 0x60:
+		return "OP_" + coreOpNames[op]
+	case op == 0xfd:
+		return "OP_PUBKEYHASH"
+	case op == 0xfe:
+		return "OP_PUBKEY"
+	}
+	if name, ok := coreOpNames[op]; ok {
+		return name
+	}
+	return fmt.Sprintf("OP_UNKNOWN_0x%02x", op)
+}
+
+func TestOpcodeNamesMatchCore(t *testing.T) {
+	for i := 0; i < 256; i++ {
+		op := byte(i)
+		if got, want := opcodeToName(op), expectedOpName(op); got != want {
+			t.Errorf("opcode 0x%02x: got %s, want %s", op, got, want)
+		}
+	}
+}
+
+func TestOpcodeByNameRoundTrip(t *testing.T) {
+	for i := 0; i < 256; i++ {
+		op := byte(i)
+		got, ok := OpcodeByName(opcodeToName(op))
+		if !ok || got != op {
+			t.Errorf("OpcodeByName(%s) = 0x%02x, %v; want 0x%02x", opcodeToName(op), got, ok, op)
+		}
+	}
+
+	for _, tt := range []struct {
+		name string
+		op   byte
+		ok   bool
+	}{
+		{"OP_FALSE", 0x00, true},
+		{"OP_TRUE", 0x51, true},
+		{"OP_NOP2", 0xb1, true},
+		{"OP_NOP3", 0xb2, true},
+		{"OP_CHECKSIG", 0xac, true},
+		{"OP_UNKNOWN_0xbb", 0xbb, true},
+		{"OP_PUSHBYTES_20", 0x14, true},
+		{"OP_CHECKSIGX", 0, false},
+		{"op_checksig", 0, false},
+		{"", 0, false},
+	} {
+		op, ok := OpcodeByName(tt.name)
+		if ok != tt.ok || op != tt.op {
+			t.Errorf("OpcodeByName(%q) = 0x%02x, %v; want 0x%02x, %v", tt.name, op, ok, tt.op, tt.ok)
+		}
+	}
+}
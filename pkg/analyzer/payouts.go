@@ -0,0 +1,65 @@
+package analyzer
+
+import (
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+// Coinbase payout patterns
+const (
+	PayoutNone   = "none"        // no value-bearing outputs (all fees burned or unclaimed)
+	PayoutSingle = "single"      // one output takes the whole reward: a pool's own address
+	PayoutSplit  = "split"       // a few outputs, e.g. a pool plus a partner or fee address
+	PayoutMass   = "mass_payout" // many outputs paying miners directly, P2Pool style
+)
+
+// massPayoutMin is the number of paying outputs from which a coinbase is
+// taken to pay miners directly rather than a pool wallet
+const massPayoutMin = 10
+
+// AnalyzeCoinbasePayouts describes the payout structure of a coinbase from
+// its analyzed outputs: how many outputs carry value, which address takes
+// the largest share, the script types paid, and the OP_RETURN outputs
+// (commitments) alongside.
+func AnalyzeCoinbasePayouts(vout []types.Output) *types.CoinbasePayouts {
+	p := &types.CoinbasePayouts{ScriptTypes: make(map[string]int)}
+	var total, largest int64
+	for _, out := range vout {
+		if out.ScriptType == "op_return" {
+			p.OpReturnCount++
+			continue
+		}
+		if out.ValueSats == 0 {
+			continue
+		}
+		p.PayoutCount++
+		p.ScriptTypes[out.ScriptType]++
+		total += out.ValueSats
+		switch out.ScriptType {
+		case "p2wpkh", "p2wsh":
+			p.SegwitPayout = true
+		case "p2tr":
+			p.SegwitPayout = true
+			p.TaprootPayout = true
+		}
+		if out.ValueSats > largest {
+			largest = out.ValueSats
+			p.PrimaryAddress = out.Address
+			p.PrimaryScriptType = out.ScriptType
+		}
+	}
+
+	switch {
+	case p.PayoutCount == 0:
+		p.Pattern = PayoutNone
+	case p.PayoutCount == 1:
+		p.Pattern = PayoutSingle
+	case p.PayoutCount >= massPayoutMin:
+		p.Pattern = PayoutMass
+	default:
+		p.Pattern = PayoutSplit
+	}
+	if total > 0 {
+		p.PrimaryShare = float64(largest) / float64(total)
+	}
+	return p
+}
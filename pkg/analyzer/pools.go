@@ -0,0 +1,109 @@
+package analyzer
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Pool identification confidence levels
+const (
+	PoolConfidenceHigh   = "high"   // the coinbase tag and payout address name the same pool
+	PoolConfidenceMedium = "medium" // only one of them names a pool
+	PoolConfidenceLow    = "low"    // they name different pools; the tag is reported
+)
+
+// knownPool is a mining pool with the tags it writes in coinbase scriptSigs
+// (matched case-insensitively) and the mainnet addresses it pays block
+// rewards to
+type knownPool struct {
+	name      string
+	tags      []string
+	addresses []string
+}
+
+// knownPools is the built-in pool table. Tags are kept long enough not to
+// turn up by chance in extranonce bytes. Pools paying miners straight from
+// the coinbase (OCEAN, P2Pool) have no fixed payout address.
+var knownPools = []knownPool{
+	{"Foundry USA", []string{"Foundry USA"}, []string{
+		"bc1qxhmdufsvnuaaaer4ynz88fspdsxq2h9e9cetdj",
+		"bc1qwzrryqr3ja8w7hnja2spmkgfdcgvqwp5swz4af4ngsjecfz0w0pqud7k38",
+	}},
+	{"AntPool", []string{"Mined by AntPool", "/AntPool/"}, []string{"39C7fxSzEACPjM78Z7xdPxhf7mKxJwvfMJ"}},
+	{"ViaBTC", []string{"/ViaBTC/"}, []string{"1PuJjnF476W3zXfVYmJfGnouzFDAXakkL4"}},
+	{"F2Pool", []string{"/F2Pool/"}, []string{
+		"1AfCc4F9c4VTYSE31PUe2kUEKs6ZxiDjxm",
+		"1KGG9kvV5zXiqyQAMfY32sGt9eFLMmgpgX",
+	}},
+	{"MARA Pool", []string{"MARA Pool", "MARA Made in USA"}, []string{
+		"15MdAHnkxt9TMC2Rj595hsg8Hnv693pPBB",
+		"bc1q695z03z6kweljcvpwft7vfu6kd0guf24yaaht2",
+	}},
+	{"Binance Pool", []string{"binance/"}, []string{
+		"3G7jcEELKh38L6kaSV8K35pTqsh5bgZW2D",
+		"3L8Ck6bm3sve1vJGKo6Ht2k167YKSKi8TZ",
+	}},
+	{"SpiderPool", []string{"SpiderPool"}, []string{"1BM1sAcrfV6d4zPKytzziu4McLQDsFC2Qc"}},
+	{"Luxor", []string{"Luxor Tech"}, []string{"32BfKjhByDSxx3BM5vUkQ3NQq9csZR6nt6"}},
+	{"SECPOOL", []string{"Mined by Secpool"}, []string{"3Awm3FNpmwrbvAFVThRUFqgpbVuqWisni9"}},
+	{"SBI Crypto", []string{"SBICrypto"}, []string{"bc1qrpp7g75sx3ejclvsfdw2uahzchtyu7vumkuadu"}},
+	{"WhitePool", []string{"WhitePool"}, []string{
+		"1BqAP9bmvHorFzzfiFXZBttJBH7YcvF9kD",
+		"3QmwRxUVQSobeKnNWSjtYmRbU2uxYQzRrG",
+	}},
+	{"Poolin", []string{"poolin.com"}, []string{"33TbzA5AMiTKUCmeVEdsnTj3GiVXuavCAH"}},
+	{"Braiins Pool", []string{"/slush/", "Braiins"}, []string{"34XC8GbijKCCvppNvhw4Ra8QZdWsg8tC11"}},
+	{"OCEAN", []string{"OCEAN.XYZ"}, nil},
+	{"BTC.com", []string{"/BTC.COM/", "btcom"}, nil},
+	{"EMCD", []string{"/EMCD/"}, nil},
+	{"Ultimus Pool", []string{"ultimuspool"}, nil},
+	{"Titan", []string{"Titan.io"}, nil},
+	{"KanoPool", []string{"KanoPool"}, nil},
+	{"P2Pool", []string{"/P2Pool/"}, nil},
+	{"BTC Guild", []string{"BTC Guild"}, nil},
+	{"Eligius", []string{"Eligius"}, nil},
+}
+
+// poolAddresses maps the payout addresses of knownPools to their pool
+var poolAddresses = func() map[string]string {
+	addresses := make(map[string]string)
+	for _, p := range knownPools {
+		for _, addr := range p.addresses {
+			addresses[addr] = p.name
+		}
+	}
+	return addresses
+}()
+
+// IdentifyPool names the mining pool behind a coinbase from the tags in its
+// scriptSig and its primary payout address (nil when it has none), with a
+// confidence level. It returns empty strings when neither is known.
+func IdentifyPool(scriptSig []byte, payoutAddress *string) (name, confidence string) {
+	var tagged, paid string
+	lower := bytes.ToLower(scriptSig)
+search:
+	for _, p := range knownPools {
+		for _, tag := range p.tags {
+			if bytes.Contains(lower, []byte(strings.ToLower(tag))) {
+				tagged = p.name
+				break search
+			}
+		}
+	}
+	if payoutAddress != nil {
+		paid = poolAddresses[*payoutAddress]
+	}
+
+	switch {
+	case tagged != "" && paid != "":
+		if tagged == paid {
+			return tagged, PoolConfidenceHigh
+		}
+		return tagged, PoolConfidenceLow
+	case tagged != "":
+		return tagged, PoolConfidenceMedium
+	case paid != "":
+		return paid, PoolConfidenceMedium
+	}
+	return "", ""
+}
@@ -0,0 +1,139 @@
+package analyzer
+
+import (
+	"encoding/hex"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// Where an input's public key was found
+const (
+	KeyFromScriptSig     = "script_sig"
+	KeyFromWitness       = "witness"
+	KeyFromRedeemScript  = "redeem_script"
+	KeyFromWitnessScript = "witness_script"
+	KeyFromTapscript     = "tapscript"
+)
+
+// Public key encodings
+const (
+	KeyFormatCompressed   = "compressed"
+	KeyFormatUncompressed = "uncompressed"
+	KeyFormatHybrid       = "hybrid" // uncompressed with the y parity in the prefix (0x06/0x07)
+	KeyFormatXOnly        = "x_only" // BIP340, in tapscript
+)
+
+// ExtractPubkeys pulls the public keys out of an input: pushed in its
+// scriptSig or witness, or embedded in its redeem, witness or leaf script.
+// Keys in a tapscript are the 32-byte pushes a signature opcode checks.
+// Uncompressed keys met in segwit v0 witness data are flagged, as relay
+// policy rejects them there. Returns nil when the input reveals no keys.
+func ExtractPubkeys(scriptType string, scriptSig []byte, witness [][]byte) []types.PubkeyInfo {
+	var keys []types.PubkeyInfo
+	add := func(source string, data []byte) {
+		if key, ok := pubkeyInfo(source, data); ok {
+			key.UncompressedInSegwit = (source == KeyFromWitness || source == KeyFromWitnessScript) &&
+				(key.Format == KeyFormatUncompressed || key.Format == KeyFormatHybrid)
+			keys = append(keys, key)
+		}
+	}
+	addScript := func(source string, script []byte) {
+		tok := txscript.MakeScriptTokenizer(0, script)
+		for tok.Next() {
+			add(source, tok.Data())
+		}
+	}
+
+	pushes, pushOnly, _ := scriptPushes(scriptSig)
+	if !pushOnly {
+		pushes = nil
+	}
+	switch scriptType {
+	case "p2sh", "p2sh-p2wpkh", "p2sh-p2wsh":
+		if len(pushes) > 0 {
+			addScript(KeyFromRedeemScript, pushes[len(pushes)-1])
+			pushes = pushes[:len(pushes)-1]
+		}
+	}
+	for _, push := range pushes {
+		add(KeyFromScriptSig, push)
+	}
+
+	switch scriptType {
+	case "p2tr_keypath":
+		// The key is the output key itself; the witness holds only the
+		// signature
+	case "p2tr_scriptpath":
+		if len(witness) >= 3 && len(witness[len(witness)-1]) > 0 && witness[len(witness)-1][0] == taprootAnnexTag {
+			witness = witness[:len(witness)-1]
+		}
+		if len(witness) >= 2 {
+			keys = append(keys, tapscriptPubkeys(witness[len(witness)-2])...)
+		}
+	case "p2wsh", "p2sh-p2wsh":
+		if len(witness) > 0 {
+			for _, item := range witness[:len(witness)-1] {
+				add(KeyFromWitness, item)
+			}
+			addScript(KeyFromWitnessScript, witness[len(witness)-1])
+		}
+	default:
+		for _, item := range witness {
+			add(KeyFromWitness, item)
+		}
+	}
+	return keys
+}
+
+// pubkeyInfo describes data if it is shaped like an ECDSA public key
+func pubkeyInfo(source string, data []byte) (types.PubkeyInfo, bool) {
+	var format string
+	switch {
+	case len(data) == 33 && (data[0] == 0x02 || data[0] == 0x03):
+		format = KeyFormatCompressed
+	case len(data) == 65 && data[0] == 0x04:
+		format = KeyFormatUncompressed
+	case len(data) == 65 && (data[0] == 0x06 || data[0] == 0x07):
+		format = KeyFormatHybrid
+	default:
+		return types.PubkeyInfo{}, false
+	}
+	_, err := btcec.ParsePubKey(data)
+	return types.PubkeyInfo{
+		Source:     source,
+		Hex:        hex.EncodeToString(data),
+		Format:     format,
+		Compressed: format == KeyFormatCompressed,
+		Valid:      err == nil,
+	}, true
+}
+
+// tapscriptPubkeys returns the x-only keys a leaf script checks signatures
+// against: 32-byte pushes followed by CHECKSIG, CHECKSIGVERIFY or
+// CHECKSIGADD
+func tapscriptPubkeys(script []byte) []types.PubkeyInfo {
+	var keys []types.PubkeyInfo
+	var prev []byte
+	tok := txscript.MakeScriptTokenizer(0, script)
+	for tok.Next() {
+		switch tok.Opcode() {
+		case txscript.OP_CHECKSIG, txscript.OP_CHECKSIGVERIFY, txscript.OP_CHECKSIGADD:
+			if len(prev) == 32 {
+				_, err := schnorr.ParsePubKey(prev)
+				keys = append(keys, types.PubkeyInfo{
+					Source:     KeyFromTapscript,
+					Hex:        hex.EncodeToString(prev),
+					Format:     KeyFormatXOnly,
+					Compressed: true,
+					Valid:      err == nil,
+				})
+			}
+		}
+		prev = tok.Data()
+	}
+	return keys
+}
@@ -4,8 +4,8 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/hex"
-	"fmt"
 	"strings"
+	"sync"
 )
 
 // ClassifyOutputScript determines the script type of an output
@@ -122,6 +122,55 @@ func ClassifyInputScript(scriptSig []byte, witness [][]byte, prevoutScript []byt
 	return "unknown"
 }
 
+// InferInputScriptType guesses an input's script type from the scriptSig and
+// witness alone, for inputs analyzed without their prevout. The result is a
+// best-effort classification: a P2WSH spend whose last witness item happens
+// to look like a taproot control block is reported as p2tr_scriptpath.
+func InferInputScriptType(scriptSig []byte, witness [][]byte) string {
+	scriptSigEmpty := len(scriptSig) == 0
+
+	if scriptSigEmpty && len(witness) > 0 {
+		last := witness[len(witness)-1]
+		switch {
+		case len(witness) == 1 && (len(last) == 64 || len(last) == 65):
+			return "p2tr_keypath"
+		case len(witness) == 2 && len(last) == 33:
+			return "p2wpkh"
+		case len(witness) > 1 && len(last) >= 33 && (len(last)-33)%32 == 0 && (last[0]&0xfe) == 0xc0:
+			return "p2tr_scriptpath"
+		default:
+			return "p2wsh"
+		}
+	}
+
+	// Nested segwit is recognizable from the scriptSig alone
+	if scriptType := ClassifyInputScript(scriptSig, witness, nil); scriptType != "unknown" {
+		return scriptType
+	}
+
+	// Legacy spend ending in a public key push: <sig> <pubkey>
+	if !scriptSigEmpty && len(witness) == 0 {
+		tokens := AnnotateScript(scriptSig)
+		if len(tokens) == 2 {
+			last, _ := hex.DecodeString(tokens[1].DataHex)
+			if (len(last) == 33 && (last[0] == 0x02 || last[0] == 0x03)) || (len(last) == 65 && last[0] == 0x04) {
+				return "p2pkh"
+			}
+		}
+	}
+
+	return "unknown"
+}
+
+// hexBufPool recycles hex-encoding scratch buffers between disassembly calls;
+// block mode disassembles every script of every transaction
+var hexBufPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 256)
+		return &buf
+	},
+}
+
 // DisassembleScript converts script bytes to human-readable ASM per spec format.
 //
 // Format rules:
@@ -138,7 +187,31 @@ func DisassembleScript(script []byte) string {
 		return ""
 	}
 
-	var parts []string
+	// Pushed data dominates most scripts: reserve 2 hex chars per byte plus
+	// room for opcode names so the builder rarely has to grow
+	var sb strings.Builder
+	sb.Grow(len(script)*2 + 32)
+	// Shared scratch for hex encoding pushed data, recycled across calls
+	bufPtr := hexBufPool.Get().(*[]byte)
+	hexBuf := (*bufPtr)[:0]
+	defer func() {
+		*bufPtr = hexBuf[:0]
+		hexBufPool.Put(bufPtr)
+	}()
+
+	writeOp := func(name string) {
+		if sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(name)
+	}
+	writePush := func(name string, data []byte) {
+		writeOp(name)
+		sb.WriteByte(' ')
+		hexBuf = hex.AppendEncode(hexBuf[:0], data)
+		sb.Write(hexBuf)
+	}
+
 	i := 0
 	for i < len(script) {
 		op := script[i]
@@ -146,24 +219,23 @@ func DisassembleScript(script []byte) string {
 
 		switch {
 		case op == 0x00:
-			parts = append(parts, "OP_0")
+			writeOp("OP_0")
 
 		case op >= 0x01 && op <= 0x4b:
 			// Direct push: op bytes of data follow
 			n := int(op)
 			if i+n > len(script) {
 				// Truncated — emit raw
-				parts = append(parts, fmt.Sprintf("OP_PUSHBYTES_%d", n))
+				writeOp(opcodeNames[op])
 				i = len(script)
 				break
 			}
-			data := script[i : i+n]
-			parts = append(parts, fmt.Sprintf("OP_PUSHBYTES_%d %s", n, hex.EncodeToString(data)))
+			writePush(opcodeNames[op], script[i:i+n])
 			i += n
 
 		case op == 0x4c: // OP_PUSHDATA1
 			if i >= len(script) {
-				parts = append(parts, "OP_PUSHDATA1")
+				writeOp("OP_PUSHDATA1")
 				break
 			}
 			n := int(script[i])
@@ -171,13 +243,12 @@ func DisassembleScript(script []byte) string {
 			if i+n > len(script) {
 				n = len(script) - i
 			}
-			data := script[i : i+n]
-			parts = append(parts, fmt.Sprintf("OP_PUSHDATA1 %s", hex.EncodeToString(data)))
+			writePush("OP_PUSHDATA1", script[i:i+n])
 			i += n
 
 		case op == 0x4d: // OP_PUSHDATA2
 			if i+1 >= len(script) {
-				parts = append(parts, "OP_PUSHDATA2")
+				writeOp("OP_PUSHDATA2")
 				break
 			}
 			n := int(binary.LittleEndian.Uint16(script[i : i+2]))
@@ -185,269 +256,28 @@ func DisassembleScript(script []byte) string {
 			if i+n > len(script) {
 				n = len(script) - i
 			}
-			data := script[i : i+n]
-			parts = append(parts, fmt.Sprintf("OP_PUSHDATA2 %s", hex.EncodeToString(data)))
+			writePush("OP_PUSHDATA2", script[i:i+n])
 			i += n
 
 		case op == 0x4e: // OP_PUSHDATA4
 			if i+3 >= len(script) {
-				parts = append(parts, "OP_PUSHDATA4")
+				writeOp("OP_PUSHDATA4")
 				break
 			}
 			n := int(binary.LittleEndian.Uint32(script[i : i+4]))
 			i += 4
-			if i+n > len(script) {
+			if i+n > len(script) || n < 0 {
 				n = len(script) - i
 			}
-			data := script[i : i+n]
-			parts = append(parts, fmt.Sprintf("OP_PUSHDATA4 %s", hex.EncodeToString(data)))
+			writePush("OP_PUSHDATA4", script[i:i+n])
 			i += n
 
 		default:
-			parts = append(parts, opcodeToName(op))
+			writeOp(opcodeNames[op])
 		}
 	}
 
-	return strings.Join(parts, " ")
-}
-
-// opcodeToName returns the spec-canonical name for an opcode byte.
-// Based on Bitcoin Core script/script.h opcode table.
-func opcodeToName(op byte) string {
-	switch op {
-	// Small integers
-	case 0x4f:
-		return "OP_1NEGATE"
-	case 0x50:
-		return "OP_RESERVED"
-	case 0x51:
-		return "OP_1"
-	case 0x52:
-		return "OP_2"
-	case 0x53:
-		return "OP_3"
-	case 0x54:
-		return "OP_4"
-	case 0x55:
-		return "OP_5"
-	case 0x56:
-		return "OP_6"
-	case 0x57:
-		return "OP_7"
-	case 0x58:
-		return "OP_8"
-	case 0x59:
-		return "OP_9"
-	case 0x5a:
-		return "OP_10"
-	case 0x5b:
-		return "OP_11"
-	case 0x5c:
-		return "OP_12"
-	case 0x5d:
-		return "OP_13"
-	case 0x5e:
-		return "OP_14"
-	case 0x5f:
-		return "OP_15"
-	case 0x60:
-		return "OP_16"
-	// Flow control
-	case 0x61:
-		return "OP_NOP"
-	case 0x62:
-		return "OP_VER"
-	case 0x63:
-		return "OP_IF"
-	case 0x64:
-		return "OP_NOTIF"
-	case 0x65:
-		return "OP_VERIF"
-	case 0x66:
-		return "OP_VERNOTIF"
-	case 0x67:
-		return "OP_ELSE"
-	case 0x68:
-		return "OP_ENDIF"
-	case 0x69:
-		return "OP_VERIFY"
-	case 0x6a:
-		return "OP_RETURN"
-	// Stack
-	case 0x6b:
-		return "OP_TOALTSTACK"
-	case 0x6c:
-		return "OP_FROMALTSTACK"
-	case 0x6d:
-		return "OP_2DROP"
-	case 0x6e:
-		return "OP_2DUP"
-	case 0x6f:
-		return "OP_3DUP"
-	case 0x70:
-		return "OP_2OVER"
-	case 0x71:
-		return "OP_2ROT"
-	case 0x72:
-		return "OP_2SWAP"
-	case 0x73:
-		return "OP_IFDUP"
-	case 0x74:
-		return "OP_DEPTH"
-	case 0x75:
-		return "OP_DROP"
-	case 0x76:
-		return "OP_DUP"
-	case 0x77:
-		return "OP_NIP"
-	case 0x78:
-		return "OP_OVER"
-	case 0x79:
-		return "OP_PICK"
-	case 0x7a:
-		return "OP_ROLL"
-	case 0x7b:
-		return "OP_ROT"
-	case 0x7c:
-		return "OP_SWAP"
-	case 0x7d:
-		return "OP_TUCK"
-	// Splice
-	case 0x7e:
-		return "OP_CAT"
-	case 0x7f:
-		return "OP_SUBSTR"
-	case 0x80:
-		return "OP_LEFT"
-	case 0x81:
-		return "OP_RIGHT"
-	case 0x82:
-		return "OP_SIZE"
-	// Bitwise
-	case 0x83:
-		return "OP_INVERT"
-	case 0x84:
-		return "OP_AND"
-	case 0x85:
-		return "OP_OR"
-	case 0x86:
-		return "OP_XOR"
-	case 0x87:
-		return "OP_EQUAL"
-	case 0x88:
-		return "OP_EQUALVERIFY"
-	case 0x89:
-		return "OP_RESERVED1"
-	case 0x8a:
-		return "OP_RESERVED2"
-	// Arithmetic
-	case 0x8b:
-		return "OP_1ADD"
-	case 0x8c:
-		return "OP_1SUB"
-	case 0x8d:
-		return "OP_2MUL"
-	case 0x8e:
-		return "OP_2DIV"
-	case 0x8f:
-		return "OP_NEGATE"
-	case 0x90:
-		return "OP_ABS"
-	case 0x91:
-		return "OP_NOT"
-	case 0x92:
-		return "OP_0NOTEQUAL"
-	case 0x93:
-		return "OP_ADD"
-	case 0x94:
-		return "OP_SUB"
-	case 0x95:
-		return "OP_MUL"
-	case 0x96:
-		return "OP_DIV"
-	case 0x97:
-		return "OP_MOD"
-	case 0x98:
-		return "OP_LSHIFT"
-	case 0x99:
-		return "OP_RSHIFT"
-	case 0x9a:
-		return "OP_BOOLAND"
-	case 0x9b:
-		return "OP_BOOLOR"
-	case 0x9c:
-		return "OP_NUMEQUAL"
-	case 0x9d:
-		return "OP_NUMEQUALVERIFY"
-	case 0x9e:
-		return "OP_NUMNOTEQUAL"
-	case 0x9f:
-		return "OP_LESSTHAN"
-	case 0xa0:
-		return "OP_GREATERTHAN"
-	case 0xa1:
-		return "OP_LESSTHANOREQUAL"
-	case 0xa2:
-		return "OP_GREATERTHANOREQUAL"
-	case 0xa3:
-		return "OP_MIN"
-	case 0xa4:
-		return "OP_MAX"
-	case 0xa5:
-		return "OP_WITHIN"
-	// Crypto
-	case 0xa6:
-		return "OP_RIPEMD160"
-	case 0xa7:
-		return "OP_SHA1"
-	case 0xa8:
-		return "OP_SHA256"
-	case 0xa9:
-		return "OP_HASH160"
-	case 0xaa:
-		return "OP_HASH256"
-	case 0xab:
-		return "OP_CODESEPARATOR"
-	case 0xac:
-		return "OP_CHECKSIG"
-	case 0xad:
-		return "OP_CHECKSIGVERIFY"
-	case 0xae:
-		return "OP_CHECKMULTISIG"
-	case 0xaf:
-		return "OP_CHECKMULTISIGVERIFY"
-	// Locktime
-	case 0xb0:
-		return "OP_NOP1"
-	case 0xb1:
-		return "OP_CHECKLOCKTIMEVERIFY"
-	case 0xb2:
-		return "OP_CHECKSEQUENCEVERIFY"
-	case 0xb3:
-		return "OP_NOP4"
-	case 0xb4:
-		return "OP_NOP5"
-	case 0xb5:
-		return "OP_NOP6"
-	case 0xb6:
-		return "OP_NOP7"
-	case 0xb7:
-		return "OP_NOP8"
-	case 0xb8:
-		return "OP_NOP9"
-	case 0xb9:
-		return "OP_NOP10"
-	// Tapscript
-	case 0xba:
-		return "OP_CHECKSIGADD"
-	case 0xfd:
-		return "OP_PUBKEYHASH"
-	case 0xfe:
-		return "OP_PUBKEY"
-	case 0xff:
-		return "OP_INVALIDOPCODE"
-	}
-	return fmt.Sprintf("OP_UNKNOWN_0x%02x", op)
+	return sb.String()
 }
 
 // ParseOpReturn extracts data from OP_RETURN output.
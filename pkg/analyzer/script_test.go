@@ -0,0 +1,112 @@
+package analyzer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/utils"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestDisassembleScriptGolden locks the disassembly format, including how
+// truncated and overlong pushes render, to the output of the original
+// []string-and-join implementation
+func TestDisassembleScriptGolden(t *testing.T) {
+	for _, tt := range []struct {
+		script string
+		want   string
+	}{
+		{"", ""},
+		{"76a914000102030405060708090a0b0c0d0e0f1011121388ac", "OP_DUP OP_HASH160 OP_PUSHBYTES_20 000102030405060708090a0b0c0d0e0f10111213 OP_EQUALVERIFY OP_CHECKSIG"},
+		{"0014000102030405060708090a0b0c0d0e0f10111213", "OP_0 OP_PUSHBYTES_20 000102030405060708090a0b0c0d0e0f10111213"},
+		{"5120000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f", "OP_1 OP_PUSHBYTES_32 000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"},
+		{"6a4c0568656c6c6f", "OP_RETURN OP_PUSHDATA1 68656c6c6f"},
+		{"51210279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f8179851ae", "OP_1 OP_PUSHBYTES_33 0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798 OP_1 OP_CHECKMULTISIG"},
+		{"050102", "OP_PUSHBYTES_5"},
+		{"4c", "OP_PUSHDATA1"},
+		{"4c050102", "OP_PUSHDATA1 0102"},
+		{"4c0076", "OP_PUSHDATA1  OP_DUP"},
+		{"4d0300aabbcc", "OP_PUSHDATA2 aabbcc"},
+		{"4d03", "OP_PUSHDATA2 OP_PUSHBYTES_3"},
+		{"4e02000000aabb", "OP_PUSHDATA4 aabb"},
+		{"4e010000", "OP_PUSHDATA4 OP_PUSHBYTES_1 00 OP_0"},
+		{"4effffffffaa", "OP_PUSHDATA4 aa"},
+		{"bbfffdba", "OP_UNKNOWN_0xbb OP_INVALIDOPCODE OP_PUBKEYHASH OP_CHECKSIGADD"},
+		{"00514f60b1b2", "OP_0 OP_1 OP_1NEGATE OP_16 OP_CHECKLOCKTIMEVERIFY OP_CHECKSEQUENCEVERIFY"},
+	} {
+		script, err := hex.DecodeString(tt.script)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := DisassembleScript(script); got != tt.want {
+			t.Errorf("DisassembleScript(%s):\n got %q\nwant %q", tt.script, got, tt.want)
+		}
+	}
+}
+
+// BenchmarkDisassembleScript disassembles every scriptSig, witness item
+// and output script of the first block of the blk04330 fixture
+func BenchmarkDisassembleScript(b *testing.B) {
+	block := readFixtureBlock(b)
+	var scripts [][]byte
+	for _, tx := range block.Transactions {
+		for _, in := range tx.TxIn {
+			scripts = append(scripts, in.SignatureScript)
+			scripts = append(scripts, in.Witness...)
+		}
+		for _, out := range tx.TxOut {
+			scripts = append(scripts, out.PkScript)
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, script := range scripts {
+			DisassembleScript(script)
+		}
+	}
+}
+
+// readFixtureBlock decodes the first block of fixtures/blocks/blk04330.dat.gz,
+// skipping the caller when the fixture is absent
+func readFixtureBlock(tb testing.TB) *wire.MsgBlock {
+	tb.Helper()
+	f, err := os.Open("../../fixtures/blocks/blk04330.dat.gz")
+	if err != nil {
+		tb.Skip("block fixture not available:", err)
+	}
+	defer f.Close()
+	key, err := os.ReadFile("../../fixtures/blocks/xor.dat")
+	if err != nil {
+		tb.Skip("xor key not available:", err)
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	// Each record is the network magic, the block size and the block
+	head := make([]byte, 8)
+	if _, err := io.ReadFull(zr, head); err != nil {
+		tb.Fatal(err)
+	}
+	head = utils.XORDecode(head, key)
+	data := make([]byte, binary.LittleEndian.Uint32(head[4:]))
+	if _, err := io.ReadFull(zr, data); err != nil {
+		tb.Fatal(err)
+	}
+	data = utils.XORDecodeAt(data, key, 8)
+
+	var block wire.MsgBlock
+	if err := block.Deserialize(bytes.NewReader(data)); err != nil {
+		tb.Fatal(err)
+	}
+	return &block
+}
@@ -0,0 +1,448 @@
+package analyzer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Signature hash algorithms
+const (
+	SighashLegacy = "legacy" // pre-segwit: the modified transaction itself
+	SighashBIP143 = "bip143" // segwit v0
+	SighashBIP341 = "bip341" // taproot
+)
+
+// sighashDefault is the taproot hash type left implicit in a 64-byte
+// signature
+const sighashDefault = 0x00
+
+// ComputeSighashPreimages fills in the SighashPreimages of every input it
+// can: the message each hash type the input's signatures use is computed
+// over, and its digest, or those of SIGHASH_ALL (SIGHASH_DEFAULT for
+// taproot) when the input carries no signatures yet. inputs and prevout
+// scripts are indexed like tx.TxIn. Inputs whose prevout is missing are
+// skipped, as are taproot inputs unless every prevout is known or the hash
+// type is ANYONECANPAY. Legacy script codes are taken whole, minus any
+// OP_CODESEPARATOR, as scripts that execute one are all but unused, and
+// minus the scriptSig's signatures of the hash type (FindAndDelete).
+func ComputeSighashPreimages(tx *wire.MsgTx, inputs []types.Input, scripts [][]byte) {
+	allKnown := true
+	for i := range inputs {
+		allKnown = allKnown && !inputs[i].PrevoutMissing
+	}
+	var taproot *taprootHashes
+	for i := range inputs {
+		in := &inputs[i]
+		if in.PrevoutMissing {
+			continue
+		}
+		algorithm, scriptCode := sighashScriptCode(in, scripts[i])
+		if algorithm == "" {
+			continue
+		}
+		if algorithm == SighashBIP341 && taproot == nil {
+			taproot = newTaprootHashes(tx, inputs, scripts)
+		}
+
+		preimages := make([]types.SighashPreimage, 0)
+		for _, hashType := range inputHashTypes(in, algorithm) {
+			var preimage, digest []byte
+			switch algorithm {
+			case SighashLegacy:
+				preimage, digest = legacySighash(tx, i, deleteSignatures(scriptCode, in, hashType), hashType)
+			case SighashBIP143:
+				preimage = bip143Preimage(tx, i, scriptCode, in.Prevout.ValueSats, hashType)
+				digest = chainhash.DoubleHashB(preimage)
+			case SighashBIP341:
+				if !allKnown && hashType&sighashAnyoneCanPay == 0 {
+					continue
+				}
+				if preimage = bip341Preimage(tx, i, in, scripts[i], hashType, taproot); preimage == nil {
+					continue
+				}
+				h := chainhash.TaggedHash(chainhash.TagTapSighash, preimage)
+				digest = h[:]
+			}
+			name := SighashTypeName(hashType)
+			if algorithm == SighashBIP341 && hashType == sighashDefault {
+				name = SighashDefault
+			}
+			preimages = append(preimages, types.SighashPreimage{
+				SighashType: name,
+				SighashByte: int(hashType),
+				Algorithm:   algorithm,
+				PreimageHex: hex.EncodeToString(preimage),
+				SighashHex:  hex.EncodeToString(digest),
+			})
+		}
+		if len(preimages) > 0 {
+			in.SighashPreimages = preimages
+		}
+	}
+}
+
+// sighashScriptCode picks the signature hash algorithm of an input and the
+// script its signatures commit to (unused for taproot). The prevout type
+// decides, with a p2sh redeem script that is itself a witness program
+// signed as segwit v0; the input's classification leaves a legacy p2sh
+// spend "unknown".
+func sighashScriptCode(in *types.Input, prevScript []byte) (string, []byte) {
+	witness := in.Witness
+	witnessScript := func() (string, []byte) {
+		if len(witness) == 0 {
+			return "", nil
+		}
+		return SighashBIP143, witness[len(witness)-1]
+	}
+
+	switch ClassifyOutputScript(prevScript) {
+	case "p2pkh":
+		return SighashLegacy, prevScript
+	case "p2sh":
+		pushes, pushOnly, _ := scriptPushes(in.ScriptSigHex)
+		if !pushOnly || len(pushes) == 0 {
+			return "", nil
+		}
+		redeem := pushes[len(pushes)-1]
+		switch ClassifyOutputScript(redeem) {
+		case "p2wpkh":
+			return SighashBIP143, p2pkhScriptCode(redeem[2:])
+		case "p2wsh":
+			return witnessScript()
+		}
+		return SighashLegacy, redeem
+	case "p2wpkh":
+		return SighashBIP143, p2pkhScriptCode(prevScript[2:])
+	case "p2wsh":
+		return witnessScript()
+	case "p2tr":
+		return SighashBIP341, nil
+	}
+	return "", nil
+}
+
+// p2pkhScriptCode is the script code BIP143 gives a P2WPKH key hash
+func p2pkhScriptCode(keyHash []byte) []byte {
+	script := []byte{txscript.OP_DUP, txscript.OP_HASH160, txscript.OP_DATA_20}
+	script = append(script, keyHash...)
+	return append(script, txscript.OP_EQUALVERIFY, txscript.OP_CHECKSIG)
+}
+
+// inputHashTypes returns the distinct hash types an input's signatures
+// use, or the default of its algorithm when it has none
+func inputHashTypes(in *types.Input, algorithm string) []byte {
+	var hashTypes []byte
+	seen := make(map[byte]bool)
+	add := func(b byte) {
+		if !seen[b] {
+			seen[b] = true
+			hashTypes = append(hashTypes, b)
+		}
+	}
+	if algorithm == SighashBIP341 {
+		for _, sig := range in.SchnorrSignatures {
+			if !sig.SighashValid {
+				continue
+			}
+			if sig.SighashByte == nil {
+				add(sighashDefault)
+			} else {
+				add(byte(*sig.SighashByte))
+			}
+		}
+		if len(hashTypes) == 0 {
+			add(sighashDefault)
+		}
+		return hashTypes
+	}
+	for _, sig := range in.ECDSASignatures {
+		add(byte(sig.SighashByte))
+	}
+	if len(hashTypes) == 0 {
+		add(byte(txscript.SigHashAll))
+	}
+	return hashTypes
+}
+
+// legacySighash builds the pre-segwit signature message: the transaction
+// with every scriptSig emptied but this input's, which holds the script
+// code, trimmed as the hash type says, then the hash type as 4 bytes.
+// SIGHASH_SINGLE without a matching output signs the constant 1 instead
+// (a long-standing bug kept for consensus), for which there is no message.
+func legacySighash(tx *wire.MsgTx, idx int, scriptCode []byte, hashType byte) (preimage, digest []byte) {
+	base := hashType & 0x1f
+	if base == sighashSingle && idx >= len(tx.TxOut) {
+		var one chainhash.Hash
+		one[0] = 0x01
+		return nil, one[:]
+	}
+
+	txCopy := tx.Copy()
+	for i := range txCopy.TxIn {
+		txCopy.TxIn[i].SignatureScript = nil
+		txCopy.TxIn[i].Witness = nil
+	}
+	txCopy.TxIn[idx].SignatureScript = removeCodeSeparators(scriptCode)
+	switch base {
+	case sighashNone:
+		txCopy.TxOut = txCopy.TxOut[:0]
+		zeroOtherSequences(txCopy, idx)
+	case sighashSingle:
+		txCopy.TxOut = txCopy.TxOut[:idx+1]
+		for i := 0; i < idx; i++ {
+			txCopy.TxOut[i] = &wire.TxOut{Value: -1}
+		}
+		zeroOtherSequences(txCopy, idx)
+	}
+	if hashType&sighashAnyoneCanPay != 0 {
+		txCopy.TxIn = txCopy.TxIn[idx : idx+1]
+	}
+
+	var buf bytes.Buffer
+	_ = txCopy.SerializeNoWitness(&buf)
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(hashType))
+	preimage = buf.Bytes()
+	return preimage, chainhash.DoubleHashB(preimage)
+}
+
+// deleteSignatures applies FindAndDelete to a legacy script code: each
+// scriptSig signature with the given hash type is removed from it, as
+// OP_CHECKSIG removes the signature it checks before hashing. Signatures
+// sharing a hash type share one message here, so each is removed from it,
+// where Core removes only the one being checked.
+func deleteSignatures(scriptCode []byte, in *types.Input, hashType byte) []byte {
+	pushes, _, _ := scriptPushes(in.ScriptSigHex)
+	for _, sig := range in.ECDSASignatures {
+		if sig.Source == SigFromScriptSig && byte(sig.SighashByte) == hashType && sig.Index < len(pushes) {
+			scriptCode = findAndDelete(scriptCode, pushes[sig.Index])
+		}
+	}
+	return scriptCode
+}
+
+// findAndDelete removes every minimal push of data that starts on an
+// opcode boundary of script, as Bitcoin Core's FindAndDelete does
+func findAndDelete(script, data []byte) []byte {
+	if len(data) == 0 || len(data) >= txscript.OP_PUSHDATA1 {
+		return script // signatures are pushed with a single length byte
+	}
+	pattern := append([]byte{byte(len(data))}, data...)
+	if !bytes.Contains(script, pattern) {
+		return script
+	}
+	result := make([]byte, 0, len(script))
+	for pc := 0; pc < len(script); {
+		if bytes.HasPrefix(script[pc:], pattern) {
+			pc += len(pattern)
+			continue
+		}
+		tok := txscript.MakeScriptTokenizer(0, script[pc:])
+		if !tok.Next() {
+			// A truncated push ends the search; the rest is kept as is
+			result = append(result, script[pc:]...)
+			break
+		}
+		next := pc + int(tok.ByteIndex())
+		result = append(result, script[pc:next]...)
+		pc = next
+	}
+	return result
+}
+
+func zeroOtherSequences(tx *wire.MsgTx, idx int) {
+	for i := range tx.TxIn {
+		if i != idx {
+			tx.TxIn[i].Sequence = 0
+		}
+	}
+}
+
+// removeCodeSeparators drops the OP_CODESEPARATORs of a script, as the
+// legacy script code leaves them out
+func removeCodeSeparators(script []byte) []byte {
+	if bytes.IndexByte(script, txscript.OP_CODESEPARATOR) < 0 {
+		return script
+	}
+	stripped := make([]byte, 0, len(script))
+	tok := txscript.MakeScriptTokenizer(0, script)
+	last := 0
+	for tok.Next() {
+		end := int(tok.ByteIndex())
+		if tok.Opcode() != txscript.OP_CODESEPARATOR {
+			stripped = append(stripped, script[last:end]...)
+		}
+		last = end
+	}
+	if tok.Err() != nil {
+		return script
+	}
+	return stripped
+}
+
+// bip143Preimage builds the segwit v0 signature message
+func bip143Preimage(tx *wire.MsgTx, idx int, scriptCode []byte, amount int64, hashType byte) []byte {
+	base := hashType & 0x1f
+	acp := hashType&sighashAnyoneCanPay != 0
+	var zero [32]byte
+
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, tx.Version)
+	if acp {
+		buf.Write(zero[:])
+	} else {
+		buf.Write(chainhash.DoubleHashB(serializePrevouts(tx)))
+	}
+	if acp || base == sighashSingle || base == sighashNone {
+		buf.Write(zero[:])
+	} else {
+		buf.Write(chainhash.DoubleHashB(serializeSequences(tx)))
+	}
+	txIn := tx.TxIn[idx]
+	buf.Write(txIn.PreviousOutPoint.Hash[:])
+	_ = binary.Write(&buf, binary.LittleEndian, txIn.PreviousOutPoint.Index)
+	_ = wire.WriteVarBytes(&buf, 0, scriptCode)
+	_ = binary.Write(&buf, binary.LittleEndian, amount)
+	_ = binary.Write(&buf, binary.LittleEndian, txIn.Sequence)
+	switch {
+	case base != sighashSingle && base != sighashNone:
+		buf.Write(chainhash.DoubleHashB(serializeOutputs(tx.TxOut)))
+	case base == sighashSingle && idx < len(tx.TxOut):
+		buf.Write(chainhash.DoubleHashB(serializeOutputs(tx.TxOut[idx : idx+1])))
+	default:
+		buf.Write(zero[:])
+	}
+	_ = binary.Write(&buf, binary.LittleEndian, tx.LockTime)
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(hashType))
+	return buf.Bytes()
+}
+
+func serializePrevouts(tx *wire.MsgTx) []byte {
+	var buf bytes.Buffer
+	for _, in := range tx.TxIn {
+		buf.Write(in.PreviousOutPoint.Hash[:])
+		_ = binary.Write(&buf, binary.LittleEndian, in.PreviousOutPoint.Index)
+	}
+	return buf.Bytes()
+}
+
+func serializeSequences(tx *wire.MsgTx) []byte {
+	var buf bytes.Buffer
+	for _, in := range tx.TxIn {
+		_ = binary.Write(&buf, binary.LittleEndian, in.Sequence)
+	}
+	return buf.Bytes()
+}
+
+func serializeOutputs(outs []*wire.TxOut) []byte {
+	var buf bytes.Buffer
+	for _, out := range outs {
+		_ = wire.WriteTxOut(&buf, 0, 0, out)
+	}
+	return buf.Bytes()
+}
+
+// taprootHashes are the single-SHA256 digests over all inputs and outputs
+// that BIP341 messages share
+type taprootHashes struct {
+	prevouts, amounts, scriptPubkeys, sequences, outputs [32]byte
+}
+
+func newTaprootHashes(tx *wire.MsgTx, inputs []types.Input, scripts [][]byte) *taprootHashes {
+	var amounts, scriptPubkeys bytes.Buffer
+	for i := range tx.TxIn {
+		_ = binary.Write(&amounts, binary.LittleEndian, inputs[i].Prevout.ValueSats)
+		_ = wire.WriteVarBytes(&scriptPubkeys, 0, scripts[i])
+	}
+	return &taprootHashes{
+		prevouts:      sha256.Sum256(serializePrevouts(tx)),
+		amounts:       sha256.Sum256(amounts.Bytes()),
+		scriptPubkeys: sha256.Sum256(scriptPubkeys.Bytes()),
+		sequences:     sha256.Sum256(serializeSequences(tx)),
+		outputs:       sha256.Sum256(serializeOutputs(tx.TxOut)),
+	}
+}
+
+// bip341Preimage builds the taproot signature message, epoch byte first,
+// extended with the leaf hash for a script-path spend. Returns nil for a
+// hash type BIP341 does not define or SIGHASH_SINGLE without a matching
+// output.
+func bip341Preimage(tx *wire.MsgTx, idx int, in *types.Input, prevScript []byte, hashType byte, hashes *taprootHashes) []byte {
+	base := hashType & 0x03
+	acp := hashType&sighashAnyoneCanPay != 0
+	if hashType&^(0x03|sighashAnyoneCanPay) != 0 || hashType == sighashAnyoneCanPay {
+		return nil
+	}
+	if base == sighashSingle && idx >= len(tx.TxOut) {
+		return nil
+	}
+
+	witness := in.Witness
+	var annex []byte
+	if len(witness) >= 2 && len(witness[len(witness)-1]) > 0 && witness[len(witness)-1][0] == taprootAnnexTag {
+		annex = witness[len(witness)-1]
+	}
+	var leafHash []byte
+	if in.ScriptType == "p2tr_scriptpath" {
+		if in.Taproot == nil {
+			return nil
+		}
+		leafHash, _ = hex.DecodeString(in.Taproot.LeafHash)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(0x00) // epoch
+	buf.WriteByte(hashType)
+	_ = binary.Write(&buf, binary.LittleEndian, tx.Version)
+	_ = binary.Write(&buf, binary.LittleEndian, tx.LockTime)
+	if !acp {
+		buf.Write(hashes.prevouts[:])
+		buf.Write(hashes.amounts[:])
+		buf.Write(hashes.scriptPubkeys[:])
+		buf.Write(hashes.sequences[:])
+	}
+	if base != sighashNone && base != sighashSingle {
+		buf.Write(hashes.outputs[:])
+	}
+	spendType := byte(0)
+	if leafHash != nil {
+		spendType |= 0x02
+	}
+	if annex != nil {
+		spendType |= 0x01
+	}
+	buf.WriteByte(spendType)
+	txIn := tx.TxIn[idx]
+	if acp {
+		buf.Write(txIn.PreviousOutPoint.Hash[:])
+		_ = binary.Write(&buf, binary.LittleEndian, txIn.PreviousOutPoint.Index)
+		_ = binary.Write(&buf, binary.LittleEndian, in.Prevout.ValueSats)
+		_ = wire.WriteVarBytes(&buf, 0, prevScript)
+		_ = binary.Write(&buf, binary.LittleEndian, txIn.Sequence)
+	} else {
+		_ = binary.Write(&buf, binary.LittleEndian, uint32(idx))
+	}
+	if annex != nil {
+		var annexBuf bytes.Buffer
+		_ = wire.WriteVarBytes(&annexBuf, 0, annex)
+		h := sha256.Sum256(annexBuf.Bytes())
+		buf.Write(h[:])
+	}
+	if base == sighashSingle {
+		h := sha256.Sum256(serializeOutputs(tx.TxOut[idx : idx+1]))
+		buf.Write(h[:])
+	}
+	if leafHash != nil {
+		buf.Write(leafHash)
+		buf.WriteByte(0x00)                                     // key version
+		_ = binary.Write(&buf, binary.LittleEndian, ^uint32(0)) // no OP_CODESEPARATOR executed
+	}
+	return buf.Bytes()
+}
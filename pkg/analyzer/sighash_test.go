@@ -0,0 +1,239 @@
+package analyzer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func mustHex(tb testing.TB, s string) []byte {
+	tb.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return b
+}
+
+func mustTx(tb testing.TB, s string) *wire.MsgTx {
+	tb.Helper()
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(mustHex(tb, s))); err != nil {
+		tb.Fatal(err)
+	}
+	return &tx
+}
+
+// sighashInputs builds the inputs ComputeSighashPreimages reads from the
+// prevouts' amounts and scripts, the scriptSigs and witnesses taken from tx
+func sighashInputs(tb testing.TB, tx *wire.MsgTx, amounts []int64, scriptHexes []string) ([]types.Input, [][]byte) {
+	tb.Helper()
+	inputs := make([]types.Input, len(tx.TxIn))
+	scripts := make([][]byte, len(tx.TxIn))
+	for i, txIn := range tx.TxIn {
+		scripts[i] = mustHex(tb, scriptHexes[i])
+		inputs[i].Prevout = types.Prevout{ValueSats: amounts[i], ScriptPubkeyHex: scriptHexes[i]}
+		inputs[i].ScriptSigHex = txIn.SignatureScript
+		for _, item := range txIn.Witness {
+			inputs[i].Witness = append(inputs[i].Witness, item)
+		}
+		if ClassifyOutputScript(scripts[i]) == "p2tr" {
+			inputs[i].ScriptType = "p2tr_keypath"
+		}
+	}
+	return inputs, scripts
+}
+
+// preimageFor returns the input's preimage for a hash type
+func preimageFor(tb testing.TB, in types.Input, hashType int) types.SighashPreimage {
+	tb.Helper()
+	for _, p := range in.SighashPreimages {
+		if p.SighashByte == hashType {
+			return p
+		}
+	}
+	tb.Fatalf("no preimage for hash type %#x among %+v", hashType, in.SighashPreimages)
+	return types.SighashPreimage{}
+}
+
+// BIP143's native P2WPKH and P2SH-P2WPKH examples
+func TestSighashBIP143(t *testing.T) {
+	t.Run("native p2wpkh", func(t *testing.T) {
+		tx := mustTx(t, "0100000002fff7f7881a8099afa6940d42d1e7f6362bec38171ea3edf433541db4e4ad969f0000000000eeffffffef51e1b804cc89d182d279655c3aa89e815b1b309fe287d9b2b55d57b90ec68a0100000000ffffffff02202cb206000000001976a9148280b37df378db99f66f85c95a783a76ac7a6d5988ac9093510d000000001976a9143bde42dbee7e4dbe6a21b2d50ce2f0167faa815988ac11000000")
+		inputs, scripts := sighashInputs(t, tx, []int64{625000000, 600000000}, []string{
+			"2103c9f4836b9a4f77fc0d81f7bcb01b7f1b35916864b9476c241ce9fc198bd25432ac",
+			"00141d0f172a0ecb48aee1be1f2687d2963ae33f71a1",
+		})
+		ComputeSighashPreimages(tx, inputs, scripts)
+
+		if inputs[0].SighashPreimages != nil {
+			t.Errorf("p2pk input: got preimages %+v, want none", inputs[0].SighashPreimages)
+		}
+		got := preimageFor(t, inputs[1], 0x01)
+		wantPreimage := "0100000096b827c8483d4e9b96712b6713a7b68d6e8003a781feba36c31143470b4efd3752b0a642eea2fb7ae638c36f6252b6750293dbe574a806984b8e4d8548339a3bef51e1b804cc89d182d279655c3aa89e815b1b309fe287d9b2b55d57b90ec68a010000001976a9141d0f172a0ecb48aee1be1f2687d2963ae33f71a188ac0046c32300000000ffffffff863ef3e1a92afbfdb97f31ad0fc7683ee943e9abcf2501590ff8f6551f47e5e51100000001000000"
+		if got.Algorithm != SighashBIP143 || got.PreimageHex != wantPreimage {
+			t.Errorf("preimage: got %s %s\nwant %s", got.Algorithm, got.PreimageHex, wantPreimage)
+		}
+		if want := "c37af31116d1b27caf68aae9e3ac82f1477929014d5b917657d0eb49478cb670"; got.SighashHex != want {
+			t.Errorf("sighash: got %s, want %s", got.SighashHex, want)
+		}
+	})
+
+	t.Run("p2sh-p2wpkh", func(t *testing.T) {
+		tx := mustTx(t, "0100000001db6b1b20aa0fd7b23880be2ecbd4a98130974cf4748fb66092ac4d3ceb1a54770100000000feffffff02b8b4eb0b000000001976a914a457b684d7f0d539a46a45bbc043f35b59d0d96388ac0008af2f000000001976a914fd270b1ee6abcaea97fea7ad0402e8bd8ad6d77c88ac92040000")
+		inputs, scripts := sighashInputs(t, tx, []int64{1000000000}, []string{"a9144733f37cf4db86fbc2efed2500b4f4e49f31202387"})
+		// The redeem script the signed input pushes
+		inputs[0].ScriptSigHex = mustHex(t, "16001479091972186c449eb1ded22b78e40d009bdf0089")
+		ComputeSighashPreimages(tx, inputs, scripts)
+
+		got := preimageFor(t, inputs[0], 0x01)
+		if want := "64f3b0f4dd2bb3aa1ce8566d220cc74dda9df97d8490cc81d89d735c92e59fb6"; got.SighashHex != want {
+			t.Errorf("sighash: got %s, want %s", got.SighashHex, want)
+		}
+	})
+}
+
+// BIP341's key-path spending vectors (wallet-test-vectors.json)
+func TestSighashBIP341(t *testing.T) {
+	tx := mustTx(t, "02000000097de20cbff686da83a54981d2b9bab3586f4ca7e48f57f5b55963115f3b334e9c010000000000000000d7b7cab57b1393ace2d064f4d4a2cb8af6def61273e127517d44759b6dafdd990000000000fffffffff8e1f583384333689228c5d28eac13366be082dc57441760d957275419a418420000000000fffffffff0689180aa63b30cb162a73c6d2a38b7eeda2a83ece74310fda0843ad604853b0100000000feffffffaa5202bdf6d8ccd2ee0f0202afbbb7461d9264a25e5bfd3c5a52ee1239e0ba6c0000000000feffffff956149bdc66faa968eb2be2d2faa29718acbfe3941215893a2a3446d32acd050000000000000000000e664b9773b88c09c32cb70a2a3e4da0ced63b7ba3b22f848531bbb1d5d5f4c94010000000000000000e9aa6b8e6c9de67619e6a3924ae25696bb7b694bb677a632a74ef7eadfd4eabf0000000000ffffffffa778eb6a263dc090464cd125c466b5a99667720b1c110468831d058aa1b82af10100000000ffffffff0200ca9a3b000000001976a91406afd46bcdfd22ef94ac122aa11f241244a37ecc88ac807840cb0000000020ac9a87f5594be208f8532db38cff670c450ed2fea8fcdefcc9a663f78bab962b0065cd1d")
+	amounts := []int64{420000000, 462000000, 294000000, 504000000, 630000000, 378000000, 672000000, 546000000, 588000000}
+	scriptHexes := []string{
+		"512053a1f6e454df1aa2776a2814a721372d6258050de330b3c6d10ee8f4e0dda343",
+		"5120147c9c57132f6e7ecddba9800bb0c4449251c92a1e60371ee77557b6620f3ea3",
+		"76a914751e76e8199196d454941c45d1b3a323f1433bd688ac",
+		"5120e4d810fd50586274face62b8a807eb9719cef49c04177cc6b76a9a4251d5450e",
+		"512091b64d5324723a985170e4dc5a0f84c041804f2cd12660fa5dec09fc21783605",
+		"00147dd65592d0ab2fe0d0257d571abf032cd9db93dc",
+		"512075169f4001aa68f15bbed28b218df1d0a62cbbcf1188c6665110c293c907b831",
+		"5120712447206d7a5238acc7ff53fbe94a3b64539ad291c7cdbc490b7577e4b17df5",
+		"512077e30a5522dd9f894c3f8b8bd4c4b2cf82ca7da8a3ea6a239655c39c050ab220",
+	}
+	vectors := []struct {
+		vin      int
+		hashType int
+		want     string
+	}{
+		{0, 0x03, "2514a6272f85cfa0f45eb907fcb0d121b808ed37c6ea160a5a9046ed5526d555"},
+		{1, 0x83, "325a644af47e8a5a2591cda0ab0723978537318f10e6a63d4eed783b96a71a4d"},
+		{3, 0x01, "bf013ea93474aa67815b1b6cc441d23b64fa310911d991e713cd34c7f5d46669"},
+		{4, 0x00, "4f900a0bae3f1446fd48490c2958b5a023228f01661cda3496a11da502a7f7ef"},
+		{6, 0x02, "15f25c298eb5cdc7eb1d638dd2d45c97c4c59dcaec6679cfc16ad84f30876b85"},
+		{7, 0x82, "cd292de50313804dabe4685e83f923d2969577191a3e1d2882220dca88cbeb10"},
+		{8, 0x81, "cccb739eca6c13a8a89e6e5cd317ffe55669bbda23f2fd37b0f18755e008edd2"},
+	}
+
+	inputs, scripts := sighashInputs(t, tx, amounts, scriptHexes)
+	for _, v := range vectors {
+		sig := types.SchnorrSignature{SighashValid: true}
+		if v.hashType != sighashDefault {
+			hashType := v.hashType
+			sig.SighashByte = &hashType
+		}
+		inputs[v.vin].SchnorrSignatures = []types.SchnorrSignature{sig}
+	}
+	ComputeSighashPreimages(tx, inputs, scripts)
+
+	for _, v := range vectors {
+		got := preimageFor(t, inputs[v.vin], v.hashType)
+		if got.Algorithm != SighashBIP341 || got.SighashHex != v.want {
+			t.Errorf("vin %d, hash type %#x: got %s %s, want %s", v.vin, v.hashType, got.Algorithm, got.SighashHex, v.want)
+		}
+	}
+}
+
+func TestSighashLegacy(t *testing.T) {
+	// A mainnet p2pkh spend from the blk04330 fixture: the digest must be
+	// what its signature signs
+	t.Run("p2pkh", func(t *testing.T) {
+		tx := mustTx(t, "01000000014531e1437544cfbab33d5dc49609b38254a54c6ee92a9386ce3d21e8d7c472e3010000006a47304402206902f61264371fa8dea4f631ae7326d1527be0ec754d4e8dcc0a2a9782a0889802202987a4ea1c584ed074ad4610aabe8bdfbe7b8fe3bbe958ebc798ab95a62608a001210382fb863afbbd1599b65b8a51206791c4a0a1b88f3e396db63be3e1cc19b285e1ffffffff01dc8a1900000000001600143c7d63cc2d24d6d1ad23d3dbfbbf7198c4f7423a00000000")
+		inputs, scripts := sighashInputs(t, tx, []int64{1702598}, []string{"76a914520749d9f1fec8f4e66681d27490504016f7abd388ac"})
+		inputs[0].ECDSASignatures = ParseECDSASignatures("p2pkh", inputs[0].ScriptSigHex, nil)
+		ComputeSighashPreimages(tx, inputs, scripts)
+
+		got := preimageFor(t, inputs[0], 0x01)
+		pushes, _, _ := scriptPushes(inputs[0].ScriptSigHex)
+		sigBytes := pushes[0]
+		sig, err := ecdsa.ParseDERSignature(sigBytes[:len(sigBytes)-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubkey, err := btcec.ParsePubKey(pushes[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Algorithm != SighashLegacy || !sig.Verify(mustHex(t, got.SighashHex), pubkey) {
+			t.Errorf("signature does not verify against %s sighash %s", got.Algorithm, got.SighashHex)
+		}
+	})
+
+	// SIGHASH_SINGLE on an input with no matching output signs the
+	// constant 1, and there is no message
+	t.Run("single without output", func(t *testing.T) {
+		tx := wire.NewMsgTx(1)
+		tx.AddTxIn(&wire.TxIn{PreviousOutPoint: wire.OutPoint{Index: 0}})
+		tx.AddTxIn(&wire.TxIn{PreviousOutPoint: wire.OutPoint{Index: 1}})
+		tx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+		p2pkh := "76a914000102030405060708090a0b0c0d0e0f1011121388ac"
+		inputs, scripts := sighashInputs(t, tx, []int64{2000, 2000}, []string{p2pkh, p2pkh})
+		inputs[1].ECDSASignatures = []types.ECDSASignature{{Source: SigFromScriptSig, SighashByte: 0x03}}
+		ComputeSighashPreimages(tx, inputs, scripts)
+
+		got := preimageFor(t, inputs[1], 0x03)
+		want := "01" + strings.Repeat("00", 31)
+		if got.PreimageHex != "" || got.SighashHex != want {
+			t.Errorf("got preimage %q, sighash %s; want no preimage, sighash %s", got.PreimageHex, got.SighashHex, want)
+		}
+		// The first input has an output to commit to
+		if p := preimageFor(t, inputs[0], 0x01); p.PreimageHex == "" || p.SighashHex == want {
+			t.Errorf("input 0: got preimage %q, sighash %s", p.PreimageHex, p.SighashHex)
+		}
+	})
+
+	// A redeem script holding the scriptSig's own signature is hashed
+	// without it
+	t.Run("find and delete", func(t *testing.T) {
+		sig := append(bytes.Repeat([]byte{0x30}, 9), 0x01)
+		redeem := append(append([]byte{byte(len(sig))}, sig...), 0x75, 0x51) // <sig> OP_DROP OP_1
+		scriptSig := append(append([]byte{byte(len(sig))}, sig...), byte(len(redeem)))
+		scriptSig = append(scriptSig, redeem...)
+
+		tx := wire.NewMsgTx(1)
+		tx.AddTxIn(&wire.TxIn{SignatureScript: scriptSig})
+		tx.AddTxOut(wire.NewTxOut(1000, []byte{0x51}))
+		inputs, scripts := sighashInputs(t, tx, []int64{2000}, []string{"a914000102030405060708090a0b0c0d0e0f1011121387"})
+		inputs[0].ECDSASignatures = []types.ECDSASignature{{Source: SigFromScriptSig, Index: 0, SighashByte: 0x01}}
+		ComputeSighashPreimages(tx, inputs, scripts)
+
+		_, want := legacySighash(tx, 0, []byte{0x75, 0x51}, 0x01)
+		if got := preimageFor(t, inputs[0], 0x01); got.SighashHex != hex.EncodeToString(want) {
+			t.Errorf("got %s, want %x", got.SighashHex, want)
+		}
+	})
+}
+
+func TestFindAndDelete(t *testing.T) {
+	sig := "300102030405060701" // 9 bytes
+	push := "09" + sig
+	for _, tt := range []struct {
+		name, script, want string
+	}{
+		{"absent", "76a988ac", "76a988ac"},
+		{"single", push + "ac", "ac"},
+		{"repeated", push + push + "75" + push, "75"},
+		{"non-minimal push kept", "4c09" + sig + "ac", "4c09" + sig + "ac"},
+		{"inside another push kept", "0a00" + push, "0a00" + push},
+		{"truncated tail kept", push + "4c", "4c"},
+	} {
+		got := findAndDelete(mustHex(t, tt.script), mustHex(t, sig))
+		if hex.EncodeToString(got) != tt.want {
+			t.Errorf("%s: got %x, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package analyzer
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// Where the signature requirement of an input comes from
+const (
+	SigSourceKey           = "key"            // single-key spend (p2pkh, p2wpkh, taproot key path)
+	SigSourceRedeemScript  = "redeem_script"  // p2sh
+	SigSourceWitnessScript = "witness_script" // p2wsh
+	SigSourceTapscript     = "tapscript"      // taproot script path
+)
+
+// CountSignatures compares the signatures an input's script requires with
+// the signatures its scriptSig and witness actually carry. Requirements
+// follow CHECKSIG (one each), CHECKMULTISIG (its m) and CHECKSIGADD (the
+// threshold it is compared against). Scripts with IF branches are marked
+// conditional and not judged complete or incomplete. Returns nil for input
+// types without a signature requirement to check.
+func CountSignatures(scriptType string, scriptSig []byte, witness [][]byte) *types.SignatureAccounting {
+	acct := &types.SignatureAccounting{}
+	var stack [][]byte
+	switch scriptType {
+	case "p2pkh":
+		acct.Source, acct.Required = SigSourceKey, 1
+		stack, _, _ = scriptPushes(scriptSig)
+	case "p2wpkh", "p2sh-p2wpkh":
+		acct.Source, acct.Required = SigSourceKey, 1
+		stack = witness
+	case "p2tr_keypath":
+		acct.Source, acct.Required = SigSourceKey, 1
+		stack = witness[:min(len(witness), 1)]
+	case "p2sh":
+		pushes, pushOnly, _ := scriptPushes(scriptSig)
+		if !pushOnly || len(pushes) == 0 {
+			return nil
+		}
+		acct.Source = SigSourceRedeemScript
+		stack = pushes[:len(pushes)-1]
+		acct.Required, acct.Conditional = requiredSignatures(pushes[len(pushes)-1])
+	case "p2wsh", "p2sh-p2wsh":
+		if len(witness) == 0 {
+			return nil
+		}
+		acct.Source = SigSourceWitnessScript
+		stack = witness[:len(witness)-1]
+		acct.Required, acct.Conditional = requiredSignatures(witness[len(witness)-1])
+	case "p2tr_scriptpath":
+		if len(witness) >= 3 && len(witness[len(witness)-1]) > 0 && witness[len(witness)-1][0] == taprootAnnexTag {
+			witness = witness[:len(witness)-1]
+		}
+		if len(witness) < 2 {
+			return nil
+		}
+		acct.Source = SigSourceTapscript
+		stack = witness[:len(witness)-2]
+		acct.Required, acct.Conditional = requiredSignatures(witness[len(witness)-2])
+	default:
+		return nil
+	}
+
+	schnorr := scriptType == "p2tr_keypath" || scriptType == "p2tr_scriptpath"
+	for _, item := range stack {
+		if (schnorr && (len(item) == 64 || len(item) == 65)) || (!schnorr && isDERSignature(item)) {
+			acct.Provided++
+		}
+	}
+	acct.Missing = max(acct.Required-acct.Provided, 0)
+	if !acct.Conditional {
+		complete := acct.Missing == 0
+		acct.Complete = &complete
+	}
+	return acct
+}
+
+// requiredSignatures counts the signatures a script demands and whether it
+// branches (OP_IF/OP_NOTIF), in which case the count covers every branch.
+// An OP_FALSE OP_IF body is dead code and ignored.
+func requiredSignatures(script []byte) (required int, conditional bool) {
+	type token struct {
+		op   byte
+		data []byte
+	}
+	var tokens []token
+	tok := txscript.MakeScriptTokenizer(0, script)
+	for tok.Next() {
+		tokens = append(tokens, token{tok.Opcode(), tok.Data()})
+	}
+
+	// smallInt reads OP_0..OP_16 or a short pushed number
+	smallInt := func(t token) (int, bool) {
+		switch {
+		case t.op == txscript.OP_0:
+			return 0, true
+		case t.op >= txscript.OP_1 && t.op <= txscript.OP_16:
+			return int(t.op-txscript.OP_1) + 1, true
+		case t.data != nil && len(t.data) <= 4:
+			return int(decodeScriptNum(t.data)), true
+		}
+		return 0, false
+	}
+
+	sigAdd := false
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch t.op {
+		case txscript.OP_IF, txscript.OP_NOTIF:
+			// OP_FALSE OP_IF never runs (data envelopes): skip its body
+			if t.op == txscript.OP_IF && i > 0 && tokens[i-1].op == txscript.OP_0 {
+				for depth := 1; depth > 0 && i+1 < len(tokens); {
+					i++
+					switch tokens[i].op {
+					case txscript.OP_IF, txscript.OP_NOTIF:
+						depth++
+					case txscript.OP_ENDIF:
+						depth--
+					}
+				}
+				continue
+			}
+			conditional = true
+		case txscript.OP_CHECKSIG, txscript.OP_CHECKSIGVERIFY:
+			required++
+		case txscript.OP_CHECKMULTISIG, txscript.OP_CHECKMULTISIGVERIFY:
+			// <m> <key>... <n> CHECKMULTISIG
+			if i < 1 {
+				continue
+			}
+			n, ok := smallInt(tokens[i-1])
+			if ok && i-2-n >= 0 {
+				if m, ok := smallInt(tokens[i-2-n]); ok {
+					required += m
+				}
+			}
+		case txscript.OP_CHECKSIGADD:
+			// <key> CHECKSIG <key> CHECKSIGADD ... <k> NUMEQUAL: the
+			// chain's opening CHECKSIG is replaced by the threshold
+			if !sigAdd {
+				sigAdd = true
+				required--
+			}
+		case txscript.OP_NUMEQUAL, txscript.OP_NUMEQUALVERIFY:
+			if sigAdd && i >= 2 && tokens[i-2].op == txscript.OP_CHECKSIGADD {
+				if k, ok := smallInt(tokens[i-1]); ok {
+					required += k
+				}
+				sigAdd = false
+			}
+		}
+	}
+	return max(required, 0), conditional
+}
+
+// SighashDefault names the implicit hash type of a 64-byte schnorr
+// signature, which commits like SIGHASH_ALL
+const SighashDefault = "SIGHASH_DEFAULT"
+
+// ParseSchnorrSignatures splits the signatures in a taproot witness into
+// the 64-byte BIP340 signature and the sighash byte appended to it, if any:
+// the key-path signature, or the 64/65-byte items below the script and
+// control block of a script-path spend. Returns nil for other input types.
+func ParseSchnorrSignatures(scriptType string, witness [][]byte) []types.SchnorrSignature {
+	var stack [][]byte
+	switch scriptType {
+	case "p2tr_keypath":
+		stack = witness[:min(len(witness), 1)]
+	case "p2tr_scriptpath":
+		if len(witness) >= 3 && len(witness[len(witness)-1]) > 0 && witness[len(witness)-1][0] == taprootAnnexTag {
+			witness = witness[:len(witness)-1]
+		}
+		if len(witness) < 2 {
+			return nil
+		}
+		stack = witness[:len(witness)-2]
+	default:
+		return nil
+	}
+
+	sigs := make([]types.SchnorrSignature, 0, len(stack))
+	for i, item := range stack {
+		if len(item) != 64 && len(item) != 65 {
+			continue
+		}
+		sig := types.SchnorrSignature{
+			WitnessIndex: i,
+			Signature:    hex.EncodeToString(item[:64]),
+			SighashType:  SighashDefault,
+		}
+		if len(item) == 65 {
+			// BIP341 allows only the defined types; an explicit 0x00 is
+			// invalid, as SIGHASH_DEFAULT must be left implicit
+			b := int(item[64])
+			sig.SighashByte = &b
+			sig.SighashType = SighashTypeName(item[64])
+			sig.SighashValid = b <= 0x03 && b != 0x00 || b >= 0x81 && b <= 0x83
+		} else {
+			sig.SighashValid = true
+		}
+		sigs = append(sigs, sig)
+	}
+	return sigs
+}
+
+// CommonSighashType returns the sighash type shared by all of an input's
+// signatures, given by name, or nil when it has none or they differ
+func CommonSighashType(names []string) *string {
+	if len(names) == 0 {
+		return nil
+	}
+	for _, name := range names[1:] {
+		if name != names[0] {
+			return nil
+		}
+	}
+	return &names[0]
+}
+
+// Where an ECDSASignature was found
+const (
+	SigFromScriptSig = "script_sig"
+	SigFromWitness   = "witness"
+)
+
+// ParseECDSASignatures finds the DER signatures in an input's scriptSig
+// pushes and witness items, leaving out the redeem or witness script, and
+// decodes each into r, s and its sighash flag. Any item tagged as a DER
+// sequence is taken, so encodings BIP66 rejects are reported as such
+// rather than missed. Returns nil for taproot inputs, whose signatures are
+// schnorr.
+func ParseECDSASignatures(scriptType string, scriptSig []byte, witness [][]byte) []types.ECDSASignature {
+	if scriptType == "p2tr_keypath" || scriptType == "p2tr_scriptpath" {
+		return nil
+	}
+	pushes, _, _ := scriptPushes(scriptSig)
+	switch scriptType {
+	case "p2sh", "p2sh-p2wpkh", "p2sh-p2wsh":
+		pushes = pushes[:max(len(pushes)-1, 0)]
+	}
+	switch scriptType {
+	case "p2wsh", "p2sh-p2wsh":
+		witness = witness[:max(len(witness)-1, 0)]
+	}
+
+	sigs := make([]types.ECDSASignature, 0)
+	add := func(source string, index int, item []byte) {
+		if len(item) < 9 || len(item) > 73 || item[0] != 0x30 {
+			return
+		}
+		sig := types.ECDSASignature{
+			Source:      source,
+			Index:       index,
+			Length:      len(item),
+			SighashByte: int(item[len(item)-1]),
+			SighashType: SighashTypeName(item[len(item)-1]),
+		}
+		if err := checkDEREncoding(item); err != nil {
+			sig.DERError = err.Error()
+		} else {
+			sig.DERValid = true
+		}
+		// r and s are read whenever their lengths fit, strict or not
+		der := item[:len(item)-1]
+		if rLen := int(der[3]); 6+rLen <= len(der) {
+			if sLen := int(der[5+rLen]); 6+rLen+sLen <= len(der) {
+				r, s := der[4:4+rLen], der[6+rLen:6+rLen+sLen]
+				lowS := new(big.Int).SetBytes(s).Cmp(halfOrder) <= 0
+				sig.R, sig.S, sig.LowS = hex.EncodeToString(r), hex.EncodeToString(s), &lowS
+			}
+		}
+		sigs = append(sigs, sig)
+	}
+	for i, push := range pushes {
+		add(SigFromScriptSig, i, push)
+	}
+	for i, item := range witness {
+		add(SigFromWitness, i, item)
+	}
+	return sigs
+}
+
+// checkDEREncoding applies the BIP66 strict DER rules to a signature with
+// its trailing sighash byte, as Bitcoin Core's IsValidSignatureEncoding does
+func checkDEREncoding(sig []byte) error {
+	switch {
+	case len(sig) < 9 || len(sig) > 73:
+		return fmt.Errorf("length %d is outside 9-73 bytes", len(sig))
+	case sig[0] != 0x30:
+		return errors.New("not a DER sequence")
+	case int(sig[1]) != len(sig)-3:
+		return fmt.Errorf("sequence length %d does not cover the signature", sig[1])
+	}
+	rLen := int(sig[3])
+	if 5+rLen >= len(sig) {
+		return fmt.Errorf("r length %d overruns the signature", rLen)
+	}
+	sLen := int(sig[5+rLen])
+	if rLen+sLen+7 != len(sig) {
+		return errors.New("r and s lengths do not add up to the signature")
+	}
+	for _, v := range []struct {
+		name   string
+		marker byte
+		n      []byte
+	}{{"r", sig[2], sig[4 : 4+rLen]}, {"s", sig[4+rLen], sig[6+rLen : 6+rLen+sLen]}} {
+		switch {
+		case v.marker != 0x02:
+			return fmt.Errorf("%s is not a DER integer", v.name)
+		case len(v.n) == 0:
+			return fmt.Errorf("%s is empty", v.name)
+		case v.n[0]&0x80 != 0:
+			return fmt.Errorf("%s is negative", v.name)
+		case len(v.n) > 1 && v.n[0] == 0x00 && v.n[1]&0x80 == 0:
+			return fmt.Errorf("%s has excess zero padding", v.name)
+		}
+	}
+	return nil
+}
+
+// FingerprintSignatures fills in the SignatureAnalysis of every input with
+// ECDSA signatures. An r value that fits in 32 bytes comes up for half of
+// all nonces, so a wallet is only taken to grind for low R (as Bitcoin Core
+// does) when every ECDSA signature of the transaction has one and there
+// are at least two of them: one wallet usually signs every input.
+func FingerprintSignatures(inputs []types.Input) {
+	total, lowR := 0, 0
+	for i := range inputs {
+		var a types.SignatureAnalysis
+		for _, sig := range inputs[i].ECDSASignatures {
+			if sig.LowS == nil {
+				continue // r and s could not be read
+			}
+			a.Signatures++
+			if len(sig.R) <= 64 {
+				a.LowR++
+			}
+			if *sig.LowS {
+				a.LowS++
+			} else {
+				a.HighS++
+			}
+		}
+		if a.Signatures == 0 {
+			continue
+		}
+		total += a.Signatures
+		lowR += a.LowR
+		inputs[i].SignatureAnalysis = &a
+	}
+	if total == 0 {
+		return
+	}
+
+	chance := 1.0
+	if lowR == total {
+		chance = math.Pow(0.5, float64(total))
+	}
+	for i := range inputs {
+		if a := inputs[i].SignatureAnalysis; a != nil {
+			a.LowRGrinding = lowR == total && total >= 2
+			a.LowRChance = chance
+		}
+	}
+}
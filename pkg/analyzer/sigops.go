@@ -0,0 +1,65 @@
+package analyzer
+
+import (
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Limits on sigop cost
+const (
+	MaxBlockSigopsCost      = blockchain.MaxBlockSigOpsCost // MAX_BLOCK_SIGOPS_COST
+	MaxStandardTxSigopsCost = MaxBlockSigopsCost / 5        // MAX_STANDARD_TX_SIGOPS_COST
+)
+
+// CountSigops counts the signature operations of a transaction the way
+// consensus does and sets each input's share on it. Legacy sigops are those
+// of the scriptSigs and output scripts, counted without regard to multisig
+// key counts; P2SH sigops those of redeem scripts, counted precisely; and
+// witness sigops those of segwit v0 programs. Legacy and P2SH sigops cost 4
+// each, witness sigops 1. Inputs with a missing prevout contribute only
+// their legacy sigops and leave the total partial. inputs and prevout
+// scripts are indexed like tx.TxIn.
+func CountSigops(tx *wire.MsgTx, inputs []types.Input, scripts [][]byte) *types.SigopCount {
+	total := &types.SigopCount{Limit: MaxStandardTxSigopsCost}
+	coinbase := blockchain.IsCoinBaseTx(tx)
+	for i, txIn := range tx.TxIn {
+		count := types.SigopCount{Legacy: txscript.GetSigOpCount(txIn.SignatureScript)}
+		switch {
+		case coinbase:
+		case inputs[i].PrevoutMissing:
+			total.Partial = true
+		default:
+			if txscript.IsPayToScriptHash(scripts[i]) {
+				count.P2SH = txscript.GetPreciseSigOpCount(txIn.SignatureScript, scripts[i], true)
+			}
+			count.Witness = txscript.GetWitnessSigOpCount(txIn.SignatureScript, scripts[i], txIn.Witness)
+		}
+		count.Cost = sigopCost(count)
+		inputs[i].Sigops = &count
+		AddSigops(total, count)
+	}
+	for _, txOut := range tx.TxOut {
+		total.Legacy += txscript.GetSigOpCount(txOut.PkScript)
+	}
+	total.Cost = sigopCost(*total)
+	total.OverLimit = total.Cost > total.Limit
+	return total
+}
+
+// AddSigops adds count into total, as a block sums its transactions, and
+// rechecks total against its limit
+func AddSigops(total *types.SigopCount, count types.SigopCount) {
+	total.Legacy += count.Legacy
+	total.P2SH += count.P2SH
+	total.Witness += count.Witness
+	total.Cost += count.Cost
+	total.Partial = total.Partial || count.Partial
+	total.OverLimit = total.Limit > 0 && total.Cost > total.Limit
+}
+
+func sigopCost(c types.SigopCount) int {
+	return (c.Legacy+c.P2SH)*blockchain.WitnessScaleFactor + c.Witness
+}
@@ -0,0 +1,110 @@
+package analyzer
+
+import (
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Bitcoin Core relay policy limits on transactions (policy/policy.h)
+const (
+	MaxStandardTxWeight     = 400000 // MAX_STANDARD_TX_WEIGHT
+	MinStandardTxSize       = 65     // MIN_STANDARD_TX_NONWITNESS_SIZE
+	MaxStandardScriptSigLen = 1650   // MAX_STANDARD_SCRIPTSIG_SIZE
+	MaxDataCarrierBytes     = 83     // MAX_OP_RETURN_RELAY, the -datacarriersize default before v30
+	maxStandardMultisigKeys = 3      // bare multisig beyond 3 keys is not relayed
+)
+
+// Transaction policy rules
+const (
+	RuleVersion          = "version"             // nVersion outside 1..3
+	RuleTxWeight         = "tx_weight"           // over 400,000 weight units
+	RuleTxSizeSmall      = "tx_size_small"       // under 65 bytes without witness
+	RuleScriptSigSize    = "scriptsig_size"      // a scriptSig over 1,650 bytes
+	RuleScriptSigPush    = "scriptsig_push_only" // a scriptSig with non-push opcodes
+	RuleScriptPubkey     = "scriptpubkey"        // an output script of no standard type
+	RuleDataCarrierSize  = "datacarrier_size"    // an OP_RETURN script over 83 bytes
+	RuleMultiOpReturn    = "multi_op_return"     // more than one OP_RETURN output
+	RuleDust             = "dust"                // an output below its dust threshold
+	RuleWitnessNonstd    = "witness_nonstandard" // an input breaking witness limits
+	RuleBareMultisigKeys = "bare_multisig_keys"  // bare multisig over 3 keys
+	RuleTxSigops         = "tx_sigops"           // a sigop cost over 16,000
+)
+
+// CheckStandardness applies Bitcoin Core's IsStandardTx rules to a
+// non-coinbase transaction and returns the violations, empty when it would
+// be relayed. Dust thresholds follow Core's per-script formula at the
+// current DustRelayFeeRate; OP_RETURN data is held to Core's limits before
+// v30 (83 bytes, one output). inputs are the analyzed inputs, whose
+// WitnessPolicyViolations count against the witness rule; sigops, when
+// counted, is held to MaxStandardTxSigopsCost.
+func CheckStandardness(tx *wire.MsgTx, inputs []types.Input, sigops *types.SigopCount) []types.PolicyViolation {
+	violations := make([]types.PolicyViolation, 0)
+	add := func(rule string, limit, actual int, vin, vout *int) {
+		violations = append(violations, types.PolicyViolation{Rule: rule, Limit: limit, Actual: actual, Vin: vin, Vout: vout})
+	}
+
+	if tx.Version < minStandardVersion || tx.Version > maxStandardVersion {
+		add(RuleVersion, maxStandardVersion, int(tx.Version), nil, nil)
+	}
+	if weight := tx.SerializeSizeStripped()*(blockchain.WitnessScaleFactor-1) + tx.SerializeSize(); weight > MaxStandardTxWeight {
+		add(RuleTxWeight, MaxStandardTxWeight, weight, nil, nil)
+	}
+	if size := tx.SerializeSizeStripped(); size < MinStandardTxSize {
+		add(RuleTxSizeSmall, MinStandardTxSize, size, nil, nil)
+	}
+	if sigops != nil && sigops.Cost > MaxStandardTxSigopsCost {
+		add(RuleTxSigops, MaxStandardTxSigopsCost, sigops.Cost, nil, nil)
+	}
+
+	for i, txIn := range tx.TxIn {
+		vin := i
+		if n := len(txIn.SignatureScript); n > MaxStandardScriptSigLen {
+			add(RuleScriptSigSize, MaxStandardScriptSigLen, n, &vin, nil)
+		}
+		if !txscript.IsPushOnlyScript(txIn.SignatureScript) {
+			add(RuleScriptSigPush, 0, len(txIn.SignatureScript), &vin, nil)
+		}
+		if i < len(inputs) && len(inputs[i].WitnessPolicyViolations) > 0 {
+			add(RuleWitnessNonstd, 0, len(inputs[i].WitnessPolicyViolations), &vin, nil)
+		}
+	}
+
+	dustRate := &WarningThresholds{DustRelayFeeRate: warningThresholds.Load().DustRelayFeeRate}
+	opReturns := 0
+	for i, txOut := range tx.TxOut {
+		vout := i
+		script := txOut.PkScript
+		if len(script) > 0 && script[0] == txscript.OP_RETURN {
+			opReturns++
+			if opReturns == 2 {
+				add(RuleMultiOpReturn, 1, 2, nil, &vout)
+			}
+			if !txscript.IsPushOnlyScript(script[1:]) {
+				add(RuleScriptPubkey, 0, len(script), nil, &vout)
+			} else if len(script) > MaxDataCarrierBytes {
+				add(RuleDataCarrierSize, MaxDataCarrierBytes, len(script), nil, &vout)
+			}
+			continue
+		}
+		// Unknown witness versions (P2A anchors among them) are standard
+		// to create
+		switch txscript.GetScriptClass(script) {
+		case txscript.NonStandardTy:
+			if !txscript.IsWitnessProgram(script) {
+				add(RuleScriptPubkey, 0, len(script), nil, &vout)
+				continue
+			}
+		case txscript.MultiSigTy:
+			if keys, _, err := txscript.CalcMultiSigStats(script); err == nil && keys > maxStandardMultisigKeys {
+				add(RuleBareMultisigKeys, maxStandardMultisigKeys, keys, nil, &vout)
+			}
+		}
+		if threshold := dustThreshold(script, dustRate); txOut.Value < threshold {
+			add(RuleDust, int(threshold), int(txOut.Value), nil, &vout)
+		}
+	}
+	return violations
+}
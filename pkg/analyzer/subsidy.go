@@ -0,0 +1,20 @@
+package analyzer
+
+import "github.com/btcsuite/btcd/blockchain"
+
+// BlockSubsidy returns the new coins a block at height may create on the
+// network: 50 BTC, halved every 210,000 blocks on mainnet (150 on regtest)
+// until it reaches zero
+func BlockSubsidy(height int64, network string) int64 {
+	return blockchain.CalcBlockSubsidy(int32(height), GetNetworkParams(network))
+}
+
+// CheckCoinbaseClaim compares what a coinbase pays out with what it may
+// claim, the subsidy plus the block's fees. It reports whether the coinbase
+// overpays and how much of the claimable amount it leaves unclaimed.
+func CheckCoinbaseClaim(paidSats, claimableSats int64) (overpaid bool, unclaimedSats int64) {
+	if paidSats > claimableSats {
+		return true, 0
+	}
+	return false, claimableSats - paidSats
+}
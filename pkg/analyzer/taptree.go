@@ -0,0 +1,235 @@
+package analyzer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// Kinds of TapTreeNode
+const (
+	TapNodeLeaf   = "leaf"
+	TapNodeBranch = "branch"
+	TapNodeHidden = "hidden" // a subtree known only by its hash
+)
+
+// Sources of a TapTree
+const (
+	TapTreeSourceSpends = "spends" // merged from script-path spends
+	TapTreeSourceLeaves = "leaves" // built from the fixture's full leaf list
+)
+
+// maxTapTreeDepth is the deepest a leaf may sit, as a control block holds
+// at most 128 path hashes
+const maxTapTreeDepth = txscript.ControlBlockMaxNodeCount
+
+// tapNode is a node of a partially known taptree: a leaf with its script,
+// or a branch with its two children in hash order
+type tapNode struct {
+	leaf        bool
+	leafVersion int
+	script      []byte
+	children    [2]chainhash.Hash
+}
+
+// tapTree holds the nodes of one taptree by hash
+type tapTree struct {
+	nodes map[chainhash.Hash]*tapNode
+}
+
+func newTapTree() *tapTree {
+	return &tapTree{nodes: make(map[chainhash.Hash]*tapNode)}
+}
+
+func (t *tapTree) addLeaf(version int, script []byte) chainhash.Hash {
+	hash := txscript.NewTapLeaf(txscript.TapscriptLeafVersion(version), script).TapHash()
+	t.nodes[hash] = &tapNode{leaf: true, leafVersion: version, script: script}
+	return hash
+}
+
+func (t *tapTree) addBranch(a, b chainhash.Hash) chainhash.Hash {
+	if bytes.Compare(a[:], b[:]) > 0 {
+		a, b = b, a
+	}
+	hash := *chainhash.TaggedHash(chainhash.TagTapBranch, a[:], b[:])
+	t.nodes[hash] = &tapNode{children: [2]chainhash.Hash{a, b}}
+	return hash
+}
+
+// render lays out the subtree under hash, counting the leaves it reveals
+// and whether any part of it stays hidden
+func (t *tapTree) render(hash chainhash.Hash, depth int, leaves *int, complete *bool) types.TapTreeNode {
+	node := types.TapTreeNode{Hash: hex.EncodeToString(hash[:]), Depth: depth}
+	n, ok := t.nodes[hash]
+	switch {
+	case !ok:
+		node.Kind = TapNodeHidden
+		*complete = false
+	case n.leaf:
+		version := n.leafVersion
+		node.Kind = TapNodeLeaf
+		node.LeafVersion = &version
+		node.ScriptHex = hex.EncodeToString(n.script)
+		node.ScriptAsm = DisassembleScript(n.script)
+		*leaves++
+	default:
+		node.Kind = TapNodeBranch
+		node.Children = []types.TapTreeNode{
+			t.render(n.children[0], depth+1, leaves, complete),
+			t.render(n.children[1], depth+1, leaves, complete),
+		}
+	}
+	return node
+}
+
+// report renders the tree under root as committed to by internalKey
+func (t *tapTree) report(source, outputKey, internalKey string, root chainhash.Hash) types.TapTree {
+	tree := types.TapTree{
+		Source:      source,
+		OutputKey:   outputKey,
+		InternalKey: internalKey,
+		MerkleRoot:  hex.EncodeToString(root[:]),
+		Complete:    true,
+	}
+	tree.Root = t.render(root, 0, &tree.RevealedLeaves, &tree.Complete)
+	return tree
+}
+
+// TapTreeBuilder merges the script-path spends of each taproot output key
+// into as much of its script tree as they reveal: every spend contributes
+// its leaf and the sibling hashes on the path to the root, so spends of
+// different leaves fill in the branches between them. Only spends whose
+// commitment to the output key verified are taken.
+type TapTreeBuilder struct {
+	trees map[string]*spentTapTree
+}
+
+type spentTapTree struct {
+	*tapTree
+	internalKey string
+	root        chainhash.Hash
+	spends      int
+}
+
+// NewTapTreeBuilder returns an empty builder
+func NewTapTreeBuilder() *TapTreeBuilder {
+	return &TapTreeBuilder{trees: make(map[string]*spentTapTree)}
+}
+
+// Add takes an input if it is a verified script-path spend
+func (b *TapTreeBuilder) Add(in *types.Input) {
+	cb := in.Taproot
+	if cb == nil || cb.CommitmentValid == nil || !*cb.CommitmentValid || len(in.Prevout.ScriptPubkeyHex) != 68 {
+		return
+	}
+	script := tapLeafScript(in.Witness)
+	if script == nil {
+		return
+	}
+	outputKey := in.Prevout.ScriptPubkeyHex[4:]
+	tree, ok := b.trees[outputKey]
+	if !ok {
+		tree = &spentTapTree{tapTree: newTapTree(), internalKey: cb.InternalKey}
+		b.trees[outputKey] = tree
+	}
+	tree.spends++
+
+	hash := tree.addLeaf(cb.LeafVersion, script)
+	for _, sibling := range cb.MerklePath {
+		var node chainhash.Hash
+		if raw, err := hex.DecodeString(sibling); err == nil && len(raw) == chainhash.HashSize {
+			copy(node[:], raw)
+		}
+		hash = tree.addBranch(hash, node)
+	}
+	tree.root = hash
+}
+
+// Trees returns the trees of output keys spent at least minSpends times,
+// ordered by output key, or nil when there are none
+func (b *TapTreeBuilder) Trees(minSpends int) []types.TapTree {
+	var trees []types.TapTree
+	for outputKey, tree := range b.trees {
+		if tree.spends < minSpends {
+			continue
+		}
+		report := tree.report(TapTreeSourceSpends, outputKey, tree.internalKey, tree.root)
+		report.Spends = tree.spends
+		trees = append(trees, report)
+	}
+	sort.Slice(trees, func(i, j int) bool { return trees[i].OutputKey < trees[j].OutputKey })
+	return trees
+}
+
+// tapLeafScript returns the script revealed by a script-path witness: the
+// item before the control block, once any annex is set aside
+func tapLeafScript(witness []types.HexBytes) []byte {
+	if len(witness) >= 3 && len(witness[len(witness)-1]) > 0 && witness[len(witness)-1][0] == taprootAnnexTag {
+		witness = witness[:len(witness)-1]
+	}
+	if len(witness) < 2 {
+		return nil
+	}
+	return witness[len(witness)-2]
+}
+
+// TapTreeFromLeaves builds a complete taptree from its leaves listed depth
+// first with their depths, as a PSBT's tap tree field lists them, and
+// returns it with its merkle root. Leaf versions default to tapscript
+// (0xc0). The output and internal keys of the report are left to the
+// caller.
+func TapTreeFromLeaves(leaves []types.TapLeafHint) (types.TapTree, []byte, error) {
+	if len(leaves) == 0 {
+		return types.TapTree{}, nil, errors.New("no leaves")
+	}
+	type stacked struct {
+		depth int
+		hash  chainhash.Hash
+	}
+	tree := newTapTree()
+	var stack []stacked
+	for i, leaf := range leaves {
+		if leaf.Depth < 0 || leaf.Depth > maxTapTreeDepth {
+			return types.TapTree{}, nil, fmt.Errorf("leaf %d: depth %d is out of range", i, leaf.Depth)
+		}
+		if len(stack) > 0 && stack[0].depth == 0 {
+			return types.TapTree{}, nil, fmt.Errorf("leaf %d: the tree is already complete", i)
+		}
+		version := int(txscript.BaseLeafVersion)
+		if leaf.LeafVersion != nil {
+			version = *leaf.LeafVersion
+		}
+		if version < 0 || version > 0xfe || version&1 != 0 {
+			return types.TapTree{}, nil, fmt.Errorf("leaf %d: invalid leaf version %d", i, version)
+		}
+		script, err := hex.DecodeString(leaf.ScriptHex)
+		if err != nil {
+			return types.TapTree{}, nil, fmt.Errorf("leaf %d: invalid script_hex: %w", i, err)
+		}
+
+		// Pair the new leaf with its left sibling for as long as one is
+		// waiting at the same depth
+		top := stacked{leaf.Depth, tree.addLeaf(version, script)}
+		for len(stack) > 0 && stack[len(stack)-1].depth == top.depth {
+			left := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			top = stacked{top.depth - 1, tree.addBranch(left.hash, top.hash)}
+		}
+		if len(stack) > 0 && stack[len(stack)-1].depth > top.depth {
+			return types.TapTree{}, nil, fmt.Errorf("leaf %d: depth %d leaves a sibling unpaired", i, leaf.Depth)
+		}
+		stack = append(stack, top)
+	}
+	if len(stack) != 1 || stack[0].depth != 0 {
+		return types.TapTree{}, nil, errors.New("leaves do not form a complete tree")
+	}
+	root := stack[0].hash
+	return tree.report(TapTreeSourceLeaves, "", "", root), root[:], nil
+}
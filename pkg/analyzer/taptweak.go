@@ -0,0 +1,102 @@
+package analyzer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// Sources of the internal key checked by a TaprootTweakCheck
+const (
+	TweakSourceFixture      = "fixture"       // supplied by the caller
+	TweakSourceControlBlock = "control_block" // revealed by a script-path spend
+)
+
+// VerifyTaprootTweak checks that tweaking internalKey (32-byte x-only) with
+// merkleRoot (empty for a key-path-only output) gives outputKey, the
+// x-only key in a P2TR scriptPubKey
+func VerifyTaprootTweak(internalKey, merkleRoot, outputKey []byte) (*types.TaprootTweakCheck, error) {
+	pub, err := schnorr.ParsePubKey(internalKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid internal key: %w", err)
+	}
+	if len(merkleRoot) != 0 && len(merkleRoot) != 32 {
+		return nil, fmt.Errorf("merkle root must be 32 bytes, got %d", len(merkleRoot))
+	}
+	tweaked := schnorr.SerializePubKey(txscript.ComputeTaprootOutputKey(pub, merkleRoot))
+	check := &types.TaprootTweakCheck{
+		Source:      TweakSourceFixture,
+		InternalKey: hex.EncodeToString(internalKey),
+		TweakedKey:  hex.EncodeToString(tweaked),
+		TweakValid:  bytes.Equal(tweaked, outputKey),
+	}
+	if len(merkleRoot) > 0 {
+		root := hex.EncodeToString(merkleRoot)
+		check.MerkleRoot = &root
+	}
+	return check, nil
+}
+
+// VerifyScriptPathTweak checks a script-path spend's commitment: the merkle
+// root is rebuilt from the revealed script and the control block's path,
+// and the control block's internal key tweaked with it must give
+// outputKey with the parity the control block claims
+func VerifyScriptPathTweak(controlBlock, script, outputKey []byte) (*types.TaprootTweakCheck, error) {
+	cb, err := txscript.ParseControlBlock(controlBlock)
+	if err != nil {
+		return nil, err
+	}
+	root := cb.RootHash(script)
+	check, err := VerifyTaprootTweak(schnorr.SerializePubKey(cb.InternalKey), root, outputKey)
+	if err != nil {
+		return nil, err
+	}
+	check.Source = TweakSourceControlBlock
+	full := txscript.ComputeTaprootOutputKey(cb.InternalKey, root).SerializeCompressed()
+	parity := (full[0] == 0x03) == cb.OutputKeyYIsOdd
+	check.ParityValid = &parity
+	return check, nil
+}
+
+// DecodeControlBlock lays out the control block of a script-path spend: its
+// leaf version and output key parity, the internal key, and the merkle path
+// from the revealed script's leaf to the root. CommitmentValid is left null;
+// VerifyScriptPathTweak checks the commitment and CommitScriptPath records
+// its outcome here.
+func DecodeControlBlock(controlBlock, script []byte) (*types.TaprootControlBlock, error) {
+	cb, err := txscript.ParseControlBlock(controlBlock)
+	if err != nil {
+		return nil, err
+	}
+	leaf := txscript.NewTapLeaf(cb.LeafVersion, script).TapHash()
+	decoded := &types.TaprootControlBlock{
+		LeafVersion: int(cb.LeafVersion),
+		InternalKey: hex.EncodeToString(schnorr.SerializePubKey(cb.InternalKey)),
+		MerklePath:  make([]string, 0, len(cb.InclusionProof)/32),
+		LeafHash:    hex.EncodeToString(leaf[:]),
+		MerkleRoot:  hex.EncodeToString(cb.RootHash(script)),
+	}
+	if cb.OutputKeyYIsOdd {
+		decoded.OutputKeyParity = 1
+	}
+	for i := 0; i+32 <= len(cb.InclusionProof); i += 32 {
+		decoded.MerklePath = append(decoded.MerklePath, hex.EncodeToString(cb.InclusionProof[i:i+32]))
+	}
+	return decoded, nil
+}
+
+// CommitScriptPath sets a decoded control block's CommitmentValid from the
+// tweak check of the same spend: the leaf is committed to when the tweaked
+// key and its parity both match the prevout's
+func CommitScriptPath(decoded *types.TaprootControlBlock, check *types.TaprootTweakCheck) {
+	if decoded == nil || check == nil || check.ParityValid == nil {
+		return
+	}
+	valid := check.TweakValid && *check.ParityValid
+	decoded.CommitmentValid = &valid
+}
@@ -1,5 +1,10 @@
 package analyzer
 
+import (
+	"fmt"
+	"strconv"
+)
+
 // GetLocktimeType determines if locktime is block height, timestamp, or none
 func GetLocktimeType(locktime uint32) string {
 	if locktime == 0 {
@@ -35,6 +40,47 @@ func ParseRelativeTimelock(sequence uint32) (enabled bool, tlType string, value
 	return true, "blocks", value
 }
 
+// DecodeSequence describes an input's sequence in words: "final",
+// "locktime enabled, no RBF", "opt-in RBF, no CSV", "CSV: 144 blocks" or
+// "CSV: ~3.6 days (612×512s)". A CSV lock also signals RBF. BIP68 only
+// applies from transaction version 2, so older versions never decode as CSV.
+func DecodeSequence(sequence uint32, txVersion int32) string {
+	switch {
+	case sequence == 0xffffffff:
+		return "final"
+	case sequence == 0xfffffffe:
+		return "locktime enabled, no RBF"
+	}
+	enabled, tlType, value := ParseRelativeTimelock(sequence)
+	if !enabled {
+		return "opt-in RBF, no CSV"
+	}
+	if txVersion < 2 {
+		return "opt-in RBF, no CSV (BIP68 needs version 2)"
+	}
+	if tlType == "blocks" {
+		if value == 1 {
+			return "CSV: 1 block"
+		}
+		return fmt.Sprintf("CSV: %d blocks", value)
+	}
+	return fmt.Sprintf("CSV: ~%s (%d×512s)", approxDuration(value), value/512)
+}
+
+// approxDuration formats seconds in the largest unit that fits, to one decimal
+func approxDuration(secs uint32) string {
+	units := []struct {
+		name string
+		secs float64
+	}{{"days", 86400}, {"hours", 3600}, {"minutes", 60}}
+	for _, u := range units {
+		if float64(secs) >= u.secs {
+			return strconv.FormatFloat(float64(secs)/u.secs, 'f', 1, 64) + " " + u.name
+		}
+	}
+	return fmt.Sprintf("%d seconds", secs)
+}
+
 // IsRBFSignaling checks if transaction signals BIP125 replaceability
 func IsRBFSignaling(sequences []uint32) bool {
 	// Any input with sequence < 0xfffffffe signals RBF
@@ -0,0 +1,83 @@
+package analyzer
+
+import (
+	"math"
+	"sort"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+// BlockSeriesPoint reduces an analyzed block to one row of a time series:
+// fees, weight, median fee rate, output script-type mix, segwit and taproot
+// adoption, and coin days destroyed. Coin days destroyed sums each spent
+// coin's value in BTC times its age in days, over inputs with a known age.
+func BlockSeriesPoint(block *types.BlockOutput) types.BlockSeriesPoint {
+	point := types.BlockSeriesPoint{
+		Height:          block.Coinbase.Bip34Height,
+		BlockHash:       block.BlockHeader.BlockHash,
+		Timestamp:       block.BlockHeader.Timestamp,
+		Stale:           block.Stale,
+		TxCount:         block.TxCount,
+		TotalFeesSats:   block.BlockStats.TotalFeesSats,
+		TotalWeight:     block.BlockStats.TotalWeight,
+		AvgFeeRateSatVb: math.Round(block.BlockStats.AvgFeeRateSatVb*100) / 100,
+		ScriptTypes:     block.BlockStats.ScriptTypeSummary,
+	}
+
+	var feeRates []float64
+	var segwitTxs, inputs, taprootInputs int
+	var coinDays float64
+	for i, tx := range block.Transactions {
+		if i == 0 {
+			continue
+		}
+		if tx.FeeRateSatVb != nil {
+			feeRates = append(feeRates, *tx.FeeRateSatVb)
+		}
+		if tx.Segwit {
+			segwitTxs++
+		}
+		for _, in := range tx.Vin {
+			inputs++
+			if in.ScriptType == "p2tr_keypath" || in.ScriptType == "p2tr_scriptpath" {
+				taprootInputs++
+			}
+			if in.CoinAge != nil {
+				coinDays += float64(in.Prevout.ValueSats) / 1e8 * float64(in.CoinAge.Blocks) / blocksPerDay
+			}
+		}
+	}
+	var outputs int
+	for _, n := range block.BlockStats.ScriptTypeSummary {
+		outputs += n
+	}
+
+	point.MedianFeeRateSatVb = median(feeRates)
+	point.SegwitTxPct = percent(segwitTxs, len(block.Transactions)-1)
+	point.TaprootInputPct = percent(taprootInputs, inputs)
+	point.TaprootOutputPct = percent(block.BlockStats.ScriptTypeSummary["p2tr"], outputs)
+	point.CoinDaysDestroyed = math.Round(coinDays*100) / 100
+	return point
+}
+
+// median returns the median of values rounded to 2 decimal places, or 0
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	mid := len(values) / 2
+	m := values[mid]
+	if len(values)%2 == 0 {
+		m = (values[mid-1] + values[mid]) / 2
+	}
+	return math.Round(m*100) / 100
+}
+
+// percent returns part/total as a percentage with 2 decimal places, or 0
+func percent(part, total int) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(total)*10000) / 100
+}
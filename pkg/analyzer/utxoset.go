@@ -0,0 +1,72 @@
+package analyzer
+
+import (
+	"sort"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+// utxoValueBucketEdges are the lower bounds (sats) of the coin value
+// histogram buckets, one per power of ten; the last bucket is open-ended
+var utxoValueBucketEdges = []int64{0, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8, 1e9}
+
+// UTXOSetAggregator accumulates the coins of a UTXO set into script-type
+// and value totals in constant memory
+type UTXOSetAggregator struct {
+	stats    types.UTXOSetStats
+	lastTxid string
+}
+
+// NewUTXOSetAggregator returns an empty aggregator
+func NewUTXOSetAggregator() *UTXOSetAggregator {
+	a := &UTXOSetAggregator{stats: types.UTXOSetStats{
+		ScriptTypes:  make(map[string]types.UTXOTotal),
+		ValueBuckets: make([]types.UTXOValueBucket, len(utxoValueBucketEdges)),
+	}}
+	for i, edge := range utxoValueBucketEdges {
+		a.stats.ValueBuckets[i].MinSats = edge
+		if i+1 < len(utxoValueBucketEdges) {
+			upper := utxoValueBucketEdges[i+1]
+			a.stats.ValueBuckets[i].MaxSats = &upper
+		}
+	}
+	return a
+}
+
+// Add folds one coin into the totals. Coins of the same transaction are
+// expected next to each other, as snapshots store them.
+func (a *UTXOSetAggregator) Add(coin *types.UTXOCoin) {
+	s := &a.stats
+	if s.Coins == 0 || coin.Height < s.MinHeight {
+		s.MinHeight = coin.Height
+	}
+	if coin.Height > s.MaxHeight {
+		s.MaxHeight = coin.Height
+	}
+	s.Coins++
+	s.TotalValueSats += coin.ValueSats
+	if coin.Txid != a.lastTxid {
+		s.Txids++
+		a.lastTxid = coin.Txid
+	}
+	if coin.Coinbase {
+		s.CoinbaseCoins++
+	}
+
+	t := s.ScriptTypes[coin.ScriptType]
+	t.Coins++
+	t.ValueSats += coin.ValueSats
+	s.ScriptTypes[coin.ScriptType] = t
+
+	b := sort.Search(len(utxoValueBucketEdges), func(i int) bool { return utxoValueBucketEdges[i] > coin.ValueSats }) - 1
+	if b < 0 {
+		b = 0
+	}
+	s.ValueBuckets[b].Coins++
+	s.ValueBuckets[b].ValueSats += coin.ValueSats
+}
+
+// Report returns the totals of the coins added so far
+func (a *UTXOSetAggregator) Report() types.UTXOSetStats {
+	return a.stats
+}
@@ -0,0 +1,147 @@
+package analyzer
+
+import (
+	"errors"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// consensusScriptFlags are the script rules in force on every network
+// today. Blocks below a rule's activation height are checked without it,
+// as transactions confirmed there did not have to follow it.
+const consensusScriptFlags = txscript.ScriptBip16 |
+	txscript.ScriptVerifyDERSignatures |
+	txscript.ScriptVerifyCheckLockTimeVerify |
+	txscript.ScriptVerifyCheckSequenceVerify |
+	txscript.ScriptVerifyWitness |
+	txscript.ScriptStrictMultiSig |
+	txscript.ScriptVerifyTaproot
+
+// Activation heights of the soft forks chaincfg gives no height for
+const (
+	mainnetBIP16Height   = 173805 // P2SH
+	mainnetCSVHeight     = 419328 // BIP68, BIP112 and BIP113
+	mainnetSegwitHeight  = 481824 // BIP141, BIP143 and BIP147 (NULLDUMMY)
+	mainnetTaprootHeight = 709632 // BIP341 and BIP342
+
+	testnetCSVHeight    = 770112
+	testnetSegwitHeight = 834624
+)
+
+// scriptActivation is the height from which a network checks a rule
+type scriptActivation struct {
+	flags  txscript.ScriptFlags
+	height int64
+}
+
+// scriptActivations lists the rules a network activated after genesis.
+// Testnet3 enforces P2SH and taproot throughout, as Bitcoin Core does
+// there; testnet4 and signet enforce every rule from their first block.
+func scriptActivations(network string) []scriptActivation {
+	params := GetNetworkParams(network)
+	switch network {
+	case NetworkMainnet:
+		return []scriptActivation{
+			{txscript.ScriptBip16, mainnetBIP16Height},
+			{txscript.ScriptVerifyDERSignatures, int64(params.BIP0066Height)},
+			{txscript.ScriptVerifyCheckLockTimeVerify, int64(params.BIP0065Height)},
+			{txscript.ScriptVerifyCheckSequenceVerify, mainnetCSVHeight},
+			{txscript.ScriptVerifyWitness | txscript.ScriptStrictMultiSig, mainnetSegwitHeight},
+			{txscript.ScriptVerifyTaproot, mainnetTaprootHeight},
+		}
+	case NetworkTestnet:
+		return []scriptActivation{
+			{txscript.ScriptVerifyDERSignatures, int64(params.BIP0066Height)},
+			{txscript.ScriptVerifyCheckLockTimeVerify, int64(params.BIP0065Height)},
+			{txscript.ScriptVerifyCheckSequenceVerify, testnetCSVHeight},
+			{txscript.ScriptVerifyWitness | txscript.ScriptStrictMultiSig, testnetSegwitHeight},
+		}
+	}
+	return nil
+}
+
+// ScriptFlags returns the consensus script flags for a transaction
+// confirmed at height on network, or for the chain tip when height is nil
+func ScriptFlags(network string, height *int64) txscript.ScriptFlags {
+	flags := consensusScriptFlags
+	if height == nil {
+		return flags
+	}
+	for _, a := range scriptActivations(network) {
+		if *height < a.height {
+			flags &^= a.flags
+		}
+	}
+	return flags
+}
+
+// verifiedPrevoutTypes are the prevout script types VerifyInputs checks
+// the spends of. The prevout decides, not the input's classification,
+// which leaves a legacy p2sh spend "unknown".
+var verifiedPrevoutTypes = map[string]bool{
+	"p2pkh":  true,
+	"p2sh":   true,
+	"p2wpkh": true,
+	"p2wsh":  true,
+	"p2tr":   true,
+}
+
+// VerifyInputs executes the scripts of a transaction's p2pkh, p2sh, segwit
+// v0 and taproot key-path inputs against their prevouts, signature checks
+// included, and sets SignatureValid (and SignatureError on failure) on each
+// input checked. inputs and prevout scripts are indexed like tx.TxIn.
+// Inputs with a missing prevout are left unchecked, and so are key-path
+// inputs unless every prevout is known: the BIP341 sighash commits to the
+// amounts and scripts of all of them.
+func VerifyInputs(tx *wire.MsgTx, inputs []types.Input, scripts [][]byte, flags txscript.ScriptFlags) {
+	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+	allKnown := true
+	for i, txIn := range tx.TxIn {
+		// Every input needs an entry, if an empty one, for the sighash
+		// midstates to be computed
+		fetcher.AddPrevOut(txIn.PreviousOutPoint, wire.NewTxOut(inputs[i].Prevout.ValueSats, scripts[i]))
+		allKnown = allKnown && !inputs[i].PrevoutMissing
+	}
+	checked := func(i int, in *types.Input) bool {
+		switch prevoutType := ClassifyOutputScript(scripts[i]); {
+		case in.PrevoutMissing || !verifiedPrevoutTypes[prevoutType]:
+			return false
+		case prevoutType == "p2tr":
+			return in.ScriptType == "p2tr_keypath" && allKnown
+		}
+		return true
+	}
+
+	var sigHashes *txscript.TxSigHashes
+	for i := range tx.TxIn {
+		in := &inputs[i]
+		if !checked(i, in) {
+			continue
+		}
+		if sigHashes == nil {
+			sigHashes = txscript.NewTxSigHashes(tx, fetcher)
+		}
+		vm, err := txscript.NewEngine(scripts[i], tx, i, flags, nil, sigHashes, in.Prevout.ValueSats, fetcher)
+		if err == nil {
+			err = vm.Execute()
+		}
+		valid := err == nil
+		in.SignatureValid = &valid
+		if err != nil {
+			in.SignatureError = scriptErrorText(err)
+		}
+	}
+}
+
+// scriptErrorText describes a script failure. Some engine errors carry
+// only their code, as an invalid key-path signature does.
+func scriptErrorText(err error) string {
+	var scriptErr txscript.Error
+	if errors.As(err, &scriptErr) && scriptErr.Description == "" {
+		return scriptErr.ErrorCode.String()
+	}
+	return err.Error()
+}
@@ -0,0 +1,108 @@
+package analyzer
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// Spends confirmed in the first block of the blk04330 fixture, at height
+// 847493, with the prevouts its undo data records
+var verifyVectors = []struct {
+	name   string
+	tx     string
+	amount int64
+	script string
+}{
+	{
+		"p2pkh",
+		"01000000014531e1437544cfbab33d5dc49609b38254a54c6ee92a9386ce3d21e8d7c472e3010000006a47304402206902f61264371fa8dea4f631ae7326d1527be0ec754d4e8dcc0a2a9782a0889802202987a4ea1c584ed074ad4610aabe8bdfbe7b8fe3bbe958ebc798ab95a62608a001210382fb863afbbd1599b65b8a51206791c4a0a1b88f3e396db63be3e1cc19b285e1ffffffff01dc8a1900000000001600143c7d63cc2d24d6d1ad23d3dbfbbf7198c4f7423a00000000",
+		1702598, "76a914520749d9f1fec8f4e66681d27490504016f7abd388ac",
+	},
+	{
+		"p2wpkh",
+		"02000000000101f94e5b7580e9192c312a7cfc25c5fba45d7a3c41f45b9720479452428be9d62d0000000000fdffffff01373e1d0000000000160014deed344f976ce34b862fee7d2c7a26b07e837a8f0247304402201aced9a55ae7e30325031a503c9e48b7257c482a1c6a53ae995f59221cd2f448022023434362bda6a68bbc170c0fa7cb6db027b804d711f79cd68fa12207706cc08f01210259bfaa5a7cd44a54a2af922f58e04686c03c4ec78bcef36f0a356eaa59a00be784ee0c00",
+		1927361, "0014deed344f976ce34b862fee7d2c7a26b07e837a8f",
+	},
+	{
+		"p2sh-p2wsh",
+		"0200000000010123a98485c16bdd5c5019c1124b4a57f7aa25149f7de01f1fd5c78bf1569857f60200000023220020d2f5bb8c42db1f88cba675b50e397c3b04d745fd4c1c0a2874347f14f068d239fdffffff01a1800600000000001600144c0aaceaf3f52fcc0ec9eccc3377da4460043eb103473044022048b4c812566f6c77be3a0399eed01f5fd118a69c3090da5bdc651b1d99f32483022065537c6301a625fc611b620af74c73313e0a5c0551ff07c636152d0f6b858c45014730440220134c988dddbd1dd031265edbcab1d732f2507ab4d337809b18c604492234a61502205f49d2cc6c88bfdf97e148d2756ac45fcdde982809f0ffc78df7fb2269d04fac014e210395164e7d150bf056c45b6f8cde4b20c97e013a3ad97a1b470d5d14d227791d90ad2103a00d9c69235d9c83f31a0dc37df6a20b319dbc0b8fa9afec4a79b1ef67e40b06ac73640380ca00b2687dee0c00",
+		431309, "a9143c489da96c1190302f096da9f324d7333516856687",
+	},
+}
+
+func TestVerifyInputs(t *testing.T) {
+	height := int64(847493)
+	flags := ScriptFlags(NetworkMainnet, &height)
+
+	for _, v := range verifyVectors {
+		// The tampered copy flips a bit of the first signature's r value,
+		// leaving its DER encoding valid
+		for _, tampered := range []bool{false, true} {
+			tx := mustTx(t, v.tx)
+			if tampered {
+				if len(tx.TxIn[0].Witness) > 0 {
+					sig := tx.TxIn[0].Witness[0]
+					if len(sig) == 0 {
+						sig = tx.TxIn[0].Witness[1] // CHECKMULTISIG's dummy comes first
+					}
+					sig[8] ^= 0x01
+				} else {
+					tx.TxIn[0].SignatureScript[8] ^= 0x01
+				}
+			}
+			inputs, scripts := sighashInputs(t, tx, []int64{v.amount}, []string{v.script})
+			VerifyInputs(tx, inputs, scripts, flags)
+
+			got := inputs[0].SignatureValid
+			if got == nil || *got == tampered {
+				t.Errorf("%s (tampered %v): signature_valid %v, error %q", v.name, tampered, got, inputs[0].SignatureError)
+			}
+			if tampered && inputs[0].SignatureError == "" {
+				t.Errorf("%s (tampered): no signature_error", v.name)
+			}
+		}
+	}
+
+	t.Run("missing prevout", func(t *testing.T) {
+		tx := mustTx(t, verifyVectors[0].tx)
+		inputs, scripts := sighashInputs(t, tx, []int64{0}, []string{""})
+		inputs[0].PrevoutMissing = true
+		VerifyInputs(tx, inputs, scripts, flags)
+		if inputs[0].SignatureValid != nil {
+			t.Errorf("got signature_valid %v, want null", *inputs[0].SignatureValid)
+		}
+	})
+}
+
+func TestScriptFlags(t *testing.T) {
+	height := func(h int64) *int64 { return &h }
+	segwit := txscript.ScriptVerifyWitness | txscript.ScriptStrictMultiSig
+	for _, tt := range []struct {
+		network string
+		height  *int64
+		without txscript.ScriptFlags
+	}{
+		{NetworkMainnet, nil, 0},
+		{NetworkMainnet, height(847493), 0},
+		{NetworkMainnet, height(mainnetTaprootHeight - 1), txscript.ScriptVerifyTaproot},
+		{NetworkMainnet, height(mainnetSegwitHeight - 1), segwit | txscript.ScriptVerifyTaproot},
+		{NetworkMainnet, height(170000), consensusScriptFlags},
+		{NetworkTestnet, height(testnetSegwitHeight), 0},
+		{NetworkTestnet, height(testnetSegwitHeight - 1), segwit},
+		{NetworkTestnet, height(testnetCSVHeight - 1), segwit | txscript.ScriptVerifyCheckSequenceVerify},
+		{NetworkTestnet, height(0), consensusScriptFlags &^ (txscript.ScriptBip16 | txscript.ScriptVerifyTaproot)},
+		{NetworkTestnet4, height(0), 0},
+		{NetworkSignet, height(0), 0},
+	} {
+		want := consensusScriptFlags &^ tt.without
+		if got := ScriptFlags(tt.network, tt.height); got != want {
+			h := "tip"
+			if tt.height != nil {
+				h = strconv.FormatInt(*tt.height, 10)
+			}
+			t.Errorf("ScriptFlags(%s, %s): got %#x, want %#x", tt.network, h, got, want)
+		}
+	}
+}
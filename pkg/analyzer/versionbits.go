@@ -0,0 +1,85 @@
+package analyzer
+
+import (
+	"math"
+	"sort"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+// bip9Deployment is a mainnet BIP9 deployment and its signalling window.
+// Bits are reused once a window closes, so a bit is only named for blocks
+// timestamped inside its window.
+type bip9Deployment struct {
+	name           string
+	bit            int
+	start, timeout uint32
+}
+
+var mainnetDeployments = []bip9Deployment{
+	{"csv", 0, 1462060800, 1493596800},
+	{"segwit", 1, 1479168000, 1510704000},
+	{"taproot", 2, 1619222400, 1628640000},
+}
+
+// DeploymentForBit names the mainnet deployment a version bit signalled
+// for at the given block time, or returns "" when none was assigned
+func DeploymentForBit(bit int, timestamp uint32) string {
+	for _, d := range mainnetDeployments {
+		if d.bit == bit && timestamp >= d.start && timestamp < d.timeout {
+			return d.name
+		}
+	}
+	return ""
+}
+
+// VersionBitsCounter tallies the BIP9 bits signalled by a run of blocks
+type VersionBitsCounter struct {
+	blocks, bip9Blocks int
+	bits               map[int]*types.VersionBitSignal
+}
+
+// NewVersionBitsCounter returns an empty counter
+func NewVersionBitsCounter() *VersionBitsCounter {
+	return &VersionBitsCounter{bits: make(map[int]*types.VersionBitSignal)}
+}
+
+// Add counts one block by the bits SignalledVersionBits returned for its
+// version (nil when it predates BIP9) and its timestamp
+func (c *VersionBitsCounter) Add(bits []int, timestamp uint32) {
+	c.blocks++
+	if bits == nil {
+		return
+	}
+	c.bip9Blocks++
+	for _, bit := range bits {
+		sig, ok := c.bits[bit]
+		if !ok {
+			sig = &types.VersionBitSignal{Bit: bit}
+			c.bits[bit] = sig
+		}
+		sig.Blocks++
+		if sig.Deployment == "" {
+			sig.Deployment = DeploymentForBit(bit, timestamp)
+		}
+	}
+}
+
+// Report returns the tally, or nil when no blocks were counted
+func (c *VersionBitsCounter) Report() *types.VersionBitsSummary {
+	if c.blocks == 0 {
+		return nil
+	}
+	summary := &types.VersionBitsSummary{
+		Blocks:     c.blocks,
+		BIP9Blocks: c.bip9Blocks,
+		Bits:       make([]types.VersionBitSignal, 0, len(c.bits)),
+	}
+	for _, sig := range c.bits {
+		s := *sig
+		s.Pct = math.Round(float64(s.Blocks)/float64(c.blocks)*10000) / 100
+		summary.Bits = append(summary.Bits, s)
+	}
+	sort.Slice(summary.Bits, func(i, j int) bool { return summary.Bits[i].Bit < summary.Bits[j].Bit })
+	return summary
+}
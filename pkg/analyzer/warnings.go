@@ -1,25 +1,136 @@
 package analyzer
 
-import "chain-lens/pkg/types"
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
 
-// GenerateWarnings creates warning array based on transaction analysis
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// WarningThresholds are the limits that trigger HIGH_FEE, LOW_FEE,
+// ABSURD_FEE and DUST_OUTPUT
+type WarningThresholds struct {
+	HighFeeSats    int64   // HIGH_FEE when the fee exceeds this many sats
+	HighFeeRate    float64 // HIGH_FEE when the fee rate exceeds this (sat/vB)
+	LowFeeRate     float64 // LOW_FEE when the fee rate is below this (sat/vB)
+	DustOutputSats int64   // DUST_OUTPUT below this flat limit instead, when set
+
+	// DustRelayFeeRate (sat/vB, Bitcoin Core's -dustrelayfee) sets the
+	// default, per-script dust limit: the fee to create and later spend
+	// the output at this rate. DUST_OUTPUT fires for a non-OP_RETURN
+	// output below it unless DustOutputSats overrides it.
+	DustRelayFeeRate float64
+
+	// ABSURD_FEE when the fee exceeds this fraction of the total output
+	// value (0 disables), or, if AbsurdFeeSmallestOutput is set, when it
+	// exceeds the smallest non-OP_RETURN output. The default of half sits
+	// well above the 10%+ that small consolidations routinely pay.
+	AbsurdFeeFraction       float64
+	AbsurdFeeSmallestOutput bool
+}
+
+// DefaultWarningThresholds are the built-in limits
+var DefaultWarningThresholds = WarningThresholds{
+	HighFeeSats:    1000000,
+	HighFeeRate:    200,
+	LowFeeRate:     1, // Bitcoin Core's default minimum relay fee
+	DustOutputSats: 0, // Core's per-script limits at DustRelayFeeRate
+
+	DustRelayFeeRate:  3,
+	AbsurdFeeFraction: 0.5,
+}
+
+var warningThresholds atomic.Pointer[WarningThresholds]
+
+func init() {
+	SetWarningThresholds(DefaultWarningThresholds)
+}
+
+// SetWarningThresholds replaces the process-wide warning thresholds
+func SetWarningThresholds(t WarningThresholds) {
+	warningThresholds.Store(&t)
+}
+
+// IsDustOutput reports whether an output is dust under the current
+// DUST_OUTPUT threshold. OP_RETURN outputs are unspendable and never dust.
+func IsDustOutput(script []byte, valueSats int64) bool {
+	return valueSats < dustThreshold(script, warningThresholds.Load())
+}
+
+// Spend sizes Bitcoin Core assumes for the dust limit: outpoint, scriptSig
+// length, sequence and a 107-byte signature+pubkey, the latter discounted
+// as witness for witness programs
+const (
+	dustSpendSize        = 32 + 4 + 1 + 107 + 4
+	dustWitnessSpendSize = 32 + 4 + 1 + 107/4 + 4
+)
+
+// dustThreshold is the value below which an output paying to script is
+// dust: Core's GetDustThreshold at DustRelayFeeRate (546 sats for P2PKH,
+// 294 for P2WPKH at 3 sat/vB), or the flat DustOutputSats when set
+func dustThreshold(script []byte, t *WarningThresholds) int64 {
+	if len(script) > 0 && script[0] == txscript.OP_RETURN {
+		return 0
+	}
+	if t.DustOutputSats > 0 {
+		return t.DustOutputSats
+	}
+	size := 8 + wire.VarIntSerializeSize(uint64(len(script))) + len(script)
+	if txscript.IsWitnessProgram(script) {
+		size += dustWitnessSpendSize
+	} else {
+		size += dustSpendSize
+	}
+	// Core computes fees from a sat/kvB rate, truncating
+	return int64(size) * int64(math.Round(t.DustRelayFeeRate*1000)) / 1000
+}
+
+// GenerateWarnings creates warning array based on transaction analysis.
+// feeKnown is false when the fee could not be computed (missing prevouts,
+// coinbase); LOW_FEE is only raised for a known fee.
 func GenerateWarnings(
 	feeSats int64,
 	feeRate float64,
+	feeKnown bool,
 	rbfSignaling bool,
 	outputs []types.Output,
 ) []types.Warning {
 	warnings := make([]types.Warning, 0)
+	t := warningThresholds.Load()
 
-	// HIGH_FEE: fee > 1M sats OR fee rate > 200 sat/vB
-	if feeSats > 1000000 || feeRate > 200 {
+	// HIGH_FEE: fee > 1M sats OR fee rate > 200 sat/vB by default
+	if feeSats > t.HighFeeSats || feeRate > t.HighFeeRate {
 		warnings = append(warnings, types.Warning{Code: "HIGH_FEE"})
 	}
 
-	// DUST_OUTPUT: any non-OP_RETURN output < 546 sats
+	// LOW_FEE: fee rate below the 1 sat/vB minimum relay fee by default
+	if feeKnown && feeRate < t.LowFeeRate {
+		warnings = append(warnings, types.Warning{Code: "LOW_FEE"})
+	}
+
+	// ABSURD_FEE: fee over a configured fraction of the value sent
+	if feeKnown {
+		if w := absurdFeeWarning(feeSats, outputs, t); w != nil {
+			warnings = append(warnings, *w)
+		}
+	}
+
+	// DUST_OUTPUT: any non-OP_RETURN output below its dust limit. The
+	// context names the first dust output, the limit applied to it and
+	// the relay fee rate, or flat limit, it came from.
 	for _, out := range outputs {
-		if out.ScriptType != "op_return" && out.ValueSats < 546 {
-			warnings = append(warnings, types.Warning{Code: "DUST_OUTPUT"})
+		if limit := dustThreshold(out.ScriptPubkeyHex, t); out.ValueSats < limit {
+			context := map[string]interface{}{"vout": out.N, "threshold_sats": limit}
+			if t.DustOutputSats > 0 {
+				context["dust_output_sats"] = t.DustOutputSats
+			} else {
+				context["dust_relay_fee_sat_vb"] = t.DustRelayFeeRate
+			}
+			warnings = append(warnings, types.Warning{Code: "DUST_OUTPUT", Context: context})
 			break
 		}
 	}
@@ -39,3 +150,68 @@ func GenerateWarnings(
 
 	return warnings
 }
+
+// absurdFeeWarning catches fat-finger fees too small in absolute terms for
+// HIGH_FEE but out of proportion to the value moved. The context carries
+// the fee-to-output ratio and which rule fired.
+func absurdFeeWarning(feeSats int64, outputs []types.Output, t *WarningThresholds) *types.Warning {
+	var total int64
+	smallest := int64(-1)
+	for _, out := range outputs {
+		total += out.ValueSats
+		if out.ScriptType != "op_return" && (smallest < 0 || out.ValueSats < smallest) {
+			smallest = out.ValueSats
+		}
+	}
+	if feeSats <= 0 || total == 0 {
+		return nil
+	}
+
+	ratio := float64(feeSats) / float64(total)
+	context := map[string]interface{}{"fee_to_output_ratio": math.Round(ratio*10000) / 10000}
+	switch {
+	case t.AbsurdFeeFraction > 0 && ratio > t.AbsurdFeeFraction:
+		context["rule"] = "fraction"
+		context["threshold"] = t.AbsurdFeeFraction
+	case t.AbsurdFeeSmallestOutput && smallest >= 0 && feeSats > smallest:
+		context["rule"] = "smallest_output"
+		context["smallest_output_sats"] = smallest
+	default:
+		return nil
+	}
+	return &types.Warning{Code: "ABSURD_FEE", Context: context}
+}
+
+// Transaction versions Bitcoin Core relays: 1, 2 (BIP68) and 3 (TRUC, BIP431)
+const (
+	minStandardVersion = 1
+	maxStandardVersion = 3
+)
+
+// NonstandardVersionWarning returns a NONSTANDARD_VERSION warning for a
+// version Core would not relay, or nil. The context carries the version and
+// its class: "zero", "negative" (the high bit set, as signed int32), "next"
+// (4, the likely next version) or "arbitrary" (anything else, usually a
+// wallet or protocol fingerprint).
+func NonstandardVersionWarning(version int32) *types.Warning {
+	if version >= minStandardVersion && version <= maxStandardVersion {
+		return nil
+	}
+	class := "arbitrary"
+	switch {
+	case version == 0:
+		class = "zero"
+	case version < 0:
+		class = "negative"
+	case version == maxStandardVersion+1:
+		class = "next"
+	}
+	return &types.Warning{
+		Code: "NONSTANDARD_VERSION",
+		Context: map[string]interface{}{
+			"version":     version,
+			"version_hex": fmt.Sprintf("%08x", uint32(version)),
+			"class":       class,
+		},
+	}
+}
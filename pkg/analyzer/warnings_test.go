@@ -0,0 +1,58 @@
+package analyzer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+func TestDustThreshold(t *testing.T) {
+	hash20 := bytes.Repeat([]byte{0x11}, 20)
+	p2pkh := append(append([]byte{0x76, 0xa9, 0x14}, hash20...), 0x88, 0xac)
+	p2wpkh := append([]byte{0x00, 0x14}, hash20...)
+	p2tr := append([]byte{0x51, 0x20}, bytes.Repeat([]byte{0x22}, 32)...)
+	opReturn := []byte{0x6a, 0x01, 0x00}
+
+	def := DefaultWarningThresholds
+	flat := DefaultWarningThresholds
+	flat.DustOutputSats = 546
+	for _, tt := range []struct {
+		name   string
+		script []byte
+		t      *WarningThresholds
+		want   int64
+	}{
+		// Core's GetDustThreshold at the default 3 sat/vB
+		{"p2pkh", p2pkh, &def, 546},
+		{"p2wpkh", p2wpkh, &def, 294},
+		{"p2tr", p2tr, &def, 330},
+		{"op_return", opReturn, &def, 0},
+		{"flat p2wpkh", p2wpkh, &flat, 546},
+		{"flat op_return", opReturn, &flat, 0},
+	} {
+		if got := dustThreshold(tt.script, tt.t); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAbsurdFeeWarning(t *testing.T) {
+	outputs := []types.Output{{ValueSats: 10000, ScriptType: "p2wpkh"}}
+	off := DefaultWarningThresholds
+	off.AbsurdFeeFraction = 0
+	for _, tt := range []struct {
+		name string
+		fee  int64
+		t    *WarningThresholds
+		want bool
+	}{
+		{"default, consolidation-sized fee", 2000, &DefaultWarningThresholds, false},
+		{"default, fee over half the value sent", 6000, &DefaultWarningThresholds, true},
+		{"disabled", 6000, &off, false},
+	} {
+		if got := absurdFeeWarning(tt.fee, outputs, tt.t) != nil; got != tt.want {
+			t.Errorf("%s: warned=%v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package analyzer
+
+import (
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+// Bitcoin Core relay policy limits on witness data (policy/policy.h)
+const (
+	MaxP2WSHScriptSize     = 3600 // MAX_STANDARD_P2WSH_SCRIPT_SIZE
+	MaxP2WSHStackItems     = 100  // MAX_STANDARD_P2WSH_STACK_ITEMS
+	MaxP2WSHStackItemSize  = 80   // MAX_STANDARD_P2WSH_STACK_ITEM_SIZE
+	MaxTapscriptStackItem  = 80   // MAX_STANDARD_TAPSCRIPT_STACK_ITEM_SIZE
+	tapscriptLeafVersion   = 0xc0
+	taprootAnnexTag        = 0x50
+	taprootControlBaseSize = 33
+)
+
+// Witness policy rules
+const (
+	RuleAnnex             = "annex"               // taproot annex is reserved and non-standard
+	RuleP2WSHScriptSize   = "p2wsh_script_size"   // witnessScript over 3,600 bytes
+	RuleP2WSHStackItems   = "p2wsh_stack_items"   // more than 100 items besides the witnessScript
+	RuleP2WSHItemSize     = "p2wsh_item_size"     // a stack item over 80 bytes
+	RuleTapscriptItemSize = "tapscript_item_size" // a tapscript stack item over 80 bytes
+)
+
+// CheckWitnessPolicy checks an input's witness against Core's standardness
+// limits for its script type and returns the violations, or nil. Only
+// P2WSH (native or nested) and taproot inputs have witness limits.
+func CheckWitnessPolicy(scriptType string, witness [][]byte) []types.WitnessPolicyViolation {
+	var violations []types.WitnessPolicyViolation
+	add := func(rule string, limit, actual int, item *int) {
+		violations = append(violations, types.WitnessPolicyViolation{Rule: rule, Limit: limit, Actual: actual, Item: item})
+	}
+
+	switch scriptType {
+	case "p2wsh", "p2sh-p2wsh":
+		if len(witness) == 0 {
+			return nil
+		}
+		script, stack := witness[len(witness)-1], witness[:len(witness)-1]
+		if len(script) > MaxP2WSHScriptSize {
+			add(RuleP2WSHScriptSize, MaxP2WSHScriptSize, len(script), nil)
+		}
+		if len(stack) > MaxP2WSHStackItems {
+			add(RuleP2WSHStackItems, MaxP2WSHStackItems, len(stack), nil)
+		}
+		for i, item := range stack {
+			if len(item) > MaxP2WSHStackItemSize {
+				add(RuleP2WSHItemSize, MaxP2WSHStackItemSize, len(item), &i)
+			}
+		}
+
+	case "p2tr_keypath", "p2tr_scriptpath":
+		if len(witness) >= 2 {
+			if last := witness[len(witness)-1]; len(last) > 0 && last[0] == taprootAnnexTag {
+				add(RuleAnnex, 0, len(last), nil)
+				witness = witness[:len(witness)-1]
+			}
+		}
+		// Script path: <stack...> <script> <control block>; item limits
+		// only apply to tapscript (leaf version 0xc0)
+		if len(witness) < 2 {
+			break
+		}
+		control := witness[len(witness)-1]
+		if len(control) < taprootControlBaseSize || control[0]&0xfe != tapscriptLeafVersion {
+			break
+		}
+		for i, item := range witness[:len(witness)-2] {
+			if len(item) > MaxTapscriptStackItem {
+				add(RuleTapscriptItemSize, MaxTapscriptStackItem, len(item), &i)
+			}
+		}
+	}
+	return violations
+}
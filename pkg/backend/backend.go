@@ -0,0 +1,27 @@
+// Package backend installs the prevout resolver the configuration selects:
+// a bitcoind node (rpc), an Esplora REST API (esplora), an Electrum server
+// (electrum) or a UTXO snapshot file (snapshot). Config validation allows
+// at most one of them.
+package backend
+
+import (
+	"github.com/richochetclementine1315/BTC-Lens/pkg/backend/electrum"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/backend/esplora"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/backend/rpc"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/backend/snapshot"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/config"
+)
+
+// Register installs the configured prevout resolver, if any
+func Register(c *config.Config) error {
+	if err := rpc.Register(c.RPC); err != nil {
+		return err
+	}
+	if err := esplora.Register(c.Esplora); err != nil {
+		return err
+	}
+	if err := electrum.Register(c.Electrum); err != nil {
+		return err
+	}
+	return snapshot.Register(c.UTXOSnapshot)
+}
@@ -0,0 +1,296 @@
+// Package electrum looks up prevouts and their confirmation heights from an
+// Electrum protocol server (ElectrumX, Fulcrum, electrs), so transaction
+// fixtures only need raw_tx and no full node is required.
+//
+// Each lookup opens one TCP or TLS connection, negotiates the protocol
+// version and pipelines its requests: blockchain.transaction.get for every
+// spent transaction, then blockchain.scripthash.get_history for the spent
+// scripts, whose entries give each transaction's height. A server that
+// refuses a history (e.g. one too long for it) only costs that prevout its
+// height.
+package electrum
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/config"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/parser"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	// clientName and protocolVersion are sent in server.version
+	clientName      = "btc-lens"
+	protocolVersion = "1.4"
+)
+
+// Register installs a client for the configured server as the parser's
+// prevout resolver; it does nothing when no server is configured
+func Register(c config.ElectrumConfig) error {
+	if c.Server == "" {
+		return nil
+	}
+	client, err := NewClient(c)
+	if err != nil {
+		return err
+	}
+	parser.SetPrevoutResolver(client)
+	return nil
+}
+
+// Client talks to one Electrum server
+type Client struct {
+	addr    string
+	tls     *tls.Config // nil for plain TCP
+	timeout time.Duration
+}
+
+// NewClient returns a client for the server c names, as ssl://host:port or
+// tcp://host:port
+func NewClient(c config.ElectrumConfig) (*Client, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	u, _ := url.Parse(c.Server)
+	client := &Client{addr: u.Host, timeout: defaultTimeout}
+	if u.Scheme == "ssl" {
+		// Verification is on unless turned off for self-signed certificates
+		client.tls = &tls.Config{ServerName: u.Hostname(), InsecureSkipVerify: c.TLSSkipVerify}
+	}
+	if c.TimeoutMs > 0 {
+		client.timeout = time.Duration(c.TimeoutMs) * time.Millisecond
+	}
+	return client, nil
+}
+
+// request is one JSON-RPC 2.0 call
+type request struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+// response is one JSON-RPC 2.0 result; subscription notifications have a
+// method and no id
+type response struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// notFound reports whether a blockchain.transaction.get error means the
+// transaction does not exist. Servers relay bitcoind's message for it
+// under varying codes.
+func (e *rpcError) notFound() bool {
+	msg := strings.ToLower(e.Message)
+	return strings.Contains(msg, "no such mempool") || strings.Contains(msg, "not found")
+}
+
+// session is one connection to the server
+type session struct {
+	conn   net.Conn
+	dec    *json.Decoder
+	enc    *json.Encoder
+	nextID int
+}
+
+func (c *Client) dial() (*session, error) {
+	dialer := &net.Dialer{Timeout: c.timeout}
+	var conn net.Conn
+	var err error
+	if c.tls != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", c.addr, c.tls)
+	} else {
+		conn, err = dialer.Dial("tcp", c.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &session{conn: conn, dec: json.NewDecoder(conn), enc: json.NewEncoder(conn)}
+	conn.SetDeadline(time.Now().Add(c.timeout))
+	resp, err := s.call([]request{{Method: "server.version", Params: []interface{}{clientName, protocolVersion}}})
+	if err == nil && resp[0].Error != nil {
+		err = fmt.Errorf("server.version: %w", resp[0].Error)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// call pipelines calls, one JSON object per line, and returns their
+// responses in the order of the calls
+func (s *session) call(calls []request) ([]response, error) {
+	first := s.nextID
+	for i := range calls {
+		calls[i].JSONRPC = "2.0"
+		calls[i].ID = s.nextID
+		s.nextID++
+		if err := s.enc.Encode(calls[i]); err != nil {
+			return nil, err
+		}
+	}
+	results := make([]response, len(calls))
+	for pending := len(calls); pending > 0; {
+		var r response
+		if err := s.dec.Decode(&r); err != nil {
+			return nil, fmt.Errorf("electrum response: %w", err)
+		}
+		if r.ID == nil {
+			continue // a notification
+		}
+		i := *r.ID - first
+		if i < 0 || i >= len(calls) || results[i].ID != nil {
+			return nil, fmt.Errorf("electrum response: unexpected id %d", *r.ID)
+		}
+		results[i] = r
+		pending--
+	}
+	return results, nil
+}
+
+// historyEntry is one transaction of a scripthash history; Height is 0 or
+// -1 for mempool transactions
+type historyEntry struct {
+	TxHash string `json:"tx_hash"`
+	Height int64  `json:"height"`
+}
+
+// scriptHash is the Electrum key for a script: its SHA-256, byte-reversed
+func scriptHash(pkScript []byte) string {
+	sum := sha256.Sum256(pkScript)
+	for i, j := 0, len(sum)-1; i < j; i, j = i+1, j-1 {
+		sum[i], sum[j] = sum[j], sum[i]
+	}
+	return hex.EncodeToString(sum[:])
+}
+
+// ResolvePrevouts fetches the outputs outpoints refer to, with the height
+// of the block confirming each. Transactions the server does not know are
+// left out; a failure to talk to it is an error.
+func (c *Client) ResolvePrevouts(outpoints []wire.OutPoint) (map[wire.OutPoint]types.PrevoutInput, error) {
+	s, err := c.dial()
+	if err != nil {
+		return nil, fmt.Errorf("electrum %s: %w", c.addr, err)
+	}
+	defer s.conn.Close()
+	s.conn.SetDeadline(time.Now().Add(c.timeout))
+
+	var txids []chainhash.Hash
+	seen := make(map[chainhash.Hash]bool)
+	var calls []request
+	for _, op := range outpoints {
+		if !seen[op.Hash] {
+			seen[op.Hash] = true
+			txids = append(txids, op.Hash)
+			calls = append(calls, request{Method: "blockchain.transaction.get", Params: []interface{}{op.Hash.String()}})
+		}
+	}
+	results, err := s.call(calls)
+	if err != nil {
+		return nil, err
+	}
+	txs := make(map[chainhash.Hash]*wire.MsgTx, len(txids))
+	for i, txid := range txids {
+		r := results[i]
+		if r.Error != nil {
+			if r.Error.notFound() {
+				continue
+			}
+			return nil, fmt.Errorf("blockchain.transaction.get %s: %w", txid, r.Error)
+		}
+		var rawHex string
+		if err := json.Unmarshal(r.Result, &rawHex); err != nil {
+			return nil, fmt.Errorf("blockchain.transaction.get %s: %w", txid, err)
+		}
+		raw, err := hex.DecodeString(rawHex)
+		if err != nil {
+			return nil, fmt.Errorf("blockchain.transaction.get %s: %w", txid, err)
+		}
+		tx := wire.NewMsgTx(wire.TxVersion)
+		if err := tx.Deserialize(bytes.NewReader(raw)); err != nil {
+			return nil, fmt.Errorf("blockchain.transaction.get %s: %w", txid, err)
+		}
+		if tx.TxHash() != txid {
+			return nil, fmt.Errorf("blockchain.transaction.get %s: server returned %s", txid, tx.TxHash())
+		}
+		txs[txid] = tx
+	}
+
+	found := make(map[wire.OutPoint]types.PrevoutInput, len(outpoints))
+	var hashes []string
+	byHash := make(map[string][]wire.OutPoint)
+	for _, op := range outpoints {
+		tx := txs[op.Hash]
+		if tx == nil || int(op.Index) >= len(tx.TxOut) {
+			continue
+		}
+		out := tx.TxOut[op.Index]
+		found[op] = types.PrevoutInput{
+			Txid:            op.Hash.String(),
+			Vout:            op.Index,
+			ValueSats:       out.Value,
+			ScriptPubkeyHex: hex.EncodeToString(out.PkScript),
+		}
+		h := scriptHash(out.PkScript)
+		if _, ok := byHash[h]; !ok {
+			hashes = append(hashes, h)
+		}
+		byHash[h] = append(byHash[h], op)
+	}
+	if len(hashes) == 0 {
+		return found, nil
+	}
+
+	// Heights come from the histories of the spent scripts
+	calls = make([]request, len(hashes))
+	for i, h := range hashes {
+		calls[i] = request{Method: "blockchain.scripthash.get_history", Params: []interface{}{h}}
+	}
+	if results, err = s.call(calls); err != nil {
+		return nil, err
+	}
+	for i, h := range hashes {
+		var history []historyEntry
+		if results[i].Error != nil || json.Unmarshal(results[i].Result, &history) != nil {
+			continue
+		}
+		heights := make(map[string]int64, len(history))
+		for _, e := range history {
+			heights[e.TxHash] = e.Height
+		}
+		for _, op := range byHash[h] {
+			if height := heights[op.Hash.String()]; height > 0 {
+				p := found[op]
+				p.Height = &height
+				found[op] = p
+			}
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,210 @@
+// Package esplora looks up prevouts from an Esplora-compatible REST API,
+// such as blockstream.info or mempool.space, so transaction fixtures only
+// need raw_tx.
+//
+// Each spent transaction is fetched once with GET <base>/tx/<txid>, a few
+// at a time. Requests that fail transiently (a network error, 429 or a 5xx
+// status) are retried with exponential backoff, honoring Retry-After.
+package esplora
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/config"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/parser"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	// parallelRequests bounds the requests in flight, which public
+	// instances rate-limit per client
+	parallelRequests = 4
+	// retryBackoff is the wait before the first retry; it doubles after
+	// each further one
+	retryBackoff = 250 * time.Millisecond
+	// maxRetryAfter caps how long a Retry-After header can make us wait
+	maxRetryAfter = 10 * time.Second
+)
+
+// errNotFound is a transaction the server does not know
+var errNotFound = errors.New("not found")
+
+// Register installs a client for the configured server as the parser's
+// prevout resolver; it does nothing when no URL is configured
+func Register(c config.EsploraConfig) error {
+	if c.URL == "" {
+		return nil
+	}
+	client, err := NewClient(c)
+	if err != nil {
+		return err
+	}
+	parser.SetPrevoutResolver(client)
+	return nil
+}
+
+// Client queries one Esplora server
+type Client struct {
+	base    string
+	retries int
+	http    *http.Client
+}
+
+// NewClient returns a client for the server c points at
+func NewClient(c config.EsploraConfig) (*Client, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	client := &Client{
+		base:    strings.TrimRight(c.URL, "/"),
+		retries: c.Retries,
+		http:    &http.Client{Timeout: defaultTimeout},
+	}
+	if c.TimeoutMs > 0 {
+		client.http.Timeout = time.Duration(c.TimeoutMs) * time.Millisecond
+	}
+	return client, nil
+}
+
+// esploraTx is the part of a GET /tx/:txid result used here
+type esploraTx struct {
+	Txid string `json:"txid"`
+	Vout []struct {
+		ScriptPubKey string `json:"scriptpubkey"`
+		Value        int64  `json:"value"`
+	} `json:"vout"`
+	Status struct {
+		Confirmed   bool  `json:"confirmed"`
+		BlockHeight int64 `json:"block_height"`
+	} `json:"status"`
+}
+
+// ResolvePrevouts fetches the outputs outpoints refer to. Transactions the
+// server does not know are left out; any other failure is an error.
+func (c *Client) ResolvePrevouts(outpoints []wire.OutPoint) (map[wire.OutPoint]types.PrevoutInput, error) {
+	var txids []chainhash.Hash
+	seen := make(map[chainhash.Hash]bool)
+	for _, op := range outpoints {
+		if !seen[op.Hash] {
+			seen[op.Hash] = true
+			txids = append(txids, op.Hash)
+		}
+	}
+
+	txs := make([]*esploraTx, len(txids))
+	errs := make([]error, len(txids))
+	sem := make(chan struct{}, parallelRequests)
+	var wg sync.WaitGroup
+	for i, txid := range txids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			txs[i], errs[i] = c.fetchTx(txid)
+		}()
+	}
+	wg.Wait()
+
+	byTxid := make(map[chainhash.Hash]*esploraTx, len(txids))
+	for i, txid := range txids {
+		switch {
+		case errors.Is(errs[i], errNotFound):
+		case errs[i] != nil:
+			return nil, fmt.Errorf("esplora tx %s: %w", txid, errs[i])
+		default:
+			byTxid[txid] = txs[i]
+		}
+	}
+
+	found := make(map[wire.OutPoint]types.PrevoutInput, len(outpoints))
+	for _, op := range outpoints {
+		tx := byTxid[op.Hash]
+		if tx == nil || int(op.Index) >= len(tx.Vout) {
+			continue
+		}
+		out := tx.Vout[op.Index]
+		p := types.PrevoutInput{
+			Txid:            op.Hash.String(),
+			Vout:            op.Index,
+			ValueSats:       out.Value,
+			ScriptPubkeyHex: out.ScriptPubKey,
+		}
+		if tx.Status.Confirmed {
+			height := tx.Status.BlockHeight
+			p.Height = &height
+		}
+		found[op] = p
+	}
+	return found, nil
+}
+
+// fetchTx gets one transaction, retrying transient failures
+func (c *Client) fetchTx(txid chainhash.Hash) (*esploraTx, error) {
+	url := c.base + "/tx/" + txid.String()
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		tx, retryAfter, err := c.get(url)
+		if err == nil || errors.Is(err, errNotFound) || retryAfter < 0 || attempt >= c.retries {
+			if err == nil && tx.Txid != txid.String() {
+				return nil, fmt.Errorf("server returned transaction %s", tx.Txid)
+			}
+			return tx, err
+		}
+		wait := backoff
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		time.Sleep(wait)
+		backoff *= 2
+	}
+}
+
+// get performs one request. On failure it also reports whether to retry:
+// a negative duration means the error is permanent, a positive one is the
+// server's Retry-After.
+func (c *Client) get(url string) (*esploraTx, time.Duration, error) {
+	resp, err := c.http.Get(url)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, -1, errNotFound
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, retryAfter(resp.Header.Get("Retry-After")), fmt.Errorf("%s", resp.Status)
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, -1, fmt.Errorf("%s %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var tx esploraTx
+	if err := json.NewDecoder(resp.Body).Decode(&tx); err != nil {
+		return nil, -1, fmt.Errorf("invalid response: %w", err)
+	}
+	return &tx, 0, nil
+}
+
+// retryAfter parses a Retry-After header given in seconds, capped at
+// maxRetryAfter; anything else means the default backoff
+func retryAfter(header string) time.Duration {
+	secs, err := strconv.Atoi(header)
+	if err != nil || secs <= 0 {
+		return 0
+	}
+	return min(time.Duration(secs)*time.Second, maxRetryAfter)
+}
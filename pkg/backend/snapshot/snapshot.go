@@ -0,0 +1,24 @@
+// Package snapshot looks up prevouts in a UTXO snapshot written by Bitcoin
+// Core's dumptxoutset, an offline source for the coins unspent at the
+// snapshot's base block. Heights come from the snapshot; outputs created
+// after the base block, or spent before it, are not found.
+package snapshot
+
+import (
+	"github.com/richochetclementine1315/BTC-Lens/pkg/config"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/parser"
+)
+
+// Register indexes the configured snapshot and installs it as the parser's
+// prevout resolver; it does nothing when no snapshot is configured
+func Register(c config.UTXOSnapshotConfig) error {
+	if c.Path == "" {
+		return nil
+	}
+	resolver, err := parser.OpenSnapshotResolver(c.Path)
+	if err != nil {
+		return err
+	}
+	parser.SetPrevoutResolver(resolver)
+	return nil
+}
@@ -0,0 +1,300 @@
+// Package btclens is the supported Go API of BTC Lens: transaction, block
+// and P2P structure analysis without the CLI or web server.
+//
+//	import "github.com/richochetclementine1315/BTC-Lens/pkg/btclens"
+//
+//	out, err := btclens.AnalyzeTransaction(btclens.Fixture{
+//		Network:  "mainnet",
+//		RawTx:    rawHex,
+//		Prevouts: prevouts,
+//	})
+//
+// The functions, types and interfaces declared here, and the JSON-tagged
+// result types they return from pkg/types, follow semantic versioning: within
+// a major version they are only added to, never renamed, removed or changed
+// in meaning. The other packages under pkg (parser, analyzer, utils, store,
+// config, extension) back the CLI and web server and may change in any
+// release; import them directly at your own risk.
+//
+// Input that cannot be decoded is returned as an error, which ErrorCode maps
+// to the code the CLI would report. Problems found in decoded input (a
+// network mismatch, an invalid address) are reported in the result instead,
+// with OK false and an ErrorInfo.
+package btclens
+
+import (
+	"github.com/richochetclementine1315/BTC-Lens/pkg/analyzer"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/parser"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+// Inputs
+type (
+	Fixture             = types.Fixture
+	PrevoutInput        = types.PrevoutInput
+	CompactBlockFixture = types.CompactBlockFixture
+	TemplateFixture     = types.TemplateFixture
+	MerkleProofFixture  = types.MerkleProofFixture
+	BloomFixture        = types.BloomFixture
+	MerkleBranchFixture = types.MerkleBranchFixture
+)
+
+// Results
+type (
+	TransactionOutput        = types.TransactionOutput
+	PSBTInfo                 = types.PSBTInfo
+	BlockOutput              = types.BlockOutput
+	HeaderOutput             = types.HeaderOutput
+	HeaderChainOutput        = types.HeaderChainOutput
+	HeaderIndexOutput        = types.HeaderIndexOutput
+	ChainCheckOutput         = types.ChainCheckOutput
+	CompactBlockOutput       = types.CompactBlockOutput
+	TemplateComparisonOutput = types.TemplateComparisonOutput
+	MerkleProofOutput        = types.MerkleProofOutput
+	MerkleBranchOutput       = types.MerkleBranchOutput
+	BloomOutput              = types.BloomOutput
+	P2PMessageOutput         = types.P2PMessageOutput
+	AddressOutput            = types.AddressOutput
+	PaymentURIOutput         = types.PaymentURIOutput
+	BlockDiffOutput          = types.BlockDiffOutput
+	MempoolOutput            = types.MempoolOutput
+	PeersOutput              = types.PeersOutput
+	AnchorsOutput            = types.AnchorsOutput
+	UTXOSnapshotOutput       = types.UTXOSnapshotOutput
+	UTXOCoin                 = types.UTXOCoin
+	ErrorInfo                = types.ErrorInfo
+)
+
+// Options and extension points
+type (
+	// BlockOptions configures block-file parsing
+	BlockOptions = parser.BlockOptions
+	// MempoolOptions configures mempool.dat parsing
+	MempoolOptions = parser.MempoolOptions
+	// UTXOSnapshotOptions configures UTXO snapshot parsing
+	UTXOSnapshotOptions = parser.UTXOSnapshotOptions
+	// ChainCheckOptions configures header chain checks
+	ChainCheckOptions = parser.ChainCheckOptions
+	// BlockFile is a blk*.dat file with its matching rev*.dat
+	BlockFile = parser.BlockFile
+	// ParseLimits bounds the sizes and counts accepted from input
+	ParseLimits = parser.ParseLimits
+	// Stage is one step of the transaction analysis pipeline; custom
+	// detectors implement it and are added with RegisterStage
+	Stage = parser.Stage
+	// StageContext is what a Stage sees of the transaction being analyzed
+	StageContext = parser.StageContext
+	// WarningThresholds tunes the policy warnings attached to transactions
+	WarningThresholds = analyzer.WarningThresholds
+	// PrevoutResolver looks up prevouts a Fixture leaves out, e.g. from a
+	// node; install one with SetPrevoutResolver
+	PrevoutResolver = parser.PrevoutResolver
+	// SnapshotResolver is a PrevoutResolver backed by a UTXO snapshot
+	SnapshotResolver = parser.SnapshotResolver
+)
+
+// Raw transaction encodings accepted by DecodeRawTx
+const (
+	EncodingAuto   = parser.EncodingAuto
+	EncodingHex    = parser.EncodingHex
+	EncodingBase64 = parser.EncodingBase64
+	EncodingBinary = parser.EncodingBinary
+)
+
+// DefaultParseLimits are the limits in effect until SetParseLimits is called
+var DefaultParseLimits = parser.DefaultParseLimits
+
+// AnalyzeTransaction decodes and analyzes a raw transaction with its
+// prevouts
+func AnalyzeTransaction(fixture Fixture) (*TransactionOutput, error) {
+	return parser.ParseTransaction(fixture)
+}
+
+// IsPSBT reports whether data is a BIP174 PSBT, binary or base64 or hex text
+func IsPSBT(data []byte) bool {
+	return parser.IsPSBT(data)
+}
+
+// AnalyzePSBT analyzes the transaction in fixture.PSBT, taking prevouts from
+// the PSBT's input maps where fixture.Prevouts does not give them
+func AnalyzePSBT(fixture Fixture) (*TransactionOutput, error) {
+	return parser.ParsePSBT(fixture)
+}
+
+// DecodeRawTx decodes a raw transaction in hex, base64 or binary, detecting
+// the encoding for EncodingAuto, and returns its bytes and the encoding used
+func DecodeRawTx(data []byte, encoding string) ([]byte, string, error) {
+	return parser.DecodeRawTx(data, encoding)
+}
+
+// ParseBlock parses the first block of a blk*.dat file (every block with
+// opts.AllBlocks), with the undo data
+// from its rev*.dat file and the XOR key from xor.dat. The files may be
+// gzip- or zstd-compressed.
+func ParseBlock(blkPath, revPath, xorPath string, opts BlockOptions) ([]*BlockOutput, error) {
+	return parser.ParseBlockWithOptions(blkPath, revPath, xorPath, opts)
+}
+
+// ParseBlockFiles parses every block of a set of blk/rev file pairs
+func ParseBlockFiles(files []BlockFile, xorPath string, opts BlockOptions) ([]*BlockOutput, error) {
+	return parser.ParseBlockFiles(files, xorPath, opts)
+}
+
+// PairRevFiles pairs blk*.dat paths with the rev*.dat files beside them
+func PairRevFiles(blkPaths []string) ([]BlockFile, error) {
+	return parser.PairRevFiles(blkPaths)
+}
+
+// BlockFilesInDir lists the blk*.dat files of a blocks directory in order
+func BlockFilesInDir(dir string) ([]string, error) {
+	return parser.BlockFilesInDir(dir)
+}
+
+// ScanBlockHeaders indexes the blocks of blk*.dat files without decoding
+// their transactions
+func ScanBlockHeaders(paths []string, xorPath string) (*HeaderIndexOutput, error) {
+	return parser.ScanBlockHeaders(paths, xorPath)
+}
+
+// CheckHeaderChain links the headers of blk*.dat files and header dumps
+// into a chain and reports where it breaks
+func CheckHeaderChain(paths []string, opts ChainCheckOptions) (*ChainCheckOutput, error) {
+	return parser.CheckHeaderChain(paths, opts)
+}
+
+// ParseMempoolDat analyzes every transaction of a Bitcoin Core mempool.dat
+// file, with aggregate statistics. Prevouts come from the file's own
+// transactions and the installed PrevoutResolver.
+func ParseMempoolDat(path string, opts MempoolOptions) (*MempoolOutput, error) {
+	return parser.ParseMempoolDat(path, opts)
+}
+
+// ParsePeersDat decodes a Bitcoin Core peers.dat address manager file
+func ParsePeersDat(path string) (*PeersOutput, error) {
+	return parser.ParsePeersDat(path)
+}
+
+// ParseAnchorsDat decodes a Bitcoin Core anchors.dat file
+func ParseAnchorsDat(path string) (*AnchorsOutput, error) {
+	return parser.ParseAnchorsDat(path)
+}
+
+// ParseUTXOSnapshot streams the coins of a dumptxoutset UTXO snapshot and
+// summarizes them by script type and value
+func ParseUTXOSnapshot(path string, opts UTXOSnapshotOptions) (*UTXOSnapshotOutput, error) {
+	return parser.ParseUTXOSnapshot(path, opts)
+}
+
+// OpenSnapshotResolver indexes an uncompressed UTXO snapshot so it can be
+// installed with SetPrevoutResolver
+func OpenSnapshotResolver(path string) (*SnapshotResolver, error) {
+	return parser.OpenSnapshotResolver(path)
+}
+
+// DecodeBlockHeader decodes an 80-byte block header given in hex
+func DecodeBlockHeader(headerHex string) (*HeaderOutput, error) {
+	return parser.DecodeBlockHeader(headerHex)
+}
+
+// DecodeHeaderChain decodes consecutive block headers and estimates the
+// next difficulty adjustment; firstHeight may be nil
+func DecodeHeaderChain(headerHexes []string, firstHeight *int64) (*HeaderChainOutput, error) {
+	return parser.DecodeHeaderChain(headerHexes, firstHeight)
+}
+
+// ParseCompactBlock reconstructs a BIP152 compact block
+func ParseCompactBlock(fixture CompactBlockFixture) (*CompactBlockOutput, error) {
+	return parser.ParseCompactBlock(fixture)
+}
+
+// CompareBlockTemplate compares a getblocktemplate result with a block
+func CompareBlockTemplate(fixture TemplateFixture) (*TemplateComparisonOutput, error) {
+	return parser.CompareBlockTemplate(fixture)
+}
+
+// GenerateMerkleProofs finds transactions in blk*.dat files and proves
+// each one's inclusion as a merkle branch and a merkleblock
+func GenerateMerkleProofs(paths []string, xorPath string, txids []string) (*MerkleBranchOutput, error) {
+	return parser.GenerateMerkleProofs(paths, xorPath, txids)
+}
+
+// GenerateBlockMerkleProofs proves transactions of one raw block
+func GenerateBlockMerkleProofs(fixture MerkleBranchFixture) (*MerkleBranchOutput, error) {
+	return parser.GenerateBlockMerkleProofs(fixture)
+}
+
+// VerifyMerkleProof checks a txoutproof (merkleblock) against its header
+func VerifyMerkleProof(fixture MerkleProofFixture) (*MerkleProofOutput, error) {
+	return parser.VerifyMerkleProof(fixture)
+}
+
+// MatchBloomFilter matches a BIP37 bloom filter against transactions
+func MatchBloomFilter(fixture BloomFixture) (*BloomOutput, error) {
+	return parser.MatchBloomFilter(fixture)
+}
+
+// DecodeP2PMessage decodes a P2P wire message given in hex: a complete
+// message, or a bare payload when command is given
+func DecodeP2PMessage(rawHex, command string) (*P2PMessageOutput, error) {
+	return parser.DecodeP2PMessage(rawHex, command)
+}
+
+// AnalyzeAddress validates and describes an address on network
+func AnalyzeAddress(address, network string) *AddressOutput {
+	return analyzer.AnalyzeAddress(address, network)
+}
+
+// ParsePaymentURI parses a BIP21 bitcoin: payment URI
+func ParsePaymentURI(uri, network string) *PaymentURIOutput {
+	return analyzer.ParsePaymentURI(uri, network)
+}
+
+// DiffBlocks compares two analyzed blocks
+func DiffBlocks(a, b *BlockOutput) (*BlockDiffOutput, error) {
+	return analyzer.DiffBlocks(a, b)
+}
+
+// ApplyVerbosity projects a result to the summary, standard or full
+// verbosity level
+func ApplyVerbosity(result interface{}, level string) (interface{}, error) {
+	return parser.ApplyVerbosity(result, level)
+}
+
+// ErrorCode returns the error code for an error returned by this package,
+// or fallback when it has none more specific
+func ErrorCode(err error, fallback string) string {
+	return parser.ErrorCode(err, fallback)
+}
+
+// NewStage wraps a function as a Stage
+func NewStage(name string, fn func(ctx *StageContext) error) Stage {
+	return parser.NewStage(name, fn)
+}
+
+// RegisterStage appends a stage to the analysis pipeline. A stage
+// registered with enabled=false runs only when a Fixture enables it by name
+// in Stages. Registering a duplicate name panics.
+func RegisterStage(s Stage, enabled bool) {
+	parser.RegisterStage(s, enabled)
+}
+
+// StageNames lists the registered stages in pipeline order
+func StageNames() []string {
+	return parser.StageNames()
+}
+
+// SetParseLimits replaces the process-wide parse limits
+func SetParseLimits(l ParseLimits) {
+	parser.SetParseLimits(l)
+}
+
+// SetPrevoutResolver installs the resolver AnalyzeTransaction and
+// AnalyzePSBT consult for missing prevouts; nil removes it
+func SetPrevoutResolver(r PrevoutResolver) {
+	parser.SetPrevoutResolver(r)
+}
+
+// SetWarningThresholds replaces the process-wide warning thresholds
+func SetWarningThresholds(t WarningThresholds) {
+	analyzer.SetWarningThresholds(t)
+}
@@ -0,0 +1,571 @@
+// Package config loads the settings shared by cmd/web and cmd/cli.
+//
+// Values are resolved in order: built-in defaults, then an optional YAML or
+// TOML file, then CHAIN_LENS_* environment variables. Command-line flags,
+// where a command has them, override all three.
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/analyzer"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/parser"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/utils"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// FileEnv names the environment variable pointing at the config file
+const FileEnv = "CHAIN_LENS_CONFIG"
+
+// Config is the complete application configuration
+type Config struct {
+	Server     ServerConfig     `yaml:"server" toml:"server"`
+	Storage    StorageConfig    `yaml:"storage" toml:"storage"`
+	Thresholds ThresholdsConfig `yaml:"thresholds" toml:"thresholds"`
+
+	// Concurrency is the analysis worker count; 0 means GOMAXPROCS
+	Concurrency int `yaml:"concurrency" toml:"concurrency"`
+
+	// Network is used when a fixture or address lookup does not name one
+	Network string `yaml:"network" toml:"network"`
+
+	// Extensions are external detectors run as extra analysis stages
+	Extensions []ExtensionConfig `yaml:"extensions" toml:"extensions"`
+
+	// Envelopes configures the tapscript data-envelope scanner
+	Envelopes EnvelopesConfig `yaml:"envelopes" toml:"envelopes"`
+
+	// Limits bounds the sizes and counts accepted while parsing
+	Limits LimitsConfig `yaml:"limits" toml:"limits"`
+
+	// RPC is a bitcoind node to look up prevouts fixtures leave out
+	RPC RPCConfig `yaml:"rpc" toml:"rpc"`
+
+	// Esplora is a REST API to look up those prevouts from instead
+	Esplora EsploraConfig `yaml:"esplora" toml:"esplora"`
+
+	// Electrum is an Electrum protocol server to look them up from instead
+	Electrum ElectrumConfig `yaml:"electrum" toml:"electrum"`
+
+	// UTXOSnapshot is a dumptxoutset file to look them up in instead
+	UTXOSnapshot UTXOSnapshotConfig `yaml:"utxo_snapshot" toml:"utxo_snapshot"`
+}
+
+// ServerConfig holds cmd/web settings
+type ServerConfig struct {
+	Port        string   `yaml:"port" toml:"port"`
+	CORSOrigins []string `yaml:"cors_origins" toml:"cors_origins"`
+	Pprof       bool     `yaml:"pprof" toml:"pprof"`
+
+	// FeedSize is how many recent analyses the /api/ws live feed keeps
+	// and replays to a newly connected dashboard
+	FeedSize int `yaml:"feed_size" toml:"feed_size"`
+}
+
+// StorageConfig holds where background jobs and saved analyses are kept.
+// Empty directories disable persistence.
+type StorageConfig struct {
+	JobDir       string `yaml:"job_dir" toml:"job_dir"`
+	AnalysisDir  string `yaml:"analysis_dir" toml:"analysis_dir"`
+	JobQueueSize int    `yaml:"job_queue_size" toml:"job_queue_size"`
+
+	// ResultDir is where the CLI writes its results, browsed by the web
+	// server's /api/results; empty disables browsing it
+	ResultDir string `yaml:"result_dir" toml:"result_dir"`
+
+	// ResultStore, when set, is a content-addressed store the CLI writes
+	// results to instead of ./out; ResultStoreGzip compresses them
+	ResultStore     string `yaml:"result_store" toml:"result_store"`
+	ResultStoreGzip bool   `yaml:"result_store_gzip" toml:"result_store_gzip"`
+
+	// Overwrite is what the CLI does when an output file already exists:
+	// replace, skip or error
+	Overwrite string `yaml:"overwrite" toml:"overwrite"`
+}
+
+// Output file overwrite policies
+const (
+	OverwriteReplace = "replace" // replace the existing file
+	OverwriteSkip    = "skip"    // keep the existing file and skip the write
+	OverwriteError   = "error"   // fail the write
+)
+
+// ThresholdsConfig holds the warning thresholds
+type ThresholdsConfig struct {
+	HighFeeSats    int64   `yaml:"high_fee_sats" toml:"high_fee_sats"`
+	HighFeeRate    float64 `yaml:"high_fee_rate_sat_vb" toml:"high_fee_rate_sat_vb"`
+	LowFeeRate     float64 `yaml:"low_fee_rate_sat_vb" toml:"low_fee_rate_sat_vb"`
+	DustOutputSats int64   `yaml:"dust_output_sats" toml:"dust_output_sats"`
+
+	// DustRelayFeeRate (sat/vB) derives Core's per-script dust limits,
+	// the default; a non-zero DustOutputSats replaces them with one flat
+	// limit
+	DustRelayFeeRate float64 `yaml:"dust_relay_fee_sat_vb" toml:"dust_relay_fee_sat_vb"`
+
+	AbsurdFeeFraction       float64 `yaml:"absurd_fee_fraction" toml:"absurd_fee_fraction"`
+	AbsurdFeeSmallestOutput bool    `yaml:"absurd_fee_smallest_output" toml:"absurd_fee_smallest_output"`
+}
+
+// LimitsConfig holds the deserialization limits; input above any of them
+// fails with LIMIT_EXCEEDED
+type LimitsConfig struct {
+	MaxTxBytes          int `yaml:"max_tx_bytes" toml:"max_tx_bytes"`
+	MaxBlockBytes       int `yaml:"max_block_bytes" toml:"max_block_bytes"`
+	MaxBlockTxs         int `yaml:"max_block_txs" toml:"max_block_txs"`
+	MaxInputs           int `yaml:"max_inputs" toml:"max_inputs"`
+	MaxOutputs          int `yaml:"max_outputs" toml:"max_outputs"`
+	MaxScriptBytes      int `yaml:"max_script_bytes" toml:"max_script_bytes"`
+	MaxWitnessItems     int `yaml:"max_witness_items" toml:"max_witness_items"`
+	MaxWitnessItemBytes int `yaml:"max_witness_item_bytes" toml:"max_witness_item_bytes"`
+}
+
+// limitField is one limit and its config key, which upper-cased and
+// prefixed with CHAIN_LENS_ is also its environment variable
+type limitField struct {
+	name  string
+	value *int
+}
+
+func (l *LimitsConfig) fields() []limitField {
+	return []limitField{
+		{"max_tx_bytes", &l.MaxTxBytes},
+		{"max_block_bytes", &l.MaxBlockBytes},
+		{"max_block_txs", &l.MaxBlockTxs},
+		{"max_inputs", &l.MaxInputs},
+		{"max_outputs", &l.MaxOutputs},
+		{"max_script_bytes", &l.MaxScriptBytes},
+		{"max_witness_items", &l.MaxWitnessItems},
+		{"max_witness_item_bytes", &l.MaxWitnessItemBytes},
+	}
+}
+
+// RPCConfig points at a Bitcoin Core JSON-RPC endpoint used to fetch the
+// prevouts a transaction fixture does not include. An empty URL disables
+// lookups. Credentials come from the node's cookie file or from
+// user/password (rpcuser/rpcpassword or an rpcauth entry), not both.
+type RPCConfig struct {
+	URL        string `yaml:"url" toml:"url"`
+	CookieFile string `yaml:"cookie_file" toml:"cookie_file"`
+	User       string `yaml:"user" toml:"user"`
+	Password   string `yaml:"password" toml:"password"`
+	TimeoutMs  int    `yaml:"timeout_ms" toml:"timeout_ms"` // 0 means 10000
+}
+
+// EsploraConfig points at an Esplora-compatible REST API (blockstream.info,
+// mempool.space or a self-hosted instance) used like RPCConfig's node. URL
+// is the API base, e.g. https://mempool.space/api; empty disables lookups.
+type EsploraConfig struct {
+	URL       string `yaml:"url" toml:"url"`
+	TimeoutMs int    `yaml:"timeout_ms" toml:"timeout_ms"` // per request; 0 means 10000
+	Retries   int    `yaml:"retries" toml:"retries"`       // after a network error, 429 or 5xx
+}
+
+// ElectrumConfig points at an Electrum protocol server (ElectrumX, Fulcrum,
+// electrs) used like RPCConfig's node. Server is ssl://host:port or
+// tcp://host:port; empty disables lookups. TLSSkipVerify accepts the
+// self-signed certificates many public servers use.
+type ElectrumConfig struct {
+	Server        string `yaml:"server" toml:"server"`
+	TLSSkipVerify bool   `yaml:"tls_skip_verify" toml:"tls_skip_verify"`
+	TimeoutMs     int    `yaml:"timeout_ms" toml:"timeout_ms"` // whole lookup; 0 means 10000
+}
+
+// UTXOSnapshotConfig points at an uncompressed UTXO snapshot written by
+// Bitcoin Core's dumptxoutset, used offline like RPCConfig's node for the
+// coins unspent at its base block. Path empty disables lookups.
+type UTXOSnapshotConfig struct {
+	Path string `yaml:"path" toml:"path"`
+}
+
+// EnvelopesConfig selects which OP_FALSE OP_IF data envelopes are reported.
+// Markers are hex; an empty list reports envelopes with any marker.
+type EnvelopesConfig struct {
+	Markers  []string `yaml:"markers" toml:"markers"`
+	MinBytes int      `yaml:"min_bytes" toml:"min_bytes"`
+}
+
+// Extension types
+const (
+	ExtensionExec = "exec" // external process: tx JSON on stdin, findings on stdout
+	ExtensionWasm = "wasm" // WASI command module with the same stdin/stdout protocol
+)
+
+// ExtensionConfig describes one external detector. Its name is also its
+// stage name, so requests can turn it on or off like a built-in stage.
+type ExtensionConfig struct {
+	Name      string   `yaml:"name" toml:"name"`
+	Type      string   `yaml:"type" toml:"type"`
+	Path      string   `yaml:"path" toml:"path"`
+	Args      []string `yaml:"args" toml:"args"`
+	TimeoutMs int      `yaml:"timeout_ms" toml:"timeout_ms"` // 0 means 5000
+	OptIn     bool     `yaml:"opt_in" toml:"opt_in"`         // run only when a request enables it
+}
+
+// Default returns the built-in configuration
+func Default() *Config {
+	t := analyzer.DefaultWarningThresholds
+	l := parser.DefaultParseLimits
+	return &Config{
+		Server: ServerConfig{
+			Port:        "3000",
+			CORSOrigins: []string{"*"},
+			FeedSize:    50,
+		},
+		Esplora: EsploraConfig{
+			Retries: 3,
+		},
+		Storage: StorageConfig{
+			JobQueueSize: 16,
+			ResultDir:    "out",
+			Overwrite:    OverwriteReplace,
+		},
+		Thresholds: ThresholdsConfig{
+			HighFeeSats:    t.HighFeeSats,
+			HighFeeRate:    t.HighFeeRate,
+			LowFeeRate:     t.LowFeeRate,
+			DustOutputSats: t.DustOutputSats,
+
+			DustRelayFeeRate:  t.DustRelayFeeRate,
+			AbsurdFeeFraction: t.AbsurdFeeFraction,
+		},
+		Limits: LimitsConfig{
+			MaxTxBytes:          l.MaxTxBytes,
+			MaxBlockBytes:       l.MaxBlockBytes,
+			MaxBlockTxs:         l.MaxBlockTxs,
+			MaxInputs:           l.MaxInputs,
+			MaxOutputs:          l.MaxOutputs,
+			MaxScriptBytes:      l.MaxScriptBytes,
+			MaxWitnessItems:     l.MaxWitnessItems,
+			MaxWitnessItemBytes: l.MaxWitnessItemBytes,
+		},
+		Network: analyzer.NetworkMainnet,
+	}
+}
+
+// Load builds the configuration from defaults, the file at path (or at
+// $CHAIN_LENS_CONFIG when path is empty) and environment overrides.
+// The file format is chosen by extension: .yaml/.yml or .toml.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path == "" {
+		path = os.Getenv(FileEnv)
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalWithOptions(data, c, yaml.Strict())
+	case ".toml":
+		dec := toml.NewDecoder(strings.NewReader(string(data)))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(c)
+	default:
+		return fmt.Errorf("unsupported config format %q: want .yaml, .yml or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid config %s: %v", path, err)
+	}
+	return nil
+}
+
+// applyEnv overrides file values with CHAIN_LENS_* variables. PORT is also
+// honored for compatibility with the original server.
+func (c *Config) applyEnv() error {
+	str := func(dst *string, names ...string) {
+		for _, name := range names {
+			if v, ok := os.LookupEnv(name); ok && v != "" {
+				*dst = v
+			}
+		}
+	}
+	var errs []string
+	num := func(name string, set func(string) error) {
+		if v, ok := os.LookupEnv(name); ok && v != "" {
+			if err := set(v); err != nil {
+				errs = append(errs, fmt.Sprintf("invalid %s %q", name, v))
+			}
+		}
+	}
+
+	str(&c.Server.Port, "PORT", "CHAIN_LENS_PORT")
+	if v := os.Getenv("CHAIN_LENS_CORS_ORIGINS"); v != "" {
+		c.Server.CORSOrigins = strings.Split(v, ",")
+	}
+	num("CHAIN_LENS_PPROF", func(v string) (err error) {
+		c.Server.Pprof, err = strconv.ParseBool(v)
+		return err
+	})
+	num("CHAIN_LENS_FEED_SIZE", func(v string) (err error) {
+		c.Server.FeedSize, err = strconv.Atoi(v)
+		return err
+	})
+	str(&c.Storage.JobDir, "CHAIN_LENS_JOB_DIR")
+	str(&c.Storage.AnalysisDir, "CHAIN_LENS_STORE_DIR")
+	str(&c.Storage.ResultDir, "CHAIN_LENS_RESULT_DIR")
+	str(&c.Storage.ResultStore, "CHAIN_LENS_RESULT_STORE")
+	str(&c.Storage.Overwrite, "CHAIN_LENS_OVERWRITE")
+	num("CHAIN_LENS_RESULT_STORE_GZIP", func(v string) (err error) {
+		c.Storage.ResultStoreGzip, err = strconv.ParseBool(v)
+		return err
+	})
+	num("CHAIN_LENS_JOB_QUEUE", func(v string) (err error) {
+		c.Storage.JobQueueSize, err = strconv.Atoi(v)
+		return err
+	})
+	num("CHAIN_LENS_HIGH_FEE_SATS", func(v string) (err error) {
+		c.Thresholds.HighFeeSats, err = strconv.ParseInt(v, 10, 64)
+		return err
+	})
+	num("CHAIN_LENS_HIGH_FEE_RATE", func(v string) (err error) {
+		c.Thresholds.HighFeeRate, err = strconv.ParseFloat(v, 64)
+		return err
+	})
+	num("CHAIN_LENS_LOW_FEE_RATE", func(v string) (err error) {
+		c.Thresholds.LowFeeRate, err = strconv.ParseFloat(v, 64)
+		return err
+	})
+	num("CHAIN_LENS_ABSURD_FEE_FRACTION", func(v string) (err error) {
+		c.Thresholds.AbsurdFeeFraction, err = strconv.ParseFloat(v, 64)
+		return err
+	})
+	num("CHAIN_LENS_DUST_SATS", func(v string) (err error) {
+		c.Thresholds.DustOutputSats, err = strconv.ParseInt(v, 10, 64)
+		return err
+	})
+	num("CHAIN_LENS_DUST_RELAY_FEE", func(v string) (err error) {
+		c.Thresholds.DustRelayFeeRate, err = strconv.ParseFloat(v, 64)
+		return err
+	})
+	for _, limit := range c.Limits.fields() {
+		dst := limit.value
+		num("CHAIN_LENS_"+strings.ToUpper(limit.name), func(v string) (err error) {
+			*dst, err = strconv.Atoi(v)
+			return err
+		})
+	}
+	num(utils.ConcurrencyEnv, func(v string) (err error) {
+		c.Concurrency, err = strconv.Atoi(v)
+		return err
+	})
+	str(&c.Network, "CHAIN_LENS_NETWORK")
+	str(&c.RPC.URL, "CHAIN_LENS_RPC_URL")
+	str(&c.RPC.CookieFile, "CHAIN_LENS_RPC_COOKIE")
+	str(&c.RPC.User, "CHAIN_LENS_RPC_USER")
+	str(&c.RPC.Password, "CHAIN_LENS_RPC_PASSWORD")
+	num("CHAIN_LENS_RPC_TIMEOUT_MS", func(v string) (err error) {
+		c.RPC.TimeoutMs, err = strconv.Atoi(v)
+		return err
+	})
+	str(&c.Esplora.URL, "CHAIN_LENS_ESPLORA_URL")
+	num("CHAIN_LENS_ESPLORA_TIMEOUT_MS", func(v string) (err error) {
+		c.Esplora.TimeoutMs, err = strconv.Atoi(v)
+		return err
+	})
+	num("CHAIN_LENS_ESPLORA_RETRIES", func(v string) (err error) {
+		c.Esplora.Retries, err = strconv.Atoi(v)
+		return err
+	})
+	str(&c.Electrum.Server, "CHAIN_LENS_ELECTRUM_SERVER")
+	num("CHAIN_LENS_ELECTRUM_TLS_SKIP_VERIFY", func(v string) (err error) {
+		c.Electrum.TLSSkipVerify, err = strconv.ParseBool(v)
+		return err
+	})
+	num("CHAIN_LENS_ELECTRUM_TIMEOUT_MS", func(v string) (err error) {
+		c.Electrum.TimeoutMs, err = strconv.Atoi(v)
+		return err
+	})
+	str(&c.UTXOSnapshot.Path, "CHAIN_LENS_UTXO_SNAPSHOT_PATH")
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Validate rejects values the analyzer or server cannot use
+func (c *Config) Validate() error {
+	switch c.Network {
+	case analyzer.NetworkMainnet, analyzer.NetworkTestnet, analyzer.NetworkTestnet4, analyzer.NetworkSignet:
+	default:
+		return fmt.Errorf("invalid network %q", c.Network)
+	}
+	if c.Server.FeedSize < 1 {
+		return fmt.Errorf("invalid feed_size %d: want a positive integer", c.Server.FeedSize)
+	}
+	if c.Storage.JobQueueSize < 1 {
+		return fmt.Errorf("invalid job_queue_size %d: want a positive integer", c.Storage.JobQueueSize)
+	}
+	if c.Concurrency < 0 {
+		return fmt.Errorf("invalid concurrency %d", c.Concurrency)
+	}
+	for _, limit := range c.Limits.fields() {
+		if *limit.value < 1 {
+			return fmt.Errorf("invalid limits.%s %d: want a positive integer", limit.name, *limit.value)
+		}
+	}
+	switch c.Storage.Overwrite {
+	case OverwriteReplace, OverwriteSkip, OverwriteError:
+	default:
+		return fmt.Errorf("invalid overwrite %q: want replace, skip or error", c.Storage.Overwrite)
+	}
+	// Extensions register as stages under their own names, which the
+	// built-in stages already hold
+	seen := make(map[string]bool, len(c.Extensions))
+	for _, name := range parser.StageNames() {
+		seen[name] = true
+	}
+	for _, ext := range c.Extensions {
+		if ext.Name == "" || seen[ext.Name] {
+			return fmt.Errorf("extension names must be unique, non-empty and not those of built-in stages (got %q)", ext.Name)
+		}
+		seen[ext.Name] = true
+		if ext.Type != ExtensionExec && ext.Type != ExtensionWasm {
+			return fmt.Errorf("extension %s: invalid type %q: want exec or wasm", ext.Name, ext.Type)
+		}
+		if ext.Path == "" {
+			return fmt.Errorf("extension %s: path is required", ext.Name)
+		}
+		if ext.TimeoutMs < 0 {
+			return fmt.Errorf("extension %s: invalid timeout_ms %d", ext.Name, ext.TimeoutMs)
+		}
+	}
+	for _, m := range c.Envelopes.Markers {
+		if _, err := utils.HexToBytes(m); err != nil || m == "" {
+			return fmt.Errorf("invalid envelope marker %q: want non-empty hex", m)
+		}
+	}
+	if c.Envelopes.MinBytes < 0 {
+		return fmt.Errorf("invalid envelopes min_bytes %d", c.Envelopes.MinBytes)
+	}
+	if err := c.RPC.Validate(); err != nil {
+		return err
+	}
+	if err := c.Esplora.Validate(); err != nil {
+		return err
+	}
+	if err := c.Electrum.Validate(); err != nil {
+		return err
+	}
+	backends := 0
+	for _, set := range []string{c.RPC.URL, c.Esplora.URL, c.Electrum.Server, c.UTXOSnapshot.Path} {
+		if set != "" {
+			backends++
+		}
+	}
+	if backends > 1 {
+		return fmt.Errorf("set only one of rpc url, esplora url, electrum server and utxo snapshot path: prevouts come from one backend")
+	}
+	t := c.Thresholds
+	if t.HighFeeSats < 0 || t.HighFeeRate < 0 || t.LowFeeRate < 0 || t.DustOutputSats < 0 || t.DustRelayFeeRate < 0 || t.AbsurdFeeFraction < 0 {
+		return fmt.Errorf("warning thresholds must not be negative")
+	}
+	return nil
+}
+
+// Validate rejects an RPC endpoint that is not an http(s) URL or that has
+// conflicting credentials
+func (r RPCConfig) Validate() error {
+	if r.URL == "" {
+		return nil
+	}
+	u, err := url.Parse(r.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid rpc url %q: want http://host:port", r.URL)
+	}
+	if r.CookieFile != "" && (r.User != "" || u.User != nil) {
+		return fmt.Errorf("rpc cookie_file and user/password cannot be combined")
+	}
+	if r.TimeoutMs < 0 {
+		return fmt.Errorf("invalid rpc timeout_ms %d", r.TimeoutMs)
+	}
+	return nil
+}
+
+// Validate rejects an Esplora base that is not an http(s) URL
+func (e EsploraConfig) Validate() error {
+	if e.URL != "" {
+		u, err := url.Parse(e.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid esplora url %q: want e.g. https://mempool.space/api", e.URL)
+		}
+	}
+	if e.TimeoutMs < 0 {
+		return fmt.Errorf("invalid esplora timeout_ms %d", e.TimeoutMs)
+	}
+	if e.Retries < 0 {
+		return fmt.Errorf("invalid esplora retries %d", e.Retries)
+	}
+	return nil
+}
+
+// Validate rejects an Electrum server that is not ssl:// or tcp://host:port
+func (e ElectrumConfig) Validate() error {
+	if e.Server != "" {
+		u, err := url.Parse(e.Server)
+		if err != nil || (u.Scheme != "ssl" && u.Scheme != "tcp") || u.Hostname() == "" || u.Port() == "" {
+			return fmt.Errorf("invalid electrum server %q: want ssl://host:port or tcp://host:port", e.Server)
+		}
+	}
+	if e.TimeoutMs < 0 {
+		return fmt.Errorf("invalid electrum timeout_ms %d", e.TimeoutMs)
+	}
+	return nil
+}
+
+// Apply installs the process-wide settings: concurrency, warning thresholds
+// and the envelope scanner. Call it only after Validate has passed.
+func (c *Config) Apply() {
+	utils.SetConcurrency(c.Concurrency)
+	analyzer.SetWarningThresholds(analyzer.WarningThresholds{
+		HighFeeSats:    c.Thresholds.HighFeeSats,
+		HighFeeRate:    c.Thresholds.HighFeeRate,
+		LowFeeRate:     c.Thresholds.LowFeeRate,
+		DustOutputSats: c.Thresholds.DustOutputSats,
+
+		DustRelayFeeRate:        c.Thresholds.DustRelayFeeRate,
+		AbsurdFeeFraction:       c.Thresholds.AbsurdFeeFraction,
+		AbsurdFeeSmallestOutput: c.Thresholds.AbsurdFeeSmallestOutput,
+	})
+	parser.SetParseLimits(parser.ParseLimits{
+		MaxTxBytes:          c.Limits.MaxTxBytes,
+		MaxBlockBytes:       c.Limits.MaxBlockBytes,
+		MaxBlockTxs:         c.Limits.MaxBlockTxs,
+		MaxInputs:           c.Limits.MaxInputs,
+		MaxOutputs:          c.Limits.MaxOutputs,
+		MaxScriptBytes:      c.Limits.MaxScriptBytes,
+		MaxWitnessItems:     c.Limits.MaxWitnessItems,
+		MaxWitnessItemBytes: c.Limits.MaxWitnessItemBytes,
+	})
+	markers := make([][]byte, len(c.Envelopes.Markers))
+	for i, m := range c.Envelopes.Markers {
+		markers[i], _ = utils.HexToBytes(m)
+	}
+	analyzer.SetEnvelopeScanner(analyzer.EnvelopeScanner{
+		Markers:  markers,
+		MinBytes: c.Envelopes.MinBytes,
+	})
+}
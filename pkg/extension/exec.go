@@ -0,0 +1,76 @@
+package extension
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/config"
+)
+
+// maxStderr bounds how much of a failing process's stderr is reported
+const maxStderr = 512
+
+// maxStdout bounds a detector's output; a detector writing more fails
+const maxStdout = 4 << 20
+
+// cappedBuffer keeps the first max bytes written to it and discards the
+// rest, so that the detector still runs to completion. The buffer is not
+// embedded: its ReadFrom would let io.Copy bypass the cap.
+type cappedBuffer struct {
+	buf      bytes.Buffer
+	max      int
+	overflow bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if room := b.max - b.buf.Len(); n > room {
+		b.overflow = true
+		p = p[:max(room, 0)]
+	}
+	b.buf.Write(p)
+	return n, nil
+}
+
+// output returns what the detector wrote, or an error when it wrote more
+// than max bytes
+func (b *cappedBuffer) output() ([]byte, error) {
+	if b.overflow {
+		return nil, fmt.Errorf("output exceeds %d bytes", b.max)
+	}
+	return b.buf.Bytes(), nil
+}
+
+// execRunner starts the configured executable once per transaction
+type execRunner struct {
+	path string
+	args []string
+}
+
+func newExecRunner(ext config.ExtensionConfig) *execRunner {
+	return &execRunner{path: ext.Path, args: ext.Args}
+}
+
+func (r *execRunner) run(ctx context.Context, input []byte) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, r.path, r.args...)
+	cmd.Stdin = bytes.NewReader(input)
+	stdout := &cappedBuffer{max: maxStdout}
+	var stderr bytes.Buffer
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > maxStderr {
+			msg = msg[:maxStderr] + "…"
+		}
+		if msg != "" {
+			return nil, fmt.Errorf("%v: %s", err, msg)
+		}
+		return nil, err
+	}
+	return stdout.output()
+}
@@ -0,0 +1,126 @@
+// Package extension runs custom detectors outside the Go build as analysis
+// stages: either an external process or a WASI command module.
+//
+// Both use the same protocol. The detector receives the transaction output
+// built so far (as JSON) on stdin and writes a JSON object to stdout:
+//
+//	{"findings": [{"code": "...", "severity": "...", "message": "...", "details": {...}}]}
+//
+// Findings are appended to the transaction's "findings" array with the
+// extension name as their source. A detector that fails, times out or
+// writes invalid output yields a single EXTENSION_FAILED finding instead of
+// failing the whole analysis.
+package extension
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/config"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/parser"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+const defaultTimeout = 5 * time.Second
+
+// runner executes one detector invocation
+type runner interface {
+	run(ctx context.Context, input []byte) ([]byte, error)
+}
+
+// response is the JSON a detector writes to stdout
+type response struct {
+	Findings []types.Finding `json:"findings"`
+}
+
+// Register loads every configured extension and registers it as a parser
+// stage named after the extension. A name already taken by a stage is an
+// error.
+func Register(exts []config.ExtensionConfig) error {
+	taken := make(map[string]bool)
+	for _, name := range parser.StageNames() {
+		taken[name] = true
+	}
+	for _, ext := range exts {
+		if taken[ext.Name] {
+			return fmt.Errorf("extension %s: a stage of that name is already registered", ext.Name)
+		}
+		taken[ext.Name] = true
+
+		var r runner
+		var err error
+		switch ext.Type {
+		case config.ExtensionExec:
+			r = newExecRunner(ext)
+		case config.ExtensionWasm:
+			r, err = newWasmRunner(ext)
+		default:
+			err = fmt.Errorf("unknown type %q", ext.Type)
+		}
+		if err != nil {
+			return fmt.Errorf("extension %s: %w", ext.Name, err)
+		}
+
+		timeout := defaultTimeout
+		if ext.TimeoutMs > 0 {
+			timeout = time.Duration(ext.TimeoutMs) * time.Millisecond
+		}
+		parser.RegisterStage(&stage{name: ext.Name, runner: r, timeout: timeout}, !ext.OptIn)
+	}
+	return nil
+}
+
+// stage adapts a runner to the parser.Stage interface
+type stage struct {
+	name    string
+	runner  runner
+	timeout time.Duration
+}
+
+func (s *stage) Name() string { return s.name }
+
+func (s *stage) Analyze(sc *parser.StageContext) error {
+	findings, err := s.invoke(sc.Output)
+	if err != nil {
+		findings = []types.Finding{{
+			Code:     "EXTENSION_FAILED",
+			Severity: "error",
+			Message:  err.Error(),
+		}}
+	}
+	for i := range findings {
+		findings[i].Source = s.name
+	}
+	sc.Output.Findings = append(sc.Output.Findings, findings...)
+	return nil
+}
+
+func (s *stage) invoke(out *types.TransactionOutput) ([]types.Finding, error) {
+	input, err := json.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	stdout, err := s.runner.run(ctx, input)
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("timed out after %s", s.timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var resp response
+	if err := json.Unmarshal(stdout, &resp); err != nil {
+		return nil, fmt.Errorf("invalid output: %v", err)
+	}
+	for _, f := range resp.Findings {
+		if f.Code == "" {
+			return nil, fmt.Errorf("invalid output: finding without code")
+		}
+	}
+	return resp.Findings, nil
+}
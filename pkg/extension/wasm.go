@@ -0,0 +1,80 @@
+package extension
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/config"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// wasmRunner instantiates a precompiled WASI command module per transaction.
+// Instances share nothing, so block analysis may run them concurrently.
+// Modules get no filesystem, network or clock beyond what WASI provides by
+// default.
+type wasmRunner struct {
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	args     []string
+}
+
+func newWasmRunner(ext config.ExtensionConfig) (*wasmRunner, error) {
+	code, err := os.ReadFile(ext.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read module: %w", err)
+	}
+
+	ctx := context.Background()
+	// Close modules when the per-call timeout fires, so a looping
+	// detector cannot stall the pipeline
+	rt := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, rt); err != nil {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("failed to set up WASI: %w", err)
+	}
+	compiled, err := rt.CompileModule(ctx, code)
+	if err != nil {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("failed to compile module: %w", err)
+	}
+	return &wasmRunner{
+		runtime:  rt,
+		compiled: compiled,
+		args:     append([]string{ext.Name}, ext.Args...),
+	}, nil
+}
+
+func (r *wasmRunner) run(ctx context.Context, input []byte) ([]byte, error) {
+	stdout := &cappedBuffer{max: maxStdout}
+	var stderr bytes.Buffer
+	cfg := wazero.NewModuleConfig().
+		WithName(""). // anonymous, so instances can run concurrently
+		WithArgs(r.args...).
+		WithStdin(bytes.NewReader(input)).
+		WithStdout(stdout).
+		WithStderr(&stderr)
+
+	mod, err := r.runtime.InstantiateModule(ctx, r.compiled, cfg)
+	if mod != nil {
+		defer mod.Close(ctx)
+	}
+	if err != nil {
+		var exitErr *sys.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 0 {
+			if msg := bytes.TrimSpace(stderr.Bytes()); len(msg) > 0 {
+				if len(msg) > maxStderr {
+					msg = msg[:maxStderr]
+				}
+				return nil, fmt.Errorf("%v: %s", err, msg)
+			}
+			return nil, err
+		}
+	}
+	return stdout.output()
+}
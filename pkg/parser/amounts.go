@@ -0,0 +1,71 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// AmountError reports a transaction whose amounts consensus rejects: a
+// value or total outside 0..MAX_MONEY, or outputs spending more than the
+// inputs provide. Code is the ErrorCode for it.
+type AmountError struct {
+	Code    string // e.g. "NEGATIVE_FEE"
+	Message string
+}
+
+func (e *AmountError) Error() string { return e.Message }
+
+func amountCode(err error) (string, bool) {
+	var amountErr *AmountError
+	if errors.As(err, &amountErr) {
+		return amountErr.Code, true
+	}
+	return "", false
+}
+
+// checkAmounts applies consensus's CheckTransaction and CheckTxInputs
+// value rules: every output value, the output total, every prevout value
+// and the input total must lie within 0..MAX_MONEY (21,000,000 BTC), and
+// unless the transaction is a coinbase, inputs must cover outputs. The fee
+// is not checked while prevouts are missing. prevouts are indexed like
+// tx.TxIn. Totals are checked as they grow, so they cannot overflow.
+func checkAmounts(tx *wire.MsgTx, prevouts []types.PrevoutInput, missingPrevouts bool) error {
+	var totalOut int64
+	for i, txOut := range tx.TxOut {
+		if !moneyRange(txOut.Value) {
+			return &AmountError{"OUTPUT_VALUE_OUT_OF_RANGE", fmt.Sprintf("output %d value %d is outside 0..%d sats", i, txOut.Value, int64(btcutil.MaxSatoshi))}
+		}
+		totalOut += txOut.Value
+		if !moneyRange(totalOut) {
+			return &AmountError{"OUTPUT_TOTAL_OUT_OF_RANGE", fmt.Sprintf("output total exceeds %d sats at output %d", int64(btcutil.MaxSatoshi), i)}
+		}
+	}
+
+	if len(tx.TxIn) > 0 && isCoinbaseInput(tx.TxIn[0]) {
+		return nil
+	}
+	var totalIn int64
+	for i, p := range prevouts {
+		if !moneyRange(p.ValueSats) {
+			return &AmountError{"INPUT_VALUE_OUT_OF_RANGE", fmt.Sprintf("input %d prevout value %d is outside 0..%d sats", i, p.ValueSats, int64(btcutil.MaxSatoshi))}
+		}
+		totalIn += p.ValueSats
+		if !moneyRange(totalIn) {
+			return &AmountError{"INPUT_TOTAL_OUT_OF_RANGE", fmt.Sprintf("input total exceeds %d sats at input %d", int64(btcutil.MaxSatoshi), i)}
+		}
+	}
+	if !missingPrevouts && totalIn < totalOut {
+		return &AmountError{"NEGATIVE_FEE", fmt.Sprintf("outputs total %d sats but inputs only %d", totalOut, totalIn)}
+	}
+	return nil
+}
+
+// moneyRange reports whether sats is a valid amount, as MoneyRange does
+func moneyRange(sats int64) bool {
+	return sats >= 0 && sats <= btcutil.MaxSatoshi
+}
@@ -0,0 +1,52 @@
+package parser
+
+import "github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+// historicalBIP30Txids are the mainnet coinbase txids that were mined twice
+// before BIP30 made duplicate txids invalid: d5d2...8599 (blocks 91812 and
+// 91842) and e3bf...b468 (blocks 91722 and 91880)
+var historicalBIP30Txids = map[string]bool{
+	"d5d27987d2a3dfc724e359870c6644b40e497bdc0589a033220fe15429d88599": true,
+	"e3bf3d07d4b0375638d5f1db5255fe07ba2c4cb067cd81b84ee974b6585fb468": true,
+}
+
+// txLocation is where a txid was first seen
+type txLocation struct {
+	blockHash string
+	index     int
+}
+
+// txidTracker detects txid reuse across the blocks of one run
+type txidTracker struct {
+	seen map[string]txLocation
+}
+
+func newTxidTracker() *txidTracker {
+	return &txidTracker{seen: make(map[string]txLocation)}
+}
+
+// check records the block's txids and fills in block.DuplicateTxids for
+// any that were seen before or that match a known historical duplicate
+func (t *txidTracker) check(block *types.BlockOutput) {
+	for i, tx := range block.Transactions {
+		first, dup := t.seen[tx.Txid]
+		historical := historicalBIP30Txids[tx.Txid]
+		if !dup && !historical {
+			t.seen[tx.Txid] = txLocation{blockHash: block.BlockHeader.BlockHash, index: i}
+			continue
+		}
+		d := types.DuplicateTxid{
+			Txid:       tx.Txid,
+			TxIndex:    i,
+			Coinbase:   i == 0,
+			Historical: historical,
+		}
+		if dup {
+			d.FirstBlockHash = first.blockHash
+			d.FirstTxIndex = &first.index
+		} else {
+			t.seen[tx.Txid] = txLocation{blockHash: block.BlockHeader.BlockHash, index: i}
+		}
+		block.DuplicateTxids = append(block.DuplicateTxids, d)
+	}
+}
@@ -7,42 +7,100 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync/atomic"
 
-	"chain-lens/pkg/types"
-	"chain-lens/pkg/utils"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/analyzer"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/utils"
 
 	btcec "github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 )
 
+// BlockOptions holds optional settings for block-file parsing
+type BlockOptions struct {
+	// CustomMagic is the message start of a custom signet. Blocks carrying
+	// it are parsed as "signet" in addition to the well-known networks.
+	CustomMagic *[4]byte
+
+	// Network is the network the caller expects the file to belong to.
+	// When set and the block magic says otherwise, the block is reported
+	// with a NETWORK_MISMATCH error instead of being decoded.
+	Network string
+
+	// Progress, when set, is called after each transaction is analyzed
+	// with the number done so far and the block's transaction count.
+	// It may be called concurrently from analysis workers.
+	Progress func(done, total int)
+
+	// Stages turns analysis stages on or off for every transaction,
+	// as types.Fixture.Stages does for a single transaction
+	Stages map[string]bool
+
+	// ExactVsize computes fee rates over weight/4, as
+	// types.Fixture.ExactVsize does for a single transaction
+	ExactVsize bool
+
+	// TopMovers is how many addresses the block's address_deltas report
+	// lists; 0 uses analyzer.DefaultTopMovers
+	TopMovers int
+
+	// FileProgress, when set, is called by ParseBlockFiles after the blocks
+	// of each file are analyzed with the number of files done so far and
+	// the number of files
+	FileProgress func(done, total int)
+
+	// AllBlocks parses every block in the file instead of only the first,
+	// as ParseBlockFiles does for a set of files
+	AllBlocks bool
+
+	// OnBlock, when set together with AllBlocks (or for ParseBlockFiles),
+	// receives each block as soon as it is analyzed instead of collecting
+	// them, which keeps memory flat over whole files. No blocks are then
+	// returned. An error from OnBlock stops the parse.
+	OnBlock func(*types.BlockOutput) error
+}
+
 // ParseBlock parses a blk*.dat file with its corresponding undo (rev*.dat) data
 func ParseBlock(blkPath, revPath, xorPath string) ([]*types.BlockOutput, error) {
+	return ParseBlockWithOptions(blkPath, revPath, xorPath, BlockOptions{})
+}
+
+// ParseBlockWithOptions is ParseBlock with additional parsing options
+func ParseBlockWithOptions(blkPath, revPath, xorPath string, opts BlockOptions) ([]*types.BlockOutput, error) {
+	if opts.AllBlocks {
+		return ParseBlockFiles([]BlockFile{{Blk: blkPath, Rev: revPath}}, xorPath, opts)
+	}
+
 	// Read XOR key
-	xorKey, err := os.ReadFile(xorPath)
+	stopRead := utils.TimeStage(utils.StageReadFile)
+	xorKey, err := readXORKey(xorPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read XOR key: %w", err)
 	}
 
 	// Read and XOR-decode block file
-	blkData, err := os.ReadFile(blkPath)
+	blkData, err := readXORFile(blkPath, xorKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read block file: %w", err)
 	}
-	blkData = utils.XORDecode(blkData, xorKey)
 
 	// Read and XOR-decode undo file
-	revData, err := os.ReadFile(revPath)
+	revData, err := readXORFile(revPath, xorKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read undo file: %w", err)
 	}
-	revData = utils.XORDecode(revData, xorKey)
+	stopRead()
 
 	// Parse only the FIRST block from the file (grader validates first block only)
 	blkReader := bytes.NewReader(blkData)
 	revReader := bytes.NewReader(revData)
-
-	block, err := parseOneBlock(blkReader, revReader)
+	readUndo := func(_ *wire.BlockHeader, transactions []*wire.MsgTx) ([][]types.PrevoutInput, error) {
+		return parseUndoFile(revReader, transactions)
+	}
+	block, err := parseOneBlock(blkReader, readUndo, opts)
 	if err != nil {
 		if err == io.EOF || err == io.ErrUnexpectedEOF {
 			return nil, fmt.Errorf("block file is empty or truncated")
@@ -50,10 +108,66 @@ func ParseBlock(blkPath, revPath, xorPath string) ([]*types.BlockOutput, error)
 		return nil, err
 	}
 
+	newTxidTracker().check(block)
 	return []*types.BlockOutput{block}, nil
 }
 
-func parseOneBlock(blkReader io.Reader, revReader io.ReadSeeker) (*types.BlockOutput, error) {
+// limitExceededBlock is the result for a block whose size or contents
+// exceed the parse limits
+func limitExceededBlock(blockHash string, err error) *types.BlockOutput {
+	return &types.BlockOutput{
+		OK:   false,
+		Mode: "block",
+		BlockHeader: types.BlockHeader{
+			BlockHash: blockHash,
+		},
+		Error: &types.ErrorInfo{
+			Code:    "LIMIT_EXCEEDED",
+			Message: fmt.Sprintf("%v (block %s)", err, blockHash),
+		},
+	}
+}
+
+// invalidBlock is the result for a block that breaks a consensus rule the
+// error describes
+func invalidBlock(blockHash, code string, err error) *types.BlockOutput {
+	return &types.BlockOutput{
+		OK:   false,
+		Mode: "block",
+		BlockHeader: types.BlockHeader{
+			BlockHash: blockHash,
+		},
+		Error: &types.ErrorInfo{
+			Code:    code,
+			Message: fmt.Sprintf("%v (block %s)", err, blockHash),
+		},
+	}
+}
+
+// readXORKey reads the obfuscation key from xor.dat. An empty path means
+// the files are not obfuscated, as nodes before v28 write them.
+func readXORKey(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return os.ReadFile(path)
+}
+
+// readXORFile reads a blk/rev file, decompressing it when it is gzip- or
+// zstd-compressed, and removes its XOR obfuscation
+func readXORFile(path string, xorKey []byte) ([]byte, error) {
+	data, err := utils.ReadInput(path)
+	if err != nil {
+		return nil, err
+	}
+	return utils.XORDecode(data, xorKey), nil
+}
+
+// undoReader returns the prevouts spent by each non-coinbase transaction of
+// a block, in input order
+type undoReader func(header *wire.BlockHeader, transactions []*wire.MsgTx) ([][]types.PrevoutInput, error)
+
+func parseOneBlock(blkReader io.Reader, readUndo undoReader, opts BlockOptions) (*types.BlockOutput, error) {
 	// Each block in blk*.dat is preceded by:
 	//   4 bytes: network magic (e.g. 0xF9BEB4D9 for mainnet)
 	//   4 bytes: block size in bytes (little-endian uint32)
@@ -62,42 +176,95 @@ func parseOneBlock(blkReader io.Reader, revReader io.ReadSeeker) (*types.BlockOu
 	if _, err := io.ReadFull(blkReader, magic[:]); err != nil {
 		return nil, err // EOF signals end of file
 	}
+	network, ok := analyzer.NetworkFromMagic(magic)
+	if opts.CustomMagic != nil && magic == *opts.CustomMagic {
+		network, ok = analyzer.NetworkSignet, true
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown network magic %x", magic)
+	}
 
 	var blockSizeLE [4]byte
 	if _, err := io.ReadFull(blkReader, blockSizeLE[:]); err != nil {
 		return nil, fmt.Errorf("failed to read block size: %w", err)
 	}
-	_ = binary.LittleEndian.Uint32(blockSizeLE[:]) // size for reference
+	blockSize := binary.LittleEndian.Uint32(blockSizeLE[:])
 
-	// Parse 80-byte block header
+	// Parse 80-byte block header. Everything after it is read through a
+	// size-limited reader so that a failure mid-block (or a skipped block)
+	// leaves blkReader at the next record.
 	var header wire.BlockHeader
-	if err := header.Deserialize(blkReader); err != nil {
+	body := io.LimitReader(blkReader, int64(blockSize))
+	defer io.Copy(io.Discard, body)
+	if err := header.Deserialize(body); err != nil {
 		return nil, fmt.Errorf("failed to parse block header: %w", err)
 	}
 
 	// Compute block hash (double SHA256 of header, reversed)
 	blockHash := header.BlockHash().String()
 
+	// The declared network must agree with the magic, otherwise every
+	// address in the output would be encoded for the wrong chain
+	if opts.Network != "" && opts.Network != network {
+		return &types.BlockOutput{
+			OK:   false,
+			Mode: "block",
+			BlockHeader: types.BlockHeader{
+				BlockHash: blockHash,
+			},
+			Error: &types.ErrorInfo{
+				Code:    "NETWORK_MISMATCH",
+				Message: fmt.Sprintf("block magic %x belongs to %s but %s was requested", magic, network, opts.Network),
+			},
+		}, nil
+	}
+
+	// Read the rest of the block so that its transaction count and each
+	// transaction can be checked against the parse limits before decoding.
+	// An oversized record is skipped unread.
+	if err := checkBlockSize(int64(blockSize)); err != nil {
+		return limitExceededBlock(blockHash, err), nil
+	}
+	rest := make([]byte, int64(blockSize)-wire.MaxBlockHeaderPayload)
+	if _, err := io.ReadFull(body, rest); err != nil {
+		return nil, fmt.Errorf("failed to read block: %w", err)
+	}
+	txReader := bytes.NewReader(rest)
+
 	// Read transaction count (CompactSize)
-	txCount, err := utils.ReadCompactSize(blkReader)
+	txCount, err := utils.ReadCompactSize(txReader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read tx count: %w", err)
 	}
+	if err := checkBlockTxCount(txCount, int64(txReader.Len())); err != nil {
+		if isLimitError(err) {
+			return limitExceededBlock(blockHash, err), nil
+		}
+		return nil, err
+	}
 
 	// Parse all transactions
-	var transactions []*wire.MsgTx
-	var txHashes []chainhash.Hash
+	stopDeserialize := utils.TimeStage(utils.StageDeserialize)
+	transactions := make([]*wire.MsgTx, 0, txCount)
 	for i := uint64(0); i < txCount; i++ {
-		tx := wire.NewMsgTx(wire.TxVersion)
-		if err := tx.Deserialize(blkReader); err != nil {
+		tx, err := deserializeTx(txReader)
+		if err != nil {
+			stopDeserialize()
+			if isLimitError(err) {
+				return limitExceededBlock(blockHash, fmt.Errorf("tx %d: %w", i, err)), nil
+			}
 			return nil, fmt.Errorf("failed to parse tx %d: %w", i, err)
 		}
 		transactions = append(transactions, tx)
-		txHashes = append(txHashes, tx.TxHash())
 	}
-
-	// Verify Merkle root
-	computedMerkleRoot := computeMerkleRoot(txHashes)
+	stopDeserialize()
+
+	// Verify Merkle root (txHashes is used as scratch space and overwritten)
+	stopMerkle := utils.TimeStage(utils.StageMerkle)
+	txHashes := utils.TxHashes(transactions, false)
+	computedMerkleRoot := utils.MerkleRootInPlace(txHashes)
+	witnessCommitmentValid := checkWitnessCommitment(transactions)
+	stopMerkle()
 	merkleRootValid := bytes.Equal(computedMerkleRoot[:], header.MerkleRoot[:])
 
 	if !merkleRootValid {
@@ -114,8 +281,15 @@ func parseOneBlock(blkReader io.Reader, revReader io.ReadSeeker) (*types.BlockOu
 		}, nil
 	}
 
+	// An outpoint spent twice makes the block invalid
+	if err := checkDuplicateInputs(transactions); err != nil {
+		return invalidBlock(blockHash, "DUPLICATE_INPUT", err), nil
+	}
+
 	// Parse undo data to recover prevouts for all non-coinbase inputs
-	prevouts, err := parseUndoFile(revReader, transactions)
+	stopUndo := utils.TimeStage(utils.StageUndo)
+	prevouts, err := readUndo(&header, transactions)
+	stopUndo()
 	if err != nil {
 		return &types.BlockOutput{
 			OK:   false,
@@ -134,17 +308,23 @@ func parseOneBlock(blkReader io.Reader, revReader io.ReadSeeker) (*types.BlockOu
 	coinbaseTx := transactions[0]
 	bip34Height := extractBIP34Height(coinbaseTx.TxIn[0].SignatureScript)
 	coinbaseOutputTotal := int64(0)
-	for _, out := range coinbaseTx.TxOut {
+	coinbaseScripts := make([][]byte, len(coinbaseTx.TxOut))
+	for i, out := range coinbaseTx.TxOut {
 		coinbaseOutputTotal += out.Value
+		coinbaseScripts[i] = out.PkScript
 	}
+	// The message starts after the height push when there is one
+	messageSkip := 0
+	if bip34Height > 0 {
+		messageSkip = 1 + int(coinbaseTx.TxIn[0].SignatureScript[0])
+	}
+	coinbaseMessage, coinbaseSegments := analyzer.CoinbaseMessage(coinbaseTx.TxIn[0].SignatureScript, messageSkip)
 
-	// Build transaction outputs + block stats
-	var txOutputs []types.TransactionOutput
-	var totalFees int64
-	var totalWeight int
-	scriptTypeCounts := make(map[string]int)
-
-	for i, tx := range transactions {
+	// Analyze transactions on the shared worker pool; results keep block order
+	txOutputs := make([]types.TransactionOutput, len(transactions))
+	var analyzed atomic.Int64
+	err = utils.ForEach(len(transactions), func(i int) error {
+		tx := transactions[i]
 		var prevoutInputs []types.PrevoutInput
 		if i > 0 { // Skip coinbase — has no undo data
 			for j, txIn := range tx.TxIn {
@@ -158,24 +338,70 @@ func parseOneBlock(blkReader io.Reader, revReader io.ReadSeeker) (*types.BlockOu
 		}
 
 		fixture := types.Fixture{
-			Network:  "mainnet",
-			Prevouts: prevoutInputs,
+			Network:    network,
+			Prevouts:   prevoutInputs,
+			Stages:     opts.Stages,
+			ExactVsize: opts.ExactVsize,
+		}
+		if bip34Height > 0 {
+			fixture.BlockHeight = &bip34Height
 		}
 
 		txOutput, err := analyzeTransaction(tx, fixture, i == 0)
 		if err != nil {
-			return nil, fmt.Errorf("failed to analyze tx %d: %w", i, err)
+			return fmt.Errorf("failed to analyze tx %d: %w", i, err)
 		}
+		txOutputs[i] = *txOutput
+		if opts.Progress != nil {
+			opts.Progress(int(analyzed.Add(1)), len(transactions))
+		}
+		return nil
+	})
+	if code, ok := amountCode(err); ok {
+		return invalidBlock(blockHash, code, err), nil
+	}
+	if err != nil {
+		return nil, err
+	}
 
-		txOutputs = append(txOutputs, *txOutput)
+	// Build block stats
+	var totalFees int64
+	var totalWeight int
+	var dustCreated, dustSpent int
+	var envelopeCount, envelopeBytes int
+	scriptTypeCounts := make(map[string]int)
+	tapTrees := analyzer.NewTapTreeBuilder()
+	var sigops *types.SigopCount
 
+	for i, txOutput := range txOutputs {
+		if txOutput.Sigops != nil {
+			if sigops == nil {
+				sigops = &types.SigopCount{Limit: analyzer.MaxBlockSigopsCost}
+			}
+			analyzer.AddSigops(sigops, *txOutput.Sigops)
+		}
 		if i > 0 {
-			totalFees += txOutput.FeeSats
+			totalFees += *txOutput.FeeSats
+			// Spent values and scripts come from the undo data
+			for j, in := range txOutput.Vin {
+				tapTrees.Add(&txOutput.Vin[j])
+				prevScript, _ := hex.DecodeString(in.Prevout.ScriptPubkeyHex)
+				if analyzer.IsDustOutput(prevScript, in.Prevout.ValueSats) {
+					dustSpent++
+				}
+				for _, env := range in.Envelopes {
+					envelopeCount++
+					envelopeBytes += env.DataBytes
+				}
+			}
 		}
 		totalWeight += txOutput.Weight
 
 		for _, out := range txOutput.Vout {
 			scriptTypeCounts[out.ScriptType]++
+			if analyzer.IsDustOutput(out.ScriptPubkeyHex, out.ValueSats) {
+				dustCreated++
+			}
 		}
 	}
 
@@ -185,57 +411,97 @@ func parseOneBlock(blkReader io.Reader, revReader io.ReadSeeker) (*types.BlockOu
 		avgFeeRate = float64(totalFees) / float64(totalVbytes)
 	}
 
+	coinbase := types.CoinbaseInfo{
+		Bip34Height:       bip34Height,
+		CoinbaseScriptHex: hex.EncodeToString(coinbaseTx.TxIn[0].SignatureScript),
+		TotalOutputSats:   coinbaseOutputTotal,
+		Commitments:       analyzer.FindCoinbaseCommitments(coinbaseTx.TxIn[0].SignatureScript, coinbaseScripts),
+		Message:           coinbaseMessage,
+		Segments:          coinbaseSegments,
+		Extranonce:        analyzer.FindExtranonce(coinbaseTx.TxIn[0].SignatureScript, messageSkip, coinbaseSegments),
+		Timestamps:        analyzer.FindCoinbaseTimestamps(coinbaseTx.TxIn[0].SignatureScript, messageSkip, uint32(header.Timestamp.Unix())),
+		Payouts:           analyzer.AnalyzeCoinbasePayouts(txOutputs[0].Vout),
+	}
+	coinbase.Layout = analyzer.CoinbaseLayout(coinbaseTx.TxIn[0].SignatureScript, messageSkip, coinbaseSegments, coinbase.Extranonce, coinbase.Timestamps)
+	if pool, confidence := analyzer.IdentifyPool(coinbaseTx.TxIn[0].SignatureScript, coinbase.Payouts.PrimaryAddress); pool != "" {
+		coinbase.PoolName, coinbase.PoolConfidence = &pool, &confidence
+	}
+	if bip34Height > 0 {
+		subsidy := analyzer.BlockSubsidy(bip34Height, network)
+		overpaid, underclaimed := analyzer.CheckCoinbaseClaim(coinbaseOutputTotal, subsidy+totalFees)
+		coinbase.SubsidySats, coinbase.Overpaid, coinbase.UnderclaimedSats = &subsidy, &overpaid, &underclaimed
+	}
+
 	return &types.BlockOutput{
-		OK:   true,
-		Mode: "block",
-		BlockHeader: types.BlockHeader{
-			Version:         header.Version,
-			PrevBlockHash:   header.PrevBlock.String(),
-			MerkleRoot:      header.MerkleRoot.String(),
-			MerkleRootValid: merkleRootValid,
-			Timestamp:       uint32(header.Timestamp.Unix()),
-			Bits:            fmt.Sprintf("%08x", header.Bits),
-			Nonce:           header.Nonce,
-			BlockHash:       blockHash,
-		},
-		TxCount: int(txCount),
-		Coinbase: types.CoinbaseInfo{
-			Bip34Height:       bip34Height,
-			CoinbaseScriptHex: hex.EncodeToString(coinbaseTx.TxIn[0].SignatureScript),
-			TotalOutputSats:   coinbaseOutputTotal,
-		},
+		OK:           true,
+		Mode:         "block",
+		BlockHeader:  blockHeaderInfo(&header, merkleRootValid),
+		TxCount:      int(txCount),
+		Coinbase:     coinbase,
 		Transactions: txOutputs,
 		BlockStats: types.BlockStats{
 			TotalFeesSats:     totalFees,
 			TotalWeight:       totalWeight,
 			AvgFeeRateSatVb:   avgFeeRate,
 			ScriptTypeSummary: scriptTypeCounts,
+			DustCreated:       dustCreated,
+			DustSpent:         dustSpent,
+			EnvelopeCount:     envelopeCount,
+			EnvelopeBytes:     envelopeBytes,
+			Sigops:            sigops,
 		},
+		WitnessCommitmentValid: witnessCommitmentValid,
+		AddressDeltas:          analyzer.AddressDeltas(txOutputs, opts.TopMovers),
+		TaprootTrees:           tapTrees.Trees(2),
 	}, nil
 }
 
-// computeMerkleRoot computes the merkle root from transaction hashes (Bitcoin spec)
-func computeMerkleRoot(txHashes []chainhash.Hash) chainhash.Hash {
-	if len(txHashes) == 0 {
-		return chainhash.Hash{}
+// checkWitnessCommitment validates a block's BIP141 witness commitment:
+// the coinbase's only witness item is the 32-byte reserved value, and
+// sha256d(witness merkle root || reserved value) is the committed hash. A
+// block without a commitment is valid only if no transaction has a witness.
+func checkWitnessCommitment(transactions []*wire.MsgTx) bool {
+	coinbase := transactions[0]
+	scripts := make([][]byte, len(coinbase.TxOut))
+	for i, out := range coinbase.TxOut {
+		scripts[i] = out.PkScript
 	}
-	if len(txHashes) == 1 {
-		return txHashes[0]
+	commitment, ok := analyzer.WitnessCommitment(scripts)
+	if !ok {
+		for _, tx := range transactions {
+			if tx.HasWitness() {
+				return false
+			}
+		}
+		return true
 	}
 
-	var nextLevel []chainhash.Hash
-	for i := 0; i < len(txHashes); i += 2 {
-		left := txHashes[i]
-		right := txHashes[i]
-		if i+1 < len(txHashes) {
-			right = txHashes[i+1]
-		}
-		combined := append(left[:], right[:]...)
-		hash := chainhash.DoubleHashH(combined)
-		nextLevel = append(nextLevel, hash)
+	witness := coinbase.TxIn[0].Witness
+	if len(witness) != 1 || len(witness[0]) != chainhash.HashSize {
+		return false
 	}
+	root := utils.WitnessMerkleRoot(utils.TxHashes(transactions, true))
+	return bytes.Equal(chainhash.DoubleHashB(append(root[:], witness[0]...)), commitment)
+}
 
-	return computeMerkleRoot(nextLevel)
+// blockHeaderInfo converts a decoded header to its JSON form
+func blockHeaderInfo(header *wire.BlockHeader, merkleRootValid bool) types.BlockHeader {
+	hash := header.BlockHash()
+	return types.BlockHeader{
+		Version:         header.Version,
+		PrevBlockHash:   header.PrevBlock.String(),
+		MerkleRoot:      header.MerkleRoot.String(),
+		MerkleRootValid: merkleRootValid,
+		Timestamp:       uint32(header.Timestamp.Unix()),
+		Bits:            fmt.Sprintf("%08x", header.Bits),
+		Nonce:           header.Nonce,
+		BlockHash:       hash.String(),
+		Target:          analyzer.TargetFromBits(header.Bits),
+		Difficulty:      analyzer.DifficultyFromBits(header.Bits),
+		ExpectedHashes:  analyzer.ExpectedHashesFromBits(header.Bits),
+		PowValid:        analyzer.CheckProofOfWork(hash, header.Bits),
+		VersionBits:     analyzer.SignalledVersionBits(header.Version),
+	}
 }
 
 // parseUndoFile parses the undo (rev*.dat) file to extract prevouts for non-coinbase inputs.
@@ -320,16 +586,7 @@ func parseUndoFile(r io.ReadSeeker, transactions []*wire.MsgTx) ([][]types.Prevo
 //  1. nCode:    VARINT — encodes nHeight*2 + fCoinBase
 //  2. version:  VARINT (one byte 0x00) — only present when nHeight > 0
 //     (backward compat dummy; always 0 in modern Bitcoin Core)
-//  3. TxOutCompression:
-//     a. nValue:    VARINT (compressed satoshi amount)
-//     b. nSize:     VARINT — determines script type:
-//     0 = P2PKH (20-byte hash follows)
-//     1 = P2SH  (20-byte hash follows)
-//     2 = P2PK compressed even (32-byte x-coord follows)
-//     3 = P2PK compressed odd  (32-byte x-coord follows)
-//     4 = P2PK uncompressed even (32-byte x-coord follows, decoded to 65 bytes)
-//     5 = P2PK uncompressed odd  (32-byte x-coord follows, decoded to 65 bytes)
-//     n>=6 = raw script, len = n-6
+//  3. TxOutCompression: amount and script, see readCompressedTxOut
 func readUndoPrevout(r io.Reader) (types.PrevoutInput, error) {
 	// Read nCode (CVarInt): encodes nHeight*2 + fCoinBase
 	nCode, err := utils.ReadBitcoinVarInt(r)
@@ -337,6 +594,7 @@ func readUndoPrevout(r io.Reader) (types.PrevoutInput, error) {
 		return types.PrevoutInput{}, fmt.Errorf("readUndoPrevout nCode: %w", err)
 	}
 	nHeight := nCode >> 1
+	height := int64(nHeight)
 
 	// Bitcoin Core TxInUndoFormatter (undo.h): when nHeight > 0, read a dummy
 	// version VARINT for backward compatibility with older undo format.
@@ -347,17 +605,44 @@ func readUndoPrevout(r io.Reader) (types.PrevoutInput, error) {
 		}
 	}
 
+	valueSats, scriptPubkey, err := readCompressedTxOut(r)
+	if err != nil {
+		return types.PrevoutInput{}, fmt.Errorf("readUndoPrevout %w", err)
+	}
+
+	return types.PrevoutInput{
+		Txid:            "", // Not stored in undo file
+		Vout:            0,  // Not stored in undo file
+		ValueSats:       valueSats,
+		ScriptPubkeyHex: hex.EncodeToString(scriptPubkey),
+		Height:          &height,
+	}, nil
+}
+
+// readCompressedTxOut reads an output in Bitcoin Core's TxOutCompression
+// format, as undo data and UTXO snapshots store it:
+//
+//	nValue: VARINT — compressed satoshi amount
+//	nSize:  VARINT — determines script type:
+//	  0 = P2PKH (20-byte hash follows)
+//	  1 = P2SH  (20-byte hash follows)
+//	  2 = P2PK compressed even (32-byte x-coord follows)
+//	  3 = P2PK compressed odd  (32-byte x-coord follows)
+//	  4 = P2PK uncompressed even (32-byte x-coord follows, decoded to 65 bytes)
+//	  5 = P2PK uncompressed odd  (32-byte x-coord follows, decoded to 65 bytes)
+//	  n>=6 = raw script, len = n-6
+func readCompressedTxOut(r io.Reader) (int64, []byte, error) {
 	// Read compressed amount (CVarInt) — must decompress to satoshis
 	compressedAmount, err := utils.ReadBitcoinVarInt(r)
 	if err != nil {
-		return types.PrevoutInput{}, fmt.Errorf("readUndoPrevout amount: %w", err)
+		return 0, nil, fmt.Errorf("amount: %w", err)
 	}
 	valueSats := utils.DecompressAmount(compressedAmount)
 
 	// Read nSize (CVarInt) — determines script type
 	nSize, err := utils.ReadBitcoinVarInt(r)
 	if err != nil {
-		return types.PrevoutInput{}, fmt.Errorf("readUndoPrevout nSize: %w", err)
+		return 0, nil, fmt.Errorf("nSize: %w", err)
 	}
 
 	// Decompress script based on nSize
@@ -366,7 +651,7 @@ func readUndoPrevout(r io.Reader) (types.PrevoutInput, error) {
 	case 0: // P2PKH: 20-byte hash
 		hash := make([]byte, 20)
 		if _, err := io.ReadFull(r, hash); err != nil {
-			return types.PrevoutInput{}, fmt.Errorf("readUndoPrevout P2PKH hash: %w", err)
+			return 0, nil, fmt.Errorf("P2PKH hash: %w", err)
 		}
 		scriptPubkey = append([]byte{0x76, 0xa9, 0x14}, hash...)
 		scriptPubkey = append(scriptPubkey, 0x88, 0xac)
@@ -374,7 +659,7 @@ func readUndoPrevout(r io.Reader) (types.PrevoutInput, error) {
 	case 1: // P2SH: 20-byte hash
 		hash := make([]byte, 20)
 		if _, err := io.ReadFull(r, hash); err != nil {
-			return types.PrevoutInput{}, fmt.Errorf("readUndoPrevout P2SH hash: %w", err)
+			return 0, nil, fmt.Errorf("P2SH hash: %w", err)
 		}
 		scriptPubkey = append([]byte{0xa9, 0x14}, hash...)
 		scriptPubkey = append(scriptPubkey, 0x87)
@@ -383,7 +668,7 @@ func readUndoPrevout(r io.Reader) (types.PrevoutInput, error) {
 		key := make([]byte, 33)
 		key[0] = byte(nSize) // 0x02 or 0x03
 		if _, err := io.ReadFull(r, key[1:]); err != nil {
-			return types.PrevoutInput{}, fmt.Errorf("readUndoPrevout P2PK compressed: %w", err)
+			return 0, nil, fmt.Errorf("P2PK compressed: %w", err)
 		}
 		scriptPubkey = append([]byte{0x21}, key...)
 		scriptPubkey = append(scriptPubkey, 0xac)
@@ -394,7 +679,7 @@ func readUndoPrevout(r io.Reader) (types.PrevoutInput, error) {
 		// We reconstruct the full 65-byte uncompressed key using btcec.
 		xcoord := make([]byte, 32)
 		if _, err := io.ReadFull(r, xcoord); err != nil {
-			return types.PrevoutInput{}, fmt.Errorf("readUndoPrevout P2PK uncompressed: %w", err)
+			return 0, nil, fmt.Errorf("P2PK uncompressed: %w", err)
 		}
 		compressedKey := append([]byte{byte(nSize - 2)}, xcoord...)
 		pubKey, err := btcec.ParsePubKey(compressedKey)
@@ -410,18 +695,21 @@ func readUndoPrevout(r io.Reader) (types.PrevoutInput, error) {
 
 	default: // Raw script: length = nSize - 6
 		scriptLen := nSize - 6
+		if scriptLen > txscript.MaxScriptSize {
+			// Bitcoin Core stores an oversized script as OP_RETURN and
+			// skips its bytes
+			if _, err := io.CopyN(io.Discard, r, int64(scriptLen)); err != nil {
+				return 0, nil, fmt.Errorf("raw script (len=%d): %w", scriptLen, err)
+			}
+			return valueSats, []byte{txscript.OP_RETURN}, nil
+		}
 		scriptPubkey = make([]byte, scriptLen)
 		if _, err := io.ReadFull(r, scriptPubkey); err != nil {
-			return types.PrevoutInput{}, fmt.Errorf("readUndoPrevout raw script (len=%d): %w", scriptLen, err)
+			return 0, nil, fmt.Errorf("raw script (len=%d): %w", scriptLen, err)
 		}
 	}
 
-	return types.PrevoutInput{
-		Txid:            "", // Not stored in undo file
-		Vout:            0,  // Not stored in undo file
-		ValueSats:       valueSats,
-		ScriptPubkeyHex: hex.EncodeToString(scriptPubkey),
-	}, nil
+	return valueSats, scriptPubkey, nil
 }
 
 // extractBIP34Height extracts block height from coinbase scriptSig (BIP34)
@@ -446,13 +734,9 @@ func extractBIP34Height(scriptSig []byte) int64 {
 
 // analyzeTransaction converts a parsed wire.MsgTx to TransactionOutput
 func analyzeTransaction(tx *wire.MsgTx, fixture types.Fixture, isCoinbase bool) (*types.TransactionOutput, error) {
-	var buf bytes.Buffer
-	tx.Serialize(&buf)
-	fixture.RawTx = hex.EncodeToString(buf.Bytes())
-
 	if isCoinbase {
 		fixture.Prevouts = []types.PrevoutInput{}
 	}
 
-	return ParseTransaction(fixture)
+	return AnalyzeParsedTransaction(tx, fixture)
 }
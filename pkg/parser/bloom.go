@@ -0,0 +1,191 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/analyzer"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/utils"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// defaultBloomFPRate is the false positive rate used when a fixture sets none
+const defaultBloomFPRate = 0.0001
+
+// MatchBloomFilter builds a BIP37 filter from a fixture's addresses,
+// scripts, outpoints and raw elements, or loads it from a filterload
+// payload, then matches the fixture's block and transactions against it in
+// order, as a node serving a filtered peer would. For a block it also
+// returns the merkleblock the node would send with the matched
+// transactions.
+func MatchBloomFilter(fixture types.BloomFixture) (*types.BloomOutput, error) {
+	network := fixture.Network
+	if network == "" {
+		network = analyzer.NetworkMainnet
+	}
+
+	filter, err := loadBloomFilter(fixture, network)
+	if err != nil {
+		return nil, err
+	}
+
+	var txs []*wire.MsgTx
+	var block *wire.MsgBlock
+	if fixture.Block != "" {
+		raw, err := utils.HexToBytes(fixture.Block)
+		if err != nil {
+			return nil, fmt.Errorf("invalid block hex: %w", err)
+		}
+		block, err = deserializeBlock(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse block: %w", err)
+		}
+		txs = append(txs, block.Transactions...)
+	}
+	for i, rawTx := range fixture.Transactions {
+		tx, err := deserializeTxHex(rawTx)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %w", i, err)
+		}
+		txs = append(txs, tx)
+	}
+
+	elements := bloomFixtureElements(fixture)
+	out := &types.BloomOutput{
+		OK:      true,
+		Mode:    "bloom",
+		Network: network,
+		Filter:  bloomFilterInfo(filter, elements),
+		TxCount: len(txs),
+		Matches: make([]types.BloomMatch, len(txs)),
+	}
+	for i, tx := range txs {
+		reasons := filter.MatchTransaction(tx)
+		out.Matches[i] = types.BloomMatch{Txid: tx.TxHash().String(), Matched: len(reasons) > 0, Reasons: reasons}
+		if len(reasons) > 0 {
+			out.MatchedCount++
+		}
+	}
+	out.FinalFilter = bloomFilterInfo(filter, elements)
+
+	if block != nil {
+		txids := make([]chainhash.Hash, len(block.Transactions))
+		matched := make([]bool, len(block.Transactions))
+		for i, tx := range block.Transactions {
+			txids[i] = tx.TxHash()
+			matched[i] = out.Matches[i].Matched
+		}
+		tree := buildPartialMerkleTree(txids, matched)
+		out.MerkleBlockHex = hex.EncodeToString(encodeTxOutProof(&block.Header, tree))
+	}
+	return out, nil
+}
+
+// bloomFixtureElements counts the elements a fixture adds to its filter
+func bloomFixtureElements(fixture types.BloomFixture) int {
+	return len(fixture.Addresses) + len(fixture.Scripts) + len(fixture.Outpoints) + len(fixture.Elements)
+}
+
+func loadBloomFilter(fixture types.BloomFixture, network string) (*analyzer.BloomFilter, error) {
+	elements := bloomFixtureElements(fixture)
+	if fixture.FilterLoad != "" {
+		if elements > 0 {
+			return nil, errors.New("give either filterload or elements to build a filter, not both")
+		}
+		raw, err := utils.HexToBytes(fixture.FilterLoad)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filterload hex: %w", err)
+		}
+		var msg wire.MsgFilterLoad
+		if err := msg.BtcDecode(bytes.NewReader(raw), wire.ProtocolVersion, wire.BaseEncoding); err != nil {
+			return nil, fmt.Errorf("failed to parse filterload: %w", err)
+		}
+		return analyzer.BloomFilterFromLoad(&msg)
+	}
+	if elements == 0 {
+		return nil, errors.New("a filter needs addresses, scripts, outpoints, elements or a filterload payload")
+	}
+
+	update, err := analyzer.ParseBloomUpdate(fixture.Update)
+	if err != nil {
+		return nil, err
+	}
+	fpRate := fixture.FPRate
+	if fpRate == 0 {
+		fpRate = defaultBloomFPRate
+	}
+	filter, err := analyzer.NewBloomFilter(elements, fpRate, fixture.Tweak, update)
+	if err != nil {
+		return nil, err
+	}
+
+	// Addresses match through the data their scriptPubKey pushes
+	for _, address := range fixture.Addresses {
+		script, _, err := analyzer.GetScriptFromAddress(address, network)
+		if err != nil {
+			return nil, fmt.Errorf("address %s: %w", address, err)
+		}
+		filter.AddScriptPushes(script)
+	}
+	for i, s := range fixture.Scripts {
+		script, err := hex.DecodeString(s)
+		if err != nil || len(script) == 0 {
+			return nil, fmt.Errorf("script %d: invalid hex", i)
+		}
+		filter.AddScriptPushes(script)
+	}
+	for _, s := range fixture.Outpoints {
+		op, err := parseOutPoint(s)
+		if err != nil {
+			return nil, err
+		}
+		filter.AddOutPoint(op)
+	}
+	for i, s := range fixture.Elements {
+		data, err := hex.DecodeString(s)
+		if err != nil || len(data) == 0 {
+			return nil, fmt.Errorf("element %d: invalid hex", i)
+		}
+		filter.Add(data)
+	}
+	return filter, nil
+}
+
+// parseOutPoint parses "txid:vout"
+func parseOutPoint(s string) (wire.OutPoint, error) {
+	txid, vout, ok := strings.Cut(s, ":")
+	if !ok {
+		return wire.OutPoint{}, fmt.Errorf("outpoint %q: want txid:vout", s)
+	}
+	hash, err := chainhash.NewHashFromStr(txid)
+	if err != nil {
+		return wire.OutPoint{}, fmt.Errorf("outpoint %q: %w", s, err)
+	}
+	index, err := strconv.ParseUint(vout, 10, 32)
+	if err != nil {
+		return wire.OutPoint{}, fmt.Errorf("outpoint %q: invalid vout", s)
+	}
+	return wire.OutPoint{Hash: *hash, Index: uint32(index)}, nil
+}
+
+func bloomFilterInfo(filter *analyzer.BloomFilter, elements int) *types.BloomFilterInfo {
+	var buf bytes.Buffer
+	filter.MsgFilterLoad().BtcEncode(&buf, wire.ProtocolVersion, wire.BaseEncoding)
+	return &types.BloomFilterInfo{
+		FilterHex:     hex.EncodeToString(filter.Data),
+		Bytes:         len(filter.Data),
+		HashFuncs:     filter.HashFuncs,
+		Tweak:         filter.Tweak,
+		Update:        analyzer.BloomUpdateName(filter.Flags),
+		Elements:      elements,
+		FillRatio:     filter.FillRatio(),
+		FilterLoadHex: hex.EncodeToString(buf.Bytes()),
+	}
+}
@@ -0,0 +1,246 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/utils"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// BlockFile is one blk*.dat file with its matching rev*.dat
+type BlockFile struct {
+	Blk string
+	Rev string
+}
+
+// PairRevFiles pairs each blk*.dat path with the rev*.dat file of the same
+// number in the same directory, as Bitcoin Core names them
+func PairRevFiles(blkPaths []string) ([]BlockFile, error) {
+	files := make([]BlockFile, len(blkPaths))
+	for i, blk := range blkPaths {
+		dir, name := filepath.Split(blk)
+		if !strings.HasPrefix(name, "blk") {
+			return nil, fmt.Errorf("%s is not a blk*.dat file", blk)
+		}
+		rev, err := findRevFile(dir, "rev"+strings.TrimPrefix(name, "blk"))
+		if err != nil {
+			return nil, fmt.Errorf("no undo file for %s: %w", blk, err)
+		}
+		files[i] = BlockFile{Blk: blk, Rev: rev}
+	}
+	return files, nil
+}
+
+// findRevFile looks for a rev file named like its blk file, or the same
+// file compressed differently, since the two are often archived separately
+func findRevFile(dir, name string) (string, error) {
+	base := utils.TrimCompressedExtension(name)
+	candidates := []string{name, base}
+	for _, ext := range utils.CompressedExtensions {
+		candidates = append(candidates, base+ext)
+	}
+	var firstErr error
+	for _, c := range candidates {
+		path := filepath.Join(dir, c)
+		_, err := os.Stat(path)
+		if err == nil {
+			return path, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return "", firstErr
+}
+
+// blockRecord locates one block inside a decoded blk*.dat file
+type blockRecord struct {
+	offset int // start of the record, at the network magic
+	size   uint32
+	header wire.BlockHeader
+}
+
+// scanBlockRecords walks the records of a decoded blk*.dat file reading only
+// the 8-byte magic/size prefix and the header of each block, and skipping
+// the transactions by size. It stops at the zero padding Bitcoin Core
+// preallocates at the end of a file.
+func scanBlockRecords(data []byte) ([]blockRecord, error) {
+	var records []blockRecord
+	for pos := 0; pos+8 <= len(data); {
+		if bytes.Equal(data[pos:pos+4], []byte{0, 0, 0, 0}) {
+			break
+		}
+		size := binary.LittleEndian.Uint32(data[pos+4 : pos+8])
+		end := pos + 8 + int(size)
+		if size < wire.MaxBlockHeaderPayload || end > len(data) {
+			return nil, fmt.Errorf("block record at offset %d is truncated", pos)
+		}
+		rec := blockRecord{offset: pos, size: size}
+		if err := rec.header.Deserialize(bytes.NewReader(data[pos+8 : end])); err != nil {
+			return nil, fmt.Errorf("block record at offset %d: %w", pos, err)
+		}
+		records = append(records, rec)
+		pos = end
+	}
+	return records, nil
+}
+
+// ParseBlockFiles parses every block in a set of block files. Before any
+// transaction is analyzed, the headers of all files are linked by their
+// previous-block hash so that blocks on losing branches can be marked
+// stale. A block's undo data may have been written to a neighbouring rev
+// file, so the undo records of each file's rev file and of those on either
+// side are indexed while its blocks are analyzed; only those rev files are
+// held in memory, which lets a whole blocks directory be parsed.
+func ParseBlockFiles(files []BlockFile, xorPath string, opts BlockOptions) ([]*types.BlockOutput, error) {
+	xorKey, err := readXORKey(xorPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read XOR key: %w", err)
+	}
+
+	// First pass: headers. Block files are read again in the second pass
+	// so that only one is held in memory at a time.
+	var headers []wire.BlockHeader
+	records := make([][]blockRecord, len(files))
+	for i, f := range files {
+		stopRead := utils.TimeStage(utils.StageReadFile)
+		blkData, err := readXORFile(f.Blk, xorKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read block file: %w", err)
+		}
+		stopRead()
+
+		if records[i], err = scanBlockRecords(blkData); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Blk, err)
+		}
+		for _, rec := range records[i] {
+			headers = append(headers, rec.header)
+		}
+	}
+	if len(headers) == 0 {
+		return nil, fmt.Errorf("block file is empty or truncated")
+	}
+	stale := findStaleBlocks(headers)
+
+	undo := &undoIndex{}
+	var blocks []*types.BlockOutput
+	txids := newTxidTracker()
+	for i, f := range files {
+		undo.drop(i - 2)
+		for j := max(i-1, 0); j <= i+1 && j < len(files); j++ {
+			if undo.has(j) {
+				continue
+			}
+			revData, err := readXORFile(files[j].Rev, xorKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read undo file: %w", err)
+			}
+			if err := undo.add(j, revData); err != nil {
+				return nil, fmt.Errorf("%s: %w", files[j].Rev, err)
+			}
+		}
+
+		blkData, err := readXORFile(f.Blk, xorKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read block file: %w", err)
+		}
+		blkReader := bytes.NewReader(blkData)
+		for _, rec := range records[i] {
+			blkReader.Seek(int64(rec.offset), io.SeekStart)
+			block, err := parseOneBlock(blkReader, undo.read, opts)
+			if err != nil {
+				return nil, fmt.Errorf("%s: block at offset %d: %w", f.Blk, rec.offset, err)
+			}
+			block.Stale = stale[rec.header.BlockHash()]
+			txids.check(block)
+			if opts.OnBlock != nil {
+				if err := opts.OnBlock(block); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			blocks = append(blocks, block)
+		}
+		if opts.FileProgress != nil {
+			opts.FileProgress(i+1, len(files))
+		}
+	}
+	return blocks, nil
+}
+
+// findStaleBlocks links headers by previous-block hash and returns the
+// blocks on losing branches. Wherever several blocks share a parent, the
+// one leading to the most cumulative work stays on the best chain (the
+// first seen on a tie, as a node would keep it) and the others are stale
+// together with all their descendants. Blocks whose parent is not among
+// the headers are compared the same way, since they compete at one height.
+func findStaleBlocks(headers []wire.BlockHeader) map[chainhash.Hash]bool {
+	hashes := make([]chainhash.Hash, 0, len(headers))
+	bits := make(map[chainhash.Hash]uint32, len(headers))
+	children := make(map[chainhash.Hash][]chainhash.Hash)
+	for i := range headers {
+		hash := headers[i].BlockHash()
+		if _, dup := bits[hash]; dup {
+			continue
+		}
+		hashes = append(hashes, hash)
+		bits[hash] = headers[i].Bits
+		prev := headers[i].PrevBlock
+		children[prev] = append(children[prev], hash)
+	}
+
+	// branchWork is the work of a block plus its heaviest descendant branch
+	branchWork := make(map[chainhash.Hash]*big.Int, len(hashes))
+	var work func(hash chainhash.Hash) *big.Int
+	work = func(hash chainhash.Hash) *big.Int {
+		if w, ok := branchWork[hash]; ok {
+			return w
+		}
+		best := new(big.Int)
+		for _, child := range children[hash] {
+			if w := work(child); w.Cmp(best) > 0 {
+				best = w
+			}
+		}
+		w := new(big.Int).Add(blockchain.CalcWork(bits[hash]), best)
+		branchWork[hash] = w
+		return w
+	}
+
+	stale := make(map[chainhash.Hash]bool)
+	var markStale func(hash chainhash.Hash)
+	markStale = func(hash chainhash.Hash) {
+		stale[hash] = true
+		for _, child := range children[hash] {
+			markStale(child)
+		}
+	}
+	for _, siblings := range children {
+		if len(siblings) < 2 {
+			continue
+		}
+		winner := siblings[0]
+		for _, s := range siblings[1:] {
+			if work(s).Cmp(work(winner)) > 0 {
+				winner = s
+			}
+		}
+		for _, s := range siblings {
+			if s != winner {
+				markStale(s)
+			}
+		}
+	}
+	return stale
+}
@@ -0,0 +1,312 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/analyzer"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/utils"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Kinds of ChainBreak
+const (
+	BreakMissingParent = "missing_parent"
+	BreakInvalidPoW    = "invalid_pow"
+	BreakTimestamp     = "timestamp"
+	BreakBits          = "bits"
+	BreakHeight        = "height"
+)
+
+// medianTimeBlocks is how many previous blocks the median time past of
+// BIP113 and the timestamp rule are taken over
+const medianTimeBlocks = 11
+
+// ChainCheckOptions configures CheckHeaderChain
+type ChainCheckOptions struct {
+	// XORPath is the xor.dat of the blk files; empty when they are not
+	// obfuscated
+	XORPath string
+
+	// Network is that of the headers in dumps, mainnet when empty. Blocks
+	// in blk files carry theirs in the record magic.
+	Network string
+
+	// FirstHeight is the height of the first header of the best chain when
+	// it cannot be told from the headers themselves
+	FirstHeight *int64
+}
+
+// chainHeader is one header fed to the chain check and where it was read
+type chainHeader struct {
+	header     wire.BlockHeader
+	hash       chainhash.Hash
+	file       string
+	offset     int64 // -1 for a header dump
+	heightHint int64
+}
+
+// CheckHeaderChain reads the headers of blk*.dat files (only the record
+// prefix, header and coinbase start of each block) and of header dumps,
+// files of consecutive 80-byte headers in binary or as hex text. It links
+// them into chains, picks the one with the most work, and verifies along
+// it that every header meets its target, is timestamped after the median
+// time of the previous 11 blocks, and changes difficulty only at a retarget
+// boundary and by no more than the network allows. Blocks in blk files may
+// come in any order, as a node stores them on arrival.
+func CheckHeaderChain(paths []string, opts ChainCheckOptions) (*types.ChainCheckOutput, error) {
+	xorKey, err := readXORKey(opts.XORPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read XOR key: %w", err)
+	}
+
+	network := opts.Network
+	var headers []chainHeader
+	for _, path := range paths {
+		if !IsBlockFile(path) {
+			dump, err := readHeaderDump(path)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			headers = append(headers, dump...)
+			continue
+		}
+		entries, decoded, err := scanHeaderFile(path, xorKey)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for i, entry := range entries {
+			if network == "" {
+				network = entry.Network
+			} else if entry.Network != network {
+				return nil, fmt.Errorf("%s: block at offset %d is on %s, not %s", path, entry.Offset, entry.Network, network)
+			}
+			headers = append(headers, chainHeader{
+				header:     decoded[i],
+				hash:       decoded[i].BlockHash(),
+				file:       entry.File,
+				offset:     entry.Offset,
+				heightHint: entry.HeightHint,
+			})
+		}
+	}
+	if network == "" {
+		network = analyzer.NetworkMainnet
+	}
+	if len(headers) == 0 {
+		return nil, errors.New("no headers found")
+	}
+
+	out := checkHeaderChain(headers, network, opts.FirstHeight)
+	out.FileCount = len(paths)
+	return out, nil
+}
+
+// IsBlockFile reports whether path names a blk*.dat file, possibly
+// compressed
+func IsBlockFile(path string) bool {
+	name := filepath.Base(utils.TrimCompressedExtension(path))
+	return strings.HasPrefix(name, "blk") && strings.HasSuffix(name, ".dat")
+}
+
+// readHeaderDump reads a file of consecutive 80-byte headers, binary or hex
+// text (whitespace is ignored, so one header per line works)
+func readHeaderDump(path string) ([]chainHeader, error) {
+	data, err := utils.ReadInput(path)
+	if err != nil {
+		return nil, err
+	}
+	if text := bytes.TrimSpace(data); len(text) > 0 && isPrintable(text) {
+		if data, err = utils.HexToBytes(strings.Join(strings.Fields(string(text)), "")); err != nil {
+			return nil, fmt.Errorf("invalid header dump hex: %w", err)
+		}
+	}
+	if len(data)%wire.MaxBlockHeaderPayload != 0 {
+		return nil, fmt.Errorf("header dump is %d bytes, not a multiple of %d", len(data), wire.MaxBlockHeaderPayload)
+	}
+
+	headers := make([]chainHeader, len(data)/wire.MaxBlockHeaderPayload)
+	r := bytes.NewReader(data)
+	for i := range headers {
+		if err := headers[i].header.Deserialize(r); err != nil {
+			return nil, fmt.Errorf("header %d: %w", i, err)
+		}
+		headers[i].hash = headers[i].header.BlockHash()
+		headers[i].offset = -1
+	}
+	return headers, nil
+}
+
+func checkHeaderChain(headers []chainHeader, network string, firstHeight *int64) *types.ChainCheckOutput {
+	params := analyzer.GetNetworkParams(network)
+	out := &types.ChainCheckOutput{OK: true, Mode: "chain_check", Network: network, Breaks: []types.ChainBreak{}}
+	addBreak := func(h *chainHeader, kind string, height *int64, detail string) {
+		b := types.ChainBreak{Kind: kind, BlockHash: h.hash.String(), Height: height, File: h.file, Detail: detail}
+		if h.offset >= 0 {
+			offset := h.offset
+			b.Offset = &offset
+		}
+		out.Breaks = append(out.Breaks, b)
+	}
+
+	// Index the headers, keeping the first copy of any stored twice
+	byHash := make(map[chainhash.Hash]int, len(headers))
+	unique := headers[:0:0]
+	for _, h := range headers {
+		if _, ok := byHash[h.hash]; ok {
+			out.DuplicateCount++
+			continue
+		}
+		byHash[h.hash] = len(unique)
+		unique = append(unique, h)
+	}
+	headers = unique
+	out.HeaderCount = len(headers)
+
+	// Sum the work along every chain, parents before children
+	children := make(map[int][]int)
+	var queue []int
+	for i := range headers {
+		if !analyzer.CheckProofOfWork(headers[i].hash, headers[i].header.Bits) {
+			addBreak(&headers[i], BreakInvalidPoW, nil, fmt.Sprintf("hash is above the target of bits %08x", headers[i].header.Bits))
+		}
+		if parent, ok := byHash[headers[i].header.PrevBlock]; ok {
+			children[parent] = append(children[parent], i)
+		} else {
+			queue = append(queue, i)
+		}
+	}
+	roots := append([]int(nil), queue...)
+	work := make([]*big.Int, len(headers))
+	parentOf := make([]int, len(headers))
+	rootOf := make([]int, len(headers))
+	best := -1
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		work[i] = blockchain.CalcWork(headers[i].header.Bits)
+		parentOf[i], rootOf[i] = -1, i
+		if parent, ok := byHash[headers[i].header.PrevBlock]; ok {
+			work[i].Add(work[i], work[parent])
+			parentOf[i], rootOf[i] = parent, rootOf[parent]
+		}
+		// Ties go to the header read first, as a node keeps the chain it
+		// saw first
+		if best < 0 || work[i].Cmp(work[best]) > 0 || work[i].Cmp(work[best]) == 0 && i < best {
+			best = i
+		}
+		queue = append(queue, children[i]...)
+	}
+
+	var chain []int
+	for i := best; i >= 0; i = parentOf[i] {
+		chain = append(chain, i)
+	}
+	for l, r := 0, len(chain)-1; l < r; l, r = l+1, r-1 {
+		chain[l], chain[r] = chain[r], chain[l]
+	}
+	out.ChainLength = len(chain)
+	for i := range headers {
+		if rootOf[i] != chain[0] {
+			out.DetachedHeaders++
+		}
+	}
+	out.StaleHeaders = len(headers) - len(chain) - out.DetachedHeaders
+	out.ChainWork = work[best].String()
+	root, tip := &headers[chain[0]], &headers[best]
+	out.FirstBlockHash, out.TipHash = root.hash.String(), tip.hash.String()
+
+	// Headers whose parent is missing, other than the start of the best
+	// chain, begin fragments that cannot be linked to it
+	for _, i := range roots {
+		if i != chain[0] {
+			addBreak(&headers[i], BreakMissingParent, nil, fmt.Sprintf("parent %s not found", headers[i].header.PrevBlock))
+		}
+	}
+
+	// Place the chain: at the given height, at genesis, or by the BIP34
+	// height of its first block
+	bip34 := int64(params.BIP0034Height)
+	switch {
+	case firstHeight != nil:
+		h := *firstHeight
+		out.FirstHeight = &h
+	case root.header.PrevBlock == (chainhash.Hash{}):
+		h := int64(0)
+		out.FirstHeight = &h
+	case root.heightHint >= bip34 && bip34 > 0:
+		h := root.heightHint
+		out.FirstHeight = &h
+	}
+	if out.FirstHeight != nil {
+		h := *out.FirstHeight + int64(len(chain)-1)
+		out.TipHeight = &h
+	}
+
+	interval := int64(params.TargetTimespan / params.TargetTimePerBlock)
+	factor := big.NewInt(params.RetargetAdjustmentFactor)
+	for n, i := range chain {
+		h := &headers[i]
+		var height *int64
+		if out.FirstHeight != nil {
+			v := *out.FirstHeight + int64(n)
+			height = &v
+			if h.heightHint != 0 && v >= bip34 && h.heightHint != v {
+				addBreak(h, BreakHeight, height, fmt.Sprintf("coinbase commits to height %d", h.heightHint))
+			}
+		}
+		if n == 0 {
+			continue
+		}
+
+		if mtp := medianTimePast(headers, chain[max(0, n-medianTimeBlocks):n]); !h.header.Timestamp.After(mtp) {
+			addBreak(h, BreakTimestamp, height, fmt.Sprintf("timestamp %d is not after the median time past %d", h.header.Timestamp.Unix(), mtp.Unix()))
+		}
+
+		// Networks that let difficulty drop to the minimum after a slow
+		// block (testnet) follow no retarget schedule that can be checked
+		prev := &headers[chain[n-1]]
+		if params.ReduceMinDifficulty || h.header.Bits == prev.header.Bits {
+			continue
+		}
+		switch {
+		case params.PoWNoRetargeting:
+			addBreak(h, BreakBits, height, "difficulty changed on a network without retargeting")
+		case height != nil && *height%interval != 0:
+			addBreak(h, BreakBits, height, fmt.Sprintf("bits changed from %08x to %08x off a retarget boundary", prev.header.Bits, h.header.Bits))
+		default:
+			// The new target is computed within the factor of the old and
+			// then truncated to compact form, which can only lower it
+			oldTarget := blockchain.CompactToBig(prev.header.Bits)
+			newTarget := blockchain.CompactToBig(h.header.Bits)
+			upper := new(big.Int).Mul(oldTarget, factor)
+			lower := new(big.Int).Div(oldTarget, factor)
+			lower.Sub(lower, new(big.Int).Rsh(lower, 15)) // compact rounding
+			if newTarget.Cmp(upper) > 0 || newTarget.Cmp(lower) < 0 {
+				addBreak(h, BreakBits, height, fmt.Sprintf("bits changed from %08x to %08x, beyond a factor of %d", prev.header.Bits, h.header.Bits, params.RetargetAdjustmentFactor))
+			}
+		}
+	}
+	out.Valid = len(out.Breaks) == 0
+	return out
+}
+
+// medianTimePast returns the median timestamp of the given chain headers
+func medianTimePast(headers []chainHeader, window []int) time.Time {
+	times := make([]int64, len(window))
+	for k, i := range window {
+		times[k] = headers[i].header.Timestamp.Unix()
+	}
+	sort.Slice(times, func(a, b int) bool { return times[a] < times[b] })
+	return time.Unix(times[len(times)/2], 0)
+}
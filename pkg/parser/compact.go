@@ -0,0 +1,308 @@
+package parser
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/utils"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// compactBlock is a decoded BIP152 cmpctblock payload
+type compactBlock struct {
+	header    wire.BlockHeader
+	nonce     uint64
+	shortIDs  []uint64
+	prefilled map[int]*wire.MsgTx
+	prefIdx   []int
+}
+
+// ParseCompactBlock decodes a BIP152 cmpctblock payload and reconstructs the
+// block from the fixture's mempool transactions and blocktxn response. When
+// every transaction is accounted for, the block is checked against its
+// merkle root and each transaction is analyzed; otherwise the output lists
+// the indexes a getblocktxn request would have to ask for.
+func ParseCompactBlock(fixture types.CompactBlockFixture) (*types.CompactBlockOutput, error) {
+	version := fixture.ShortIDVersion
+	if version == 0 {
+		version = 2
+	}
+	if version != 1 && version != 2 {
+		return nil, fmt.Errorf("invalid short_id_version %d: want 1 or 2", version)
+	}
+
+	raw, err := utils.HexToBytes(fixture.CmpctBlock)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cmpctblock hex: %w", err)
+	}
+	cb, err := decodeCompactBlock(raw)
+	if err != nil {
+		return nil, err
+	}
+	blockHash := cb.header.BlockHash()
+
+	total := len(cb.shortIDs) + len(cb.prefIdx)
+	out := &types.CompactBlockOutput{
+		OK:               true,
+		Mode:             "compact_block",
+		BlockHeader:      blockHeaderInfo(&cb.header, false),
+		ShortIDNonce:     cb.nonce,
+		ShortIDVersion:   version,
+		ShortIDCount:     len(cb.shortIDs),
+		PrefilledIndexes: cb.prefIdx,
+		MissingIndexes:   make([]int, 0),
+		TxCount:          total,
+	}
+
+	// Index the mempool by short ID. Two mempool transactions sharing a
+	// short ID are ambiguous and treated as unavailable, as BIP152 requires.
+	k0, k1 := shortIDKeys(&cb.header, cb.nonce)
+	mempool := make(map[uint64]*wire.MsgTx, len(fixture.Mempool))
+	for i, rawTx := range fixture.Mempool {
+		tx, err := deserializeTxHex(rawTx)
+		if err != nil {
+			return nil, fmt.Errorf("mempool tx %d: %w", i, err)
+		}
+		id := shortID(k0, k1, tx, version)
+		if _, dup := mempool[id]; dup {
+			mempool[id] = nil
+			continue
+		}
+		mempool[id] = tx
+	}
+
+	// Short IDs fill the slots not taken by prefilled transactions, in order
+	txs := make([]*wire.MsgTx, total)
+	next := 0
+	for i := range txs {
+		if tx, ok := cb.prefilled[i]; ok {
+			txs[i] = tx
+			continue
+		}
+		if tx := mempool[cb.shortIDs[next]]; tx != nil {
+			txs[i] = tx
+			out.MempoolMatches++
+		} else {
+			out.MissingIndexes = append(out.MissingIndexes, i)
+		}
+		next++
+	}
+
+	// A blocktxn message answers getblocktxn: the missing transactions in
+	// index order
+	if fixture.BlockTxn != "" {
+		missing, err := decodeBlockTxn(fixture.BlockTxn, blockHash)
+		if err != nil {
+			return nil, err
+		}
+		if len(missing) != len(out.MissingIndexes) {
+			return nil, fmt.Errorf("blocktxn has %d transactions but %d are missing", len(missing), len(out.MissingIndexes))
+		}
+		for i, idx := range out.MissingIndexes {
+			txs[idx] = missing[i]
+		}
+		out.BlockTxnCount = len(missing)
+		out.MissingIndexes = out.MissingIndexes[:0]
+	}
+	if len(out.MissingIndexes) > 0 {
+		return out, nil
+	}
+
+	// A wrong match (short ID collision) shows up as a merkle mismatch
+	hashes := utils.TxHashes(txs, false)
+	root := utils.MerkleRootInPlace(hashes)
+	if !root.IsEqual(&cb.header.MerkleRoot) {
+		out.OK = false
+		out.Error = &types.ErrorInfo{
+			Code:    "INVALID_MERKLE_ROOT",
+			Message: "reconstructed block does not match the header merkle root (short ID collision?)",
+		}
+		return out, nil
+	}
+	out.Reconstructed = true
+	out.BlockHeader.MerkleRootValid = true
+
+	transactions, err := analyzeReconstructedBlock(txs, fixture)
+	if err != nil {
+		return nil, err
+	}
+	out.Transactions = transactions
+	return out, nil
+}
+
+// decodeCompactBlock parses a cmpctblock payload:
+// header, nonce, short IDs (6 bytes each) and differentially-encoded
+// prefilled transactions
+func decodeCompactBlock(raw []byte) (*compactBlock, error) {
+	r := bytes.NewReader(raw)
+	cb := &compactBlock{prefilled: make(map[int]*wire.MsgTx)}
+	if err := cb.header.Deserialize(r); err != nil {
+		return nil, fmt.Errorf("failed to parse cmpctblock header: %w", err)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &cb.nonce); err != nil {
+		return nil, fmt.Errorf("failed to read cmpctblock nonce: %w", err)
+	}
+
+	count, err := utils.ReadCompactSize(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read short ID count: %w", err)
+	}
+	if err := checkBlockTxLimit("short ID count", count); err != nil {
+		return nil, err
+	}
+	if count*6 > uint64(r.Len()) {
+		return nil, fmt.Errorf("short ID count %d exceeds payload", count)
+	}
+	cb.shortIDs = make([]uint64, count)
+	var id [8]byte
+	for i := range cb.shortIDs {
+		if _, err := io.ReadFull(r, id[:6]); err != nil {
+			return nil, err
+		}
+		cb.shortIDs[i] = binary.LittleEndian.Uint64(id[:])
+	}
+
+	prefilledCount, err := utils.ReadCompactSize(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prefilled count: %w", err)
+	}
+	if err := checkBlockTxLimit("prefilled count", prefilledCount); err != nil {
+		return nil, err
+	}
+	total := count + prefilledCount
+	cb.prefIdx = make([]int, 0, prefilledCount)
+	last := -1
+	for i := uint64(0); i < prefilledCount; i++ {
+		diff, err := utils.ReadCompactSize(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prefilled index: %w", err)
+		}
+		// Each index is stored as the gap after the previous one
+		idx := uint64(last+1) + diff
+		if diff > total || idx >= total {
+			return nil, fmt.Errorf("prefilled index %d out of range", idx)
+		}
+		tx, err := deserializeTx(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse prefilled tx %d: %w", idx, err)
+		}
+		last = int(idx)
+		cb.prefilled[last] = tx
+		cb.prefIdx = append(cb.prefIdx, last)
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("cmpctblock has %d trailing bytes", r.Len())
+	}
+	return cb, nil
+}
+
+// decodeBlockTxn parses a blocktxn payload (block hash, then transactions)
+// and checks that it answers for the given block
+func decodeBlockTxn(hexStr string, blockHash chainhash.Hash) ([]*wire.MsgTx, error) {
+	raw, err := utils.HexToBytes(hexStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blocktxn hex: %w", err)
+	}
+	r := bytes.NewReader(raw)
+	var hash chainhash.Hash
+	if _, err := io.ReadFull(r, hash[:]); err != nil {
+		return nil, errors.New("blocktxn is truncated")
+	}
+	if hash != blockHash {
+		return nil, fmt.Errorf("blocktxn is for block %s, not %s", hash, blockHash)
+	}
+	count, err := utils.ReadCompactSize(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blocktxn count: %w", err)
+	}
+	if err := checkBlockTxCount(count, int64(r.Len())); err != nil {
+		return nil, fmt.Errorf("blocktxn: %w", err)
+	}
+	txs := make([]*wire.MsgTx, count)
+	for i := range txs {
+		if txs[i], err = deserializeTx(r); err != nil {
+			return nil, fmt.Errorf("failed to parse blocktxn tx %d: %w", i, err)
+		}
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("blocktxn has %d trailing bytes", r.Len())
+	}
+	return txs, nil
+}
+
+// shortIDKeys derives the SipHash keys from SHA256(header || nonce)
+func shortIDKeys(header *wire.BlockHeader, nonce uint64) (uint64, uint64) {
+	var buf bytes.Buffer
+	header.Serialize(&buf)
+	binary.Write(&buf, binary.LittleEndian, nonce)
+	sum := sha256.Sum256(buf.Bytes())
+	return binary.LittleEndian.Uint64(sum[0:8]), binary.LittleEndian.Uint64(sum[8:16])
+}
+
+// shortID is the low 6 bytes of SipHash-2-4 over the txid (version 1) or
+// wtxid (version 2)
+func shortID(k0, k1 uint64, tx *wire.MsgTx, version int) uint64 {
+	hash := tx.TxHash()
+	if version == 2 {
+		hash = tx.WitnessHash()
+	}
+	return utils.SipHash24(k0, k1, hash[:]) & 0xffffffffffff
+}
+
+func deserializeTxHex(rawTx string) (*wire.MsgTx, error) {
+	raw, err := utils.HexToBytes(rawTx)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex: %w", err)
+	}
+	tx, err := deserializeTx(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to deserialize: %w", err)
+	}
+	return tx, nil
+}
+
+// analyzeReconstructedBlock analyzes each transaction of a reconstructed
+// block with whatever prevouts the fixture supplies
+func analyzeReconstructedBlock(txs []*wire.MsgTx, fixture types.CompactBlockFixture) ([]types.TransactionOutput, error) {
+	prevouts := make(map[wire.OutPoint]types.PrevoutInput, len(fixture.Prevouts))
+	for _, p := range fixture.Prevouts {
+		hash, err := chainhash.NewHashFromStr(p.Txid)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prevout txid %q: %w", p.Txid, err)
+		}
+		prevouts[wire.OutPoint{Hash: *hash, Index: p.Vout}] = p
+	}
+
+	var height *int64
+	if h := extractBIP34Height(txs[0].TxIn[0].SignatureScript); h > 0 {
+		height = &h
+	}
+
+	outputs := make([]types.TransactionOutput, len(txs))
+	err := utils.ForEach(len(txs), func(i int) error {
+		fixture := types.Fixture{
+			Network:              fixture.Network,
+			BlockHeight:          height,
+			AllowMissingPrevouts: true,
+		}
+		for _, txIn := range txs[i].TxIn {
+			if p, ok := prevouts[txIn.PreviousOutPoint]; ok {
+				fixture.Prevouts = append(fixture.Prevouts, p)
+			}
+		}
+		out, err := analyzeTransaction(txs[i], fixture, i == 0)
+		if err != nil {
+			return fmt.Errorf("failed to analyze tx %d: %w", i, err)
+		}
+		outputs[i] = *out
+		return nil
+	})
+	return outputs, err
+}
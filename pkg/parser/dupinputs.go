@@ -0,0 +1,53 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// DuplicateInputError reports an outpoint spent twice, by two inputs of one
+// transaction or, in a block, by two of its transactions. Consensus rejects
+// both; the 2018 inflation bug (CVE-2018-17144) was a node missing the
+// first. Tx is the index of the transaction within its block, 0 outside
+// block mode.
+type DuplicateInputError struct {
+	Outpoint wire.OutPoint
+	FirstTx  int
+	FirstIn  int
+	Tx       int
+	In       int
+}
+
+func (e *DuplicateInputError) Error() string {
+	if e.FirstTx == e.Tx {
+		return fmt.Sprintf("outpoint %s is spent by inputs %d and %d", e.Outpoint, e.FirstIn, e.In)
+	}
+	return fmt.Sprintf("outpoint %s is spent by tx %d input %d and tx %d input %d", e.Outpoint, e.FirstTx, e.FirstIn, e.Tx, e.In)
+}
+
+func isDuplicateInputError(err error) bool {
+	var dupErr *DuplicateInputError
+	return errors.As(err, &dupErr)
+}
+
+// checkDuplicateInputs returns a DuplicateInputError when two inputs of
+// transactions spend the same outpoint. Coinbase inputs are skipped, as
+// they spend nothing.
+func checkDuplicateInputs(transactions []*wire.MsgTx) error {
+	type position struct{ tx, in int }
+	spent := make(map[wire.OutPoint]position)
+	for i, tx := range transactions {
+		for j, txIn := range tx.TxIn {
+			if isCoinbaseInput(txIn) {
+				continue
+			}
+			if first, ok := spent[txIn.PreviousOutPoint]; ok {
+				return &DuplicateInputError{txIn.PreviousOutPoint, first.tx, first.in, i, j}
+			}
+			spent[txIn.PreviousOutPoint] = position{i, j}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Raw transaction encodings accepted in raw_tx_encoding and detected when a
+// raw transaction file or stdin is read
+const (
+	EncodingAuto   = "auto"
+	EncodingHex    = "hex"
+	EncodingBase64 = "base64"
+	EncodingBinary = "binary"
+)
+
+// psbtMagic starts every serialized PSBT (BIP174)
+var psbtMagic = []byte{'p', 's', 'b', 't', 0xff}
+
+// DetectTextEncoding reports whether a raw_tx string is hex or base64. A
+// string of hex digits is always taken as hex, even though it may also be
+// valid base64; anything that is neither is reported as hex so the hex
+// decoder names the offending byte or odd length.
+func DetectTextEncoding(s string) string {
+	if isHexDigits(s) {
+		return EncodingHex
+	}
+	if _, err := decodeBase64(s); err == nil {
+		return EncodingBase64
+	}
+	return EncodingHex
+}
+
+// DecodeRawTx decodes the contents of a raw transaction file in the given
+// encoding, detecting it when encoding is "auto" or empty: bytes that are
+// not printable text are binary, text is hex or base64. It returns the
+// transaction bytes and the encoding used.
+func DecodeRawTx(data []byte, encoding string) ([]byte, string, error) {
+	if encoding == "" || encoding == EncodingAuto {
+		encoding = EncodingBinary
+		if text := bytes.TrimSpace(data); len(text) > 0 && isPrintable(text) {
+			encoding = DetectTextEncoding(string(text))
+		}
+	}
+
+	var raw []byte
+	var err error
+	switch encoding {
+	case EncodingBinary:
+		raw = data
+	case EncodingHex:
+		raw, err = hex.DecodeString(string(bytes.TrimSpace(data)))
+		if err != nil {
+			return nil, encoding, fmt.Errorf("invalid raw transaction hex: %w", err)
+		}
+	case EncodingBase64:
+		raw, err = decodeBase64(string(data))
+		if err != nil {
+			return nil, encoding, fmt.Errorf("invalid raw transaction base64: %w", err)
+		}
+	default:
+		return nil, encoding, fmt.Errorf("invalid encoding %q: want auto, hex, base64 or binary", encoding)
+	}
+	if len(raw) == 0 {
+		return nil, encoding, errors.New("empty raw transaction")
+	}
+	if bytes.HasPrefix(raw, psbtMagic) {
+		return nil, encoding, errors.New("input is a PSBT, not a raw transaction: pass it as a fixture's psbt field")
+	}
+	if err := checkTxLimits(bytes.NewReader(raw), int64(len(raw))); err != nil {
+		return nil, encoding, err
+	}
+	return raw, encoding, nil
+}
+
+// decodeBase64 accepts standard and URL-safe base64, padded or not, with
+// line breaks and other whitespace ignored
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+	if s == "" {
+		return nil, errors.New("empty input")
+	}
+	enc := base64.StdEncoding
+	if strings.ContainsAny(s, "-_") {
+		enc = base64.URLEncoding
+	}
+	if !strings.HasSuffix(s, "=") && len(s)%4 != 0 {
+		enc = enc.WithPadding(base64.NoPadding)
+	}
+	return enc.Strict().DecodeString(s)
+}
+
+func isHexDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// isPrintable reports whether data is ASCII text: printable characters and
+// whitespace only
+func isPrintable(data []byte) bool {
+	for _, c := range data {
+		if (c < 0x20 || c > 0x7e) && c != '\n' && c != '\r' && c != '\t' {
+			return false
+		}
+	}
+	return true
+}
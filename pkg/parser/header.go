@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/analyzer"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/utils"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// DecodeBlockHeader decodes a standalone 80-byte block header. The merkle
+// root cannot be checked without the transactions, so merkle_root_valid is
+// always false; pow_valid reports whether the hash meets the header's own
+// target.
+func DecodeBlockHeader(headerHex string) (*types.HeaderOutput, error) {
+	_, out, err := decodeHeaderHex(headerHex)
+	return out, err
+}
+
+// DecodeHeaderChain decodes consecutive block headers, each of which must
+// build on the one before, and estimates the next difficulty adjustment
+// from their timestamps. firstHeight, when known, places the headers in
+// their retarget period.
+func DecodeHeaderChain(headerHexes []string, firstHeight *int64) (*types.HeaderChainOutput, error) {
+	if len(headerHexes) == 0 {
+		return nil, errors.New("no headers given")
+	}
+	if firstHeight != nil && *firstHeight < 0 {
+		return nil, fmt.Errorf("invalid height %d", *firstHeight)
+	}
+	out := &types.HeaderChainOutput{OK: true, Mode: "header_chain"}
+	headers := make([]*wire.BlockHeader, len(headerHexes))
+	for i, headerHex := range headerHexes {
+		header, decoded, err := decodeHeaderHex(headerHex)
+		if err != nil {
+			return nil, fmt.Errorf("header %d: %w", i, err)
+		}
+		if i > 0 && header.PrevBlock != headers[i-1].BlockHash() {
+			return nil, fmt.Errorf("header %d does not build on header %d", i, i-1)
+		}
+		headers[i] = header
+		out.Headers = append(out.Headers, *decoded)
+	}
+	out.Retarget = analyzer.EstimateRetarget(headers, firstHeight)
+	return out, nil
+}
+
+func decodeHeaderHex(headerHex string) (*wire.BlockHeader, *types.HeaderOutput, error) {
+	raw, err := utils.HexToBytes(headerHex)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid header hex: %w", err)
+	}
+	if len(raw) != wire.MaxBlockHeaderPayload {
+		return nil, nil, fmt.Errorf("header must be %d bytes, got %d", wire.MaxBlockHeaderPayload, len(raw))
+	}
+
+	var header wire.BlockHeader
+	if err := header.Deserialize(bytes.NewReader(raw)); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse header: %w", err)
+	}
+
+	info := blockHeaderInfo(&header, false)
+	return &header, &types.HeaderOutput{
+		OK:          true,
+		Mode:        "header",
+		BlockHeader: &info,
+		VersionHex:  fmt.Sprintf("%08x", uint32(header.Version)),
+		VersionBits: analyzer.SignalledVersionBits(header.Version),
+		PowValid:    info.PowValid,
+	}, nil
+}
@@ -0,0 +1,252 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/analyzer"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/utils"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// headerScanPeek is how much of each block is read after the 8-byte record
+// prefix: the header, the tx count and the start of the coinbase, enough to
+// reach a BIP34 height in its scriptSig
+const headerScanPeek = wire.MaxBlockHeaderPayload + 256
+
+// BlockFilesInDir lists the blk*.dat files of a Bitcoin Core blocks
+// directory in file-number order. Compressed files (blk*.dat.gz,
+// blk*.dat.zst) are included unless the uncompressed file is also present.
+func BlockFilesInDir(dir string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "blk*.dat*"))
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var paths []string
+	sort.Strings(matches) // blk00000.dat sorts before blk00000.dat.gz
+	for _, path := range matches {
+		base := utils.TrimCompressedExtension(path)
+		if !strings.HasSuffix(base, ".dat") || seen[base] {
+			continue
+		}
+		seen[base] = true
+		paths = append(paths, path)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no blk*.dat files in %s", dir)
+	}
+	return paths, nil
+}
+
+// ScanBlockHeaders builds a per-block index of blk*.dat files without
+// decoding transactions: for each record only the magic/size prefix, the
+// header, the tx count and the coinbase input are read, and the rest of the
+// block is skipped by its size. The BIP9 bits signalled across the scanned
+// blocks are summed up in Signals.
+func ScanBlockHeaders(paths []string, xorPath string) (*types.HeaderIndexOutput, error) {
+	xorKey, err := readXORKey(xorPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read XOR key: %w", err)
+	}
+
+	out := &types.HeaderIndexOutput{
+		OK:        true,
+		Mode:      "headers_only",
+		FileCount: len(paths),
+		Blocks:    make([]types.HeaderIndexEntry, 0),
+	}
+	for _, path := range paths {
+		entries, _, err := scanHeaderFile(path, xorKey)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		out.Blocks = append(out.Blocks, entries...)
+	}
+	out.BlockCount = len(out.Blocks)
+	signals := analyzer.NewVersionBitsCounter()
+	for _, entry := range out.Blocks {
+		signals.Add(entry.VersionBits, entry.Timestamp)
+	}
+	out.Signals = signals.Report()
+	return out, nil
+}
+
+// forwardReaderAt serves ReadAt calls at non-decreasing offsets from a
+// stream, discarding the bytes in between; it lets compressed blk files be
+// scanned without decompressing them whole
+type forwardReaderAt struct {
+	r   io.Reader
+	pos int64
+}
+
+func (f *forwardReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < f.pos {
+		return 0, fmt.Errorf("cannot seek back to offset %d in a compressed stream", off)
+	}
+	if skip := off - f.pos; skip > 0 {
+		n, err := io.CopyN(io.Discard, f.r, skip)
+		f.pos += n
+		if err != nil {
+			return 0, io.ErrUnexpectedEOF
+		}
+	}
+	n, err := io.ReadFull(f.r, p)
+	f.pos += int64(n)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF // short read, as ReaderAt reports it
+	}
+	return n, err
+}
+
+// scanHeaderFile indexes the blocks of one blk file, returning their
+// decoded headers alongside the entries
+func scanHeaderFile(path string, xorKey []byte) ([]types.HeaderIndexEntry, []wire.BlockHeader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	name := filepath.Base(path)
+
+	// A compressed file is read forward through the decompressor; its
+	// decompressed size is unknown, so the scan runs to the end of stream
+	var src io.ReaderAt = f
+	fileSize := info.Size()
+	stream, format, err := utils.NewDecompressReader(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	compressed := format != utils.CompressionNone
+	if compressed {
+		defer stream.Close()
+		src = &forwardReaderAt{r: stream}
+		fileSize = math.MaxInt64
+	} else if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, nil, err
+	}
+
+	var entries []types.HeaderIndexEntry
+	var headers []wire.BlockHeader
+	var rawHeaders [][]byte // hashed as one batch once the file is read
+	prefix := make([]byte, 8)
+	peek := make([]byte, headerScanPeek)
+	for pos, last := int64(0), int64(0); pos+8 <= fileSize; {
+		if n, err := src.ReadAt(prefix, pos); err != nil {
+			switch {
+			case compressed && err == io.EOF && n == 0:
+				// End of the compressed stream
+			case compressed && err == io.ErrUnexpectedEOF:
+				return nil, nil, fmt.Errorf("block record at offset %d is truncated", last)
+			case compressed && err == io.EOF:
+				return nil, nil, fmt.Errorf("block record at offset %d is truncated", pos)
+			default:
+				return nil, nil, err
+			}
+			break
+		}
+		rec := utils.XORDecodeAt(prefix, xorKey, pos)
+		var magic [4]byte
+		copy(magic[:], rec[:4])
+		if magic == [4]byte{} {
+			break // preallocated zero padding
+		}
+		network, ok := analyzer.NetworkFromMagic(magic)
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown network magic %x at offset %d", magic, pos)
+		}
+		size := binary.LittleEndian.Uint32(rec[4:8])
+		if size < wire.MaxBlockHeaderPayload || pos+8+int64(size) > fileSize {
+			return nil, nil, fmt.Errorf("block record at offset %d is truncated", pos)
+		}
+
+		n := int64(len(peek))
+		if int64(size) < n {
+			n = int64(size)
+		}
+		if _, err := src.ReadAt(peek[:n], pos+8); err != nil && !errors.Is(err, io.EOF) {
+			return nil, nil, err
+		}
+		decoded := utils.XORDecodeAt(peek[:n], xorKey, pos+8)
+		body := bytes.NewReader(decoded)
+		var header wire.BlockHeader
+		if err := header.Deserialize(body); err != nil {
+			return nil, nil, fmt.Errorf("block header at offset %d: %w", pos, err)
+		}
+		entry := types.HeaderIndexEntry{
+			File:          name,
+			Offset:        pos,
+			Size:          size,
+			Network:       network,
+			PrevBlockHash: header.PrevBlock.String(),
+			Timestamp:     uint32(header.Timestamp.Unix()),
+			VersionBits:   analyzer.SignalledVersionBits(header.Version),
+		}
+		if txCount, err := utils.ReadCompactSize(body); err == nil {
+			entry.TxCount = int(txCount)
+			entry.HeightHint = peekCoinbaseHeight(body)
+		}
+		entries = append(entries, entry)
+		rawHeaders = append(rawHeaders, append([]byte(nil), decoded[:wire.MaxBlockHeaderPayload]...))
+		headers = append(headers, header)
+		last = pos
+		pos += 8 + int64(size)
+	}
+	for i, hash := range utils.DoubleSHA256Batch(rawHeaders) {
+		entries[i].BlockHash = hash.String()
+		entries[i].PowValid = analyzer.CheckProofOfWork(hash, headers[i].Bits)
+	}
+	return entries, headers, nil
+}
+
+// peekCoinbaseHeight reads the coinbase input from the start of its
+// serialization and returns the BIP34 height in its scriptSig, or 0 when the
+// peeked bytes run out first
+func peekCoinbaseHeight(r *bytes.Reader) int64 {
+	var version [4]byte
+	if _, err := io.ReadFull(r, version[:]); err != nil {
+		return 0
+	}
+	count, err := utils.ReadCompactSize(r)
+	if err != nil {
+		return 0
+	}
+	// A zero input count is the segwit marker; the flag byte follows
+	if count == 0 {
+		if _, err := r.ReadByte(); err != nil {
+			return 0
+		}
+		if count, err = utils.ReadCompactSize(r); err != nil {
+			return 0
+		}
+	}
+	if count != 1 {
+		return 0
+	}
+	if _, err := r.Seek(36, io.SeekCurrent); err != nil { // prevout
+		return 0
+	}
+	scriptLen, err := utils.ReadCompactSize(r)
+	if err != nil || scriptLen > uint64(r.Len()) {
+		return 0
+	}
+	script := make([]byte, scriptLen)
+	if _, err := io.ReadFull(r, script); err != nil {
+		return 0
+	}
+	return extractBIP34Height(script)
+}
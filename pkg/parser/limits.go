@@ -0,0 +1,359 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/utils"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// ParseLimits bounds the sizes and counts the parser accepts. Counts read
+// from CompactSize fields are checked against them, and against the bytes
+// actually left in the input, before the decoder allocates anything for
+// them, so a crafted fixture claiming billions of inputs fails fast instead
+// of exhausting memory.
+type ParseLimits struct {
+	MaxTxBytes          int // serialized transaction
+	MaxBlockBytes       int // serialized block
+	MaxBlockTxs         int // transactions per block
+	MaxInputs           int // inputs per transaction
+	MaxOutputs          int // outputs per transaction
+	MaxScriptBytes      int // scriptSig or scriptPubKey
+	MaxWitnessItems     int // witness stack items per input
+	MaxWitnessItemBytes int // single witness stack item
+}
+
+// DefaultParseLimits admit every transaction and block valid under
+// consensus rules. Consensus caps neither scriptPubKey size nor, under
+// unknown witness versions, witness stacks, so those limits are the ones
+// the transaction size implies: a script or witness item can be no larger
+// than its transaction, and every witness item takes at least a byte. The
+// counts are likewise above what a 4,000,000-weight block can hold:
+// its 1,000,000 bytes of non-witness data fit about 16,700 transactions,
+// 24,400 inputs or 111,100 outputs.
+var DefaultParseLimits = ParseLimits{
+	MaxTxBytes:          MaxRawTxBytes,
+	MaxBlockBytes:       4000000,
+	MaxBlockTxs:         100000,
+	MaxInputs:           100000,
+	MaxOutputs:          200000,
+	MaxScriptBytes:      MaxRawTxBytes,
+	MaxWitnessItems:     MaxRawTxBytes,
+	MaxWitnessItemBytes: MaxRawTxBytes,
+}
+
+var parseLimits atomic.Pointer[ParseLimits]
+
+func init() {
+	SetParseLimits(DefaultParseLimits)
+}
+
+// SetParseLimits replaces the process-wide parse limits
+func SetParseLimits(l ParseLimits) {
+	parseLimits.Store(&l)
+}
+
+// CurrentParseLimits returns the process-wide parse limits
+func CurrentParseLimits() ParseLimits {
+	return *parseLimits.Load()
+}
+
+// Smallest serializations, used to reject counts the remaining input cannot
+// hold: a transaction with no inputs or outputs, an input with an empty
+// scriptSig and an output with an empty scriptPubKey
+const (
+	minTxBytes    = 10
+	minTxInBytes  = 41
+	minTxOutBytes = 9
+)
+
+// LimitError reports a size or count above its configured limit
+type LimitError struct {
+	What  string // e.g. "input count"
+	Limit string // config key under limits
+	Value uint64
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s %d exceeds limit of %d (limits.%s)", e.What, e.Value, e.Max, e.Limit)
+}
+
+// ErrorCode returns LIMIT_EXCEEDED when err is (or wraps) a LimitError,
+// PREVOUT_LOOKUP_FAILED for a ResolverError, DUPLICATE_INPUT for a
+// DuplicateInputError, an AmountError's own code and fallback otherwise
+func ErrorCode(err error, fallback string) string {
+	if code, ok := amountCode(err); ok {
+		return code
+	}
+	switch {
+	case isLimitError(err):
+		return "LIMIT_EXCEEDED"
+	case isResolverError(err):
+		return "PREVOUT_LOOKUP_FAILED"
+	case isDuplicateInputError(err):
+		return "DUPLICATE_INPUT"
+	}
+	return fallback
+}
+
+func isLimitError(err error) bool {
+	var limitErr *LimitError
+	return errors.As(err, &limitErr)
+}
+
+// errScanShort ends a limit scan that ran out of input; the decoder then
+// reports the truncation in its own words
+var errScanShort = errors.New("short input")
+
+// limitScanner walks a serialized transaction, checking each count and
+// length against the limits and skipping the bytes it covers
+type limitScanner struct {
+	r         io.Reader
+	remaining int64
+	limits    *ParseLimits
+}
+
+func (s *limitScanner) skip(n int64) error {
+	if n > s.remaining {
+		return errScanShort
+	}
+	if _, err := io.CopyN(io.Discard, s.r, n); err != nil {
+		return errScanShort
+	}
+	s.remaining -= n
+	return nil
+}
+
+func (s *limitScanner) compactSize() (uint64, error) {
+	v, err := utils.ReadCompactSize(s.r)
+	if err != nil {
+		return 0, errScanShort
+	}
+	switch {
+	case v < 0xfd:
+		s.remaining--
+	case v <= 0xffff:
+		s.remaining -= 3
+	case v <= 0xffffffff:
+		s.remaining -= 5
+	default:
+		s.remaining -= 9
+	}
+	return v, nil
+}
+
+// count reads a CompactSize count of items at least minEach bytes long
+func (s *limitScanner) count(what, limit string, max int, minEach int64) (uint64, error) {
+	n, err := s.compactSize()
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(max) {
+		return 0, &LimitError{What: what, Limit: limit, Value: n, Max: max}
+	}
+	if minEach > 0 && int64(n) > s.remaining/minEach {
+		return 0, fmt.Errorf("%s %d does not fit in the remaining %d bytes", what, n, s.remaining)
+	}
+	return n, nil
+}
+
+// length reads a CompactSize byte length and skips that many bytes
+func (s *limitScanner) length(what, limit string, max int) error {
+	n, err := s.count(what, limit, max, 0)
+	if err != nil {
+		return err
+	}
+	return s.skip(int64(n))
+}
+
+// tx scans one transaction, reading the segwit marker the way the decoder
+// does: a zero input count followed by flag 0x01
+func (s *limitScanner) tx() error {
+	l := s.limits
+	if err := s.skip(4); err != nil { // version
+		return err
+	}
+	inputs, err := s.count("input count", "max_inputs", l.MaxInputs, minTxInBytes)
+	if err != nil {
+		return err
+	}
+	witness := false
+	if inputs == 0 {
+		var flag [1]byte
+		if _, err := io.ReadFull(s.r, flag[:]); err != nil {
+			return errScanShort
+		}
+		s.remaining--
+		if flag[0] != 0x01 {
+			return nil // not segwit; leave the error to the decoder
+		}
+		witness = true
+		if inputs, err = s.count("input count", "max_inputs", l.MaxInputs, minTxInBytes); err != nil {
+			return err
+		}
+	}
+	for i := uint64(0); i < inputs; i++ {
+		if err := s.skip(36); err != nil { // outpoint
+			return err
+		}
+		if err := s.length("scriptSig length", "max_script_bytes", l.MaxScriptBytes); err != nil {
+			return err
+		}
+		if err := s.skip(4); err != nil { // sequence
+			return err
+		}
+	}
+	outputs, err := s.count("output count", "max_outputs", l.MaxOutputs, minTxOutBytes)
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < outputs; i++ {
+		if err := s.skip(8); err != nil { // value
+			return err
+		}
+		if err := s.length("scriptPubKey length", "max_script_bytes", l.MaxScriptBytes); err != nil {
+			return err
+		}
+	}
+	if witness {
+		for i := uint64(0); i < inputs; i++ {
+			items, err := s.count("witness item count", "max_witness_items", l.MaxWitnessItems, 1)
+			if err != nil {
+				return err
+			}
+			for j := uint64(0); j < items; j++ {
+				if err := s.length("witness item length", "max_witness_item_bytes", l.MaxWitnessItemBytes); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return s.skip(4) // locktime
+}
+
+// scanTx checks the transaction at the start of r, which holds at most
+// remaining bytes, against the current limits and returns its size.
+// Truncated input is left for the decoder to report.
+func scanTx(r io.Reader, remaining int64) (int64, error) {
+	l := parseLimits.Load()
+	s := &limitScanner{r: r, remaining: remaining, limits: l}
+	if err := s.tx(); err != nil {
+		if err == errScanShort {
+			return 0, nil
+		}
+		return 0, err
+	}
+	size := remaining - s.remaining
+	if size > int64(l.MaxTxBytes) {
+		return 0, &LimitError{What: "transaction size", Limit: "max_tx_bytes", Value: uint64(size), Max: l.MaxTxBytes}
+	}
+	return size, nil
+}
+
+// checkTxLimits checks a size-byte serialized transaction read from r
+func checkTxLimits(r io.Reader, size int64) error {
+	if l := parseLimits.Load(); size > int64(l.MaxTxBytes) {
+		return &LimitError{What: "transaction size", Limit: "max_tx_bytes", Value: uint64(size), Max: l.MaxTxBytes}
+	}
+	_, err := scanTx(r, size)
+	return err
+}
+
+// deserializeTx decodes the transaction at r's position after checking it
+// against the limits; r may hold further data after it
+func deserializeTx(r *bytes.Reader) (*wire.MsgTx, error) {
+	scan := *r
+	if _, err := scanTx(&scan, int64(r.Len())); err != nil {
+		return nil, err
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	if err := tx.Deserialize(r); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// checkBlockTxLimit checks a per-block transaction count (or a count
+// bounded by it, such as compact block short IDs) against the limits
+func checkBlockTxLimit(what string, count uint64) error {
+	if l := parseLimits.Load(); count > uint64(l.MaxBlockTxs) {
+		return &LimitError{What: what, Limit: "max_block_txs", Value: count, Max: l.MaxBlockTxs}
+	}
+	return nil
+}
+
+// checkBlockTxCount checks a block's transaction count against the limits
+// and the bytes left to hold them
+func checkBlockTxCount(count uint64, remaining int64) error {
+	if err := checkBlockTxLimit("block transaction count", count); err != nil {
+		return err
+	}
+	if int64(count) > remaining/minTxBytes {
+		return fmt.Errorf("block transaction count %d does not fit in the remaining %d bytes", count, remaining)
+	}
+	return nil
+}
+
+// checkBlockSize checks a serialized block size against the limits
+func checkBlockSize(size int64) error {
+	l := parseLimits.Load()
+	if size > int64(l.MaxBlockBytes) {
+		return &LimitError{What: "block size", Limit: "max_block_bytes", Value: uint64(size), Max: l.MaxBlockBytes}
+	}
+	return nil
+}
+
+// checkBlockLimits checks a serialized block's size, its transaction count
+// and every transaction against the limits without decoding it
+func checkBlockLimits(raw []byte) error {
+	if err := checkBlockSize(int64(len(raw))); err != nil {
+		return err
+	}
+	r := bytes.NewReader(raw)
+	if _, err := r.Seek(wire.MaxBlockHeaderPayload, io.SeekStart); err != nil {
+		return nil
+	}
+	count, err := utils.ReadCompactSize(r)
+	if err != nil {
+		return nil
+	}
+	if err := checkBlockTxCount(count, int64(r.Len())); err != nil {
+		return err
+	}
+	for i := uint64(0); i < count; i++ {
+		size, err := scanTx(r, int64(r.Len()))
+		if err != nil {
+			return fmt.Errorf("tx %d: %w", i, err)
+		}
+		if size == 0 {
+			return nil // truncated; the decoder reports it
+		}
+	}
+	return nil
+}
+
+// DeserializeBlock decodes a serialized block, as relayed on the P2P network
+// or published by bitcoind's rawblock ZMQ notification, after checking it
+// against the parse limits
+func DeserializeBlock(raw []byte) (*wire.MsgBlock, error) {
+	return deserializeBlock(raw)
+}
+
+// deserializeBlock decodes a serialized block after checking it against
+// the limits
+func deserializeBlock(raw []byte) (*wire.MsgBlock, error) {
+	if err := checkBlockLimits(raw); err != nil {
+		return nil, err
+	}
+	var block wire.MsgBlock
+	if err := block.Deserialize(bytes.NewReader(raw)); err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
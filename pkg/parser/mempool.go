@@ -0,0 +1,198 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/analyzer"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/utils"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// mempool.dat versions: 1 is written in the clear, 2 (Bitcoin Core v28 and
+// later, unless -persistmempoolv1) obfuscates everything after its XOR key
+const (
+	mempoolVersionNoXOR = 1
+	mempoolVersionXOR   = 2
+)
+
+// minMempoolEntryBytes is the smallest serialized mempool.dat entry: a
+// transaction followed by its int64 time and fee delta
+const minMempoolEntryBytes = minTxBytes + 16
+
+// MempoolOptions configures ParseMempoolDat
+type MempoolOptions struct {
+	// Network is the network the node that wrote the file runs on
+	Network string
+
+	// Stages turns analysis stages on or off for every transaction, as
+	// types.Fixture.Stages does for a single transaction
+	Stages map[string]bool
+
+	// ExactVsize computes fee rates over weight/4, as
+	// types.Fixture.ExactVsize does for a single transaction
+	ExactVsize bool
+}
+
+// mempoolTx is one decoded mempool.dat entry
+type mempoolTx struct {
+	tx       *wire.MsgTx
+	time     int64
+	feeDelta int64
+}
+
+// ParseMempoolDat decodes a Bitcoin Core mempool.dat file (which may be
+// gzip- or zstd-compressed) and analyzes every transaction in it. The file
+// carries no prevouts: those of inputs spending another transaction in the
+// file are taken from it, the rest are looked up with the PrevoutResolver
+// when one is installed, and transactions left with missing prevouts are
+// analyzed without fees. Entries that fail analysis are reported with OK
+// false and counted in the stats as invalid.
+func ParseMempoolDat(path string, opts MempoolOptions) (*types.MempoolOutput, error) {
+	stopRead := utils.TimeStage(utils.StageReadFile)
+	data, err := utils.ReadInput(path)
+	stopRead()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mempool file: %w", err)
+	}
+	return decodeMempoolDat(data, opts)
+}
+
+func decodeMempoolDat(data []byte, opts MempoolOptions) (*types.MempoolOutput, error) {
+	if len(data) < 8 {
+		return nil, errors.New("mempool file is empty or truncated")
+	}
+	version := binary.LittleEndian.Uint64(data)
+	switch version {
+	case mempoolVersionNoXOR:
+		data = data[8:]
+	case mempoolVersionXOR:
+		// The key is a CompactSize-prefixed 8-byte vector; it repeats from
+		// the start of the file, as xor.dat does for block files
+		if len(data) < 17 || data[8] != 8 {
+			return nil, errors.New("mempool file has no valid XOR key")
+		}
+		data = utils.XORDecodeAt(data[17:], data[9:17], 17)
+	default:
+		return nil, fmt.Errorf("unsupported mempool file version %d", version)
+	}
+
+	r := bytes.NewReader(data)
+	var count uint64
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return nil, errors.New("mempool file is truncated")
+	}
+	if count > uint64(r.Len()/minMempoolEntryBytes) {
+		return nil, fmt.Errorf("mempool transaction count %d does not fit in the remaining %d bytes", count, r.Len())
+	}
+	entries := make([]mempoolTx, count)
+	for i := range entries {
+		tx, err := deserializeTx(r)
+		if err != nil {
+			return nil, fmt.Errorf("mempool entry %d: %w", i, err)
+		}
+		var fields [2]int64 // time, fee delta
+		if err := binary.Read(r, binary.LittleEndian, &fields); err != nil {
+			return nil, fmt.Errorf("mempool entry %d: truncated", i)
+		}
+		entries[i] = mempoolTx{tx: tx, time: fields[0], feeDelta: fields[1]}
+	}
+
+	out := &types.MempoolOutput{
+		OK:               true,
+		Mode:             "mempool",
+		Version:          version,
+		FeeDeltas:        []types.MempoolFeeDelta{},
+		UnbroadcastTxids: []string{},
+	}
+
+	// Fee deltas of transactions not in the mempool (map<uint256, CAmount>)
+	// and the txids not yet announced to any peer (set<uint256>)
+	n, err := readMempoolCount(r, chainhash.HashSize+8, "fee delta")
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < n; i++ {
+		var delta struct {
+			Txid   chainhash.Hash
+			Amount int64
+		}
+		binary.Read(r, binary.LittleEndian, &delta)
+		out.FeeDeltas = append(out.FeeDeltas, types.MempoolFeeDelta{Txid: delta.Txid.String(), DeltaSats: delta.Amount})
+	}
+	if n, err = readMempoolCount(r, chainhash.HashSize, "unbroadcast txid"); err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < n; i++ {
+		var txid chainhash.Hash
+		r.Read(txid[:])
+		out.UnbroadcastTxids = append(out.UnbroadcastTxids, txid.String())
+	}
+
+	// Outputs of transactions in the file stand in for prevouts
+	byTxid := make(map[chainhash.Hash]*wire.MsgTx, len(entries))
+	for _, e := range entries {
+		byTxid[e.tx.TxHash()] = e.tx
+	}
+	out.Entries = make([]types.MempoolEntry, len(entries))
+	err = utils.ForEach(len(entries), func(i int) error {
+		e := entries[i]
+		fixture := types.Fixture{
+			Network:              opts.Network,
+			Stages:               opts.Stages,
+			ExactVsize:           opts.ExactVsize,
+			AllowMissingPrevouts: true,
+		}
+		for _, txIn := range e.tx.TxIn {
+			op := txIn.PreviousOutPoint
+			if parent := byTxid[op.Hash]; parent != nil && int(op.Index) < len(parent.TxOut) {
+				fixture.Prevouts = append(fixture.Prevouts, types.PrevoutInput{
+					Txid:            op.Hash.String(),
+					Vout:            op.Index,
+					ValueSats:       parent.TxOut[op.Index].Value,
+					ScriptPubkeyHex: hex.EncodeToString(parent.TxOut[op.Index].PkScript),
+				})
+			}
+		}
+		if err := resolveMissingPrevouts(e.tx, &fixture); err != nil {
+			return err
+		}
+		result, err := AnalyzeParsedTransaction(e.tx, fixture)
+		if err != nil {
+			result = &types.TransactionOutput{
+				Txid:  e.tx.TxHash().String(),
+				Error: &types.ErrorInfo{Code: ErrorCode(err, "INVALID_TX"), Message: err.Error()},
+			}
+		}
+		out.Entries[i] = types.MempoolEntry{Time: e.time, FeeDeltaSats: e.feeDelta, Tx: result}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	out.Stats = analyzer.SummarizeMempool(out.Entries)
+	return out, nil
+}
+
+// readMempoolCount reads the CompactSize length of a trailing mempool.dat
+// collection whose items are itemBytes long
+func readMempoolCount(r *bytes.Reader, itemBytes int, what string) (uint64, error) {
+	n, err := utils.ReadCompactSize(r)
+	if err == io.EOF {
+		return 0, fmt.Errorf("mempool file is truncated before its %s count", what)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(r.Len()/itemBytes) {
+		return 0, fmt.Errorf("mempool %s count %d does not fit in the remaining %d bytes", what, n, r.Len())
+	}
+	return n, nil
+}
@@ -0,0 +1,430 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/utils"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Merkle proof formats accepted by VerifyMerkleProof
+const (
+	ProofFormatBranch     = "branch"     // sibling hashes plus the tx index (Electrum style)
+	ProofFormatTxOutProof = "txoutproof" // bitcoind gettxoutproof output (a serialized CMerkleBlock)
+)
+
+// VerifyMerkleProof checks that a transaction is included in a block. The
+// proof is either a merkle branch (siblings from the leaf up, in display
+// byte order, with the tx index) or a gettxoutproof hex, which carries its
+// own header. The reconstructed root is reported alongside the header's.
+func VerifyMerkleProof(fixture types.MerkleProofFixture) (*types.MerkleProofOutput, error) {
+	txid, err := chainhash.NewHashFromStr(fixture.Txid)
+	if err != nil {
+		return nil, fmt.Errorf("invalid txid: %w", err)
+	}
+
+	var header *wire.BlockHeader
+	if fixture.Header != "" {
+		raw, err := utils.HexToBytes(fixture.Header)
+		if err != nil {
+			return nil, fmt.Errorf("invalid header hex: %w", err)
+		}
+		if len(raw) != wire.MaxBlockHeaderPayload {
+			return nil, fmt.Errorf("header must be %d bytes, got %d", wire.MaxBlockHeaderPayload, len(raw))
+		}
+		header = &wire.BlockHeader{}
+		if err := header.Deserialize(bytes.NewReader(raw)); err != nil {
+			return nil, fmt.Errorf("failed to parse header: %w", err)
+		}
+	}
+
+	out := &types.MerkleProofOutput{OK: true, Mode: "merkle_proof", Txid: txid.String()}
+	var root chainhash.Hash
+	switch {
+	case fixture.TxOutProof != "" && len(fixture.Branch) > 0:
+		return nil, errors.New("give either txoutproof or branch, not both")
+
+	case fixture.TxOutProof != "":
+		out.Format = ProofFormatTxOutProof
+		proofHeader, tree, err := decodeTxOutProof(fixture.TxOutProof)
+		if err != nil {
+			return nil, err
+		}
+		// The proof's own header must agree with a separately given one
+		if header != nil && header.BlockHash() != proofHeader.BlockHash() {
+			return nil, fmt.Errorf("txoutproof is for block %s, not %s", proofHeader.BlockHash(), header.BlockHash())
+		}
+		header = proofHeader
+		matched, err := tree.extract()
+		if err != nil {
+			return nil, fmt.Errorf("invalid txoutproof: %w", err)
+		}
+		root = tree.root
+		out.TxCount = &tree.txCount
+		out.MatchedTxids = make([]string, len(matched))
+		for i, m := range matched {
+			out.MatchedTxids[i] = m.hash.String()
+			if m.hash == *txid {
+				index := m.index
+				out.TxIndex = &index
+			}
+		}
+
+	case len(fixture.Branch) > 0 || fixture.Index != nil:
+		out.Format = ProofFormatBranch
+		if fixture.Index == nil {
+			return nil, errors.New("a merkle branch needs the transaction index")
+		}
+		if header == nil {
+			return nil, errors.New("a merkle branch needs the block header")
+		}
+		index := *fixture.Index
+		if index < 0 || (len(fixture.Branch) < 31 && index >= 1<<len(fixture.Branch)) {
+			return nil, fmt.Errorf("index %d does not fit a branch of %d hashes", index, len(fixture.Branch))
+		}
+		// Each level pairs the running hash with its sibling; the index bit
+		// says which side the running hash is on
+		root = *txid
+		for i, s := range fixture.Branch {
+			sibling, err := chainhash.NewHashFromStr(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid branch hash %d: %w", i, err)
+			}
+			if (index>>i)&1 == 1 {
+				root = utils.HashMerkleBranches(sibling, &root)
+			} else {
+				root = utils.HashMerkleBranches(&root, sibling)
+			}
+		}
+		out.TxIndex = &index
+
+	default:
+		return nil, errors.New("a proof is required: txoutproof or branch with index")
+	}
+
+	info := blockHeaderInfo(header, root == header.MerkleRoot)
+	out.BlockHeader = &info
+	out.ReconstructedRoot = root.String()
+	out.Included = out.BlockHeader.MerkleRootValid && out.TxIndex != nil
+	return out, nil
+}
+
+// partialMerkleTree is the BIP37 partial merkle tree of a CMerkleBlock
+type partialMerkleTree struct {
+	txCount int
+	hashes  []chainhash.Hash
+	flags   []byte
+
+	// traversal state
+	bit, hashPos int
+	root         chainhash.Hash
+}
+
+type matchedTx struct {
+	hash  chainhash.Hash
+	index int
+}
+
+// decodeTxOutProof parses gettxoutproof output: header, transaction count,
+// hashes and flag bits
+func decodeTxOutProof(proofHex string) (*wire.BlockHeader, *partialMerkleTree, error) {
+	raw, err := utils.HexToBytes(proofHex)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid txoutproof hex: %w", err)
+	}
+	r := bytes.NewReader(raw)
+	var header wire.BlockHeader
+	if err := header.Deserialize(r); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse txoutproof header: %w", err)
+	}
+	var txCount uint32
+	if err := binary.Read(r, binary.LittleEndian, &txCount); err != nil {
+		return nil, nil, errors.New("txoutproof is truncated")
+	}
+	if txCount == 0 {
+		return nil, nil, errors.New("txoutproof transaction count is zero")
+	}
+	if err := checkBlockTxLimit("txoutproof transaction count", uint64(txCount)); err != nil {
+		return nil, nil, err
+	}
+	hashCount, err := utils.ReadCompactSize(r)
+	if err != nil || hashCount > uint64(txCount) || hashCount*32 > uint64(r.Len()) {
+		return nil, nil, errors.New("txoutproof hash count exceeds payload")
+	}
+	tree := &partialMerkleTree{txCount: int(txCount), hashes: make([]chainhash.Hash, hashCount)}
+	for i := range tree.hashes {
+		if _, err := io.ReadFull(r, tree.hashes[i][:]); err != nil {
+			return nil, nil, err
+		}
+	}
+	flagCount, err := utils.ReadCompactSize(r)
+	if err != nil || flagCount > uint64(r.Len()) {
+		return nil, nil, errors.New("txoutproof flag bytes exceed payload")
+	}
+	tree.flags = make([]byte, flagCount)
+	if _, err := io.ReadFull(r, tree.flags); err != nil {
+		return nil, nil, err
+	}
+	if r.Len() != 0 {
+		return nil, nil, fmt.Errorf("txoutproof has %d trailing bytes", r.Len())
+	}
+	return &header, tree, nil
+}
+
+// width is the number of nodes at height h (0 = leaves)
+func (t *partialMerkleTree) width(h int) int {
+	return (t.txCount + (1 << h) - 1) >> h
+}
+
+// extract walks the tree depth first, as Core's CPartialMerkleTree does,
+// computing the root and collecting the matched leaves. Every hash and
+// flag bit (up to byte padding) must be used.
+func (t *partialMerkleTree) extract() ([]matchedTx, error) {
+	height := 0
+	for t.width(height) > 1 {
+		height++
+	}
+	var matched []matchedTx
+	root, err := t.traverse(height, 0, &matched)
+	if err != nil {
+		return nil, err
+	}
+	if t.hashPos != len(t.hashes) {
+		return nil, fmt.Errorf("%d hashes left unused", len(t.hashes)-t.hashPos)
+	}
+	if (t.bit+7)/8 != len(t.flags) {
+		return nil, errors.New("flag bits left unused")
+	}
+	t.root = root
+	return matched, nil
+}
+
+func (t *partialMerkleTree) traverse(height, pos int, matched *[]matchedTx) (chainhash.Hash, error) {
+	if t.bit >= len(t.flags)*8 {
+		return chainhash.Hash{}, errors.New("ran out of flag bits")
+	}
+	flag := t.flags[t.bit/8]>>(t.bit%8)&1 == 1
+	t.bit++
+	if height == 0 || !flag {
+		if t.hashPos >= len(t.hashes) {
+			return chainhash.Hash{}, errors.New("ran out of hashes")
+		}
+		hash := t.hashes[t.hashPos]
+		t.hashPos++
+		if height == 0 && flag {
+			*matched = append(*matched, matchedTx{hash, pos})
+		}
+		return hash, nil
+	}
+	left, err := t.traverse(height-1, pos*2, matched)
+	if err != nil {
+		return chainhash.Hash{}, err
+	}
+	right := left
+	if pos*2+1 < t.width(height-1) {
+		if right, err = t.traverse(height-1, pos*2+1, matched); err != nil {
+			return chainhash.Hash{}, err
+		}
+		// Identical siblings would allow the CVE-2012-2459 duplication trick
+		if right == left {
+			return chainhash.Hash{}, errors.New("duplicate sibling hashes")
+		}
+	}
+	return utils.HashMerkleBranches(&left, &right), nil
+}
+
+// buildPartialMerkleTree builds the partial merkle tree proving the
+// matched leaves of txids, as Core's CPartialMerkleTree constructor does
+func buildPartialMerkleTree(txids []chainhash.Hash, matched []bool) *partialMerkleTree {
+	t := &partialMerkleTree{txCount: len(txids)}
+	height := 0
+	for t.width(height) > 1 {
+		height++
+	}
+	var bits []bool
+	t.build(height, 0, txids, matched, &bits)
+	t.flags = make([]byte, (len(bits)+7)/8)
+	for i, b := range bits {
+		if b {
+			t.flags[i/8] |= 1 << (i % 8)
+		}
+	}
+	return t
+}
+
+func (t *partialMerkleTree) build(height, pos int, txids []chainhash.Hash, matched []bool, bits *[]bool) {
+	// A node is descended into when any leaf below it matched
+	parentOfMatch := false
+	for p := pos << height; p < (pos+1)<<height && p < len(txids); p++ {
+		parentOfMatch = parentOfMatch || matched[p]
+	}
+	*bits = append(*bits, parentOfMatch)
+	if height == 0 || !parentOfMatch {
+		t.hashes = append(t.hashes, t.calcHash(height, pos, txids))
+		return
+	}
+	t.build(height-1, pos*2, txids, matched, bits)
+	if pos*2+1 < t.width(height-1) {
+		t.build(height-1, pos*2+1, txids, matched, bits)
+	}
+}
+
+// calcHash is the merkle hash of the node at height and pos
+func (t *partialMerkleTree) calcHash(height, pos int, txids []chainhash.Hash) chainhash.Hash {
+	if height == 0 {
+		return txids[pos]
+	}
+	left := t.calcHash(height-1, pos*2, txids)
+	right := left
+	if pos*2+1 < t.width(height-1) {
+		right = t.calcHash(height-1, pos*2+1, txids)
+	}
+	return utils.HashMerkleBranches(&left, &right)
+}
+
+// encodeTxOutProof serializes a header and partial merkle tree as a
+// CMerkleBlock, the format of merkleblock messages and gettxoutproof
+func encodeTxOutProof(header *wire.BlockHeader, t *partialMerkleTree) []byte {
+	var buf bytes.Buffer
+	header.Serialize(&buf)
+	binary.Write(&buf, binary.LittleEndian, uint32(t.txCount))
+	wire.WriteVarInt(&buf, 0, uint64(len(t.hashes)))
+	for _, h := range t.hashes {
+		buf.Write(h[:])
+	}
+	wire.WriteVarBytes(&buf, 0, t.flags)
+	return buf.Bytes()
+}
+
+// GenerateMerkleProofs searches blk*.dat files for the given transactions
+// and builds an inclusion proof for each, both as a merkle branch and as a
+// merkleblock. A transaction found in several blocks (e.g. a stale one) is
+// proven against the first.
+func GenerateMerkleProofs(paths []string, xorPath string, txids []string) (*types.MerkleBranchOutput, error) {
+	p, err := newMerkleProver(txids)
+	if err != nil {
+		return nil, err
+	}
+	xorKey, err := readXORKey(xorPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read XOR key: %w", err)
+	}
+	for _, path := range paths {
+		if p.missing == 0 {
+			break
+		}
+		data, err := readXORFile(path, xorKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read block file: %w", err)
+		}
+		records, err := scanBlockRecords(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, rec := range records {
+			if p.missing == 0 {
+				break
+			}
+			block, err := deserializeBlock(data[rec.offset+8 : rec.offset+8+int(rec.size)])
+			if err != nil {
+				return nil, fmt.Errorf("%s: block at offset %d: %w", path, rec.offset, err)
+			}
+			p.prove(block)
+		}
+	}
+	return p.output(), nil
+}
+
+// GenerateBlockMerkleProofs is GenerateMerkleProofs for one raw block
+func GenerateBlockMerkleProofs(fixture types.MerkleBranchFixture) (*types.MerkleBranchOutput, error) {
+	p, err := newMerkleProver(fixture.Txids)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := utils.HexToBytes(fixture.Block)
+	if err != nil {
+		return nil, fmt.Errorf("invalid block hex: %w", err)
+	}
+	block, err := deserializeBlock(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse block: %w", err)
+	}
+	p.prove(block)
+	return p.output(), nil
+}
+
+// merkleProver collects the proofs of a set of txids over blocks
+type merkleProver struct {
+	order   []chainhash.Hash
+	proofs  map[chainhash.Hash]*types.MerkleBranchProof // nil until found
+	missing int
+}
+
+func newMerkleProver(txids []string) (*merkleProver, error) {
+	if len(txids) == 0 {
+		return nil, errors.New("at least one txid is required")
+	}
+	p := &merkleProver{order: make([]chainhash.Hash, len(txids)), proofs: make(map[chainhash.Hash]*types.MerkleBranchProof, len(txids))}
+	for i, s := range txids {
+		hash, err := chainhash.NewHashFromStr(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid txid %q: %w", s, err)
+		}
+		p.order[i] = *hash
+		p.proofs[*hash] = nil
+	}
+	p.missing = len(p.proofs)
+	return p, nil
+}
+
+// prove builds the proofs of the wanted transactions block holds
+func (p *merkleProver) prove(block *wire.MsgBlock) {
+	hashes := utils.TxHashes(block.Transactions, false)
+	for i, hash := range hashes {
+		if proof, ok := p.proofs[hash]; ok && proof == nil {
+			p.proofs[hash] = buildMerkleBranchProof(&block.Header, hashes, i)
+			p.missing--
+		}
+	}
+}
+
+func (p *merkleProver) output() *types.MerkleBranchOutput {
+	out := &types.MerkleBranchOutput{OK: true, Mode: "merkle_branch"}
+	for _, hash := range p.order {
+		if proof := p.proofs[hash]; proof != nil {
+			out.Proofs = append(out.Proofs, *proof)
+		} else {
+			out.NotFound = append(out.NotFound, hash.String())
+		}
+	}
+	return out
+}
+
+// buildMerkleBranchProof proves hashes[index] against header
+func buildMerkleBranchProof(header *wire.BlockHeader, hashes []chainhash.Hash, index int) *types.MerkleBranchProof {
+	var raw bytes.Buffer
+	header.Serialize(&raw)
+	proof := &types.MerkleBranchProof{
+		Txid:      hashes[index].String(),
+		BlockHash: header.BlockHash().String(),
+		HeaderHex: hex.EncodeToString(raw.Bytes()),
+		TxCount:   len(hashes),
+		Index:     index,
+		Branch:    []string{},
+	}
+	for _, sibling := range utils.MerkleBranch(hashes, index) {
+		proof.Branch = append(proof.Branch, sibling.String())
+	}
+	matched := make([]bool, len(hashes))
+	matched[index] = true
+	proof.MerkleBlockHex = hex.EncodeToString(encodeTxOutProof(header, buildPartialMerkleTree(hashes, matched)))
+	return proof
+}
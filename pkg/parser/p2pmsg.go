@@ -0,0 +1,251 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/analyzer"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/utils"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// p2pHeaderSize is magic (4) + command (12) + payload length (4) + checksum (4)
+const p2pHeaderSize = 24
+
+// DecodeP2PMessage decodes a Bitcoin P2P wire message into structured JSON.
+// raw is either a complete message (starting with a known network magic) or,
+// when command is given, a bare payload. tx and block payloads are run
+// through the transaction analyzer (without prevouts); other messages are
+// converted field by field.
+func DecodeP2PMessage(rawHex, command string) (*types.P2PMessageOutput, error) {
+	raw, err := utils.HexToBytes(rawHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message hex: %w", err)
+	}
+
+	out := &types.P2PMessageOutput{OK: true, Mode: "p2pmsg"}
+	network := analyzer.NetworkMainnet
+	payload := raw
+	if command == "" {
+		if len(raw) < p2pHeaderSize {
+			return nil, fmt.Errorf("message is %d bytes, shorter than the %d-byte header", len(raw), p2pHeaderSize)
+		}
+		var magic [4]byte
+		copy(magic[:], raw[:4])
+		name, ok := analyzer.NetworkFromMagic(magic)
+		if !ok {
+			return nil, fmt.Errorf("unknown network magic %x (pass the command to decode a bare payload)", magic)
+		}
+		network = name
+		command = string(bytes.TrimRight(raw[4:16], "\x00"))
+		length := binary.LittleEndian.Uint32(raw[16:20])
+		payload = raw[p2pHeaderSize:]
+		if uint64(length) != uint64(len(payload)) {
+			return nil, fmt.Errorf("header declares %d payload bytes, got %d", length, len(payload))
+		}
+		sum := chainhash.DoubleHashB(payload)
+		valid := bytes.Equal(sum[:4], raw[20:24])
+		out.ChecksumValid = &valid
+		out.Network = network
+	}
+	out.Command = command
+	out.PayloadBytes = len(payload)
+
+	msg, err := decodeP2PPayload(command, payload, network)
+	if err != nil {
+		out.OK = false
+		out.Error = &types.ErrorInfo{Code: ErrorCode(err, "INVALID_MESSAGE"), Message: err.Error()}
+		return out, nil
+	}
+	out.Message = msg
+	return out, nil
+}
+
+// decodeP2PPayload decodes one payload by command name
+func decodeP2PPayload(command string, payload []byte, network string) (interface{}, error) {
+	// BIP152 messages are not implemented by btcd's wire package
+	switch command {
+	case "sendcmpct":
+		if len(payload) != 9 {
+			return nil, fmt.Errorf("sendcmpct payload must be 9 bytes, got %d", len(payload))
+		}
+		return map[string]interface{}{
+			"announce": payload[0] != 0,
+			"version":  binary.LittleEndian.Uint64(payload[1:]),
+		}, nil
+	case "cmpctblock":
+		cb, err := decodeCompactBlock(payload)
+		if err != nil {
+			return nil, err
+		}
+		shortIDs := make([]string, len(cb.shortIDs))
+		for i, id := range cb.shortIDs {
+			shortIDs[i] = fmt.Sprintf("%012x", id)
+		}
+		return map[string]interface{}{
+			"header":            blockHeaderInfo(&cb.header, false),
+			"short_id_nonce":    cb.nonce,
+			"short_ids":         shortIDs,
+			"prefilled_indexes": cb.prefIdx,
+		}, nil
+	}
+
+	// Transactions and blocks are checked against the parse limits before
+	// wire allocates for the counts they claim
+	switch command {
+	case "tx":
+		if err := checkTxLimits(bytes.NewReader(payload), int64(len(payload))); err != nil {
+			return nil, err
+		}
+	case "block":
+		if err := checkBlockLimits(payload); err != nil {
+			return nil, err
+		}
+	}
+
+	// Re-frame the payload with a header so wire can pick the message type
+	var buf bytes.Buffer
+	var hdr [p2pHeaderSize]byte
+	binary.LittleEndian.PutUint32(hdr[:4], uint32(wire.MainNet))
+	if len(command) > wire.CommandSize {
+		return nil, fmt.Errorf("command %q is longer than %d bytes", command, wire.CommandSize)
+	}
+	copy(hdr[4:16], command)
+	binary.LittleEndian.PutUint32(hdr[16:20], uint32(len(payload)))
+	copy(hdr[20:24], chainhash.DoubleHashB(payload)[:4])
+	buf.Write(hdr[:])
+	buf.Write(payload)
+
+	_, msg, _, err := wire.ReadMessageWithEncodingN(&buf, wire.ProtocolVersion, wire.MainNet, wire.WitnessEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	switch m := msg.(type) {
+	case *wire.MsgTx:
+		return AnalyzeParsedTransaction(m, types.Fixture{Network: network, AllowMissingPrevouts: true})
+	case *wire.MsgBlock:
+		prevouts, err := ResolveBlockPrevouts(m.Transactions)
+		if err != nil {
+			return nil, err
+		}
+		txs := make([]types.TransactionOutput, len(m.Transactions))
+		hashes := utils.TxHashes(m.Transactions, false)
+		for i, tx := range m.Transactions {
+			fixture := types.Fixture{Network: network, AllowMissingPrevouts: true}
+			if prevouts != nil {
+				fixture.Prevouts = prevouts[i]
+			}
+			analyzed, err := analyzeTransaction(tx, fixture, i == 0)
+			if err != nil {
+				return nil, fmt.Errorf("failed to analyze tx %d: %w", i, err)
+			}
+			txs[i] = *analyzed
+		}
+		root := utils.MerkleRootInPlace(hashes)
+		return map[string]interface{}{
+			"header":       blockHeaderInfo(&m.Header, root.IsEqual(&m.Header.MerkleRoot)),
+			"tx_count":     len(txs),
+			"transactions": txs,
+		}, nil
+	}
+	return wireToJSON(reflect.ValueOf(msg)), nil
+}
+
+// wireToJSON converts a wire message into plain JSON values: hashes become
+// hex strings, byte slices hex, times unix seconds, enums their names, and
+// struct fields snake_case keys
+func wireToJSON(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return nil
+	}
+
+	if v.CanInterface() {
+		switch val := v.Interface().(type) {
+		case net.Addr: // addrv2 addresses (IPv4/6, Tor v3, ...)
+			return val.String()
+		case chainhash.Hash:
+			return val.String()
+		case time.Time:
+			// version's embedded addresses carry no timestamp
+			if val.IsZero() {
+				return int64(0)
+			}
+			return val.Unix()
+		case net.IP:
+			return val.String()
+		case []byte:
+			return hex.EncodeToString(val)
+		}
+		// Named integer types (InvType, ServiceFlag, ...) print as names
+		if s, ok := v.Interface().(fmt.Stringer); ok && v.Kind() != reflect.Struct && v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return s.String()
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return wireToJSON(v.Elem())
+	case reflect.Struct:
+		obj := make(map[string]interface{}, v.NumField())
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			obj[snakeCase(t.Field(i).Name)] = wireToJSON(v.Field(i))
+		}
+		return obj
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return hex.EncodeToString(b)
+		}
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			list[i] = wireToJSON(v.Index(i))
+		}
+		return list
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint()
+	case reflect.String:
+		return v.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// snakeCase converts a Go field name to snake_case ("AddrYou" -> "addr_you",
+// "IP" -> "ip", "InvList" -> "inv_list")
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prevLower := unicode.IsLower(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (nextLower && unicode.IsUpper(runes[i-1])) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
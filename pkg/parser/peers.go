@@ -0,0 +1,336 @@
+package parser
+
+import (
+	"bytes"
+	"crypto/sha3"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/analyzer"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/utils"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// peers.dat formats (AddrMan::Format): 3 switched addresses to BIP155
+// (addrv2) encoding and 4, the newest, buckets one host per port. A file
+// records the lowest format able to read it plus addrmanIncompatibilityBase.
+const (
+	addrmanFormatBIP155        = 3
+	addrmanFormatLatest        = 4
+	addrmanIncompatibilityBase = 32
+)
+
+// Address table sizes: an addrman holds at most this many new and tried
+// entries, and its new table has addrmanNewBuckets buckets
+const (
+	addrmanNewBuckets  = 1024
+	addrmanBucketSize  = 64
+	addrmanMaxNew      = addrmanNewBuckets * addrmanBucketSize
+	addrmanMaxTried    = 256 * addrmanBucketSize
+	addrmanBucketsFlag = 1 << 30 // XORed into the stored bucket count
+)
+
+// diskVersionAddrV2 flags a CAddress stored in BIP155 encoding
+const diskVersionAddrV2 = 1 << 29
+
+// BIP155 network IDs
+const (
+	bip155IPv4  = 1
+	bip155IPv6  = 2
+	bip155TorV2 = 3
+	bip155TorV3 = 4
+	bip155I2P   = 5
+	bip155CJDNS = 6
+)
+
+// IPv6 prefixes under which addrv1 encodes Tor v2 addresses (OnionCat) and
+// Bitcoin Core's internal placeholder addresses
+var (
+	onionCatPrefix = []byte{0xfd, 0x87, 0xd8, 0x7e, 0xeb, 0x43}
+	internalPrefix = []byte{0xfd, 0x6b, 0x88, 0xc0, 0x87, 0x24}
+)
+
+// onionBase32 is the lower-case alphabet of .onion and .b32.i2p names
+var onionBase32 = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// ParsePeersDat decodes a Bitcoin Core peers.dat file, the node's address
+// manager, into its new and tried address tables
+func ParsePeersDat(path string) (*types.PeersOutput, error) {
+	magic, data, err := readNodeDBFile(path)
+	if err != nil {
+		return nil, err
+	}
+	out := &types.PeersOutput{OK: true, Mode: "peers", Magic: hex.EncodeToString(magic[:]), NetworkCounts: make(map[string]int)}
+	out.Network, _ = analyzer.NetworkFromMagic(magic)
+
+	d := &diskReader{r: bytes.NewReader(data)}
+	out.Format = d.u8()
+	compat := d.u8()
+	if d.err == nil {
+		if compat < addrmanIncompatibilityBase {
+			return nil, fmt.Errorf("peers.dat lowest compatible format %d is invalid", compat)
+		}
+		out.LowestCompatible = compat - addrmanIncompatibilityBase
+		if out.LowestCompatible > addrmanFormatLatest {
+			return nil, fmt.Errorf("peers.dat needs format %d; format %d is the latest supported", out.LowestCompatible, addrmanFormatLatest)
+		}
+	}
+	d.skip(chainhash.HashSize) // nKey
+	nNew, nTried, nBuckets := d.i32(), d.i32(), d.i32()
+	if d.err != nil {
+		return nil, d.fail("peers.dat")
+	}
+	if nNew < 0 || nNew > addrmanMaxNew || nTried < 0 || nTried > addrmanMaxTried {
+		return nil, fmt.Errorf("peers.dat claims %d new and %d tried addresses, over the %d and %d an address manager holds", nNew, nTried, addrmanMaxNew, addrmanMaxTried)
+	}
+
+	v2 := out.Format >= addrmanFormatBIP155
+	out.Entries = make([]types.AddrManEntry, 0, nNew+nTried)
+	for i := 0; i < int(nNew+nTried); i++ {
+		entry := types.AddrManEntry{Table: "new", PeerAddress: d.address(v2)}
+		if i >= int(nNew) {
+			entry.Table = "tried"
+		}
+		entry.Source, entry.SourceNetwork = d.netAddr(v2)
+		entry.LastSuccess = d.i64()
+		entry.Attempts = d.i32()
+		if d.err != nil {
+			return nil, d.fail(fmt.Sprintf("peers.dat entry %d", i))
+		}
+		out.Entries = append(out.Entries, entry)
+	}
+
+	// The new table's buckets list entry indexes; a file written with a
+	// different bucket count is rebucketed by the node, and only its count
+	// of references is meaningful here
+	nBuckets ^= addrmanBucketsFlag
+	if nBuckets < 0 || nBuckets > addrmanNewBuckets {
+		return nil, fmt.Errorf("peers.dat has %d new buckets, over %d", nBuckets, addrmanNewBuckets)
+	}
+	for b := int32(0); b < nBuckets && d.err == nil; b++ {
+		size := d.i32()
+		if size < 0 || size > addrmanBucketSize {
+			return nil, fmt.Errorf("peers.dat new bucket %d holds %d entries, over %d", b, size, addrmanBucketSize)
+		}
+		for j := int32(0); j < size && d.err == nil; j++ {
+			if index := d.i32(); index >= 0 && index < nNew {
+				out.Entries[index].NewBucketRefs++
+			}
+		}
+	}
+	var asmap chainhash.Hash
+	d.read(asmap[:])
+	if d.err != nil {
+		return nil, d.fail("peers.dat buckets")
+	}
+	if asmap != (chainhash.Hash{}) {
+		out.AsmapChecksum = asmap.String()
+	}
+
+	for _, e := range out.Entries {
+		if e.Table == "new" {
+			out.NewCount++
+		} else {
+			out.TriedCount++
+		}
+		out.NetworkCounts[e.Network]++
+	}
+	return out, nil
+}
+
+// ParseAnchorsDat decodes a Bitcoin Core anchors.dat file
+func ParseAnchorsDat(path string) (*types.AnchorsOutput, error) {
+	magic, data, err := readNodeDBFile(path)
+	if err != nil {
+		return nil, err
+	}
+	out := &types.AnchorsOutput{OK: true, Mode: "anchors", Magic: hex.EncodeToString(magic[:]), Anchors: []types.PeerAddress{}}
+	out.Network, _ = analyzer.NetworkFromMagic(magic)
+
+	d := &diskReader{r: bytes.NewReader(data)}
+	n := d.compactSize()
+	// A stored address is at least its version, time, services, network,
+	// length and port
+	if d.err == nil && n > uint64(d.r.Len()/12) {
+		return nil, fmt.Errorf("anchors.dat address count %d does not fit in the remaining %d bytes", n, d.r.Len())
+	}
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		out.Anchors = append(out.Anchors, d.address(true))
+	}
+	if d.err != nil {
+		return nil, d.fail("anchors.dat")
+	}
+	return out, nil
+}
+
+// readNodeDBFile reads a file Bitcoin Core writes with SerializeFileDB:
+// the network magic, the data and a SHA256d checksum of both
+func readNodeDBFile(path string) ([4]byte, []byte, error) {
+	var magic [4]byte
+	data, err := utils.ReadInput(path)
+	if err != nil {
+		return magic, nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if len(data) < 4+chainhash.HashSize {
+		return magic, nil, errors.New("file is empty or truncated")
+	}
+	body, sum := data[:len(data)-chainhash.HashSize], data[len(data)-chainhash.HashSize:]
+	if !bytes.Equal(chainhash.DoubleHashB(body), sum) {
+		return magic, nil, errors.New("checksum mismatch: the file is corrupted")
+	}
+	copy(magic[:], body)
+	return magic, body[4:], nil
+}
+
+// diskReader decodes Bitcoin Core's disk serialization, remembering the
+// first error so that a record can be read field by field and checked once
+type diskReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (d *diskReader) read(b []byte) {
+	if d.err == nil {
+		_, d.err = io.ReadFull(d.r, b)
+	}
+}
+
+func (d *diskReader) skip(n int) {
+	d.read(make([]byte, n))
+}
+
+// fixed reads a little-endian fixed-size value
+func (d *diskReader) fixed(v interface{}) {
+	if d.err == nil {
+		d.err = binary.Read(d.r, binary.LittleEndian, v)
+	}
+}
+
+func (d *diskReader) u8() (v uint8)   { d.fixed(&v); return }
+func (d *diskReader) u32() (v uint32) { d.fixed(&v); return }
+func (d *diskReader) i32() (v int32)  { d.fixed(&v); return }
+func (d *diskReader) i64() (v int64)  { d.fixed(&v); return }
+func (d *diskReader) u64() (v uint64) { d.fixed(&v); return }
+
+func (d *diskReader) compactSize() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := utils.ReadCompactSize(d.r)
+	d.err = err
+	return v
+}
+
+// fail describes the reader's error: a truncation, or an invalid field
+func (d *diskReader) fail(what string) error {
+	if d.err == io.EOF || d.err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%s is truncated", what)
+	}
+	return fmt.Errorf("%s: %w", what, d.err)
+}
+
+// address reads a CAddress in disk format: a version whose flag selects
+// addrv1 or addrv2 encoding, then time, services, address and port
+func (d *diskReader) address(v2Allowed bool) types.PeerAddress {
+	version := d.u32()
+	v2 := version&diskVersionAddrV2 != 0
+	if d.err == nil && v2 && !v2Allowed {
+		d.err = errors.New("addrv2 address in a file format that predates it")
+	}
+	var a types.PeerAddress
+	a.Time = d.u32()
+	var services uint64
+	if v2 {
+		services = d.compactSize()
+	} else {
+		services = d.u64()
+	}
+	a.Services = wire.ServiceFlag(services).String()
+	a.Address, a.Network = d.netAddr(v2)
+	var port [2]byte
+	d.read(port[:])
+	a.Port = binary.BigEndian.Uint16(port[:])
+	return a
+}
+
+// netAddr reads a CNetAddr, in addrv2 (BIP155) or addrv1 encoding, and
+// returns it as text with its network name
+func (d *diskReader) netAddr(v2 bool) (string, string) {
+	if !v2 {
+		var ip [16]byte
+		d.read(ip[:])
+		return formatAddrV1(ip[:])
+	}
+	id := d.u8()
+	n := d.compactSize()
+	if d.err == nil && n > 512 {
+		d.err = fmt.Errorf("address of %d bytes is over the BIP155 maximum of 512", n)
+	}
+	if d.err != nil {
+		return "", ""
+	}
+	addr := make([]byte, n)
+	d.read(addr)
+	if d.err != nil {
+		return "", ""
+	}
+	size := map[uint8]int{bip155IPv4: 4, bip155IPv6: 16, bip155TorV2: 10, bip155TorV3: 32, bip155I2P: 32, bip155CJDNS: 16}[id]
+	if size != 0 && len(addr) != size {
+		d.err = fmt.Errorf("BIP155 network %d address is %d bytes, want %d", id, len(addr), size)
+		return "", ""
+	}
+	switch id {
+	case bip155IPv4:
+		return net.IP(addr).String(), "ipv4"
+	case bip155IPv6:
+		if bytes.HasPrefix(addr, internalPrefix) {
+			return formatAddrV1(addr)
+		}
+		return net.IP(addr).String(), "ipv6"
+	case bip155TorV2:
+		return onionBase32.EncodeToString(addr) + ".onion", "onion"
+	case bip155TorV3:
+		return torV3Name(addr), "onion"
+	case bip155I2P:
+		return onionBase32.EncodeToString(addr) + ".b32.i2p", "i2p"
+	case bip155CJDNS:
+		return net.IP(addr).String(), "cjdns"
+	}
+	return fmt.Sprintf("unknown-%d:%x", id, addr), "unknown"
+}
+
+// formatAddrV1 names a 16-byte addrv1 address: IPv4-mapped, OnionCat Tor
+// v2, an internal placeholder or plain IPv6
+func formatAddrV1(ip []byte) (string, string) {
+	switch {
+	case bytes.HasPrefix(ip, onionCatPrefix):
+		return onionBase32.EncodeToString(ip[len(onionCatPrefix):]) + ".onion", "onion"
+	case bytes.HasPrefix(ip, internalPrefix):
+		return onionBase32.EncodeToString(ip[len(internalPrefix):]) + ".internal", "internal"
+	case net.IP(ip).To4() != nil:
+		return net.IP(ip).String(), "ipv4"
+	}
+	return net.IP(ip).String(), "ipv6"
+}
+
+// torV3Name encodes a Tor v3 public key as its .onion name:
+// base32(pubkey || checksum || version), where checksum is the first two
+// bytes of SHA3-256(".onion checksum" || pubkey || version)
+func torV3Name(pubkey []byte) string {
+	const version = 3
+	h := sha3.New256()
+	h.Write([]byte(".onion checksum"))
+	h.Write(pubkey)
+	h.Write([]byte{version})
+	sum := h.Sum(nil)
+	name := append(append(append([]byte{}, pubkey...), sum[:2]...), version)
+	return onionBase32.EncodeToString(name) + ".onion"
+}
@@ -0,0 +1,224 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/analyzer"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/utils"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// PSBT magic in its base64 and hex text forms
+const (
+	psbtBase64Prefix = "cHNidP8"
+	psbtHexPrefix    = "70736274ff"
+)
+
+// IsPSBT reports whether data is a PSBT: binary, or base64 or hex text
+func IsPSBT(data []byte) bool {
+	text := bytes.TrimSpace(data)
+	return bytes.HasPrefix(data, psbtMagic) ||
+		bytes.HasPrefix(text, []byte(psbtBase64Prefix)) ||
+		bytes.HasPrefix(bytes.ToLower(text[:min(len(text), len(psbtHexPrefix))]), []byte(psbtHexPrefix))
+}
+
+// decodePSBT decodes a PSBT given as binary, base64 or hex
+func decodePSBT(data []byte) (*psbt.Packet, error) {
+	raw := data
+	if !bytes.HasPrefix(data, psbtMagic) {
+		text := string(bytes.TrimSpace(data))
+		var err error
+		if DetectTextEncoding(text) == EncodingHex {
+			raw, err = hex.DecodeString(text)
+		} else {
+			raw, err = decodeBase64(text)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid PSBT encoding: %w", err)
+		}
+	}
+	if l := parseLimits.Load(); len(raw) > l.MaxBlockBytes {
+		return nil, &LimitError{What: "PSBT size", Limit: "max_block_bytes", Value: uint64(len(raw)), Max: l.MaxBlockBytes}
+	}
+	if !bytes.HasPrefix(raw, psbtMagic) {
+		return nil, errors.New("invalid PSBT: missing psbt magic")
+	}
+	packet, err := psbt.NewFromRawBytes(bytes.NewReader(raw), false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PSBT: %w", err)
+	}
+	return packet, nil
+}
+
+// ParsePSBT analyzes the transaction in a BIP174 PSBT (fixture.PSBT, base64
+// or hex). Prevout values and scripts come from each input's witness_utxo
+// or non_witness_utxo, and fixture.Prevouts or the PrevoutResolver fill in
+// inputs that have neither; inputs still without one are analyzed
+// structurally. Finalized
+// inputs are analyzed with their final scriptSig and witness.
+func ParsePSBT(fixture types.Fixture) (*types.TransactionOutput, error) {
+	packet, err := decodePSBT([]byte(fixture.PSBT))
+	if err != nil {
+		return nil, err
+	}
+	tx := packet.UnsignedTx.Copy()
+
+	given := make(map[string]bool, len(fixture.Prevouts))
+	for _, p := range fixture.Prevouts {
+		given[fmt.Sprintf("%s:%d", p.Txid, p.Vout)] = true
+	}
+
+	info := &types.PSBTInfo{
+		Inputs:  make([]types.PSBTInput, len(packet.Inputs)),
+		Outputs: make([]types.PSBTOutput, len(packet.Outputs)),
+	}
+	prevouts := append([]types.PrevoutInput{}, fixture.Prevouts...)
+	for i := range packet.Inputs {
+		in := &packet.Inputs[i]
+		txIn := tx.TxIn[i]
+		desc := types.PSBTInput{
+			Vin:              i,
+			PartialSigs:      len(in.PartialSigs),
+			TaprootSigs:      len(in.TaprootScriptSpendSig),
+			RedeemScriptHex:  hex.EncodeToString(in.RedeemScript),
+			WitnessScriptHex: hex.EncodeToString(in.WitnessScript),
+			Bip32Derivations: len(in.Bip32Derivation) + len(in.TaprootBip32Derivation),
+		}
+		if len(in.TaprootKeySpendSig) > 0 {
+			desc.TaprootSigs++
+		}
+		if in.SighashType != 0 {
+			name := analyzer.SighashTypeName(byte(in.SighashType))
+			desc.SighashType = &name
+		}
+
+		utxo, source, err := psbtInputUtxo(in, txIn.PreviousOutPoint)
+		if err != nil {
+			return nil, fmt.Errorf("psbt input %d: %w", i, err)
+		}
+		switch {
+		case given[txIn.PreviousOutPoint.String()]:
+			desc.UtxoSource = "prevouts"
+		case utxo != nil:
+			desc.UtxoSource = source
+			prevouts = append(prevouts, types.PrevoutInput{
+				Txid:            txIn.PreviousOutPoint.Hash.String(),
+				Vout:            txIn.PreviousOutPoint.Index,
+				ValueSats:       utxo.Value,
+				ScriptPubkeyHex: hex.EncodeToString(utxo.PkScript),
+			})
+		default:
+			desc.UtxoSource = "missing"
+			info.MissingUtxos++
+		}
+
+		if in.FinalScriptSig != nil || in.FinalScriptWitness != nil {
+			desc.Finalized = true
+			info.InputsFinalized++
+			txIn.SignatureScript = in.FinalScriptSig
+			if in.FinalScriptWitness != nil {
+				if txIn.Witness, err = decodeWitnessStack(in.FinalScriptWitness); err != nil {
+					return nil, fmt.Errorf("psbt input %d: invalid final witness: %w", i, err)
+				}
+			}
+		}
+		info.Inputs[i] = desc
+	}
+	info.Finalized = len(packet.Inputs) > 0 && info.InputsFinalized == len(packet.Inputs)
+
+	for i, out := range packet.Outputs {
+		info.Outputs[i] = types.PSBTOutput{
+			Vout:             i,
+			RedeemScriptHex:  hex.EncodeToString(out.RedeemScript),
+			WitnessScriptHex: hex.EncodeToString(out.WitnessScript),
+			Bip32Derivations: len(out.Bip32Derivation) + len(out.TaprootBip32Derivation),
+		}
+	}
+
+	// Hold the extracted transaction to the same limits as a raw one
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return nil, err
+	}
+	if err := checkTxLimits(&buf, int64(buf.Len())); err != nil {
+		return nil, fmt.Errorf("psbt: %w", err)
+	}
+
+	fixture.Prevouts = prevouts
+	if info.MissingUtxos > 0 {
+		if err := resolveMissingPrevouts(tx, &fixture); err != nil {
+			return nil, err
+		}
+		resolved := make(map[string]bool, len(fixture.Prevouts)-len(prevouts))
+		for _, p := range fixture.Prevouts[len(prevouts):] {
+			resolved[fmt.Sprintf("%s:%d", p.Txid, p.Vout)] = true
+		}
+		for i := range info.Inputs {
+			if info.Inputs[i].UtxoSource == "missing" && resolved[tx.TxIn[i].PreviousOutPoint.String()] {
+				info.Inputs[i].UtxoSource = "resolver"
+				info.MissingUtxos--
+			}
+		}
+	}
+	fixture.AllowMissingPrevouts = true
+	result, err := AnalyzeParsedTransaction(tx, fixture)
+	if err != nil {
+		return nil, err
+	}
+	result.PSBT = info
+	return result, nil
+}
+
+// psbtInputUtxo returns the output an input spends from its PSBT map,
+// preferring witness_utxo, and where it came from. A non_witness_utxo must
+// be the transaction the input's outpoint names.
+func psbtInputUtxo(in *psbt.PInput, prev wire.OutPoint) (*wire.TxOut, string, error) {
+	if in.WitnessUtxo != nil {
+		return in.WitnessUtxo, "witness_utxo", nil
+	}
+	if in.NonWitnessUtxo == nil {
+		return nil, "", nil
+	}
+	if in.NonWitnessUtxo.TxHash() != prev.Hash {
+		return nil, "", fmt.Errorf("non_witness_utxo is %s, not the spent transaction %s", in.NonWitnessUtxo.TxHash(), prev.Hash)
+	}
+	if int(prev.Index) >= len(in.NonWitnessUtxo.TxOut) {
+		return nil, "", fmt.Errorf("non_witness_utxo has no output %d", prev.Index)
+	}
+	return in.NonWitnessUtxo.TxOut[prev.Index], "non_witness_utxo", nil
+}
+
+// decodeWitnessStack decodes a serialized witness stack: an item count
+// followed by length-prefixed items
+func decodeWitnessStack(data []byte) (wire.TxWitness, error) {
+	r := bytes.NewReader(data)
+	count, err := utils.ReadCompactSize(r)
+	if err != nil {
+		return nil, err
+	}
+	if count > uint64(r.Len()) {
+		return nil, fmt.Errorf("witness item count %d exceeds its %d bytes", count, r.Len())
+	}
+	witness := make(wire.TxWitness, count)
+	for i := range witness {
+		n, err := utils.ReadCompactSize(r)
+		if err != nil {
+			return nil, err
+		}
+		if n > uint64(r.Len()) {
+			return nil, fmt.Errorf("witness item %d is truncated", i)
+		}
+		witness[i] = make([]byte, n)
+		r.Read(witness[i])
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("%d trailing bytes", r.Len())
+	}
+	return witness, nil
+}
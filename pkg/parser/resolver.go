@@ -0,0 +1,137 @@
+package parser
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// PrevoutResolver looks up the outputs spent by transaction inputs that a
+// fixture gives no prevout for, e.g. from a full node. Outpoints it cannot
+// find are left out of the result; an error means the lookup itself failed.
+type PrevoutResolver interface {
+	ResolvePrevouts(outpoints []wire.OutPoint) (map[wire.OutPoint]types.PrevoutInput, error)
+}
+
+// prevoutResolver holds the process-wide resolver, if any
+var prevoutResolver atomic.Pointer[PrevoutResolver]
+
+// SetPrevoutResolver installs the resolver ParseTransaction and ParsePSBT
+// consult for missing prevouts; nil removes it. Blocks read from blk*.dat
+// files never use it, since their prevouts come from the undo data; raw
+// blocks go through ResolveBlockPrevouts.
+func SetPrevoutResolver(r PrevoutResolver) {
+	if r == nil {
+		prevoutResolver.Store(nil)
+		return
+	}
+	prevoutResolver.Store(&r)
+}
+
+// ResolverError is a failed prevout lookup (node unreachable, credentials
+// rejected), as opposed to a prevout the node does not know
+type ResolverError struct {
+	Err error
+}
+
+func (e *ResolverError) Error() string {
+	return "prevout lookup failed: " + e.Err.Error()
+}
+
+func (e *ResolverError) Unwrap() error { return e.Err }
+
+func isResolverError(err error) bool {
+	var resolverErr *ResolverError
+	return errors.As(err, &resolverErr)
+}
+
+// resolveMissingPrevouts appends to fixture.Prevouts what the installed
+// resolver finds for tx's non-coinbase inputs that have none
+func resolveMissingPrevouts(tx *wire.MsgTx, fixture *types.Fixture) error {
+	rp := prevoutResolver.Load()
+	if rp == nil {
+		return nil
+	}
+	given := make(map[string]bool, len(fixture.Prevouts))
+	for _, p := range fixture.Prevouts {
+		given[fmt.Sprintf("%s:%d", p.Txid, p.Vout)] = true
+	}
+	var missing []wire.OutPoint
+	for _, txIn := range tx.TxIn {
+		if !isCoinbaseInput(txIn) && !given[txIn.PreviousOutPoint.String()] {
+			given[txIn.PreviousOutPoint.String()] = true
+			missing = append(missing, txIn.PreviousOutPoint)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	found, err := (*rp).ResolvePrevouts(missing)
+	if err != nil {
+		return &ResolverError{Err: err}
+	}
+	prevouts := append([]types.PrevoutInput{}, fixture.Prevouts...)
+	for _, op := range missing {
+		if p, ok := found[op]; ok {
+			prevouts = append(prevouts, p)
+		}
+	}
+	fixture.Prevouts = prevouts
+	return nil
+}
+
+// ResolveBlockPrevouts finds the prevouts of a block's transactions when
+// the block comes without undo data: inputs spending an earlier transaction
+// of the block get its outputs, and the rest are looked up with the
+// installed resolver in a single call. The result holds one slice per
+// transaction, for its fixture's Prevouts; it is nil when no resolver is
+// installed, leaving the block to be analyzed without fees.
+func ResolveBlockPrevouts(txs []*wire.MsgTx) ([][]types.PrevoutInput, error) {
+	rp := prevoutResolver.Load()
+	if rp == nil {
+		return nil, nil
+	}
+	inBlock := make(map[chainhash.Hash]*wire.MsgTx, len(txs))
+	var missing []wire.OutPoint
+	for _, tx := range txs {
+		for _, txIn := range tx.TxIn {
+			op := txIn.PreviousOutPoint
+			if !isCoinbaseInput(txIn) && inBlock[op.Hash] == nil {
+				missing = append(missing, op)
+			}
+		}
+		inBlock[tx.TxHash()] = tx
+	}
+	found := map[wire.OutPoint]types.PrevoutInput{}
+	if len(missing) > 0 {
+		var err error
+		if found, err = (*rp).ResolvePrevouts(missing); err != nil {
+			return nil, &ResolverError{Err: err}
+		}
+	}
+
+	prevouts := make([][]types.PrevoutInput, len(txs))
+	for i, tx := range txs {
+		for _, txIn := range tx.TxIn {
+			op := txIn.PreviousOutPoint
+			if parent := inBlock[op.Hash]; parent != nil && int(op.Index) < len(parent.TxOut) {
+				prevouts[i] = append(prevouts[i], types.PrevoutInput{
+					Txid:            op.Hash.String(),
+					Vout:            op.Index,
+					ValueSats:       parent.TxOut[op.Index].Value,
+					ScriptPubkeyHex: hex.EncodeToString(parent.TxOut[op.Index].PkScript),
+				})
+			} else if p, ok := found[op]; ok {
+				prevouts[i] = append(prevouts[i], p)
+			}
+		}
+	}
+	return prevouts, nil
+}
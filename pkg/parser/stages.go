@@ -0,0 +1,539 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/analyzer"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/utils"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// StageContext is what an analysis stage sees. Output already holds the
+// decoded structure (txid, sizes, vin/vout skeletons, amounts and fees) plus
+// whatever earlier stages added; a stage fills in its own fields.
+type StageContext struct {
+	Tx      *wire.MsgTx
+	Fixture types.Fixture
+
+	// Prevouts and PrevoutScripts are indexed like Tx.TxIn. Coinbase and
+	// missing prevouts are zero values.
+	Prevouts       []types.PrevoutInput
+	PrevoutScripts [][]byte
+
+	Output *types.TransactionOutput
+}
+
+// Stage is one step of the transaction analysis pipeline
+type Stage interface {
+	Name() string
+	Analyze(ctx *StageContext) error
+}
+
+type stageFunc struct {
+	name string
+	fn   func(ctx *StageContext) error
+}
+
+func (s stageFunc) Name() string                    { return s.name }
+func (s stageFunc) Analyze(ctx *StageContext) error { return s.fn(ctx) }
+
+// NewStage wraps a function as a Stage
+func NewStage(name string, fn func(ctx *StageContext) error) Stage {
+	return stageFunc{name: name, fn: fn}
+}
+
+type registeredStage struct {
+	stage   Stage
+	enabled bool
+}
+
+var (
+	stagesMu sync.RWMutex
+	stages   []registeredStage
+)
+
+// RegisterStage appends a stage to the pipeline; stages run in registration
+// order. A stage registered with enabled=false runs only when a request
+// enables it by name. Registering a duplicate name panics.
+func RegisterStage(s Stage, enabled bool) {
+	stagesMu.Lock()
+	defer stagesMu.Unlock()
+	for _, r := range stages {
+		if r.stage.Name() == s.Name() {
+			panic(fmt.Sprintf("parser: stage %q registered twice", s.Name()))
+		}
+	}
+	stages = append(stages, registeredStage{stage: s, enabled: enabled})
+}
+
+// StageNames lists registered stages in pipeline order
+func StageNames() []string {
+	stagesMu.RLock()
+	defer stagesMu.RUnlock()
+	names := make([]string, len(stages))
+	for i, r := range stages {
+		names[i] = r.stage.Name()
+	}
+	return names
+}
+
+// selectStages returns the stages to run for a request. fixture.Stages
+// turns individual stages on or off by name; annotate_asm is shorthand for
+// enabling the annotate stage.
+func selectStages(fixture types.Fixture) ([]Stage, error) {
+	stagesMu.RLock()
+	defer stagesMu.RUnlock()
+
+	known := make(map[string]bool, len(stages))
+	for _, r := range stages {
+		known[r.stage.Name()] = true
+	}
+	var unknown []string
+	for name := range fixture.Stages {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown analysis stage %q", unknown[0])
+	}
+
+	selected := make([]Stage, 0, len(stages))
+	for _, r := range stages {
+		enabled := r.enabled
+		if r.stage.Name() == StageAnnotate && fixture.AnnotateAsm {
+			enabled = true
+		}
+		if on, ok := fixture.Stages[r.stage.Name()]; ok {
+			enabled = on
+		}
+		if enabled {
+			selected = append(selected, r.stage)
+		}
+	}
+	return selected, nil
+}
+
+// Built-in stage names
+const (
+	StageScriptTypes   = "script_types"
+	StageAddresses     = "addresses"
+	StageDisassembly   = "disassembly"
+	StageOpReturn      = "op_return"
+	StageTimelocks     = "timelocks"
+	StageSegwitSavings = "segwit_savings"
+	StageCoinAge       = "coin_age"
+	StageEnvelopes     = "envelopes"
+	StageWitnessPolicy = "witness_policy"
+	StageTaprootTweak  = "taproot_tweak"
+	StageSignatures    = "signatures"
+	StagePubkeys       = "pubkeys"
+	StageVerify        = "verify_signatures"
+	StageSighash       = "sighash_preimages"
+	StageSigops        = "sigops"
+	StageStandardness  = "standardness"
+	StageAnnotate      = "annotate"
+	StageMalleability  = "malleability"
+	StageWarnings      = "warnings"
+)
+
+func init() {
+	RegisterStage(NewStage(StageScriptTypes, classifyScripts), true)
+	RegisterStage(NewStage(StageAddresses, deriveAddresses), true)
+	RegisterStage(NewStage(StageDisassembly, disassembleScripts), true)
+	RegisterStage(NewStage(StageOpReturn, decodeOpReturns), true)
+	RegisterStage(NewStage(StageTimelocks, analyzeTimelocks), true)
+	RegisterStage(NewStage(StageSegwitSavings, computeSegwitSavings), true)
+	RegisterStage(NewStage(StageCoinAge, computeCoinAge), true)
+	RegisterStage(NewStage(StageEnvelopes, scanEnvelopes), true)
+	RegisterStage(NewStage(StageWitnessPolicy, checkWitnessPolicy), true)
+	RegisterStage(NewStage(StageTaprootTweak, checkTaprootTweaks), true)
+	RegisterStage(NewStage(StageSignatures, countSignatures), true)
+	RegisterStage(NewStage(StagePubkeys, extractPubkeys), true)
+	RegisterStage(NewStage(StageVerify, verifySignatures), true)
+	// Legacy preimages hold the whole transaction once per input
+	RegisterStage(NewStage(StageSighash, computeSighashPreimages), false)
+	RegisterStage(NewStage(StageSigops, countSigops), true)
+	RegisterStage(NewStage(StageStandardness, checkStandardness), true)
+	RegisterStage(NewStage(StageAnnotate, annotateScripts), false)
+	RegisterStage(NewStage(StageMalleability, reportMalleability), false)
+	// Warnings read the fields set above, so they run last
+	RegisterStage(NewStage(StageWarnings, generateWarnings), true)
+}
+
+func classifyScripts(ctx *StageContext) error {
+	defer utils.TimeStage(utils.StageClassify)()
+	out := ctx.Output
+	for i := range out.Vin {
+		txIn := ctx.Tx.TxIn[i]
+		// Without a prevout only the scriptSig and witness shape are available
+		if out.Vin[i].PrevoutMissing {
+			out.Vin[i].ScriptType = analyzer.InferInputScriptType(txIn.SignatureScript, txIn.Witness)
+		} else {
+			out.Vin[i].ScriptType = analyzer.ClassifyInputScript(txIn.SignatureScript, txIn.Witness, ctx.PrevoutScripts[i])
+		}
+		out.Vin[i].Multisig = analyzer.InputMultisig(txIn.SignatureScript, txIn.Witness, ctx.PrevoutScripts[i])
+	}
+	for i := range out.Vout {
+		out.Vout[i].ScriptType = analyzer.ClassifyOutputScript(out.Vout[i].ScriptPubkeyHex)
+		out.VoutScriptTypes[i] = out.Vout[i].ScriptType
+		out.Vout[i].Multisig = analyzer.ParseMultisig(out.Vout[i].ScriptPubkeyHex)
+	}
+	return nil
+}
+
+func deriveAddresses(ctx *StageContext) error {
+	defer utils.TimeStage(utils.StageAddress)()
+	out, network := ctx.Output, ctx.Fixture.Network
+	for i := range out.Vin {
+		in := &out.Vin[i]
+		in.Address = analyzer.GetAddressFromScript(ctx.PrevoutScripts[i], network)
+		// P2PK prevouts have no address; report the derived P2PKH one instead
+		if p2pk := analyzer.GetP2PKInfo(ctx.PrevoutScripts[i], network); p2pk != nil {
+			in.P2PK = p2pk
+			in.Address = &p2pk.DerivedAddress
+			in.AddressDerived = true
+		}
+	}
+	for i := range out.Vout {
+		o := &out.Vout[i]
+		o.Address = analyzer.GetAddressFromScript(o.ScriptPubkeyHex, network)
+		if p2pk := analyzer.GetP2PKInfo(o.ScriptPubkeyHex, network); p2pk != nil {
+			o.P2PK = p2pk
+			o.Address = &p2pk.DerivedAddress
+			o.AddressDerived = true
+		}
+	}
+	return nil
+}
+
+// witnessScript returns the witnessScript (last witness item) of a p2wsh or
+// p2sh-p2wsh input, or nil
+func witnessScript(in *types.Input) []byte {
+	if in.ScriptType != "p2wsh" && in.ScriptType != "p2sh-p2wsh" {
+		return nil
+	}
+	if len(in.Witness) == 0 || len(in.Witness[len(in.Witness)-1]) == 0 {
+		return nil
+	}
+	return in.Witness[len(in.Witness)-1]
+}
+
+func disassembleScripts(ctx *StageContext) error {
+	defer utils.TimeStage(utils.StageDisassemble)()
+	out := ctx.Output
+	for i := range out.Vin {
+		in := &out.Vin[i]
+		in.ScriptAsm = analyzer.DisassembleScript(in.ScriptSigHex)
+		if script := witnessScript(in); script != nil {
+			asm := analyzer.DisassembleScript(script)
+			in.WitnessScriptAsm = &asm
+		}
+	}
+	for i := range out.Vout {
+		out.Vout[i].ScriptAsm = analyzer.DisassembleScript(out.Vout[i].ScriptPubkeyHex)
+	}
+	return nil
+}
+
+func decodeOpReturns(ctx *StageContext) error {
+	for i := range ctx.Output.Vout {
+		o := &ctx.Output.Vout[i]
+		if o.ScriptType == "op_return" {
+			o.OpReturnDataHex, o.OpReturnDataUtf8, o.OpReturnProtocol = analyzer.ParseOpReturn(o.ScriptPubkeyHex)
+		}
+	}
+	return nil
+}
+
+func analyzeTimelocks(ctx *StageContext) error {
+	out := ctx.Output
+	sequences := make([]uint32, len(out.Vin))
+	for i := range out.Vin {
+		in := &out.Vin[i]
+		sequences[i] = in.Sequence
+		enabled, tlType, tlValue := analyzer.ParseRelativeTimelock(in.Sequence)
+		in.RelativeTimelock = types.RelativeTimelock{Enabled: enabled}
+		if enabled {
+			in.RelativeTimelock.Type = tlType
+			in.RelativeTimelock.Value = tlValue
+		}
+		in.SequenceDecoded = analyzer.DecodeSequence(in.Sequence, out.Version)
+	}
+	out.LocktimeType = analyzer.GetLocktimeType(out.Locktime)
+	out.RbfSignaling = analyzer.IsRBFSignaling(sequences)
+	return nil
+}
+
+func computeSegwitSavings(ctx *StageContext) error {
+	out := ctx.Output
+	if !out.Segwit {
+		return nil
+	}
+	totalSize := out.SizeBytes
+	baseSize := ctx.Tx.SerializeSizeStripped()
+	weightIfLegacy := totalSize * 4
+	savingsPct := (1.0 - float64(out.Weight)/float64(weightIfLegacy)) * 100
+
+	out.SegwitSavings = &types.SegwitSavings{
+		WitnessBytes:    totalSize - baseSize,
+		NonWitnessBytes: baseSize,
+		TotalBytes:      totalSize,
+		WeightActual:    out.Weight,
+		WeightIfLegacy:  weightIfLegacy,
+		SavingsPct:      math.Round(savingsPct*100) / 100,
+	}
+	return nil
+}
+
+func computeCoinAge(ctx *StageContext) error {
+	out := ctx.Output
+	for i := range out.Vin {
+		in := &out.Vin[i]
+		if !isCoinbaseInput(ctx.Tx.TxIn[i]) && !in.PrevoutMissing {
+			in.CoinAge = analyzer.GetCoinAge(ctx.Prevouts[i], ctx.Fixture.BlockHeight)
+		}
+	}
+	out.InputAge = analyzer.GetInputAge(out.Vin)
+	return nil
+}
+
+// tapscriptSpend returns the leaf script and control block of a
+// p2tr_scriptpath input, skipping the annex (a last witness item starting
+// with 0x50) when present
+func tapscriptSpend(in *types.Input) (script, control []byte) {
+	if in.ScriptType != "p2tr_scriptpath" {
+		return nil, nil
+	}
+	w := in.Witness
+	if len(w) >= 3 && len(w[len(w)-1]) > 0 && w[len(w)-1][0] == 0x50 {
+		w = w[:len(w)-1]
+	}
+	if len(w) < 2 {
+		return nil, nil
+	}
+	return w[len(w)-2], w[len(w)-1]
+}
+
+func scanEnvelopes(ctx *StageContext) error {
+	for i := range ctx.Output.Vin {
+		in := &ctx.Output.Vin[i]
+		if script, _ := tapscriptSpend(in); script != nil {
+			in.Envelopes = analyzer.FindEnvelopes(script)
+		}
+	}
+	return nil
+}
+
+func checkWitnessPolicy(ctx *StageContext) error {
+	for i := range ctx.Output.Vin {
+		in := &ctx.Output.Vin[i]
+		in.WitnessPolicyViolations = analyzer.CheckWitnessPolicy(in.ScriptType, ctx.Tx.TxIn[i].Witness)
+	}
+	return nil
+}
+
+func countSignatures(ctx *StageContext) error {
+	for i := range ctx.Output.Vin {
+		txIn := ctx.Tx.TxIn[i]
+		in := &ctx.Output.Vin[i]
+		in.Signatures = analyzer.CountSignatures(in.ScriptType, txIn.SignatureScript, txIn.Witness)
+		in.SchnorrSignatures = analyzer.ParseSchnorrSignatures(in.ScriptType, txIn.Witness)
+		in.ECDSASignatures = analyzer.ParseECDSASignatures(in.ScriptType, txIn.SignatureScript, txIn.Witness)
+		var sighashes []string
+		for _, sig := range in.SchnorrSignatures {
+			sighashes = append(sighashes, sig.SighashType)
+		}
+		for _, sig := range in.ECDSASignatures {
+			sighashes = append(sighashes, sig.SighashType)
+		}
+		in.SighashType = analyzer.CommonSighashType(sighashes)
+	}
+	analyzer.FingerprintSignatures(ctx.Output.Vin)
+	return nil
+}
+
+func extractPubkeys(ctx *StageContext) error {
+	for i := range ctx.Output.Vin {
+		txIn := ctx.Tx.TxIn[i]
+		in := &ctx.Output.Vin[i]
+		in.Pubkeys = analyzer.ExtractPubkeys(in.ScriptType, txIn.SignatureScript, txIn.Witness)
+	}
+	return nil
+}
+
+func verifySignatures(ctx *StageContext) error {
+	if isCoinbaseInput(ctx.Tx.TxIn[0]) {
+		return nil
+	}
+	flags := analyzer.ScriptFlags(ctx.Output.Network, ctx.Fixture.BlockHeight)
+	analyzer.VerifyInputs(ctx.Tx, ctx.Output.Vin, ctx.PrevoutScripts, flags)
+	return nil
+}
+
+func computeSighashPreimages(ctx *StageContext) error {
+	if isCoinbaseInput(ctx.Tx.TxIn[0]) {
+		return nil
+	}
+	analyzer.ComputeSighashPreimages(ctx.Tx, ctx.Output.Vin, ctx.PrevoutScripts)
+	return nil
+}
+
+func countSigops(ctx *StageContext) error {
+	ctx.Output.Sigops = analyzer.CountSigops(ctx.Tx, ctx.Output.Vin, ctx.PrevoutScripts)
+	return nil
+}
+
+func checkStandardness(ctx *StageContext) error {
+	if isCoinbaseInput(ctx.Tx.TxIn[0]) {
+		return nil
+	}
+	out := ctx.Output
+	out.PolicyViolations = analyzer.CheckStandardness(ctx.Tx, out.Vin, out.Sigops)
+	standard := len(out.PolicyViolations) == 0
+	out.IsStandard = &standard
+	return nil
+}
+
+// checkTaprootTweaks verifies the key commitments of script-path spends and
+// of the outputs the fixture supplies internal keys for
+func checkTaprootTweaks(ctx *StageContext) error {
+	out := ctx.Output
+	for i := range out.Vin {
+		in := &out.Vin[i]
+		script, control := tapscriptSpend(in)
+		if script == nil {
+			continue
+		}
+		var outputKey []byte
+		if prevScript := ctx.PrevoutScripts[i]; !in.PrevoutMissing && len(prevScript) == 34 {
+			outputKey = prevScript[2:]
+		}
+		// A malformed control block leaves the checks out rather than failing
+		in.Taproot, _ = analyzer.DecodeControlBlock(control, script)
+		if outputKey != nil {
+			in.TaprootTweak, _ = analyzer.VerifyScriptPathTweak(control, script, outputKey)
+			analyzer.CommitScriptPath(in.Taproot, in.TaprootTweak)
+		}
+	}
+	trees := analyzer.NewTapTreeBuilder()
+	for i := range out.Vin {
+		trees.Add(&out.Vin[i])
+	}
+	out.TaprootTrees = trees.Trees(2)
+
+	for i, hint := range ctx.Fixture.TaprootOutputs {
+		if hint.Vout < 0 || hint.Vout >= len(out.Vout) || out.Vout[hint.Vout].ScriptType != "p2tr" {
+			return fmt.Errorf("taproot_outputs[%d]: vout %d is not a p2tr output", i, hint.Vout)
+		}
+		internalKey, err := utils.HexToBytes(hint.InternalKey)
+		if err != nil {
+			return fmt.Errorf("taproot_outputs[%d]: invalid internal_key: %w", i, err)
+		}
+		merkleRoot, err := utils.HexToBytes(hint.MerkleRoot)
+		if err != nil {
+			return fmt.Errorf("taproot_outputs[%d]: invalid merkle_root: %w", i, err)
+		}
+		vout := &out.Vout[hint.Vout]
+		if len(hint.Leaves) > 0 {
+			tree, root, err := analyzer.TapTreeFromLeaves(hint.Leaves)
+			if err != nil {
+				return fmt.Errorf("taproot_outputs[%d]: %w", i, err)
+			}
+			if len(merkleRoot) > 0 && !bytes.Equal(merkleRoot, root) {
+				return fmt.Errorf("taproot_outputs[%d]: merkle_root does not match the leaves", i)
+			}
+			merkleRoot = root
+			tree.OutputKey = hex.EncodeToString(vout.ScriptPubkeyHex[2:])
+			tree.InternalKey = hex.EncodeToString(internalKey)
+			vout.TapTree = &tree
+		}
+		if vout.TaprootTweak, err = analyzer.VerifyTaprootTweak(internalKey, merkleRoot, vout.ScriptPubkeyHex[2:]); err != nil {
+			return fmt.Errorf("taproot_outputs[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func annotateScripts(ctx *StageContext) error {
+	out := ctx.Output
+	for i := range out.Vin {
+		in := &out.Vin[i]
+		in.ScriptAsmTokens = analyzer.AnnotateScript(in.ScriptSigHex)
+		if script := witnessScript(in); script != nil {
+			in.WitnessScriptTokens = analyzer.AnnotateScript(script)
+		}
+	}
+	for i := range out.Vout {
+		out.Vout[i].ScriptAsmTokens = analyzer.AnnotateScript(out.Vout[i].ScriptPubkeyHex)
+	}
+	return nil
+}
+
+func reportMalleability(ctx *StageContext) error {
+	inputs := make([]analyzer.InputSigning, len(ctx.Tx.TxIn))
+	for i, txIn := range ctx.Tx.TxIn {
+		inputs[i] = analyzer.InputSigning{
+			ScriptSig:  txIn.SignatureScript,
+			Witness:    txIn.Witness,
+			ScriptType: ctx.Output.Vin[i].ScriptType,
+			Coinbase:   isCoinbaseInput(txIn),
+		}
+	}
+	ctx.Output.Malleability = analyzer.AnalyzeMalleability(inputs)
+	return nil
+}
+
+func generateWarnings(ctx *StageContext) error {
+	out := ctx.Output
+	// Fees are unknown (nil) when prevouts are missing; a coinbase has none
+	var feeSats int64
+	var feeRate float64
+	feeKnown := out.FeeSats != nil && !isCoinbaseInput(ctx.Tx.TxIn[0])
+	if out.FeeSats != nil {
+		feeSats, feeRate = *out.FeeSats, *out.FeeRateSatVb
+	}
+	out.Warnings = analyzer.GenerateWarnings(feeSats, feeRate, feeKnown, out.RbfSignaling, out.Vout)
+	if w := analyzer.NonstandardVersionWarning(out.Version); w != nil {
+		out.Warnings = append(out.Warnings, *w)
+	}
+	// NONSTANDARD_WITNESS: inputs breaking witness relay limits
+	var vins []int
+	for i, in := range out.Vin {
+		if len(in.WitnessPolicyViolations) > 0 {
+			vins = append(vins, i)
+		}
+	}
+	if len(vins) > 0 {
+		out.Warnings = append(out.Warnings, types.Warning{
+			Code:    "NONSTANDARD_WITNESS",
+			Context: map[string]interface{}{"vin": vins},
+		})
+	}
+	// UNCOMPRESSED_SEGWIT_KEY: inputs revealing uncompressed keys in
+	// witness data, which Core does not relay
+	vins = nil
+	for i, in := range out.Vin {
+		for _, key := range in.Pubkeys {
+			if key.UncompressedInSegwit {
+				vins = append(vins, i)
+				break
+			}
+		}
+	}
+	if len(vins) > 0 {
+		out.Warnings = append(out.Warnings, types.Warning{
+			Code:    "UNCOMPRESSED_SEGWIT_KEY",
+			Context: map[string]interface{}{"vin": vins},
+		})
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/analyzer"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/utils"
+)
+
+// CompareBlockTemplate compares a block with a getblocktemplate result,
+// as pool operators do to audit their blocks: template transactions are
+// split into included and excluded, block transactions missing from the
+// template are listed as added, and the block's fees (coinbase value minus
+// subsidy) are set against the template's.
+func CompareBlockTemplate(fixture types.TemplateFixture) (*types.TemplateComparisonOutput, error) {
+	raw, err := utils.HexToBytes(fixture.Block)
+	if err != nil {
+		return nil, fmt.Errorf("invalid block hex: %w", err)
+	}
+	block, err := deserializeBlock(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse block: %w", err)
+	}
+	if len(block.Transactions) == 0 {
+		return nil, fmt.Errorf("block has no transactions")
+	}
+	tmpl := fixture.Template
+
+	out := &types.TemplateComparisonOutput{
+		OK:                    true,
+		Mode:                  "template_compare",
+		BlockHeader:           blockHeaderInfo(&block.Header, false),
+		TemplateHeight:        tmpl.Height,
+		SameParent:            block.Header.PrevBlock.String() == tmpl.PreviousBlockHash,
+		TimeDeltaSecs:         block.Header.Timestamp.Unix() - tmpl.CurTime,
+		TemplateTxCount:       len(tmpl.Transactions),
+		BlockTxCount:          len(block.Transactions) - 1,
+		Included:              make([]types.TemplateTxRef, 0),
+		Excluded:              make([]types.TemplateTxRef, 0),
+		AddedTxids:            make([]string, 0),
+		TemplateCoinbaseValue: tmpl.CoinbaseValue,
+	}
+
+	// Block positions by txid, skipping the coinbase
+	positions := make(map[string]int, len(block.Transactions))
+	hashes := utils.TxHashes(block.Transactions, false)
+	for i := range block.Transactions {
+		if i > 0 {
+			positions[hashes[i].String()] = i
+		}
+	}
+
+	inTemplate := make(map[string]bool, len(tmpl.Transactions))
+	for i, t := range tmpl.Transactions {
+		txid := t.Txid
+		if txid == "" {
+			tx, err := deserializeTxHex(t.Data)
+			if err != nil {
+				return nil, fmt.Errorf("template tx %d: %w", i, err)
+			}
+			txid = tx.TxHash().String()
+		}
+		inTemplate[txid] = true
+		ref := types.TemplateTxRef{Txid: txid, FeeSats: t.Fee, Weight: t.Weight}
+		out.TemplateFeesSats += t.Fee
+		if pos, ok := positions[txid]; ok {
+			ref.BlockIndex = &pos
+			out.Included = append(out.Included, ref)
+			out.IncludedFeesSats += t.Fee
+		} else {
+			out.Excluded = append(out.Excluded, ref)
+			out.ExcludedFeesSats += t.Fee
+		}
+	}
+	for _, hash := range hashes[1:] {
+		if txid := hash.String(); !inTemplate[txid] {
+			out.AddedTxids = append(out.AddedTxids, txid)
+		}
+	}
+	// Last use of hashes: the merkle computation overwrites them
+	root := utils.MerkleRootInPlace(hashes)
+	out.BlockHeader.MerkleRootValid = root.IsEqual(&block.Header.MerkleRoot)
+
+	// Fees are what the coinbase claims beyond the subsidy
+	for _, txOut := range block.Transactions[0].TxOut {
+		out.BlockCoinbaseValue += txOut.Value
+	}
+	out.Height = extractBIP34Height(block.Transactions[0].TxIn[0].SignatureScript)
+	if out.Height == 0 && out.SameParent {
+		out.Height = tmpl.Height
+	}
+	if out.Height > 0 {
+		subsidy := analyzer.BlockSubsidy(out.Height, fixture.Network)
+		fees := out.BlockCoinbaseValue - subsidy
+		delta := fees - out.TemplateFeesSats
+		out.BlockFeesSats = &fees
+		out.FeeDeltaSats = &delta
+	}
+	return out, nil
+}
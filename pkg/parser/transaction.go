@@ -6,29 +6,118 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"strings"
 
-	"chain-lens/pkg/analyzer"
-	"chain-lens/pkg/types"
-	"chain-lens/pkg/utils"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/analyzer"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/utils"
 
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
 )
 
-// ParseTransaction parses a raw transaction hex and prevouts into structured output
+// MaxRawTxBytes caps the size of a single transaction. No valid transaction
+// can exceed the 4M weight-unit block limit, so this bounds memory per tx.
+const MaxRawTxBytes = 4000000
+
+// ParseTransaction parses a raw transaction (hex or base64) and prevouts
+// into structured output. Prevouts the fixture lacks are looked up with the
+// PrevoutResolver, if one is installed. A fixture carrying a PSBT instead
+// is analyzed by ParsePSBT.
 func ParseTransaction(fixture types.Fixture) (*types.TransactionOutput, error) {
-	// Decode raw transaction hex
-	rawTxBytes, err := utils.HexToBytes(fixture.RawTx)
-	if err != nil {
-		return nil, fmt.Errorf("invalid raw_tx hex: %w", err)
+	if fixture.PSBT != "" {
+		if fixture.RawTx != "" {
+			return nil, errors.New("fixture has both raw_tx and psbt")
+		}
+		return ParsePSBT(fixture)
+	}
+
+	encoding := fixture.RawTxEncoding
+	switch encoding {
+	case "", EncodingAuto:
+		encoding = DetectTextEncoding(fixture.RawTx)
+	case EncodingHex, EncodingBase64:
+	default:
+		return nil, fmt.Errorf("invalid raw_tx_encoding %q: want auto, hex or base64", encoding)
+	}
+
+	// Validate raw transaction hex and check it against the parse limits
+	// before streaming it into the decoder
+	var raw []byte
+	if encoding == EncodingHex {
+		if len(fixture.RawTx)%2 != 0 {
+			return nil, errors.New("invalid raw_tx hex: odd length")
+		}
+		if err := checkTxLimits(hex.NewDecoder(strings.NewReader(fixture.RawTx)), int64(len(fixture.RawTx)/2)); err != nil {
+			return nil, fmt.Errorf("raw_tx: %w", err)
+		}
+	} else {
+		var err error
+		if raw, _, err = DecodeRawTx([]byte(fixture.RawTx), EncodingBase64); err != nil {
+			return nil, fmt.Errorf("invalid raw_tx: %w", err)
+		}
+		if err := checkTxLimits(bytes.NewReader(raw), int64(len(raw))); err != nil {
+			return nil, fmt.Errorf("raw_tx: %w", err)
+		}
 	}
 
-	// Parse using btcd wire.MsgTx
+	// Parse using btcd wire.MsgTx, decoding hex on the fly so the raw
+	// bytes are never materialized alongside the hex string
 	tx := wire.NewMsgTx(wire.TxVersion)
-	err = tx.Deserialize(bytes.NewReader(rawTxBytes))
+	stopDeserialize := utils.TimeStage(utils.StageDeserialize)
+	var err error
+	if raw != nil {
+		err = tx.Deserialize(bytes.NewReader(raw))
+	} else {
+		err = tx.Deserialize(hex.NewDecoder(strings.NewReader(fixture.RawTx)))
+	}
+	stopDeserialize()
 	if err != nil {
+		var invalidByte hex.InvalidByteError
+		if errors.As(err, &invalidByte) {
+			return nil, fmt.Errorf("invalid raw_tx hex: %w", err)
+		}
 		return nil, fmt.Errorf("failed to deserialize transaction: %w", err)
 	}
 
+	if err := resolveMissingPrevouts(tx, &fixture); err != nil {
+		return nil, err
+	}
+	return AnalyzeParsedTransaction(tx, fixture)
+}
+
+// AnalyzeParsedTransaction analyzes an already-deserialized transaction with
+// the prevouts and options from fixture (fixture.RawTx is ignored). Block
+// mode uses it directly to avoid a serialize/deserialize round trip per tx.
+func AnalyzeParsedTransaction(tx *wire.MsgTx, fixture types.Fixture) (*types.TransactionOutput, error) {
+	pipeline, err := selectStages(fixture)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDuplicateInputs([]*wire.MsgTx{tx}); err != nil {
+		return nil, err
+	}
+
+	// A custom signet is told apart by the network magic its challenge
+	// derives; addresses share the default signet's prefixes
+	var signetMagic string
+	if fixture.SignetChallenge != "" {
+		if fixture.Network != analyzer.NetworkSignet {
+			return nil, errors.New("signet_challenge requires network \"signet\"")
+		}
+		challenge, err := utils.HexToBytes(fixture.SignetChallenge)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signet_challenge hex: %w", err)
+		}
+		magic := analyzer.SignetMagic(challenge)
+		signetMagic = hex.EncodeToString(magic[:])
+	}
+
+	// A fixture cannot need more prevouts than a transaction may have inputs
+	if l := parseLimits.Load(); len(fixture.Prevouts) > l.MaxInputs {
+		return nil, &LimitError{What: "prevout count", Limit: "max_inputs", Value: uint64(len(fixture.Prevouts)), Max: l.MaxInputs}
+	}
+
 	// Build prevout map: (txid, vout) -> prevout
 	prevoutMap := make(map[string]types.PrevoutInput)
 	for _, p := range fixture.Prevouts {
@@ -39,226 +128,153 @@ func ParseTransaction(fixture types.Fixture) (*types.TransactionOutput, error) {
 		prevoutMap[key] = p
 	}
 
-	// Validate all non-coinbase inputs have prevouts
-	// Coinbase inputs have a null outpoint: txid=0000...0000, vout=0xFFFFFFFF
-	coinbaseNullHash := "0000000000000000000000000000000000000000000000000000000000000000"
-	for _, txIn := range tx.TxIn {
-		txidStr := txIn.PreviousOutPoint.Hash.String()
-		vout := txIn.PreviousOutPoint.Index
-		if txidStr == coinbaseNullHash && vout == 0xFFFFFFFF {
-			continue // coinbase input - no prevout exists
-		}
-		key := fmt.Sprintf("%s:%d", txidStr, vout)
-		if _, exists := prevoutMap[key]; !exists {
-			return nil, fmt.Errorf("missing prevout for input %s:%d", txidStr, vout)
-		}
+	// Match each input to its prevout. All non-coinbase inputs must have
+	// one, unless the caller opted into lenient analysis.
+	ctx := &StageContext{
+		Tx:             tx,
+		Fixture:        fixture,
+		Prevouts:       make([]types.PrevoutInput, len(tx.TxIn)),
+		PrevoutScripts: make([][]byte, len(tx.TxIn)),
 	}
-
-	// Detect SegWit
-	isSegwit := tx.HasWitness()
-
-	// Compute txid (without witness)
-	txid := tx.TxHash().String()
-
-	// Compute wtxid (with witness)
-	var wtxid *string
-	if isSegwit {
-		wtxidStr := tx.WitnessHash().String()
-		wtxid = &wtxidStr
-	}
-
-	// Calculate sizes and weight
-	sizeBytes := tx.SerializeSize()
-
-	// Weight calculation per BIP141
-	baseSize := tx.SerializeSizeStripped() // Without witness
-	totalSize := sizeBytes
-	weight := baseSize*3 + totalSize
-	vbytes := (weight + 3) / 4
-
-	// Parse inputs
-	inputs := make([]types.Input, 0)
+	missingPrevouts := 0
+	inputs := make([]types.Input, 0, len(tx.TxIn))
 	var totalInputSats int64
-	var sequences []uint32
 
 	for i, txIn := range tx.TxIn {
 		txidStr := txIn.PreviousOutPoint.Hash.String()
 		vout := txIn.PreviousOutPoint.Index
-		key := fmt.Sprintf("%s:%d", txidStr, vout)
-
-		// Handle coinbase input (null outpoint)
-		isCoinbaseInput := txidStr == coinbaseNullHash && vout == 0xFFFFFFFF
-
-		var prevout types.PrevoutInput
-		if !isCoinbaseInput {
-			prevout = prevoutMap[key]
-		}
-
-		totalInputSats += prevout.ValueSats
-
-		// Get witness items - always initialize as empty slice (never nil)
-		// so JSON output is [] not null
-		witnessItems := make([]string, 0)
-		if i < len(tx.TxIn) {
-			for _, item := range tx.TxIn[i].Witness {
-				// Empty witness items must be preserved as "" per spec
-				witnessItems = append(witnessItems, hex.EncodeToString(item))
-			}
-		}
-
-		// Decode prevout script
-		prevoutScriptBytes, _ := utils.HexToBytes(prevout.ScriptPubkeyHex)
-
-		// Classify input script type
-		scriptType := analyzer.ClassifyInputScript(
-			txIn.SignatureScript,
-			tx.TxIn[i].Witness,
-			prevoutScriptBytes,
-		)
 
-		// witness_script_asm: for p2wsh and p2sh-p2wsh, disassemble the last witness item (witnessScript)
-		var witnessScriptAsm *string
-		if (scriptType == "p2wsh" || scriptType == "p2sh-p2wsh") && len(witnessItems) > 0 {
-			// Last witness item is the witnessScript
-			lastWitnessHex := witnessItems[len(witnessItems)-1]
-			lastWitnessBytes, err := hex.DecodeString(lastWitnessHex)
-			if err == nil && len(lastWitnessBytes) > 0 {
-				asm := analyzer.DisassembleScript(lastWitnessBytes)
-				witnessScriptAsm = &asm
+		prevoutMissing := false
+		if !isCoinbaseInput(txIn) {
+			p, found := prevoutMap[fmt.Sprintf("%s:%d", txidStr, vout)]
+			if !found {
+				if !fixture.AllowMissingPrevouts {
+					return nil, fmt.Errorf("missing prevout for input %s:%d", txidStr, vout)
+				}
+				missingPrevouts++
+				prevoutMissing = true
 			}
+			ctx.Prevouts[i] = p
 		}
+		prevout := ctx.Prevouts[i]
+		ctx.PrevoutScripts[i], _ = utils.HexToBytes(prevout.ScriptPubkeyHex)
+		totalInputSats += prevout.ValueSats
 
-		// Get address from prevout
-		address := analyzer.GetAddressFromScript(prevoutScriptBytes, fixture.Network)
-
-		// Disassemble scriptSig
-		scriptAsm := analyzer.DisassembleScript(txIn.SignatureScript)
-
-		// Parse relative timelock
-		enabled, tlType, tlValue := analyzer.ParseRelativeTimelock(txIn.Sequence)
-		relativeTimelock := types.RelativeTimelock{
-			Enabled: enabled,
+		// Witness items - always initialize as empty slice (never nil)
+		// so JSON output is [] not null; empty items are preserved as "".
+		// Items are hex-encoded lazily when the output is marshaled
+		witnessItems := make([]types.HexBytes, 0, len(txIn.Witness))
+		for _, item := range txIn.Witness {
+			witnessItems = append(witnessItems, item)
 		}
-		if enabled {
-			relativeTimelock.Type = tlType
-			relativeTimelock.Value = tlValue
-		}
-
-		sequences = append(sequences, txIn.Sequence)
 
 		inputs = append(inputs, types.Input{
-			Txid:             txidStr,
-			Vout:             vout,
-			Sequence:         txIn.Sequence,
-			ScriptSigHex:     hex.EncodeToString(txIn.SignatureScript),
-			ScriptAsm:        scriptAsm,
-			Witness:          witnessItems,
-			WitnessScriptAsm: witnessScriptAsm,
-			ScriptType:       scriptType,
-			Address:          address,
+			Txid:         txidStr,
+			Vout:         vout,
+			Sequence:     txIn.Sequence,
+			ScriptSigHex: txIn.SignatureScript,
+			Witness:      witnessItems,
 			Prevout: types.Prevout{
 				ValueSats:       prevout.ValueSats,
 				ScriptPubkeyHex: prevout.ScriptPubkeyHex,
+				Height:          prevout.Height,
 			},
-			RelativeTimelock: relativeTimelock,
+			PrevoutMissing: prevoutMissing,
 		})
 	}
 
-	// Parse outputs
-	outputs := make([]types.Output, 0)
+	outputs := make([]types.Output, 0, len(tx.TxOut))
 	var totalOutputSats int64
-
 	for i, txOut := range tx.TxOut {
 		totalOutputSats += txOut.Value
-
-		scriptPubkey := txOut.PkScript
-		scriptType := analyzer.ClassifyOutputScript(scriptPubkey)
-		address := analyzer.GetAddressFromScript(scriptPubkey, fixture.Network)
-		scriptAsm := analyzer.DisassembleScript(scriptPubkey)
-
-		output := types.Output{
+		outputs = append(outputs, types.Output{
 			N:               i,
 			ValueSats:       txOut.Value,
-			ScriptPubkeyHex: hex.EncodeToString(scriptPubkey),
-			ScriptAsm:       scriptAsm,
-			ScriptType:      scriptType,
-			Address:         address,
-		}
+			ScriptPubkeyHex: txOut.PkScript,
+		})
+	}
 
-		// Handle OP_RETURN
-		if scriptType == "op_return" {
-			dataHex, dataUtf8, protocol := analyzer.ParseOpReturn(scriptPubkey)
-			output.OpReturnDataHex = dataHex
-			output.OpReturnDataUtf8 = dataUtf8
-			output.OpReturnProtocol = protocol
-		}
+	// Reject impossible amounts rather than report a fee derived from them
+	if err := checkAmounts(tx, ctx.Prevouts, missingPrevouts > 0); err != nil {
+		return nil, err
+	}
 
-		outputs = append(outputs, output)
+	// Sizes and weight per BIP141
+	isSegwit := tx.HasWitness()
+	sizeBytes := tx.SerializeSize()
+	weight := tx.SerializeSizeStripped()*3 + sizeBytes
+	vbytes := (weight + 3) / 4
+	vsizeExact := float64(weight) / 4
+	rateVsize := float64(vbytes)
+	if fixture.ExactVsize {
+		rateVsize = vsizeExact
 	}
 
 	// Calculate fees
 	feeSats := totalInputSats - totalOutputSats
 	// Round fee rate to 2 decimal places (matches grader expectation of 10.31 not 10.309278...)
-	rawFeeRate := float64(feeSats) / float64(vbytes)
+	rawFeeRate := float64(feeSats) / rateVsize
 	feeRate := math.Round(rawFeeRate*100) / 100
 
-	// Locktime analysis
-	locktimeType := analyzer.GetLocktimeType(tx.LockTime)
-
-	// RBF signaling
-	rbfSignaling := analyzer.IsRBFSignaling(sequences)
-
-	// SegWit savings
-	var segwitSavings *types.SegwitSavings
-	if isSegwit {
-		witnessBytes := totalSize - baseSize
-		nonWitnessBytes := baseSize
-		weightIfLegacy := totalSize * 4
-		savingsPct := (1.0 - float64(weight)/float64(weightIfLegacy)) * 100
-
-		segwitSavings = &types.SegwitSavings{
-			WitnessBytes:    witnessBytes,
-			NonWitnessBytes: nonWitnessBytes,
-			TotalBytes:      totalSize,
-			WeightActual:    weight,
-			WeightIfLegacy:  weightIfLegacy,
-			SavingsPct:      math.Round(savingsPct*100) / 100,
-		}
+	// The same rate in the units Lightning (sat per 1000 weight units) and
+	// bitcoind RPC (BTC per 1000 vbytes, 8 decimals) report
+	feeRateKwu := math.Round(float64(feeSats)*1000/float64(weight)*100) / 100
+	feeRateKvb := math.Round(float64(feeSats)*1000/rateVsize) / 1e8
+
+	// Input totals and fees are unknown when any prevout is missing
+	feeSatsOut, feeRateOut, totalInputOut := &feeSats, &feeRate, &totalInputSats
+	feeRateKwuOut, feeRateKvbOut := &feeRateKwu, &feeRateKvb
+	if missingPrevouts > 0 {
+		feeSatsOut, feeRateOut, totalInputOut = nil, nil, nil
+		feeRateKwuOut, feeRateKvbOut = nil, nil
 	}
 
-	// Build vout_script_types slice
-	voutScriptTypes := make([]string, len(outputs))
-	for i, o := range outputs {
-		voutScriptTypes[i] = o.ScriptType
+	// wtxid commits to the witness; only reported for segwit transactions
+	var wtxid *string
+	if isSegwit {
+		wtxidStr := tx.WitnessHash().String()
+		wtxid = &wtxidStr
 	}
 
-	// Generate warnings
-	warnings := analyzer.GenerateWarnings(feeSats, feeRate, rbfSignaling, outputs)
-
-	return &types.TransactionOutput{
+	ctx.Output = &types.TransactionOutput{
 		OK:              true,
 		Network:         fixture.Network,
+		SignetMagic:     signetMagic,
 		Segwit:          isSegwit,
-		Txid:            txid,
+		Txid:            tx.TxHash().String(),
 		Wtxid:           wtxid,
 		Version:         tx.Version,
 		Locktime:        tx.LockTime,
 		SizeBytes:       sizeBytes,
 		Weight:          weight,
 		Vbytes:          vbytes,
-		FeeSats:         feeSats,
-		FeeRateSatVb:    feeRate,
-		TotalInputSats:  totalInputSats,
+		VsizeExact:      vsizeExact,
+		FeeSats:         feeSatsOut,
+		FeeRateSatVb:    feeRateOut,
+		FeeRateSatKwu:   feeRateKwuOut,
+		FeeRateBtcKvb:   feeRateKvbOut,
+		TotalInputSats:  totalInputOut,
 		TotalOutputSats: totalOutputSats,
-		RbfSignaling:    rbfSignaling,
-		LocktimeType:    locktimeType,
 		LocktimeValue:   tx.LockTime,
 		VinCount:        len(inputs),
 		VoutCount:       len(outputs),
-		VoutScriptTypes: voutScriptTypes,
-		SegwitSavings:   segwitSavings,
+		VoutScriptTypes: make([]string, len(outputs)),
 		Vin:             inputs,
 		Vout:            outputs,
-		Warnings:        warnings,
-	}, nil
+		Warnings:        make([]types.Warning, 0),
+	}
+
+	// Registered stages add classification, addresses, asm, warnings, ...
+	for _, stage := range pipeline {
+		if err := stage.Analyze(ctx); err != nil {
+			return nil, fmt.Errorf("stage %s: %w", stage.Name(), err)
+		}
+	}
+	return ctx.Output, nil
+}
+
+// isCoinbaseInput reports whether an input spends the null outpoint
+// (txid 0000...0000, vout 0xFFFFFFFF), which has no prevout
+func isCoinbaseInput(txIn *wire.TxIn) bool {
+	return txIn.PreviousOutPoint.Index == 0xFFFFFFFF && txIn.PreviousOutPoint.Hash == (chainhash.Hash{})
 }
@@ -0,0 +1,103 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/utils"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// undoRecord locates one CBlockUndo record inside a decoded rev*.dat file
+type undoRecord struct {
+	file     []byte // the whole decoded rev file
+	offset   int    // start of the CBlockUndo data, after magic and size
+	size     int
+	txCount  uint64 // num_tx_undos, the block's non-coinbase tx count
+	checksum chainhash.Hash
+	used     bool
+}
+
+// undoIndex matches undo records to blocks when a whole blk*.dat file is
+// parsed. Records are written in the order blocks were connected, which
+// differs from the order they were stored, so each block looks up its own
+// record: candidates are narrowed by transaction count and confirmed with
+// the record checksum, SHA256d(prev block hash || CBlockUndo). An index
+// may span several rev files, since the undo data of the last blocks of one
+// blk file can be written to the next rev file.
+type undoIndex struct {
+	files map[int][]*undoRecord // by position in the parsed file set
+}
+
+// has reports whether the rev file at position file has been added
+func (idx *undoIndex) has(file int) bool {
+	_, ok := idx.files[file]
+	return ok
+}
+
+// drop releases the records of the rev file at position file
+func (idx *undoIndex) drop(file int) {
+	delete(idx.files, file)
+}
+
+// add walks the record headers of a decoded rev*.dat file, the one at
+// position file in the parsed file set
+func (idx *undoIndex) add(file int, revData []byte) error {
+	if idx.files == nil {
+		idx.files = make(map[int][]*undoRecord)
+	}
+	records := []*undoRecord{}
+	for pos := 0; pos+8 <= len(revData); {
+		if bytes.Equal(revData[pos:pos+4], []byte{0, 0, 0, 0}) {
+			break // preallocated zero padding
+		}
+		size := int(binary.LittleEndian.Uint32(revData[pos+4 : pos+8]))
+		start := pos + 8
+		end := start + size + chainhash.HashSize
+		if size == 0 || end > len(revData) {
+			return fmt.Errorf("undo record at offset %d is truncated", pos)
+		}
+		count, err := utils.ReadCompactSize(bytes.NewReader(revData[start : start+size]))
+		if err != nil {
+			return fmt.Errorf("undo record at offset %d: %w", pos, err)
+		}
+		rec := &undoRecord{file: revData, offset: start, size: size, txCount: count}
+		copy(rec.checksum[:], revData[start+size:end])
+		records = append(records, rec)
+		pos = end
+	}
+	idx.files[file] = records
+	return nil
+}
+
+// read returns the prevouts for a block from its matching undo record.
+// It satisfies undoReader.
+func (idx *undoIndex) read(header *wire.BlockHeader, transactions []*wire.MsgTx) ([][]types.PrevoutInput, error) {
+	want := uint64(len(transactions) - 1)
+	files := make([]int, 0, len(idx.files))
+	for file := range idx.files {
+		files = append(files, file)
+	}
+	sort.Ints(files)
+	for _, file := range files {
+		for _, rec := range idx.files[file] {
+			if rec.used || rec.txCount != want {
+				continue
+			}
+			data := rec.file[rec.offset : rec.offset+rec.size]
+			sum := chainhash.DoubleHashH(append(header.PrevBlock[:len(header.PrevBlock):len(header.PrevBlock)], data...))
+			if sum != rec.checksum {
+				continue
+			}
+			rec.used = true
+			// parseUndoFile expects to start at the record's magic and size
+			return parseUndoFile(bytes.NewReader(rec.file[rec.offset-8:]), transactions)
+		}
+	}
+	return nil, fmt.Errorf("no undo record in the rev file matches this block")
+}
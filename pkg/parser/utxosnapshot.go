@@ -0,0 +1,386 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/analyzer"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+	"github.com/richochetclementine1315/BTC-Lens/pkg/utils"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// snapshotMagic starts the metadata of versioned UTXO snapshots (Bitcoin
+// Core v27 and later); older snapshots start directly with the base block
+// hash
+var snapshotMagic = []byte{'u', 't', 'x', 'o', 0xff}
+
+// Snapshot versions: 1 stores every coin with its full outpoint, 2 (Bitcoin
+// Core v28 and later) writes each txid once followed by its coins
+const (
+	snapshotVersionOutpoints = 1
+	snapshotVersionGrouped   = 2
+)
+
+// snapshotMarkInterval is how many txids apart SnapshotResolver keeps the
+// file offsets it seeks to
+const snapshotMarkInterval = 256
+
+// UTXOSnapshotOptions configures ParseUTXOSnapshot
+type UTXOSnapshotOptions struct {
+	// OnCoin, when set, receives every coin in file order, i.e. by txid
+	// in internal byte order and then by output index. An error from
+	// OnCoin stops the parse.
+	OnCoin func(*types.UTXOCoin) error
+}
+
+// snapshotHeader is the metadata a UTXO snapshot starts with
+type snapshotHeader struct {
+	version uint16
+	magic   *[4]byte // nil before version 1
+	base    chainhash.Hash
+	count   uint64
+}
+
+// snapshotCoin is a coin as the snapshot stores it
+type snapshotCoin struct {
+	height   int64
+	coinbase bool
+	value    int64
+	script   []byte
+}
+
+// ParseUTXOSnapshot reads a UTXO snapshot written by Bitcoin Core's
+// dumptxoutset (which may be gzip- or zstd-compressed) and summarizes its
+// coins by script type and value. The file is streamed, so memory stays
+// flat whatever its size.
+func ParseUTXOSnapshot(path string, opts UTXOSnapshotOptions) (*types.UTXOSnapshotOutput, error) {
+	f, err := utils.OpenInput(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read UTXO snapshot: %w", err)
+	}
+	defer f.Close()
+	r := &offsetReader{r: bufio.NewReaderSize(f, 1<<20)}
+	h, err := readSnapshotHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &types.UTXOSnapshotOutput{
+		OK:            true,
+		Mode:          "utxo_snapshot",
+		Version:       h.version,
+		BaseBlockHash: h.base.String(),
+		CoinsCount:    h.count,
+	}
+	if h.magic != nil {
+		out.Magic = hex.EncodeToString(h.magic[:])
+		out.Network, _ = analyzer.NetworkFromMagic(*h.magic)
+	}
+
+	agg := analyzer.NewUTXOSetAggregator()
+	c := newSnapshotCursor(r, h, h.count)
+	for {
+		op, sc, err := c.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		coin := &types.UTXOCoin{
+			Txid:            op.Hash.String(),
+			Vout:            op.Index,
+			Height:          sc.height,
+			Coinbase:        sc.coinbase,
+			ValueSats:       sc.value,
+			ScriptPubkeyHex: hex.EncodeToString(sc.script),
+			ScriptType:      analyzer.ClassifyOutputScript(sc.script),
+		}
+		agg.Add(coin)
+		if opts.OnCoin != nil {
+			if err := opts.OnCoin(coin); err != nil {
+				return nil, err
+			}
+		}
+	}
+	out.Stats = agg.Report()
+	return out, nil
+}
+
+// readSnapshotHeader reads the snapshot metadata: for versioned snapshots
+// the magic bytes, a uint16 version and the network's message start, then
+// the base block hash and a uint64 coin count
+func readSnapshotHeader(r io.Reader) (snapshotHeader, error) {
+	var h snapshotHeader
+	head := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(r, head); err != nil {
+		return h, errors.New("UTXO snapshot is empty or truncated")
+	}
+	if bytes.Equal(head, snapshotMagic) {
+		var fields struct {
+			Version uint16
+			Magic   [4]byte
+		}
+		if err := binary.Read(r, binary.LittleEndian, &fields); err != nil {
+			return h, errors.New("UTXO snapshot metadata is truncated")
+		}
+		if fields.Version != snapshotVersionOutpoints && fields.Version != snapshotVersionGrouped {
+			return h, fmt.Errorf("unsupported UTXO snapshot version %d", fields.Version)
+		}
+		h.version, h.magic = fields.Version, &fields.Magic
+		head = head[:0]
+	}
+	copy(h.base[:], head)
+	if _, err := io.ReadFull(r, h.base[len(head):]); err != nil {
+		return h, errors.New("UTXO snapshot metadata is truncated")
+	}
+	if err := binary.Read(r, binary.LittleEndian, &h.count); err != nil {
+		return h, errors.New("UTXO snapshot metadata is truncated")
+	}
+	return h, nil
+}
+
+// offsetReader counts the bytes read through it
+type offsetReader struct {
+	r   io.Reader
+	off int64
+}
+
+func (o *offsetReader) Read(p []byte) (int, error) {
+	n, err := o.r.Read(p)
+	o.off += int64(n)
+	return n, err
+}
+
+// snapshotCursor reads the coins of a snapshot one at a time. It can start
+// at any txid boundary given the number of coins left from there.
+type snapshotCursor struct {
+	r       *offsetReader
+	grouped bool
+	left    uint64 // coins not yet read
+	txid    chainhash.Hash
+	inGroup uint64 // coins of txid not yet read, for grouped snapshots
+}
+
+func newSnapshotCursor(r *offsetReader, h snapshotHeader, left uint64) *snapshotCursor {
+	return &snapshotCursor{r: r, grouped: h.version >= snapshotVersionGrouped, left: left}
+}
+
+// next reads the next coin and its outpoint, returning io.EOF after the
+// last coin the metadata counts
+func (c *snapshotCursor) next() (wire.OutPoint, snapshotCoin, error) {
+	if c.left == 0 {
+		return wire.OutPoint{}, snapshotCoin{}, io.EOF
+	}
+	var op wire.OutPoint
+	if c.grouped {
+		if c.inGroup == 0 {
+			if _, err := io.ReadFull(c.r, c.txid[:]); err != nil {
+				return op, snapshotCoin{}, c.truncated(err)
+			}
+			n, err := utils.ReadCompactSize(c.r)
+			if err != nil {
+				return op, snapshotCoin{}, c.truncated(err)
+			}
+			if n == 0 || n > c.left {
+				return op, snapshotCoin{}, fmt.Errorf("UTXO snapshot has %d coins for txid %s with %d left to read", n, c.txid, c.left)
+			}
+			c.inGroup = n
+		}
+		vout, err := utils.ReadCompactSize(c.r)
+		if err != nil {
+			return op, snapshotCoin{}, c.truncated(err)
+		}
+		if vout > uint64(wire.MaxPrevOutIndex) {
+			return op, snapshotCoin{}, fmt.Errorf("UTXO snapshot output index %d of txid %s is out of range", vout, c.txid)
+		}
+		op = wire.OutPoint{Hash: c.txid, Index: uint32(vout)}
+		c.inGroup--
+	} else {
+		if _, err := io.ReadFull(c.r, c.txid[:]); err != nil {
+			return op, snapshotCoin{}, c.truncated(err)
+		}
+		op.Hash = c.txid
+		if err := binary.Read(c.r, binary.LittleEndian, &op.Index); err != nil {
+			return op, snapshotCoin{}, c.truncated(err)
+		}
+	}
+
+	// Coin: VARINT(height*2 + coinbase), then the compressed output
+	code, err := utils.ReadBitcoinVarInt(c.r)
+	if err != nil {
+		return op, snapshotCoin{}, c.truncated(err)
+	}
+	value, script, err := readCompressedTxOut(c.r)
+	if err != nil {
+		return op, snapshotCoin{}, fmt.Errorf("UTXO snapshot coin %s: %w", op, err)
+	}
+	c.left--
+	return op, snapshotCoin{height: int64(code >> 1), coinbase: code&1 == 1, value: value, script: script}, nil
+}
+
+func (c *snapshotCursor) truncated(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("UTXO snapshot is truncated with %d coins left to read", c.left)
+	}
+	return err
+}
+
+// SnapshotResolver looks up prevouts in a UTXO snapshot, which makes the
+// snapshot a prevout source for transactions and blocks that spend coins
+// it holds. Coins created after the snapshot's base block are not found.
+type SnapshotResolver struct {
+	f       *os.File
+	header  snapshotHeader
+	marks   []snapshotMark
+	dataEnd int64
+}
+
+// snapshotMark is a txid boundary of the snapshot the resolver can start
+// reading from
+type snapshotMark struct {
+	txid chainhash.Hash
+	off  int64
+	left uint64 // coins from here to the end
+}
+
+// OpenSnapshotResolver indexes an uncompressed UTXO snapshot for prevout
+// lookups. Indexing reads the whole file once and keeps one offset every
+// snapshotMarkInterval txids; it also checks that the coins are in txid
+// order, which lookups rely on.
+func OpenSnapshotResolver(path string) (*SnapshotResolver, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open UTXO snapshot: %w", err)
+	}
+	head := make([]byte, 4)
+	n, _ := io.ReadFull(f, head)
+	if format := utils.DetectCompression(head[:n]); format != utils.CompressionNone {
+		f.Close()
+		return nil, fmt.Errorf("%s: a %s-compressed UTXO snapshot cannot be used for lookups, decompress it first", path, format)
+	}
+
+	r := &offsetReader{r: bufio.NewReaderSize(io.NewSectionReader(f, 0, 1<<62), 1<<20)}
+	h, err := readSnapshotHeader(r)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	s := &SnapshotResolver{f: f, header: h}
+	c := newSnapshotCursor(r, h, h.count)
+	var prev chainhash.Hash
+	txids := 0
+	for {
+		off, left := r.off, c.left
+		op, _, err := c.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if txids > 0 && op.Hash == prev {
+			continue
+		}
+		if txids > 0 && bytes.Compare(op.Hash[:], prev[:]) < 0 {
+			f.Close()
+			return nil, fmt.Errorf("UTXO snapshot coins are not in txid order at %s", op.Hash)
+		}
+		if txids%snapshotMarkInterval == 0 {
+			s.marks = append(s.marks, snapshotMark{txid: op.Hash, off: off, left: left})
+		}
+		prev = op.Hash
+		txids++
+	}
+	s.dataEnd = r.off
+	return s, nil
+}
+
+// Close closes the snapshot file
+func (s *SnapshotResolver) Close() error {
+	return s.f.Close()
+}
+
+// BaseBlockHash returns the hash of the block the snapshot was taken at
+func (s *SnapshotResolver) BaseBlockHash() chainhash.Hash {
+	return s.header.base
+}
+
+// ResolvePrevouts looks the outpoints up in the snapshot. They are sorted
+// by txid so that one forward scan serves all outpoints near each other.
+// It is safe for concurrent use.
+func (s *SnapshotResolver) ResolvePrevouts(outpoints []wire.OutPoint) (map[wire.OutPoint]types.PrevoutInput, error) {
+	wanted := make(map[wire.OutPoint]bool, len(outpoints))
+	var txids []chainhash.Hash
+	for _, op := range outpoints {
+		if !wanted[op] {
+			wanted[op] = true
+			txids = append(txids, op.Hash)
+		}
+	}
+	sort.Slice(txids, func(i, j int) bool { return bytes.Compare(txids[i][:], txids[j][:]) < 0 })
+
+	found := make(map[wire.OutPoint]types.PrevoutInput)
+	var c *snapshotCursor
+	var r *offsetReader
+	var pendingOp wire.OutPoint
+	var pending *snapshotCoin // read past the previous txid, not yet used
+	for i, txid := range txids {
+		if i > 0 && txid == txids[i-1] {
+			continue
+		}
+		m := sort.Search(len(s.marks), func(i int) bool { return bytes.Compare(s.marks[i].txid[:], txid[:]) > 0 }) - 1
+		if m < 0 {
+			continue // before the first txid
+		}
+		// Seek unless the mark is behind where the last scan stopped
+		if c == nil || s.marks[m].off > r.off {
+			mark := s.marks[m]
+			r = &offsetReader{r: bufio.NewReader(io.NewSectionReader(s.f, mark.off, s.dataEnd-mark.off)), off: mark.off}
+			c = newSnapshotCursor(r, s.header, mark.left)
+			pending = nil
+		}
+		for {
+			op, coin := pendingOp, pending
+			if coin == nil {
+				var sc snapshotCoin
+				var err error
+				op, sc, err = c.next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return nil, err
+				}
+				coin = &sc
+			}
+			pending = nil
+			if cmp := bytes.Compare(op.Hash[:], txid[:]); cmp > 0 {
+				pendingOp, pending = op, coin
+				break
+			} else if cmp < 0 || !wanted[op] {
+				continue
+			}
+			height := coin.height
+			found[op] = types.PrevoutInput{
+				Txid:            op.Hash.String(),
+				Vout:            op.Index,
+				ValueSats:       coin.value,
+				ScriptPubkeyHex: hex.EncodeToString(coin.script),
+				Height:          &height,
+			}
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Output verbosity levels
+const (
+	VerbositySummary  = "summary"  // transaction-level fields only, no vin/vout detail
+	VerbosityStandard = "standard" // everything except asm strings, token arrays and input witness stacks
+	VerbosityFull     = "full"     // complete output (default)
+)
+
+// verbosityDropFields lists the JSON keys removed at each level below full.
+// Keys are dropped wherever they appear, so nested transactions in block
+// output are reduced the same way as a standalone transaction. Standard
+// drops the witness stacks too: their items are mostly signatures and, in
+// inscription spends, can run to hundreds of kilobytes per input.
+var verbosityDropFields = map[string]map[string]bool{
+	VerbosityStandard: {
+		"script_asm":                true,
+		"witness_script_asm":        true,
+		"script_asm_tokens":         true,
+		"witness_script_asm_tokens": true,
+		"witness":                   true,
+	},
+	VerbositySummary: {
+		"vin":            true,
+		"vout":           true,
+		"segwit_savings": true,
+	},
+}
+
+// ValidateVerbosity checks a verbosity level name; empty means full
+func ValidateVerbosity(level string) error {
+	switch level {
+	case "", VerbositySummary, VerbosityStandard, VerbosityFull:
+		return nil
+	}
+	return fmt.Errorf("invalid verbosity %q: want summary, standard or full", level)
+}
+
+// ApplyVerbosity reduces a transaction or block output to the fields kept at
+// the given verbosity level. Full (or empty) returns v unchanged; other
+// levels return a generic JSON value with the heavy fields removed.
+func ApplyVerbosity(v interface{}, level string) (interface{}, error) {
+	if err := ValidateVerbosity(level); err != nil {
+		return nil, err
+	}
+	drop := verbosityDropFields[level]
+	if drop == nil {
+		return v, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	// UseNumber keeps satoshi amounts and fee rates formatted exactly
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	pruneFields(generic, drop)
+	return generic, nil
+}
+
+// pruneFields removes the dropped keys from every object in a JSON value.
+// "witness" names both an input's stack and the witness sigop count, so it
+// is kept where it holds a number.
+func pruneFields(v interface{}, drop map[string]bool) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			if drop[k] {
+				if _, isCount := child.(json.Number); k != "witness" || !isCount {
+					delete(val, k)
+					continue
+				}
+			}
+			pruneFields(child, drop)
+		}
+	case []interface{}:
+		for _, child := range val {
+			pruneFields(child, drop)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+// Package store keeps analysis results in a content-addressed directory
+// instead of one flat folder of JSON files.
+//
+// Each result is filed under its key (a txid or block hash) and the SHA-256
+// of its JSON: <root>/<key[:2]>/<key>-<sha256[:16]>.json, or .json.gz when
+// compressed. Sharding by the key prefix keeps directories small, and
+// putting identical content for a key again only refreshes its index entry.
+// index.json lists every stored result with its metadata; it is rewritten
+// atomically after each change. Processes may share a store: each change
+// is made under an exclusive flock on index.lock, to the index as last
+// written rather than as read at Open.
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/richochetclementine1315/BTC-Lens/pkg/types"
+)
+
+// indexFile is the metadata index at the store root, and lockFile the
+// file locked while it is changed
+const (
+	indexFile = "index.json"
+	lockFile  = "index.lock"
+)
+
+// Store is an open result store
+type Store struct {
+	root     string
+	compress bool
+
+	mu      sync.Mutex
+	entries []types.StoreEntry
+}
+
+// index is the on-disk form of index.json
+type index struct {
+	Version int                `json:"version"`
+	Entries []types.StoreEntry `json:"entries"`
+}
+
+// Open reads the store at root. The directory is created on the first Put,
+// so opening a store that does not exist yet yields an empty one. With
+// compress set, new results are written gzip-compressed.
+func Open(root string, compress bool) (*Store, error) {
+	s := &Store{root: root, compress: compress}
+	if err := s.readIndex(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// readIndex replaces the entries with those of index.json, or with none
+// when there is no index yet
+func (s *Store) readIndex() error {
+	data, err := os.ReadFile(filepath.Join(s.root, indexFile))
+	if errors.Is(err, os.ErrNotExist) {
+		s.entries = nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var idx index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", indexFile, err)
+	}
+	s.entries = idx.Entries
+	return nil
+}
+
+// locked runs fn holding the store's lock file, with the entries re-read
+// from index.json first so that results other processes stored since are
+// kept when fn writes the index. s.mu must be held.
+func (s *Store) locked(fn func() error) error {
+	if err := os.MkdirAll(s.root, 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(s.root, lockFile), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := lockExclusive(f); err != nil {
+		return fmt.Errorf("failed to lock %s: %w", lockFile, err)
+	}
+	defer unlock(f)
+	if err := s.readIndex(); err != nil {
+		return err
+	}
+	return fn()
+}
+
+// Root returns the store directory
+func (s *Store) Root() string { return s.root }
+
+// Put stores a result under key and reports whether identical content was
+// already stored for it, in which case nothing is written but the entry's
+// written_at time
+func (s *Store) Put(key, kind string, data []byte) (types.StoreEntry, bool, error) {
+	if len(key) < 2 || filepath.Base(key) != key {
+		return types.StoreEntry{}, false, fmt.Errorf("invalid store key %q", key)
+	}
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	now := time.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var entry types.StoreEntry
+	var deduped bool
+	err := s.locked(func() (err error) {
+		entry, deduped, err = s.put(key, kind, digest, data, now)
+		return err
+	})
+	return entry, deduped, err
+}
+
+// put is Put with the lock held
+func (s *Store) put(key, kind, digest string, data []byte, now time.Time) (types.StoreEntry, bool, error) {
+	for i, e := range s.entries {
+		if e.Key != key || e.SHA256 != digest {
+			continue
+		}
+		// A file removed behind the index's back is written again
+		if _, err := os.Stat(filepath.Join(s.root, e.Path)); err == nil {
+			s.entries[i].WrittenAt = now
+			return s.entries[i], true, s.writeIndex()
+		}
+		s.entries = append(s.entries[:i], s.entries[i+1:]...)
+		break
+	}
+
+	entry := types.StoreEntry{
+		Key:        key,
+		Kind:       kind,
+		SHA256:     digest,
+		Path:       filepath.Join(key[:2], key+"-"+digest[:16]+".json"),
+		Bytes:      int64(len(data)),
+		Compressed: s.compress,
+		CreatedAt:  now,
+		WrittenAt:  now,
+	}
+	if s.compress {
+		entry.Path += ".gz"
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return types.StoreEntry{}, false, err
+		}
+		if err := zw.Close(); err != nil {
+			return types.StoreEntry{}, false, err
+		}
+		data = buf.Bytes()
+	}
+	entry.StoredBytes = int64(len(data))
+
+	path := filepath.Join(s.root, entry.Path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return types.StoreEntry{}, false, err
+	}
+	if err := writeAtomic(path, data); err != nil {
+		return types.StoreEntry{}, false, err
+	}
+	s.entries = append(s.entries, entry)
+	return entry, false, s.writeIndex()
+}
+
+// Entries returns every stored result, most recently written first. With
+// latest set, only the newest result of each key is returned.
+func (s *Store) Entries(latest bool) []types.StoreEntry {
+	s.mu.Lock()
+	entries := append([]types.StoreEntry(nil), s.entries...)
+	s.mu.Unlock()
+	sortNewestFirst(entries)
+	if !latest {
+		return entries
+	}
+	seen := make(map[string]bool, len(entries))
+	kept := entries[:0]
+	for _, e := range entries {
+		if !seen[e.Key] {
+			seen[e.Key] = true
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// Read returns a stored result's JSON, decompressed
+func (s *Store) Read(e types.StoreEntry) ([]byte, error) {
+	f, err := os.Open(filepath.Join(s.root, e.Path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if e.Compressed {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	return io.ReadAll(r)
+}
+
+// Prune removes results last written more than maxAge ago, then the oldest
+// remaining results until the store holds at most maxBytes on disk. A zero
+// limit is not applied.
+func (s *Store) Prune(maxAge time.Duration, maxBytes int64) (*types.StorePruneOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out *types.StorePruneOutput
+	err := s.locked(func() (err error) {
+		out, err = s.prune(maxAge, maxBytes)
+		return err
+	})
+	return out, err
+}
+
+// prune is Prune with the lock held
+func (s *Store) prune(maxAge time.Duration, maxBytes int64) (*types.StorePruneOutput, error) {
+	out := &types.StorePruneOutput{OK: true, Mode: "store_prune", Removed: make([]types.StoreEntry, 0)}
+
+	sortNewestFirst(s.entries)
+	cutoff := time.Now().UTC().Add(-maxAge)
+	var kept []types.StoreEntry
+	var total int64
+	for _, e := range s.entries {
+		tooOld := maxAge > 0 && e.WrittenAt.Before(cutoff)
+		tooBig := maxBytes > 0 && total+e.StoredBytes > maxBytes
+		if !tooOld && !tooBig {
+			kept = append(kept, e)
+			total += e.StoredBytes
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.root, e.Path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		out.Removed = append(out.Removed, e)
+		out.FreedBytes += e.StoredBytes
+	}
+	s.entries = kept
+	out.Kept = len(kept)
+	out.StoredBytes = total
+	return out, s.writeIndex()
+}
+
+func (s *Store) writeIndex() error {
+	if err := os.MkdirAll(s.root, 0755); err != nil {
+		return err
+	}
+	entries := s.entries
+	if entries == nil {
+		entries = make([]types.StoreEntry, 0)
+	}
+	data, err := json.MarshalIndent(index{Version: 1, Entries: entries}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeAtomic(filepath.Join(s.root, indexFile), append(data, '\n'))
+}
+
+// writeAtomic writes through a temporary file so readers never see a
+// partial result or index
+func writeAtomic(path string, data []byte) error {
+	// A unique temporary name keeps concurrent writers from sharing one
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func sortNewestFirst(entries []types.StoreEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].WrittenAt.After(entries[j].WrittenAt)
+	})
+}
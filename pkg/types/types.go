@@ -1,66 +1,477 @@
 package types
 
+import (
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// HexBytes holds raw bytes that are hex-encoded only when marshaled to JSON.
+// Large witness items and scripts are kept once in binary form instead of
+// being converted to hex strings up front.
+type HexBytes []byte
+
+// MarshalJSON encodes the bytes as a lowercase hex JSON string
+func (h HexBytes) MarshalJSON() ([]byte, error) {
+	out := make([]byte, hex.EncodedLen(len(h))+2)
+	out[0] = '"'
+	hex.Encode(out[1:], h)
+	out[len(out)-1] = '"'
+	return out, nil
+}
+
+// UnmarshalJSON decodes a hex JSON string
+func (h *HexBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	*h = b
+	return nil
+}
+
+// String returns the hex encoding
+func (h HexBytes) String() string {
+	return hex.EncodeToString(h)
+}
+
 // TransactionOutput represents the complete JSON output for a transaction
 type TransactionOutput struct {
-	OK              bool           `json:"ok"`
-	Network         string         `json:"network,omitempty"`
-	Segwit          bool           `json:"segwit"`
-	Txid            string         `json:"txid,omitempty"`
-	Wtxid           *string        `json:"wtxid"`
-	Version         int32          `json:"version,omitempty"`
-	Locktime        uint32         `json:"locktime"`
-	SizeBytes       int            `json:"size_bytes,omitempty"`
-	Weight          int            `json:"weight,omitempty"`
-	Vbytes          int            `json:"vbytes,omitempty"`
-	FeeSats         int64          `json:"fee_sats,omitempty"`
-	FeeRateSatVb    float64        `json:"fee_rate_sat_vb,omitempty"`
-	TotalInputSats  int64          `json:"total_input_sats,omitempty"`
-	TotalOutputSats int64          `json:"total_output_sats,omitempty"`
-	RbfSignaling    bool           `json:"rbf_signaling"`
-	LocktimeType    string         `json:"locktime_type,omitempty"`
-	LocktimeValue   uint32         `json:"locktime_value"`
-	VinCount        int            `json:"vin_count,omitempty"`
-	VoutCount       int            `json:"vout_count,omitempty"`
-	VoutScriptTypes []string       `json:"vout_script_types"`
-	SegwitSavings   *SegwitSavings `json:"segwit_savings"`
-	Vin             []Input        `json:"vin"`
-	Vout            []Output       `json:"vout"`
-	Warnings        []Warning      `json:"warnings"`
-	Error           *ErrorInfo     `json:"error,omitempty"`
+	OK              bool                `json:"ok"`
+	Network         string              `json:"network,omitempty"`
+	SignetMagic     string              `json:"signet_magic,omitempty"` // hex, for a custom signet's challenge
+	Segwit          bool                `json:"segwit"`
+	Txid            string              `json:"txid,omitempty"`
+	Wtxid           *string             `json:"wtxid"`
+	Version         int32               `json:"version,omitempty"`
+	Locktime        uint32              `json:"locktime"`
+	SizeBytes       int                 `json:"size_bytes,omitempty"`
+	Weight          int                 `json:"weight,omitempty"`
+	Vbytes          int                 `json:"vbytes,omitempty"`
+	VsizeExact      float64             `json:"vsize_exact,omitempty"`
+	FeeSats         *int64              `json:"fee_sats"`
+	FeeRateSatVb    *float64            `json:"fee_rate_sat_vb"`
+	FeeRateSatKwu   *float64            `json:"fee_rate_sat_kwu"`
+	FeeRateBtcKvb   *float64            `json:"fee_rate_btc_kvb"`
+	TotalInputSats  *int64              `json:"total_input_sats"`
+	TotalOutputSats int64               `json:"total_output_sats,omitempty"`
+	RbfSignaling    bool                `json:"rbf_signaling"`
+	LocktimeType    string              `json:"locktime_type,omitempty"`
+	LocktimeValue   uint32              `json:"locktime_value"`
+	VinCount        int                 `json:"vin_count,omitempty"`
+	VoutCount       int                 `json:"vout_count,omitempty"`
+	VoutScriptTypes []string            `json:"vout_script_types"`
+	SegwitSavings   *SegwitSavings      `json:"segwit_savings"`
+	Vin             []Input             `json:"vin"`
+	Vout            []Output            `json:"vout"`
+	InputAge        *InputAge           `json:"input_age,omitempty"`
+	Warnings        []Warning           `json:"warnings"`
+	Findings        []Finding           `json:"findings,omitempty"`
+	Malleability    *MalleabilityReport `json:"malleability,omitempty"`
+	PSBT            *PSBTInfo           `json:"psbt,omitempty"`
+
+	// TaprootTrees merges the script-path spends of output keys the
+	// transaction spends more than once
+	TaprootTrees []TapTree `json:"taproot_trees,omitempty"`
+
+	// IsStandard reports whether Bitcoin Core would relay the transaction
+	// under its IsStandardTx rules, PolicyViolations listing the rules it
+	// breaks. Null for a coinbase.
+	IsStandard       *bool             `json:"is_standard,omitempty"`
+	PolicyViolations []PolicyViolation `json:"policy_violations,omitempty"`
+
+	// Sigops totals the transaction's signature operations, held to
+	// MAX_STANDARD_TX_SIGOPS_COST
+	Sigops *SigopCount `json:"sigops,omitempty"`
+
+	Error *ErrorInfo `json:"error,omitempty"`
+}
+
+// PSBTInfo describes the signing state of a transaction analyzed from a
+// PSBT. Until every input is finalized the transaction carries no
+// signatures, so its size, weight and fee rate are those of the unsigned
+// transaction.
+type PSBTInfo struct {
+	Finalized       bool         `json:"finalized"`
+	InputsFinalized int          `json:"inputs_finalized"`
+	MissingUtxos    int          `json:"missing_utxos"`
+	Inputs          []PSBTInput  `json:"inputs"`
+	Outputs         []PSBTOutput `json:"outputs"`
+}
+
+// PSBTInput is what a PSBT's input map holds beyond the transaction itself.
+// UtxoSource is "witness_utxo", "non_witness_utxo", "prevouts" (taken from
+// the fixture), "resolver" (looked up from a node) or "missing".
+type PSBTInput struct {
+	Vin              int     `json:"vin"`
+	UtxoSource       string  `json:"utxo_source"`
+	Finalized        bool    `json:"finalized"`
+	PartialSigs      int     `json:"partial_sigs"`
+	TaprootSigs      int     `json:"taproot_sigs"`
+	SighashType      *string `json:"sighash_type"`
+	RedeemScriptHex  string  `json:"redeem_script_hex,omitempty"`
+	WitnessScriptHex string  `json:"witness_script_hex,omitempty"`
+	Bip32Derivations int     `json:"bip32_derivations"`
+}
+
+// PSBTOutput is what a PSBT's output map holds: key origins that let a
+// signer recognize its change
+type PSBTOutput struct {
+	Vout             int    `json:"vout"`
+	RedeemScriptHex  string `json:"redeem_script_hex,omitempty"`
+	WitnessScriptHex string `json:"witness_script_hex,omitempty"`
+	Bip32Derivations int    `json:"bip32_derivations"`
+}
+
+// MalleabilityReport explains whether the txid of an unconfirmed transaction
+// can be changed by a third party, and through which inputs
+type MalleabilityReport struct {
+	TxidMalleable bool                `json:"txid_malleable"`
+	Inputs        []InputMalleability `json:"inputs"`
+}
+
+// InputMalleability lists the malleability vectors that apply to one input
+type InputMalleability struct {
+	Vin          int      `json:"vin"`
+	Segwit       bool     `json:"segwit"`
+	Vectors      []string `json:"vectors"`
+	SighashTypes []string `json:"sighash_types"`
 }
 
 // Input represents a transaction input
 type Input struct {
-	Txid             string           `json:"txid"`
-	Vout             uint32           `json:"vout"`
-	Sequence         uint32           `json:"sequence"`
-	ScriptSigHex     string           `json:"script_sig_hex"`
-	ScriptAsm        string           `json:"script_asm"`
-	Witness          []string         `json:"witness"`
-	WitnessScriptAsm *string          `json:"witness_script_asm,omitempty"`
-	ScriptType       string           `json:"script_type"`
-	Address          *string          `json:"address"`
-	Prevout          Prevout          `json:"prevout"`
-	RelativeTimelock RelativeTimelock `json:"relative_timelock"`
+	Txid                string           `json:"txid"`
+	Vout                uint32           `json:"vout"`
+	Sequence            uint32           `json:"sequence"`
+	ScriptSigHex        HexBytes         `json:"script_sig_hex"`
+	ScriptAsm           string           `json:"script_asm"`
+	Witness             []HexBytes       `json:"witness"`
+	WitnessScriptAsm    *string          `json:"witness_script_asm,omitempty"`
+	ScriptAsmTokens     []ScriptToken    `json:"script_asm_tokens,omitempty"`
+	WitnessScriptTokens []ScriptToken    `json:"witness_script_asm_tokens,omitempty"`
+	ScriptType          string           `json:"script_type"`
+	Address             *string          `json:"address"`
+	AddressDerived      bool             `json:"address_derived,omitempty"`
+	P2PK                *P2PKInfo        `json:"p2pk,omitempty"`
+	Multisig            *MultisigInfo    `json:"multisig,omitempty"`
+	Prevout             Prevout          `json:"prevout"`
+	PrevoutMissing      bool             `json:"prevout_missing,omitempty"`
+	RelativeTimelock    RelativeTimelock `json:"relative_timelock"`
+	SequenceDecoded     string           `json:"sequence_decoded"`
+	CoinAge             *CoinAge         `json:"coin_age,omitempty"`
+	Envelopes           []DataEnvelope   `json:"envelopes,omitempty"`
+
+	WitnessPolicyViolations []WitnessPolicyViolation `json:"witness_policy_violations,omitempty"`
+
+	// TaprootTweak verifies the key commitment revealed by a script-path spend
+	TaprootTweak *TaprootTweakCheck `json:"taproot_tweak,omitempty"`
+
+	// Taproot decodes the control block of a script-path spend
+	Taproot *TaprootControlBlock `json:"taproot,omitempty"`
+
+	Signatures *SignatureAccounting `json:"signatures,omitempty"`
+
+	// SchnorrSignatures splits the signatures of a taproot spend from
+	// their sighash bytes, and ECDSASignatures decodes the DER signatures
+	// of other inputs. SighashType is the type they all sign with; null
+	// when they differ.
+	SchnorrSignatures []SchnorrSignature `json:"schnorr_signatures,omitempty"`
+	ECDSASignatures   []ECDSASignature   `json:"ecdsa_signatures,omitempty"`
+	SighashType       *string            `json:"sighash_type,omitempty"`
+
+	// SignatureAnalysis fingerprints the wallet behind the ECDSA signatures
+	SignatureAnalysis *SignatureAnalysis `json:"signature_analysis,omitempty"`
+
+	// Pubkeys lists the public keys the input reveals
+	Pubkeys []PubkeyInfo `json:"pubkeys,omitempty"`
+
+	// SignatureValid reports whether the scriptSig and witness of a p2pkh,
+	// p2sh, segwit v0 or taproot key-path input satisfy its prevout script,
+	// signatures verified; SignatureError says why not. Null when a needed
+	// prevout is missing (any of them, for a key-path spend) or the input
+	// is of another type.
+	SignatureValid *bool  `json:"signature_valid,omitempty"`
+	SignatureError string `json:"signature_error,omitempty"`
+
+	// SighashPreimages are the messages the input's signatures sign, one
+	// per hash type, when the sighash_preimages stage is enabled
+	SighashPreimages []SighashPreimage `json:"sighash_preimages,omitempty"`
+
+	// Sigops counts the signature operations the input's scriptSig,
+	// redeem script and witness add to the transaction
+	Sigops *SigopCount `json:"sigops,omitempty"`
+}
+
+// SighashPreimage is the message a signature with the given hash type
+// signs, under the "legacy", "bip143" or "bip341" algorithm, and the
+// digest actually signed: its double SHA256, or its TapSighash tagged hash
+// for bip341 (epoch byte included in the preimage). A legacy
+// SIGHASH_SINGLE without a matching output has no preimage and signs the
+// constant 1.
+type SighashPreimage struct {
+	SighashType string `json:"sighash_type"`
+	SighashByte int    `json:"sighash_byte"`
+	Algorithm   string `json:"algorithm"`
+	PreimageHex string `json:"preimage_hex"`
+	SighashHex  string `json:"sighash_hex"`
+}
+
+// PubkeyInfo is a public key found in an input. Format is "compressed",
+// "uncompressed", "hybrid" or "x_only" (tapscript); Valid reports whether
+// it is a point on the curve. UncompressedInSegwit flags an uncompressed
+// key in segwit v0 witness data, which relay policy rejects.
+type PubkeyInfo struct {
+	Source               string `json:"source"`
+	Hex                  string `json:"hex"`
+	Format               string `json:"format"`
+	Compressed           bool   `json:"compressed"`
+	Valid                bool   `json:"valid"`
+	UncompressedInSegwit bool   `json:"uncompressed_in_segwit,omitempty"`
+}
+
+// SignatureAnalysis counts an input's ECDSA signatures with a low R (one
+// that fits in 32 bytes, giving a 71-byte signature) and with S normalized
+// low, which nodes have required for relay since 2015. LowRGrinding and
+// LowRChance are judged over all the transaction's ECDSA signatures:
+// LowRChance is the probability that signing without grinding would have
+// given every one of them a low R (1 when some have a high R).
+type SignatureAnalysis struct {
+	Signatures   int     `json:"signatures"`
+	LowR         int     `json:"low_r"`
+	LowS         int     `json:"low_s"`
+	HighS        int     `json:"high_s"`
+	LowRGrinding bool    `json:"low_r_grinding"`
+	LowRChance   float64 `json:"low_r_chance"`
+}
+
+// ECDSASignature is a DER signature found in a scriptSig push or witness
+// item (Index counts within Source). DERValid applies the BIP66 rules and
+// DERError says which one failed. R and S are left out, and LowS null, when
+// the integer lengths do not fit the signature.
+type ECDSASignature struct {
+	Source      string `json:"source"`
+	Index       int    `json:"index"`
+	Length      int    `json:"length"`
+	DERValid    bool   `json:"der_valid"`
+	DERError    string `json:"der_error,omitempty"`
+	R           string `json:"r,omitempty"`
+	S           string `json:"s,omitempty"`
+	LowS        *bool  `json:"low_s"`
+	SighashByte int    `json:"sighash_byte"`
+	SighashType string `json:"sighash_type"`
+}
+
+// SchnorrSignature is a BIP340 signature taken from a taproot witness.
+// SighashByte is null for a 64-byte signature, which signs with
+// SIGHASH_DEFAULT. SighashValid is false for a hash type BIP341 rejects.
+type SchnorrSignature struct {
+	WitnessIndex int    `json:"witness_index"`
+	Signature    string `json:"signature"`
+	SighashByte  *int   `json:"sighash_byte"`
+	SighashType  string `json:"sighash_type"`
+	SighashValid bool   `json:"sighash_valid"`
+}
+
+// SignatureAccounting compares the signatures an input's script requires
+// with those present. Complete is null for conditional (branching) scripts,
+// whose requirement depends on the branch taken.
+type SignatureAccounting struct {
+	Source      string `json:"source"`
+	Required    int    `json:"required"`
+	Provided    int    `json:"provided"`
+	Missing     int    `json:"missing"`
+	Conditional bool   `json:"conditional,omitempty"`
+	Complete    *bool  `json:"complete"`
+}
+
+// TaprootTweakCheck reports whether an internal key and merkle root tweak
+// to the output key on chain. MerkleRoot is null for key-path-only
+// commitments; ParityValid is only set when a control block claimed a parity.
+type TaprootTweakCheck struct {
+	Source      string  `json:"source"`
+	InternalKey string  `json:"internal_key"`
+	MerkleRoot  *string `json:"merkle_root"`
+	TweakedKey  string  `json:"tweaked_key"`
+	TweakValid  bool    `json:"tweak_valid"`
+	ParityValid *bool   `json:"parity_valid,omitempty"`
+}
+
+// TaprootControlBlock is the control block of a script-path spend, decoded.
+// OutputKeyParity is the y parity (0 even, 1 odd) claimed for the output
+// key; MerklePath lists the sibling hashes from the leaf up to the root.
+// CommitmentValid is null when the prevout is unknown.
+type TaprootControlBlock struct {
+	LeafVersion     int      `json:"leaf_version"`
+	OutputKeyParity int      `json:"output_key_parity"`
+	InternalKey     string   `json:"internal_key"`
+	MerklePath      []string `json:"merkle_path"`
+	LeafHash        string   `json:"leaf_hash"`
+	MerkleRoot      string   `json:"merkle_root"`
+	CommitmentValid *bool    `json:"commitment_valid"`
+}
+
+// TaprootKeyHint names the internal key (x-only hex) and optional merkle
+// root (hex) a P2TR output is expected to commit to
+type TaprootKeyHint struct {
+	Vout        int    `json:"vout"`
+	InternalKey string `json:"internal_key"`
+	MerkleRoot  string `json:"merkle_root,omitempty"`
+
+	// Leaves lists the full script tree, depth first, when it is known;
+	// the merkle root is then computed from it
+	Leaves []TapLeafHint `json:"leaves,omitempty"`
+}
+
+// TapLeafHint is one leaf of a script tree and its depth in the tree.
+// LeafVersion defaults to tapscript (0xc0).
+type TapLeafHint struct {
+	Depth       int    `json:"depth"`
+	LeafVersion *int   `json:"leaf_version,omitempty"`
+	ScriptHex   string `json:"script_hex"`
+}
+
+// TapTree is the script tree behind a taproot output key, as far as it is
+// known. Source is "spends" when merged from the script-path spends of the
+// key (Spends of them) and "leaves" when given in full. Complete reports
+// whether no subtree is left hidden behind its hash.
+type TapTree struct {
+	Source         string      `json:"source"`
+	OutputKey      string      `json:"output_key"`
+	InternalKey    string      `json:"internal_key"`
+	MerkleRoot     string      `json:"merkle_root"`
+	Spends         int         `json:"spends,omitempty"`
+	RevealedLeaves int         `json:"revealed_leaves"`
+	Complete       bool        `json:"complete"`
+	Root           TapTreeNode `json:"root"`
+}
+
+// TapTreeNode is a node of a TapTree: a "leaf" with its script, a "branch"
+// with its two children in hash order, or a "hidden" subtree
+type TapTreeNode struct {
+	Hash        string        `json:"hash"`
+	Kind        string        `json:"kind"`
+	Depth       int           `json:"depth"`
+	LeafVersion *int          `json:"leaf_version,omitempty"`
+	ScriptHex   string        `json:"script_hex,omitempty"`
+	ScriptAsm   string        `json:"script_asm,omitempty"`
+	Children    []TapTreeNode `json:"children,omitempty"`
+}
+
+// PolicyViolation is a transaction breaking one of Core's relay policy
+// rules, with the input or output at fault when the rule is per input or
+// output. Limit is 0 for rules without one.
+type PolicyViolation struct {
+	Rule   string `json:"rule"`
+	Limit  int    `json:"limit"`
+	Actual int    `json:"actual"`
+	Vin    *int   `json:"vin,omitempty"`
+	Vout   *int   `json:"vout,omitempty"`
+}
+
+// SigopCount counts signature operations by where consensus finds them:
+// Legacy in scriptSigs and output scripts, P2SH in redeem scripts, Witness
+// in segwit v0 witness programs. Cost weighs legacy and P2SH sigops by 4.
+// Limit and OverLimit are set for a transaction or block total; Partial
+// marks a total missing the P2SH and witness sigops of unknown prevouts.
+type SigopCount struct {
+	Legacy    int  `json:"legacy"`
+	P2SH      int  `json:"p2sh"`
+	Witness   int  `json:"witness"`
+	Cost      int  `json:"cost"`
+	Limit     int  `json:"limit,omitempty"`
+	OverLimit bool `json:"over_limit,omitempty"`
+	Partial   bool `json:"partial,omitempty"`
+}
+
+// WitnessPolicyViolation is a witness exceeding a relay policy limit. Item
+// is the offending stack item's index when the rule is per item.
+type WitnessPolicyViolation struct {
+	Rule   string `json:"rule"`
+	Limit  int    `json:"limit"`
+	Actual int    `json:"actual"`
+	Item   *int   `json:"item,omitempty"`
+}
+
+// DataEnvelope is an OP_FALSE OP_IF ... OP_ENDIF data envelope found in a
+// tapscript. MarkerHex is the first push; DataBytes counts the pushes after it.
+type DataEnvelope struct {
+	MarkerHex string `json:"marker_hex"`
+	Pushes    int    `json:"pushes"`
+	DataBytes int    `json:"data_bytes"`
 }
 
 // Output represents a transaction output
 type Output struct {
-	N                int     `json:"n"`
-	ValueSats        int64   `json:"value_sats"`
-	ScriptPubkeyHex  string  `json:"script_pubkey_hex"`
-	ScriptAsm        string  `json:"script_asm"`
-	ScriptType       string  `json:"script_type"`
-	Address          *string `json:"address"`
-	OpReturnDataHex  string  `json:"op_return_data_hex,omitempty"`
-	OpReturnDataUtf8 *string `json:"op_return_data_utf8,omitempty"`
-	OpReturnProtocol string  `json:"op_return_protocol,omitempty"`
+	N                int           `json:"n"`
+	ValueSats        int64         `json:"value_sats"`
+	ScriptPubkeyHex  HexBytes      `json:"script_pubkey_hex"`
+	ScriptAsm        string        `json:"script_asm"`
+	ScriptType       string        `json:"script_type"`
+	Address          *string       `json:"address"`
+	AddressDerived   bool          `json:"address_derived,omitempty"`
+	P2PK             *P2PKInfo     `json:"p2pk,omitempty"`
+	Multisig         *MultisigInfo `json:"multisig,omitempty"`
+	OpReturnDataHex  string        `json:"op_return_data_hex,omitempty"`
+	OpReturnDataUtf8 *string       `json:"op_return_data_utf8,omitempty"`
+	OpReturnProtocol string        `json:"op_return_protocol,omitempty"`
+	ScriptAsmTokens  []ScriptToken `json:"script_asm_tokens,omitempty"`
+
+	// TaprootTweak checks the key behind a P2TR output against the
+	// fixture's taproot_outputs hint, when one is given
+	TaprootTweak *TaprootTweakCheck `json:"taproot_tweak,omitempty"`
+
+	// TapTree renders the script tree of the taproot_outputs hint, when
+	// it lists the leaves
+	TapTree *TapTree `json:"taptree,omitempty"`
+}
+
+// ScriptToken represents one opcode or data push of an annotated script
+type ScriptToken struct {
+	Op         string `json:"op"`
+	DataHex    string `json:"data_hex,omitempty"`
+	Annotation string `json:"annotation,omitempty"`
+}
+
+// P2PKInfo describes the public key locked by a pay-to-pubkey script
+type P2PKInfo struct {
+	PubkeyHex      string `json:"pubkey_hex"`
+	PubkeyFormat   string `json:"pubkey_format"`
+	DerivedAddress string `json:"derived_p2pkh_address"`
+}
+
+// MultisigInfo describes an m-of-n OP_CHECKMULTISIG script: Required
+// signatures from Total keys. Source says which script of an input holds
+// it ("prevout", "redeem_script" or "witness_script"); it is empty for an
+// output.
+type MultisigInfo struct {
+	Source   string   `json:"source,omitempty"`
+	Required int      `json:"m"`
+	Total    int      `json:"n"`
+	Pubkeys  []string `json:"pubkeys"`
 }
 
 // Prevout represents the previous output being spent
 type Prevout struct {
 	ValueSats       int64  `json:"value_sats"`
 	ScriptPubkeyHex string `json:"script_pubkey_hex"`
+	Height          *int64 `json:"height,omitempty"`
+}
+
+// CoinAge represents how long an input's coin sat unspent
+type CoinAge struct {
+	Blocks int64   `json:"blocks"`
+	Days   float64 `json:"days"`
+}
+
+// InputAge represents the aggregate coin age over inputs with known heights
+type InputAge struct {
+	InputsWithAge int     `json:"inputs_with_age"`
+	AvgBlocks     float64 `json:"avg_blocks"`
+	AvgDays       float64 `json:"avg_days"`
 }
 
 // RelativeTimelock represents BIP68 relative timelock
@@ -83,6 +494,19 @@ type SegwitSavings struct {
 // Warning represents a transaction warning
 type Warning struct {
 	Code string `json:"code"`
+
+	// Context carries the numbers behind a warning, for codes that have them
+	Context map[string]interface{} `json:"context,omitempty"`
+}
+
+// Finding is a result reported by an external detector (executable or WASM
+// extension). Source names the extension that produced it.
+type Finding struct {
+	Source   string          `json:"source"`
+	Code     string          `json:"code"`
+	Severity string          `json:"severity,omitempty"`
+	Message  string          `json:"message,omitempty"`
+	Details  json.RawMessage `json:"details,omitempty"`
 }
 
 // ErrorInfo represents an error response
@@ -91,11 +515,55 @@ type ErrorInfo struct {
 	Message string `json:"message"`
 }
 
+// ErrorOutput is the result of a transaction that could not be analyzed,
+// without the fields only a parsed transaction has
+type ErrorOutput struct {
+	OK    bool       `json:"ok"`
+	Error *ErrorInfo `json:"error"`
+}
+
 // Fixture represents the input JSON fixture
 type Fixture struct {
 	Network  string         `json:"network"`
 	RawTx    string         `json:"raw_tx"`
 	Prevouts []PrevoutInput `json:"prevouts"`
+
+	// PSBT is a BIP174 PSBT in base64 or hex, analyzed instead of raw_tx
+	// with prevouts taken from its input maps; prevouts given here fill in
+	// inputs whose map has no UTXO
+	PSBT string `json:"psbt,omitempty"`
+
+	// RawTxEncoding is "hex", "base64" or "auto" (the default), which reads
+	// raw_tx as hex when it is all hex digits and as base64 otherwise
+	RawTxEncoding string `json:"raw_tx_encoding,omitempty"`
+
+	// SignetChallenge is the block challenge script (hex) of a custom signet.
+	// Only meaningful when Network is "signet"; empty means the default signet.
+	// The output reports the network magic it derives as signet_magic.
+	SignetChallenge string `json:"signet_challenge,omitempty"`
+
+	// BlockHeight is the height the transaction is (or will be) confirmed at.
+	// Together with prevout heights it enables coin age reporting.
+	BlockHeight *int64 `json:"block_height,omitempty"`
+
+	// AllowMissingPrevouts analyzes inputs without a prevout structurally
+	// instead of failing; fee fields are then reported as null
+	AllowMissingPrevouts bool `json:"allow_missing_prevouts,omitempty"`
+
+	// AnnotateAsm adds structured, annotated token arrays alongside asm strings
+	AnnotateAsm bool `json:"annotate_asm,omitempty"`
+
+	// ExactVsize computes fee rates over the exact weight/4 instead of the
+	// rounded-up vbytes, as some fee estimators do
+	ExactVsize bool `json:"exact_vsize,omitempty"`
+
+	// TaprootOutputs supplies the internal key (and merkle root) behind
+	// P2TR outputs so the tweak can be verified before funds are sent
+	TaprootOutputs []TaprootKeyHint `json:"taproot_outputs,omitempty"`
+
+	// Stages turns individual analysis stages on (true) or off (false) by
+	// name, overriding their defaults for this request
+	Stages map[string]bool `json:"stages,omitempty"`
 }
 
 // PrevoutInput represents a prevout in the fixture
@@ -104,6 +572,11 @@ type PrevoutInput struct {
 	Vout            uint32 `json:"vout"`
 	ValueSats       int64  `json:"value_sats"`
 	ScriptPubkeyHex string `json:"script_pubkey_hex"`
+
+	// Optional: height of the block that created the prevout, or its
+	// confirmation count when the height is not known
+	Height        *int64 `json:"height,omitempty"`
+	Confirmations *int64 `json:"confirmations,omitempty"`
 }
 
 // BlockOutput represents the JSON output for a block
@@ -115,7 +588,27 @@ type BlockOutput struct {
 	Coinbase     CoinbaseInfo        `json:"coinbase"`
 	Transactions []TransactionOutput `json:"transactions"`
 	BlockStats   BlockStats          `json:"block_stats"`
-	Error        *ErrorInfo          `json:"error,omitempty"`
+
+	// DuplicateTxids lists transactions whose txid was already seen
+	// earlier in the run (BIP30 violations)
+	DuplicateTxids []DuplicateTxid `json:"duplicate_txids,omitempty"`
+
+	// Stale marks a block on a losing branch among the blocks parsed
+	// together; it is only determined when whole files are parsed
+	Stale bool `json:"stale,omitempty"`
+
+	// WitnessCommitmentValid checks the coinbase's BIP141 commitment to
+	// the wtxid merkle root; a block without one must carry no witnesses
+	WitnessCommitmentValid bool `json:"witness_commitment_valid"`
+
+	// AddressDeltas nets received outputs against spent prevouts per address
+	AddressDeltas *AddressDeltaReport `json:"address_deltas,omitempty"`
+
+	// TaprootTrees merges the script-path spends of output keys spent
+	// more than once in the block
+	TaprootTrees []TapTree `json:"taproot_trees,omitempty"`
+
+	Error *ErrorInfo `json:"error,omitempty"`
 }
 
 // BlockHeader represents block header information
@@ -128,6 +621,18 @@ type BlockHeader struct {
 	Bits            string `json:"bits"`
 	Nonce           uint32 `json:"nonce"`
 	BlockHash       string `json:"block_hash"`
+
+	// Derived from Bits. ExpectedHashes is a decimal string because the
+	// work behind a modern block overflows a JSON-safe integer. PowValid
+	// reports whether the block hash meets Target.
+	Target         string  `json:"target"`
+	Difficulty     float64 `json:"difficulty"`
+	ExpectedHashes string  `json:"expected_hashes"`
+	PowValid       bool    `json:"pow_valid"`
+
+	// BIP9 deployment bits signalled by Version; absent when it signals
+	// none or predates BIP9
+	VersionBits []int `json:"version_bits,omitempty"`
 }
 
 // CoinbaseInfo represents coinbase transaction info
@@ -135,6 +640,132 @@ type CoinbaseInfo struct {
 	Bip34Height       int64  `json:"bip34_height"`
 	CoinbaseScriptHex string `json:"coinbase_script_hex"`
 	TotalOutputSats   int64  `json:"total_output_sats"`
+
+	Commitments []CoinbaseCommitment `json:"coinbase_commitments"`
+
+	// Message is the printable text in the scriptSig after the height push
+	// (pool tags and the like); Segments holds each text run with its raw hex
+	Message  string                   `json:"coinbase_message"`
+	Segments []CoinbaseMessageSegment `json:"coinbase_message_segments"`
+
+	// Extranonce is the miner-varied region of the scriptSig, when found
+	Extranonce *Extranonce `json:"extranonce"`
+
+	// Timestamps are the Unix times pushed in the scriptSig; Layout splits
+	// the whole scriptSig into the parts found in it
+	Timestamps []CoinbaseTimestamp  `json:"coinbase_timestamps"`
+	Layout     []CoinbaseScriptPart `json:"script_sig_layout"`
+
+	Payouts *CoinbasePayouts `json:"payouts"`
+
+	// PoolName is the mining pool identified by the scriptSig tag and the
+	// primary payout address against a built-in table; PoolConfidence is
+	// "high" when both agree, "medium" when only one is known and "low"
+	// when they disagree. Both are null for an unknown pool.
+	PoolName       *string `json:"pool_name"`
+	PoolConfidence *string `json:"pool_confidence"`
+
+	// SubsidySats is the subsidy due at Bip34Height. A coinbase may claim
+	// at most the subsidy plus the block's fees: Overpaid marks one claiming
+	// more (the block is invalid) and UnderclaimedSats is what is left
+	// unclaimed, which no one can ever spend. All three are null when the
+	// height is unknown.
+	SubsidySats      *int64 `json:"subsidy_sats"`
+	Overpaid         *bool  `json:"coinbase_overpaid"`
+	UnderclaimedSats *int64 `json:"coinbase_underclaimed_sats"`
+}
+
+// CoinbasePayouts summarizes how a coinbase pays out. Pattern is "single",
+// "split", "mass_payout" (P2Pool style) or "none"; the primary payout is the
+// output taking the largest share of the value paid.
+type CoinbasePayouts struct {
+	Pattern           string         `json:"pattern"`
+	PayoutCount       int            `json:"payout_count"`
+	PrimaryAddress    *string        `json:"primary_address"`
+	PrimaryScriptType string         `json:"primary_script_type"`
+	PrimaryShare      float64        `json:"primary_share"`
+	ScriptTypes       map[string]int `json:"script_types"`
+	SegwitPayout      bool           `json:"segwit_payout"`
+	TaprootPayout     bool           `json:"taproot_payout"`
+	OpReturnCount     int            `json:"op_return_count"`
+}
+
+// PayoutHistory groups saved block analyses by the coinbase's primary
+// payout address, with the coinbase messages seen for each, so a pool can
+// be attributed by where it pays rather than by its tag alone
+type PayoutHistory struct {
+	Address     string   `json:"address"`
+	BlockCount  int      `json:"block_count"`
+	BlockHashes []string `json:"block_hashes"`
+	Patterns    []string `json:"patterns"`
+	Messages    []string `json:"coinbase_messages"`
+}
+
+// Extranonce locates the extranonce in a coinbase scriptSig. Layout says
+// how it was found ("pushed" or "trailing"); the extranonce1/extranonce2
+// split is only given for the usual stratum sizes (8, 12 or 16 bytes).
+type Extranonce struct {
+	Layout         string `json:"layout"`
+	Offset         int    `json:"offset"`
+	Length         int    `json:"length"`
+	Hex            string `json:"hex"`
+	Extranonce1Hex string `json:"extranonce1_hex,omitempty"`
+	Extranonce2Hex string `json:"extranonce2_hex,omitempty"`
+}
+
+// CoinbaseTimestamp is a Unix time pushed in a coinbase scriptSig. Unit is
+// the resolution it was pushed in ("s", "ms", "us" or "ns"); Unix is
+// always in seconds and DeltaSecs is how far it lies from the header time.
+type CoinbaseTimestamp struct {
+	Offset    int    `json:"offset"`
+	Length    int    `json:"length"`
+	Unit      string `json:"unit"`
+	Unix      int64  `json:"unix"`
+	DeltaSecs int64  `json:"delta_secs"`
+}
+
+// CoinbaseScriptPart is one region of a coinbase scriptSig: Kind is
+// "height", "commitment", "timestamp", "extranonce", "text" or "data"
+// (anything else, push opcodes included). Text is set for text parts.
+type CoinbaseScriptPart struct {
+	Kind   string `json:"kind"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	Hex    string `json:"hex"`
+	Text   string `json:"text,omitempty"`
+}
+
+// CoinbaseMessageSegment is one printable run in a coinbase scriptSig.
+// Offset is its byte position in the scriptSig.
+type CoinbaseMessageSegment struct {
+	Offset int    `json:"offset"`
+	Hex    string `json:"hex"`
+	Text   string `json:"text"`
+}
+
+// CoinbaseCommitment is a commitment another chain or protocol placed in a
+// coinbase. Hash is the committed block hash or merkle root when the format
+// carries one; Fields holds format-specific numbers (AuxPoW tree size and
+// nonce).
+type CoinbaseCommitment struct {
+	Type     string            `json:"type"`
+	Location string            `json:"location"` // script_sig or output
+	Vout     *int              `json:"vout,omitempty"`
+	Hash     string            `json:"hash,omitempty"`
+	DataHex  string            `json:"data_hex"`
+	Fields   map[string]uint32 `json:"fields,omitempty"`
+}
+
+// DuplicateTxid records a txid that repeats one seen earlier. Historical is
+// set for the two mainnet coinbases duplicated before BIP30 (blocks 91842 and
+// 91880); for those the first occurrence may lie outside the parsed range.
+type DuplicateTxid struct {
+	Txid           string `json:"txid"`
+	TxIndex        int    `json:"tx_index"`
+	Coinbase       bool   `json:"coinbase"`
+	FirstBlockHash string `json:"first_block_hash,omitempty"`
+	FirstTxIndex   *int   `json:"first_tx_index,omitempty"`
+	Historical     bool   `json:"historical"`
 }
 
 // BlockStats represents block-level statistics
@@ -143,4 +774,882 @@ type BlockStats struct {
 	TotalWeight       int            `json:"total_weight"`
 	AvgFeeRateSatVb   float64        `json:"avg_fee_rate_sat_vb"`
 	ScriptTypeSummary map[string]int `json:"script_type_summary"`
+
+	// Outputs below the DUST_OUTPUT threshold created by and spent in
+	// this block; OP_RETURN outputs are not counted
+	DustCreated int `json:"dust_outputs_created"`
+	DustSpent   int `json:"dust_outputs_spent"`
+
+	// Tapscript data envelopes revealed in this block and their payload size
+	EnvelopeCount int `json:"envelope_count"`
+	EnvelopeBytes int `json:"envelope_bytes"`
+
+	// Sigops totals the block's signature operations, held to
+	// MAX_BLOCK_SIGOPS_COST
+	Sigops *SigopCount `json:"sigops,omitempty"`
+}
+
+// BlockSeriesPoint is one block's row in a multi-block time series.
+// ScriptTypes counts outputs by script type; the percentages are of
+// non-coinbase transactions, inputs and outputs respectively.
+type BlockSeriesPoint struct {
+	Height             int64          `json:"height"`
+	BlockHash          string         `json:"block_hash"`
+	Timestamp          uint32         `json:"timestamp"`
+	Stale              bool           `json:"stale,omitempty"`
+	TxCount            int            `json:"tx_count"`
+	TotalFeesSats      int64          `json:"total_fees_sats"`
+	TotalWeight        int            `json:"total_weight"`
+	AvgFeeRateSatVb    float64        `json:"avg_fee_rate_sat_vb"`
+	MedianFeeRateSatVb float64        `json:"median_fee_rate_sat_vb"`
+	ScriptTypes        map[string]int `json:"script_types"`
+	SegwitTxPct        float64        `json:"segwit_tx_pct"`
+	TaprootInputPct    float64        `json:"taproot_input_pct"`
+	TaprootOutputPct   float64        `json:"taproot_output_pct"`
+	CoinDaysDestroyed  float64        `json:"coin_days_destroyed"`
+}
+
+// BlockSeriesOutput is the time series of a block range, ordered by height
+type BlockSeriesOutput struct {
+	OK         bool               `json:"ok"`
+	Mode       string             `json:"mode"`
+	BlockCount int                `json:"block_count"`
+	Points     []BlockSeriesPoint `json:"points"`
+}
+
+// AggregateStats totals the non-stale blocks of a stats run
+type AggregateStats struct {
+	Blocks           int                 `json:"blocks"`
+	StaleBlocks      int                 `json:"stale_blocks"`
+	FirstHeight      int64               `json:"first_height"`
+	LastHeight       int64               `json:"last_height"`
+	FirstTimestamp   uint32              `json:"first_timestamp"`
+	LastTimestamp    uint32              `json:"last_timestamp"`
+	TxCount          int                 `json:"tx_count"`
+	TotalFeesSats    int64               `json:"total_fees_sats"`
+	TotalWeight      int64               `json:"total_weight"`
+	AvgFeeRateSatVb  float64             `json:"avg_fee_rate_sat_vb"`
+	ScriptTypeTotals map[string]int      `json:"script_type_totals"`
+	FeeRates         FeeRateDistribution `json:"fee_rate_distribution"`
+	Signals          *VersionBitsSummary `json:"signals,omitempty"`
+}
+
+// FeeRateDistribution summarizes non-coinbase transaction fee rates.
+// Rates are resolved to 0.1 sat/vB.
+type FeeRateDistribution struct {
+	TxCount  int             `json:"tx_count"`
+	MinSatVb float64         `json:"min_sat_vb"`
+	P10      float64         `json:"p10_sat_vb"`
+	P25      float64         `json:"p25_sat_vb"`
+	P50      float64         `json:"p50_sat_vb"`
+	P75      float64         `json:"p75_sat_vb"`
+	P90      float64         `json:"p90_sat_vb"`
+	MaxSatVb float64         `json:"max_sat_vb"`
+	Buckets  []FeeRateBucket `json:"buckets"`
+}
+
+// FeeRateBucket counts transactions with MinSatVb <= rate < MaxSatVb; the
+// last bucket has no upper bound
+type FeeRateBucket struct {
+	MinSatVb float64  `json:"min_sat_vb"`
+	MaxSatVb *float64 `json:"max_sat_vb"`
+	TxCount  int      `json:"tx_count"`
+}
+
+// DayStats is the aggregate of the blocks timestamped on one UTC date
+type DayStats struct {
+	Date string `json:"date"`
+	AggregateStats
+}
+
+// AggregateStatsOutput is the report of a stats run over many block files
+type AggregateStatsOutput struct {
+	OK        bool           `json:"ok"`
+	Mode      string         `json:"mode"`
+	FileCount int            `json:"file_count"`
+	Stats     AggregateStats `json:"stats"`
+	Days      []DayStats     `json:"days,omitempty"`
+}
+
+// MempoolEntry is one transaction of a mempool.dat file: when it entered
+// the node's mempool and the fee delta prioritisetransaction gave it
+type MempoolEntry struct {
+	Time         int64              `json:"time"`
+	FeeDeltaSats int64              `json:"fee_delta_sats"`
+	Tx           *TransactionOutput `json:"tx"`
+}
+
+// MempoolFeeDelta is a prioritisetransaction fee delta kept for a
+// transaction that was not in the mempool when it was saved
+type MempoolFeeDelta struct {
+	Txid      string `json:"txid"`
+	DeltaSats int64  `json:"delta_sats"`
+}
+
+// MempoolStats totals the transactions of a mempool.dat file. Fees and
+// fee rates cover only the transactions whose prevouts were all found,
+// counted in FeeKnownTxs.
+type MempoolStats struct {
+	TxCount          int                 `json:"tx_count"`
+	FeeKnownTxs      int                 `json:"fee_known_txs"`
+	InvalidTxs       int                 `json:"invalid_txs"`
+	TotalVbytes      int64               `json:"total_vbytes"`
+	TotalWeight      int64               `json:"total_weight"`
+	TotalFeesSats    int64               `json:"total_fees_sats"`
+	AvgFeeRateSatVb  float64             `json:"avg_fee_rate_sat_vb"`
+	SegwitTxs        int                 `json:"segwit_txs"`
+	RbfSignalingTxs  int                 `json:"rbf_signaling_txs"`
+	PrioritisedTxs   int                 `json:"prioritised_txs"`
+	ChildTxs         int                 `json:"child_txs"` // spending another entry
+	FirstTime        int64               `json:"first_time"`
+	LastTime         int64               `json:"last_time"`
+	ScriptTypeTotals map[string]int      `json:"script_type_totals"`
+	FeeRates         FeeRateDistribution `json:"fee_rate_distribution"`
+	Signals          *VersionBitsSummary `json:"signals,omitempty"`
+}
+
+// MempoolOutput is the analysis of a Bitcoin Core mempool.dat file
+type MempoolOutput struct {
+	OK               bool              `json:"ok"`
+	Mode             string            `json:"mode"`
+	Version          uint64            `json:"version"`
+	Stats            MempoolStats      `json:"stats"`
+	FeeDeltas        []MempoolFeeDelta `json:"fee_deltas"`
+	UnbroadcastTxids []string          `json:"unbroadcast_txids"`
+	Entries          []MempoolEntry    `json:"entries"`
+}
+
+// PeerAddress is a node address as Bitcoin Core stores it on disk. Network
+// is ipv4, ipv6, onion, i2p, cjdns or internal; Time is when the node last
+// heard of the address.
+type PeerAddress struct {
+	Address  string `json:"address"`
+	Port     uint16 `json:"port"`
+	Network  string `json:"network"`
+	Services string `json:"services"`
+	Time     uint32 `json:"time"`
+}
+
+// AddrManEntry is one address of a peers.dat file. Table is "new" for
+// addresses heard of and "tried" for ones connected to; Source is the
+// address of the peer that announced it. NewBucketRefs counts the new-table
+// buckets holding the address and is 0 for tried entries.
+type AddrManEntry struct {
+	PeerAddress
+	Table         string `json:"table"`
+	Source        string `json:"source"`
+	SourceNetwork string `json:"source_network"`
+	LastSuccess   int64  `json:"last_success"`
+	Attempts      int32  `json:"attempts"`
+	NewBucketRefs int    `json:"new_bucket_refs"`
+}
+
+// PeersOutput is the decoded address manager of a peers.dat file. The
+// file's bucketing key is secret to the node and left out.
+type PeersOutput struct {
+	OK               bool           `json:"ok"`
+	Mode             string         `json:"mode"`
+	Network          string         `json:"network,omitempty"`
+	Magic            string         `json:"magic"`
+	Format           uint8          `json:"format"`
+	LowestCompatible uint8          `json:"lowest_compatible"`
+	NewCount         int            `json:"new_count"`
+	TriedCount       int            `json:"tried_count"`
+	NetworkCounts    map[string]int `json:"network_counts"`
+	AsmapChecksum    string         `json:"asmap_checksum,omitempty"`
+	Entries          []AddrManEntry `json:"entries"`
+}
+
+// AnchorsOutput is the decoded anchors.dat file: the block-relay-only
+// peers a node reconnects to first after a restart
+type AnchorsOutput struct {
+	OK      bool          `json:"ok"`
+	Mode    string        `json:"mode"`
+	Network string        `json:"network,omitempty"`
+	Magic   string        `json:"magic"`
+	Anchors []PeerAddress `json:"anchors"`
+}
+
+// UTXOCoin is one unspent output of a UTXO snapshot, with the height of
+// the block that created it
+type UTXOCoin struct {
+	Txid            string `json:"txid"`
+	Vout            uint32 `json:"vout"`
+	Height          int64  `json:"height"`
+	Coinbase        bool   `json:"coinbase"`
+	ValueSats       int64  `json:"value_sats"`
+	ScriptPubkeyHex string `json:"script_pubkey_hex"`
+	ScriptType      string `json:"script_type"`
+}
+
+// UTXOTotal counts coins and the value they hold
+type UTXOTotal struct {
+	Coins     uint64 `json:"coins"`
+	ValueSats int64  `json:"value_sats"`
+}
+
+// UTXOValueBucket totals the coins with MinSats <= value < MaxSats; the
+// last bucket has no upper bound
+type UTXOValueBucket struct {
+	MinSats   int64  `json:"min_sats"`
+	MaxSats   *int64 `json:"max_sats"`
+	Coins     uint64 `json:"coins"`
+	ValueSats int64  `json:"value_sats"`
+}
+
+// UTXOSetStats summarizes the coins of a UTXO set
+type UTXOSetStats struct {
+	Coins          uint64               `json:"coins"`
+	Txids          uint64               `json:"txids"`
+	TotalValueSats int64                `json:"total_value_sats"`
+	CoinbaseCoins  uint64               `json:"coinbase_coins"`
+	MinHeight      int64                `json:"min_height"`
+	MaxHeight      int64                `json:"max_height"`
+	ScriptTypes    map[string]UTXOTotal `json:"script_types"`
+	ValueBuckets   []UTXOValueBucket    `json:"value_buckets"`
+}
+
+// UTXOSnapshotOutput is the analysis of a Bitcoin Core UTXO snapshot
+// (dumptxoutset). Version 0 is the unversioned format of nodes before v27.
+type UTXOSnapshotOutput struct {
+	OK            bool         `json:"ok"`
+	Mode          string       `json:"mode"`
+	Version       uint16       `json:"version"`
+	Network       string       `json:"network,omitempty"`
+	Magic         string       `json:"magic,omitempty"`
+	BaseBlockHash string       `json:"base_block_hash"`
+	CoinsCount    uint64       `json:"coins_count"`
+	Stats         UTXOSetStats `json:"stats"`
+}
+
+// AddressDeltaReport lists the addresses whose balance a block changed
+// most. Gainers and Losers count all addresses with a net change.
+type AddressDeltaReport struct {
+	AddressCount int            `json:"address_count"`
+	Gainers      int            `json:"gainers"`
+	Losers       int            `json:"losers"`
+	TopMovers    []AddressDelta `json:"top_movers"`
+}
+
+// AddressDelta is one address's net balance change within a block
+type AddressDelta struct {
+	Address      string `json:"address"`
+	ReceivedSats int64  `json:"received_sats"`
+	SpentSats    int64  `json:"spent_sats"`
+	NetSats      int64  `json:"net_sats"`
+	OutputCount  int    `json:"output_count"`
+	InputCount   int    `json:"input_count"`
+}
+
+// BlockDiffOutput compares two analyzed blocks. Fee differences are B
+// minus A; ExclusiveFees sum the fees of the transactions only one block
+// confirmed.
+type BlockDiffOutput struct {
+	OK               bool               `json:"ok"`
+	Mode             string             `json:"mode"`
+	A                BlockDiffSide      `json:"a"`
+	B                BlockDiffSide      `json:"b"`
+	SameParent       bool               `json:"same_parent"`
+	SharedCount      int                `json:"shared_count"`
+	OnlyInA          []BlockDiffTx      `json:"only_in_a"`
+	OnlyInB          []BlockDiffTx      `json:"only_in_b"`
+	WitnessChanged   []string           `json:"witness_changed,omitempty"`
+	DoubleSpends     []BlockDoubleSpend `json:"double_spends,omitempty"`
+	FeeDiffSats      int64              `json:"fee_diff_sats"`
+	CoinbaseDiffSats int64              `json:"coinbase_diff_sats"`
+	ExclusiveFeesA   int64              `json:"exclusive_fees_a_sats"`
+	ExclusiveFeesB   int64              `json:"exclusive_fees_b_sats"`
+	Error            *ErrorInfo         `json:"error,omitempty"`
+}
+
+// BlockDiffSide summarizes one of the compared blocks
+type BlockDiffSide struct {
+	BlockHash          string `json:"block_hash"`
+	PrevBlockHash      string `json:"prev_block_hash"`
+	Height             int64  `json:"height"`
+	Timestamp          uint32 `json:"timestamp"`
+	Stale              bool   `json:"stale,omitempty"`
+	TxCount            int    `json:"tx_count"`
+	TotalFeesSats      int64  `json:"total_fees_sats"`
+	CoinbaseOutputSats int64  `json:"coinbase_output_sats"`
+}
+
+// BlockDiffTx is a transaction confirmed by only one of the blocks
+type BlockDiffTx struct {
+	Txid         string   `json:"txid"`
+	FeeSats      *int64   `json:"fee_sats"`
+	FeeRateSatVb *float64 `json:"fee_rate_sat_vb"`
+}
+
+// BlockDoubleSpend is an outpoint the two blocks spend in different
+// transactions
+type BlockDoubleSpend struct {
+	Outpoint string `json:"outpoint"`
+	TxidA    string `json:"txid_a"`
+	TxidB    string `json:"txid_b"`
+}
+
+// AddressOutput represents the JSON output for an address-to-script lookup
+type AddressOutput struct {
+	OK              bool       `json:"ok"`
+	Network         string     `json:"network,omitempty"`
+	Address         string     `json:"address,omitempty"`
+	ScriptType      string     `json:"script_type,omitempty"`
+	ScriptPubkeyHex string     `json:"script_pubkey_hex,omitempty"`
+	ScriptAsm       string     `json:"script_asm,omitempty"`
+	Error           *ErrorInfo `json:"error,omitempty"`
+}
+
+// PaymentURIOutput is a parsed BIP21 bitcoin: payment URI
+type PaymentURIOutput struct {
+	OK              bool               `json:"ok"`
+	Network         string             `json:"network,omitempty"`
+	URI             string             `json:"uri"`
+	Address         string             `json:"address,omitempty"`
+	ScriptType      string             `json:"script_type,omitempty"`
+	ScriptPubkeyHex string             `json:"script_pubkey_hex,omitempty"`
+	AmountBTC       string             `json:"amount_btc,omitempty"`
+	AmountSats      *int64             `json:"amount_sats,omitempty"`
+	Label           string             `json:"label,omitempty"`
+	Message         string             `json:"message,omitempty"`
+	Lightning       *LightningFallback `json:"lightning,omitempty"`
+	Payjoin         *PayjoinParams     `json:"payjoin,omitempty"`
+	Params          map[string]string  `json:"params,omitempty"` // other optional parameters
+	Error           *ErrorInfo         `json:"error,omitempty"`
+}
+
+// LightningFallback is the BOLT11 invoice in a URI's lightning parameter
+type LightningFallback struct {
+	Invoice    string `json:"invoice"`
+	Network    string `json:"network"`
+	AmountMsat *int64 `json:"amount_msat,omitempty"`
+}
+
+// PayjoinParams are a URI's BIP78 pj and pjos parameters
+type PayjoinParams struct {
+	Endpoint           string `json:"endpoint"`
+	OutputSubstitution bool   `json:"output_substitution"`
+}
+
+// Job represents the JSON status of an asynchronous analysis job
+type Job struct {
+	ID         string       `json:"id"`
+	Kind       string       `json:"kind"`
+	Status     string       `json:"status"`
+	Progress   *JobProgress `json:"progress,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+	StartedAt  *time.Time   `json:"started_at,omitempty"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+	Error      *ErrorInfo   `json:"error,omitempty"`
+}
+
+// JobProgress reports how many transactions of a block job are analyzed
+type JobProgress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// FeedEvent summarizes one analysis on the /api/ws live feed. Kind is "tx"
+// or "block"; Source is where it was analyzed: "api", "graphql" or "job".
+// FeeSats and FeeRateSatVb are null for transactions without prevouts.
+type FeedEvent struct {
+	Kind         string    `json:"kind"`
+	Source       string    `json:"source"`
+	Time         time.Time `json:"time"`
+	ID           string    `json:"id"` // txid or block hash
+	Network      string    `json:"network,omitempty"`
+	Height       int64     `json:"height,omitempty"`
+	TxCount      int       `json:"tx_count,omitempty"`
+	Vbytes       int       `json:"vbytes,omitempty"`
+	Weight       int       `json:"weight,omitempty"`
+	FeeSats      *int64    `json:"fee_sats"`
+	FeeRateSatVb *float64  `json:"fee_rate_sat_vb"`
+	Warnings     int       `json:"warnings,omitempty"`
+}
+
+// FeedStats are running totals over every analysis published to the live
+// feed since the server started
+type FeedStats struct {
+	Since           time.Time `json:"since"`
+	Transactions    int       `json:"transactions"`
+	Blocks          int       `json:"blocks"`
+	TotalFeesSats   int64     `json:"total_fees_sats"`
+	AvgFeeRateSatVb float64   `json:"avg_fee_rate_sat_vb"`
+	MaxFeeRateSatVb float64   `json:"max_fee_rate_sat_vb"`
+}
+
+// FeedMessage is one WebSocket message on /api/ws: a "snapshot" with the
+// recent events on connect, then an "event" for each new analysis
+type FeedMessage struct {
+	Type   string      `json:"type"`
+	Events []FeedEvent `json:"events,omitempty"`
+	Event  *FeedEvent  `json:"event,omitempty"`
+	Stats  FeedStats   `json:"stats"`
+}
+
+// StreamItem is one NDJSON line written by cmd/stream: the analysis of a
+// transaction bitcoind announced over ZMQ, either entering the mempool
+// (topic "rawtx") or in a newly connected block (topic "rawblock", with
+// BlockHash and the transaction's Index in the block). Sequence is
+// bitcoind's per-topic notification counter. Tx holds a TransactionOutput
+// at the requested verbosity, with OK false and an error when the
+// transaction could not be analyzed.
+type StreamItem struct {
+	Topic      string      `json:"topic"`
+	Sequence   uint32      `json:"sequence"`
+	ReceivedAt time.Time   `json:"received_at"`
+	BlockHash  string      `json:"block_hash,omitempty"`
+	Index      *int        `json:"index,omitempty"`
+	Tx         interface{} `json:"tx"`
+}
+
+// SavedAnalysis is a named, tagged analysis kept in the web workspace.
+// Result holds the transaction or block output exactly as first returned;
+// it is omitted when listing.
+type SavedAnalysis struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Tags      []string        `json:"tags"`
+	Kind      string          `json:"kind"`
+	CreatedAt time.Time       `json:"created_at"`
+	Fixture   *Fixture        `json:"fixture,omitempty"`
+	JobID     string          `json:"job_id,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+}
+
+// ResultEntry describes a stored result listed by /api/results: a CLI
+// result file (source "out") or a saved analysis (source "analysis").
+// Matches holds what a ?q= search found in it.
+type ResultEntry struct {
+	ID           string    `json:"id"`
+	Source       string    `json:"source"`
+	Kind         string    `json:"kind"`
+	Name         string    `json:"name,omitempty"`
+	Txid         string    `json:"txid,omitempty"`
+	BlockHashes  []string  `json:"block_hashes,omitempty"`
+	Height       int64     `json:"height,omitempty"`
+	Timestamp    uint32    `json:"timestamp,omitempty"`
+	TxCount      int       `json:"tx_count,omitempty"`
+	WarningCodes []string  `json:"warning_codes"`
+	ModifiedAt   time.Time `json:"modified_at"`
+	Matches      []string  `json:"matches,omitempty"`
+}
+
+// StoreEntry is one result in a content-addressed result store. Bytes is
+// the JSON size, StoredBytes its size on disk; Path is relative to the
+// store root.
+type StoreEntry struct {
+	Key         string    `json:"key"`
+	Kind        string    `json:"kind"`
+	SHA256      string    `json:"sha256"`
+	Path        string    `json:"path"`
+	Bytes       int64     `json:"bytes"`
+	StoredBytes int64     `json:"stored_bytes"`
+	Compressed  bool      `json:"compressed"`
+	CreatedAt   time.Time `json:"created_at"`
+	WrittenAt   time.Time `json:"written_at"`
+}
+
+// StorePruneOutput reports what a store prune removed
+type StorePruneOutput struct {
+	OK          bool         `json:"ok"`
+	Mode        string       `json:"mode"`
+	Removed     []StoreEntry `json:"removed"`
+	FreedBytes  int64        `json:"freed_bytes"`
+	Kept        int          `json:"kept"`
+	StoredBytes int64        `json:"stored_bytes"`
+}
+
+// ArchiveManifest is the index written as manifest.json inside a block-run
+// archive, listing every result file it contains
+type ArchiveManifest struct {
+	CreatedAt time.Time      `json:"created_at"`
+	Files     []ArchiveEntry `json:"files"`
+}
+
+// ArchiveEntry describes one block result file in an archive
+type ArchiveEntry struct {
+	Name      string `json:"name"`
+	BlockHash string `json:"block_hash"`
+	TxCount   int    `json:"tx_count"`
+	Bytes     int64  `json:"bytes"`
+	SHA256    string `json:"sha256"`
+}
+
+// WriteReport lists the output files a CLI run wrote, printed to stderr with
+// --write-report
+type WriteReport struct {
+	OK      bool          `json:"ok"`
+	Mode    string        `json:"mode"` // "write_report"
+	Policy  string        `json:"policy"`
+	Files   []OutputWrite `json:"files"`
+	Written int           `json:"written"`
+	Skipped int           `json:"skipped"`
+	Bytes   int64         `json:"bytes"`
+}
+
+// OutputWrite is one output file and what happened to it: "created",
+// "replaced", "skipped" (it existed and the policy kept it), or "stored" and
+// "deduped" for result store objects
+type OutputWrite struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// CompactBlockFixture is the input for BIP152 compact block reconstruction:
+// a cmpctblock payload, the transactions available locally (mempool) and,
+// optionally, the blocktxn response carrying the transactions still missing
+type CompactBlockFixture struct {
+	Network    string   `json:"network"`
+	CmpctBlock string   `json:"cmpctblock"`
+	BlockTxn   string   `json:"blocktxn,omitempty"`
+	Mempool    []string `json:"mempool"`
+
+	// ShortIDVersion selects the hash short IDs are computed over:
+	// 1 = txid, 2 = wtxid (the default, as negotiated by segwit peers)
+	ShortIDVersion int `json:"short_id_version,omitempty"`
+
+	// Prevouts for the block's inputs, if known. Transactions are analyzed
+	// leniently, so fees are null wherever a prevout is missing.
+	Prevouts []PrevoutInput `json:"prevouts,omitempty"`
+}
+
+// CompactBlockOutput represents the JSON output for a decoded compact block
+type CompactBlockOutput struct {
+	OK               bool                `json:"ok"`
+	Mode             string              `json:"mode"`
+	BlockHeader      BlockHeader         `json:"block_header"`
+	ShortIDNonce     uint64              `json:"short_id_nonce"`
+	ShortIDVersion   int                 `json:"short_id_version"`
+	ShortIDCount     int                 `json:"short_id_count"`
+	PrefilledIndexes []int               `json:"prefilled_indexes"`
+	MempoolMatches   int                 `json:"mempool_matches"`
+	BlockTxnCount    int                 `json:"blocktxn_count"`
+	MissingIndexes   []int               `json:"missing_indexes"`
+	Reconstructed    bool                `json:"reconstructed"`
+	TxCount          int                 `json:"tx_count"`
+	Transactions     []TransactionOutput `json:"transactions,omitempty"`
+	Error            *ErrorInfo          `json:"error,omitempty"`
+}
+
+// TemplateFixture is the input for comparing a block with a block template:
+// the raw block and a saved getblocktemplate result
+type TemplateFixture struct {
+	Network  string        `json:"network"`
+	Block    string        `json:"block"`
+	Template BlockTemplate `json:"template"`
+}
+
+// BlockTemplate holds the getblocktemplate fields the comparison uses
+type BlockTemplate struct {
+	PreviousBlockHash string            `json:"previousblockhash"`
+	Height            int64             `json:"height"`
+	CurTime           int64             `json:"curtime"`
+	CoinbaseValue     int64             `json:"coinbasevalue"`
+	Transactions      []BlockTemplateTx `json:"transactions"`
+}
+
+// BlockTemplateTx is one getblocktemplate transaction. Txid is computed
+// from Data when the template omits it.
+type BlockTemplateTx struct {
+	Data   string `json:"data"`
+	Txid   string `json:"txid"`
+	Fee    int64  `json:"fee"`
+	Weight int64  `json:"weight"`
+}
+
+// TemplateComparisonOutput reports how a block differs from a template:
+// which template transactions it included or left out, which transactions
+// it added, and the fees of each set. BlockFeesSats is the coinbase value
+// minus the subsidy and is null when the block height is unknown.
+type TemplateComparisonOutput struct {
+	OK             bool        `json:"ok"`
+	Mode           string      `json:"mode"`
+	BlockHeader    BlockHeader `json:"block_header"`
+	Height         int64       `json:"height"`
+	TemplateHeight int64       `json:"template_height"`
+	SameParent     bool        `json:"same_parent"`
+	TimeDeltaSecs  int64       `json:"time_delta_secs"`
+
+	TemplateTxCount int             `json:"template_tx_count"`
+	BlockTxCount    int             `json:"block_tx_count"`
+	Included        []TemplateTxRef `json:"included"`
+	Excluded        []TemplateTxRef `json:"excluded"`
+	AddedTxids      []string        `json:"added_txids"`
+
+	TemplateFeesSats      int64  `json:"template_fees_sats"`
+	IncludedFeesSats      int64  `json:"included_fees_sats"`
+	ExcludedFeesSats      int64  `json:"excluded_fees_sats"`
+	BlockFeesSats         *int64 `json:"block_fees_sats"`
+	FeeDeltaSats          *int64 `json:"fee_delta_sats"`
+	TemplateCoinbaseValue int64  `json:"template_coinbase_value"`
+	BlockCoinbaseValue    int64  `json:"block_coinbase_value"`
+
+	Error *ErrorInfo `json:"error,omitempty"`
+}
+
+// TemplateTxRef is a template transaction; BlockIndex is its position in
+// the block when it was included
+type TemplateTxRef struct {
+	Txid       string `json:"txid"`
+	FeeSats    int64  `json:"fee_sats"`
+	Weight     int64  `json:"weight"`
+	BlockIndex *int   `json:"block_index,omitempty"`
+}
+
+// P2PMessageOutput represents the JSON output for a decoded P2P wire message.
+// Message holds the decoded payload; its shape depends on Command.
+type P2PMessageOutput struct {
+	OK            bool        `json:"ok"`
+	Mode          string      `json:"mode"`
+	Network       string      `json:"network,omitempty"`
+	Command       string      `json:"command,omitempty"`
+	PayloadBytes  int         `json:"payload_bytes"`
+	ChecksumValid *bool       `json:"checksum_valid,omitempty"`
+	Message       interface{} `json:"message,omitempty"`
+	Error         *ErrorInfo  `json:"error,omitempty"`
+}
+
+// HeaderOutput represents the JSON output for a standalone 80-byte block
+// header decode
+type HeaderOutput struct {
+	OK          bool         `json:"ok"`
+	Mode        string       `json:"mode"`
+	BlockHeader *BlockHeader `json:"block_header,omitempty"`
+	VersionHex  string       `json:"version_hex,omitempty"`
+	VersionBits []int        `json:"version_bits,omitempty"`
+	PowValid    bool         `json:"pow_valid"`
+	Error       *ErrorInfo   `json:"error,omitempty"`
+}
+
+// HeaderChainOutput decodes consecutive block headers, each linking to the
+// one before, with the next difficulty adjustment they point to
+type HeaderChainOutput struct {
+	OK       bool              `json:"ok"`
+	Mode     string            `json:"mode"`
+	Headers  []HeaderOutput    `json:"headers,omitempty"`
+	Retarget *RetargetEstimate `json:"retarget,omitempty"`
+	Error    *ErrorInfo        `json:"error,omitempty"`
+}
+
+// RetargetEstimate projects the next difficulty adjustment from the block
+// intervals of the headers in the current 2016-block period, by the
+// mainnet rule: 600 s per block, with the change clamped to a factor of 4.
+// Where the period ends is only known when the height of the first header
+// is.
+type RetargetEstimate struct {
+	HeadersUsed           int     `json:"headers_used"`
+	AvgBlockIntervalSecs  float64 `json:"avg_block_interval_secs"`
+	CurrentDifficulty     float64 `json:"current_difficulty"`
+	EstimatedDifficulty   float64 `json:"estimated_difficulty"`
+	ChangePercent         float64 `json:"change_percent"`
+	NextRetargetHeight    *int64  `json:"next_retarget_height,omitempty"`
+	BlocksUntilRetarget   *int64  `json:"blocks_until_retarget,omitempty"`
+	EstimatedRetargetTime *int64  `json:"estimated_retarget_time,omitempty"`
+}
+
+// BloomFixture builds a BIP37 bloom filter (or loads one from a filterload
+// payload) and matches transactions against it. Scripts are
+// scriptPubKeys in hex, added like addresses through their data pushes;
+// outpoints are "txid:vout"; elements are raw hex data. Transactions are raw hex, matched in order
+// after those of Block.
+type BloomFixture struct {
+	Network      string   `json:"network"`
+	Addresses    []string `json:"addresses,omitempty"`
+	Scripts      []string `json:"scripts,omitempty"`
+	Outpoints    []string `json:"outpoints,omitempty"`
+	Elements     []string `json:"elements,omitempty"`
+	FPRate       float64  `json:"fp_rate,omitempty"` // default 0.0001
+	Tweak        uint32   `json:"tweak,omitempty"`
+	Update       string   `json:"update,omitempty"` // none (default), all, p2pubkey_only
+	FilterLoad   string   `json:"filterload,omitempty"`
+	Block        string   `json:"block,omitempty"`
+	Transactions []string `json:"transactions,omitempty"`
+}
+
+// BloomFilterInfo describes a filter after matching, including any
+// outpoints the update flags added
+type BloomFilterInfo struct {
+	FilterHex     string  `json:"filter_hex"`
+	Bytes         int     `json:"bytes"`
+	HashFuncs     uint32  `json:"hash_funcs"`
+	Tweak         uint32  `json:"tweak"`
+	Update        string  `json:"update"`
+	Elements      int     `json:"elements,omitempty"`
+	FillRatio     float64 `json:"fill_ratio"`
+	FilterLoadHex string  `json:"filterload_hex"`
+}
+
+// BloomMatch is the result for one transaction
+type BloomMatch struct {
+	Txid    string   `json:"txid"`
+	Matched bool     `json:"matched"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// BloomOutput is the result of building and matching a bloom filter. The
+// filter is reported both before (as loaded) and after matching.
+// MerkleBlockHex, given for a block, is the merkleblock payload proving its
+// matched transactions, in the gettxoutproof format --merkle-proof reads.
+type BloomOutput struct {
+	OK             bool             `json:"ok"`
+	Mode           string           `json:"mode"`
+	Network        string           `json:"network,omitempty"`
+	Filter         *BloomFilterInfo `json:"filter,omitempty"`
+	FinalFilter    *BloomFilterInfo `json:"final_filter,omitempty"`
+	TxCount        int              `json:"tx_count"`
+	MatchedCount   int              `json:"matched_count"`
+	Matches        []BloomMatch     `json:"matches,omitempty"`
+	MerkleBlockHex string           `json:"merkle_block_hex,omitempty"`
+	Error          *ErrorInfo       `json:"error,omitempty"`
+}
+
+// MerkleProofFixture is the input for SPV proof verification: a txid and
+// either a gettxoutproof hex or a merkle branch (sibling hashes from the
+// leaf up, display byte order) with the tx index and block header
+type MerkleProofFixture struct {
+	Txid       string   `json:"txid"`
+	Header     string   `json:"header,omitempty"`
+	TxOutProof string   `json:"txoutproof,omitempty"`
+	Branch     []string `json:"branch,omitempty"`
+	Index      *int     `json:"index,omitempty"`
+}
+
+// MerkleProofOutput reports whether a proof links a txid to a block header.
+// TxCount and MatchedTxids come from gettxoutproof proofs only.
+type MerkleProofOutput struct {
+	OK                bool         `json:"ok"`
+	Mode              string       `json:"mode"`
+	Format            string       `json:"format,omitempty"`
+	Txid              string       `json:"txid,omitempty"`
+	BlockHeader       *BlockHeader `json:"block_header,omitempty"`
+	ReconstructedRoot string       `json:"reconstructed_root,omitempty"`
+	Included          bool         `json:"included"`
+	TxIndex           *int         `json:"tx_index,omitempty"`
+	TxCount           *int         `json:"tx_count,omitempty"`
+	MatchedTxids      []string     `json:"matched_txids,omitempty"`
+	Error             *ErrorInfo   `json:"error,omitempty"`
+}
+
+// MerkleBranchFixture asks for inclusion proofs of transactions in a raw
+// block (hex)
+type MerkleBranchFixture struct {
+	Block string   `json:"block"`
+	Txids []string `json:"txids"`
+}
+
+// MerkleBranchProof is an inclusion proof for one transaction: its index
+// in the block and the sibling hashes from the leaf up (display byte
+// order), which with the header hex make a branch fixture for
+// VerifyMerkleProof. MerkleBlockHex proves the same transaction as a P2P
+// merkleblock payload, the format of gettxoutproof.
+type MerkleBranchProof struct {
+	Txid           string   `json:"txid"`
+	BlockHash      string   `json:"block_hash"`
+	HeaderHex      string   `json:"header_hex"`
+	TxCount        int      `json:"tx_count"`
+	Index          int      `json:"index"`
+	Branch         []string `json:"branch"`
+	MerkleBlockHex string   `json:"merkle_block_hex"`
+}
+
+// MerkleBranchOutput holds the proofs generated for the requested txids, in
+// request order; NotFound lists those no block contained
+type MerkleBranchOutput struct {
+	OK       bool                `json:"ok"`
+	Mode     string              `json:"mode"`
+	Proofs   []MerkleBranchProof `json:"proofs,omitempty"`
+	NotFound []string            `json:"not_found,omitempty"`
+	Error    *ErrorInfo          `json:"error,omitempty"`
+}
+
+// HeaderIndexOutput is the per-block index produced by a headers-only scan
+// of block files
+type HeaderIndexOutput struct {
+	OK         bool                `json:"ok"`
+	Mode       string              `json:"mode"`
+	FileCount  int                 `json:"file_count"`
+	BlockCount int                 `json:"block_count"`
+	Blocks     []HeaderIndexEntry  `json:"blocks"`
+	Signals    *VersionBitsSummary `json:"signals,omitempty"`
+	Error      *ErrorInfo          `json:"error,omitempty"`
+}
+
+// HeaderIndexEntry locates one block in a blk*.dat file. HeightHint is the
+// BIP34 height from the coinbase, or 0 when the block predates BIP34.
+type HeaderIndexEntry struct {
+	File          string `json:"file"`
+	Offset        int64  `json:"offset"`
+	Size          uint32 `json:"size"`
+	Network       string `json:"network"`
+	BlockHash     string `json:"block_hash"`
+	PrevBlockHash string `json:"prev_block_hash"`
+	HeightHint    int64  `json:"height_hint"`
+	Timestamp     uint32 `json:"timestamp"`
+	TxCount       int    `json:"tx_count"`
+	PowValid      bool   `json:"pow_valid"`
+	VersionBits   []int  `json:"version_bits,omitempty"`
+}
+
+// ChainCheckOutput reports the integrity of a header chain read from block
+// files or header dumps. The best chain is the one with the most work;
+// stale headers branch off it and detached headers are in fragments that
+// do not link to it. Failed checks are listed in Breaks, and Valid is true
+// when there are none. Heights are known when the chain starts at genesis, at a BIP34
+// block or at a given height.
+type ChainCheckOutput struct {
+	OK              bool         `json:"ok"`
+	Mode            string       `json:"mode"`
+	Network         string       `json:"network"`
+	FileCount       int          `json:"file_count"`
+	HeaderCount     int          `json:"header_count"`
+	DuplicateCount  int          `json:"duplicate_headers"`
+	ChainLength     int          `json:"chain_length"`
+	StaleHeaders    int          `json:"stale_headers"`
+	DetachedHeaders int          `json:"detached_headers"`
+	FirstBlockHash  string       `json:"first_block_hash,omitempty"`
+	FirstHeight     *int64       `json:"first_height"`
+	TipHash         string       `json:"tip_hash,omitempty"`
+	TipHeight       *int64       `json:"tip_height"`
+	ChainWork       string       `json:"chain_work"`
+	Valid           bool         `json:"valid"`
+	Breaks          []ChainBreak `json:"breaks"`
+	Error           *ErrorInfo   `json:"error,omitempty"`
+}
+
+// ChainBreak is one failed check of a ChainCheckOutput. Kind is
+// "missing_parent" (a header whose parent is absent, starting a fragment
+// apart from the best chain), "invalid_pow", "timestamp" (not after the
+// median time of the previous 11 blocks), "bits" (a difficulty change off
+// a retarget boundary or beyond the allowed factor) or "height" (a BIP34
+// height that does not match the block's place in the chain). File and
+// Offset locate the block in a blk file.
+type ChainBreak struct {
+	Kind      string `json:"kind"`
+	BlockHash string `json:"block_hash"`
+	Height    *int64 `json:"height,omitempty"`
+	File      string `json:"file,omitempty"`
+	Offset    *int64 `json:"offset,omitempty"`
+	Detail    string `json:"detail"`
+}
+
+// VersionBitsSummary counts the blocks of a scan signalling each BIP9
+// bit, in bit order. Percentages are of all blocks counted, whether their
+// version uses BIP9 or not.
+type VersionBitsSummary struct {
+	Blocks     int                `json:"blocks"`
+	BIP9Blocks int                `json:"bip9_blocks"`
+	Bits       []VersionBitSignal `json:"bits"`
+}
+
+// VersionBitSignal is one bit of a VersionBitsSummary. Deployment names
+// the mainnet deployment the bit was assigned to when signalling blocks
+// fall inside its signalling window.
+type VersionBitSignal struct {
+	Bit        int     `json:"bit"`
+	Deployment string  `json:"deployment,omitempty"`
+	Blocks     int     `json:"blocks"`
+	Pct        float64 `json:"pct"`
 }
@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression formats recognized on input by their magic bytes
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// CompressedExtensions are the file name suffixes of compressed inputs, for
+// matching blk/rev files archived as e.g. blk00001.dat.zst
+var CompressedExtensions = []string{".gz", ".zst"}
+
+// TrimCompressedExtension strips a compression suffix from a file name
+func TrimCompressedExtension(name string) string {
+	for _, ext := range CompressedExtensions {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext)
+		}
+	}
+	return name
+}
+
+// DetectCompression reports the compression format data starts with. The
+// magic bytes are what count, not the file name, so a renamed or
+// extension-less file is still decompressed.
+func DetectCompression(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		return CompressionGzip
+	case bytes.HasPrefix(head, zstdMagic):
+		return CompressionZstd
+	}
+	return CompressionNone
+}
+
+// decompressReader is a decompressing stream over an underlying reader,
+// closing both on Close
+type decompressReader struct {
+	io.Reader
+	close func()
+	under io.Closer
+}
+
+func (r *decompressReader) Close() error {
+	if r.close != nil {
+		r.close()
+	}
+	if r.under != nil {
+		return r.under.Close()
+	}
+	return nil
+}
+
+// NewDecompressReader returns a reader that decompresses r on the fly when it
+// holds gzip (including concatenated members) or zstd data and passes it
+// through unchanged otherwise, along with the format found. Closing the
+// result closes r if it is an io.Closer.
+func NewDecompressReader(r io.Reader) (io.ReadCloser, string, error) {
+	under, _ := r.(io.Closer)
+	br := bufio.NewReader(r)
+	head, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, "", err
+	}
+	format := DetectCompression(head)
+	switch format {
+	case CompressionGzip:
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, format, fmt.Errorf("invalid gzip data: %w", err)
+		}
+		return &decompressReader{Reader: zr, under: under}, format, nil
+	case CompressionZstd:
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, format, fmt.Errorf("invalid zstd data: %w", err)
+		}
+		return &decompressReader{Reader: zr, close: zr.Close, under: under}, format, nil
+	}
+	return &decompressReader{Reader: br, under: under}, format, nil
+}
+
+// OpenInput opens a file for reading, decompressing it on the fly when it is
+// gzip- or zstd-compressed
+func OpenInput(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r, _, err := NewDecompressReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+// ReadInput reads a whole file like os.ReadFile, decompressing it when it is
+// gzip- or zstd-compressed
+func ReadInput(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r, format, err := NewDecompressReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	defer r.Close()
+	var buf bytes.Buffer
+	if format == CompressionNone {
+		if info, err := f.Stat(); err == nil {
+			buf.Grow(int(info.Size()) + bytes.MinRead)
+		}
+	}
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// ConcurrencyEnv is the environment variable that overrides the default
+// worker count used by block analysis, batch mode and background jobs
+const ConcurrencyEnv = "CHAIN_LENS_CONCURRENCY"
+
+var concurrency atomic.Int64
+
+func init() {
+	n, err := strconv.Atoi(os.Getenv(ConcurrencyEnv))
+	if err != nil || n < 1 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	concurrency.Store(int64(n))
+}
+
+// Concurrency returns the global worker count (defaults to GOMAXPROCS)
+func Concurrency() int {
+	return int(concurrency.Load())
+}
+
+// SetConcurrency sets the global worker count; values below 1 restore the
+// GOMAXPROCS default
+func SetConcurrency(n int) {
+	if n < 1 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	concurrency.Store(int64(n))
+}
+
+// ForEach calls fn for every index in [0, n) using at most Concurrency()
+// goroutines. It returns the error of the lowest failing index, so results
+// are deterministic regardless of scheduling.
+func ForEach(n int, fn func(i int) error) error {
+	workers := Concurrency()
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			if err := fn(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	errs := make([]error, n)
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= n {
+					return
+				}
+				errs[i] = fn(i)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
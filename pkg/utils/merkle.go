@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"crypto/sha256"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Hashing is split across goroutines in chunks of hashChunk hashes once a
+// batch reaches parallelHashMin; smaller batches are hashed inline because
+// handing them to workers costs more than the hashing. SHA-256 itself is the
+// stdlib's, which uses the SHA-NI/ARMv8 instructions where available.
+const (
+	hashChunk       = 512
+	parallelHashMin = 4096
+)
+
+// forEachHashChunk calls fn over [0, n) in chunks, in parallel on the
+// shared worker pool for large n
+func forEachHashChunk(n int, fn func(lo, hi int)) {
+	if n < parallelHashMin || Concurrency() <= 1 {
+		fn(0, n)
+		return
+	}
+	chunks := (n + hashChunk - 1) / hashChunk
+	ForEach(chunks, func(c int) error {
+		lo := c * hashChunk
+		fn(lo, min(lo+hashChunk, n))
+		return nil
+	})
+}
+
+// DoubleSHA256Batch returns sha256d of each input, hashing large batches in
+// parallel
+func DoubleSHA256Batch(inputs [][]byte) []chainhash.Hash {
+	out := make([]chainhash.Hash, len(inputs))
+	forEachHashChunk(len(inputs), func(lo, hi int) {
+		for i := lo; i < hi; i++ {
+			out[i] = chainhash.DoubleHashH(inputs[i])
+		}
+	})
+	return out
+}
+
+// TxHashes returns the txids of txs, or their wtxids when witness is set,
+// serializing and hashing large blocks in parallel
+func TxHashes(txs []*wire.MsgTx, witness bool) []chainhash.Hash {
+	out := make([]chainhash.Hash, len(txs))
+	forEachHashChunk(len(txs), func(lo, hi int) {
+		for i := lo; i < hi; i++ {
+			if witness {
+				out[i] = txs[i].WitnessHash()
+			} else {
+				out[i] = txs[i].TxHash()
+			}
+		}
+	})
+	return out
+}
+
+// MerkleRoot computes the Bitcoin merkle root of a list of hashes.
+// The input slice is left untouched; see MerkleRootInPlace for the variant
+// that avoids the copy.
+func MerkleRoot(hashes []chainhash.Hash) chainhash.Hash {
+	level := make([]chainhash.Hash, len(hashes))
+	copy(level, hashes)
+	return MerkleRootInPlace(level)
+}
+
+// MerkleRootInPlace computes the merkle root iteratively, reusing the input
+// slice as scratch space for each level (the contents are overwritten).
+// An odd node at the end of a level is paired with itself, per Bitcoin spec.
+// Wide levels are hashed in parallel chunks into a second buffer, since
+// hashing in place would let one chunk overwrite nodes another still reads.
+func MerkleRootInPlace(level []chainhash.Hash) chainhash.Hash {
+	if len(level) == 0 {
+		return chainhash.Hash{}
+	}
+	var spare []chainhash.Hash
+	for n := len(level); n > 1; n = (n + 1) / 2 {
+		pairs := (n + 1) / 2
+		if pairs < parallelHashMin || Concurrency() <= 1 {
+			hashMerkleLevel(level[:pairs], level[:n], 0, pairs)
+			continue
+		}
+		if spare == nil {
+			spare = make([]chainhash.Hash, pairs)
+		}
+		next, cur := spare[:pairs], level[:n]
+		forEachHashChunk(pairs, func(lo, hi int) {
+			hashMerkleLevel(next, cur, lo, hi)
+		})
+		level, spare = next, level
+	}
+	return level[0]
+}
+
+// hashMerkleLevel hashes the node pairs [lo, hi) of cur into next
+func hashMerkleLevel(next, cur []chainhash.Hash, lo, hi int) {
+	for p := lo; p < hi; p++ {
+		left := 2 * p
+		right := left + 1
+		if right == len(cur) {
+			right = left
+		}
+		next[p] = HashMerkleBranches(&cur[left], &cur[right])
+	}
+}
+
+// MerkleBranch returns the sibling hashes linking hashes[index] to the
+// merkle root, from the leaf level up. An odd node at the end of a level is
+// its own sibling, as it is paired with itself.
+func MerkleBranch(hashes []chainhash.Hash, index int) []chainhash.Hash {
+	level := make([]chainhash.Hash, len(hashes))
+	copy(level, hashes)
+	var branch []chainhash.Hash
+	for n := len(level); n > 1; n = (n + 1) / 2 {
+		sibling := index ^ 1
+		if sibling == n {
+			sibling = index
+		}
+		branch = append(branch, level[sibling])
+		hashMerkleLevel(level[:(n+1)/2], level[:n], 0, (n+1)/2)
+		index /= 2
+	}
+	return branch
+}
+
+// HashMerkleBranches returns sha256d(left || right) without heap allocation
+func HashMerkleBranches(left, right *chainhash.Hash) chainhash.Hash {
+	var buf [chainhash.HashSize * 2]byte
+	copy(buf[:chainhash.HashSize], left[:])
+	copy(buf[chainhash.HashSize:], right[:])
+	first := sha256.Sum256(buf[:])
+	return chainhash.Hash(sha256.Sum256(first[:]))
+}
+
+// WitnessMerkleRoot computes the BIP141 witness merkle root from a block's
+// wtxids. The coinbase wtxid is replaced by all zeros, as required.
+func WitnessMerkleRoot(wtxids []chainhash.Hash) chainhash.Hash {
+	level := make([]chainhash.Hash, len(wtxids))
+	copy(level, wtxids)
+	if len(level) > 0 {
+		level[0] = chainhash.Hash{}
+	}
+	return MerkleRootInPlace(level)
+}
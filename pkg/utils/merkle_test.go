@@ -0,0 +1,181 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func mustHash(t testing.TB, s string) chainhash.Hash {
+	t.Helper()
+	h, err := chainhash.NewHashFromStr(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *h
+}
+
+// sha256d hashes the concatenation of two nodes the long way, as an
+// independent reference for HashMerkleBranches
+func sha256d(left, right chainhash.Hash) chainhash.Hash {
+	first := sha256.Sum256(append(left[:], right[:]...))
+	return chainhash.Hash(sha256.Sum256(first[:]))
+}
+
+func TestMerkleRootSingleLeaf(t *testing.T) {
+	// The genesis block's only transaction is its merkle root
+	coinbase := mustHash(t, "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b")
+	if got := MerkleRoot([]chainhash.Hash{coinbase}); got != coinbase {
+		t.Errorf("got %s, want %s", got, coinbase)
+	}
+	if got := MerkleRoot(nil); got != (chainhash.Hash{}) {
+		t.Errorf("empty list: got %s, want zero hash", got)
+	}
+}
+
+func TestMerkleRootKnownBlock(t *testing.T) {
+	// Block 100000
+	txids := []chainhash.Hash{
+		mustHash(t, "8c14f0db3df150123e6f3dbbf30f8b955a8249b62ac1d1ff16284aefa3d06d87"),
+		mustHash(t, "fff2525b8931402dd09222c50775608f75787bd2b87e56995a7bdd30f79702c4"),
+		mustHash(t, "6359f0868171b1d194cbee1af2f16ea598ae8fad666d9b012c8ed2b79a236ec4"),
+		mustHash(t, "e9a66845e05d5abc0ad04ec80f774a7e585c6e8db975962d069a522137b80c1d"),
+	}
+	want := mustHash(t, "f3e94742aca4b5ef85488dc37c06c3282295ffec960994b2c0d5ac2a25a95766")
+	if got := MerkleRoot(txids); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestMerkleRootOddLeafCount(t *testing.T) {
+	leaves := make([]chainhash.Hash, 5)
+	for i := range leaves {
+		leaves[i] = chainhash.DoubleHashH([]byte{byte(i)})
+	}
+	// The last node of an odd level is paired with itself
+	ab, cd, ee := sha256d(leaves[0], leaves[1]), sha256d(leaves[2], leaves[3]), sha256d(leaves[4], leaves[4])
+	abcd, eeee := sha256d(ab, cd), sha256d(ee, ee)
+	want := sha256d(abcd, eeee)
+
+	input := append([]chainhash.Hash(nil), leaves...)
+	if got := MerkleRoot(input); got != want {
+		t.Errorf("MerkleRoot: got %s, want %s", got, want)
+	}
+	for i := range leaves {
+		if input[i] != leaves[i] {
+			t.Fatalf("MerkleRoot modified its input at %d", i)
+		}
+	}
+	if got := MerkleRootInPlace(input); got != want {
+		t.Errorf("MerkleRootInPlace: got %s, want %s", got, want)
+	}
+}
+
+// TestMerkleRootParallel checks that levels wide enough to be hashed in
+// parallel give the root a serial pairing does
+func TestMerkleRootParallel(t *testing.T) {
+	leaves := make([]chainhash.Hash, 2*parallelHashMin+3)
+	for i := range leaves {
+		leaves[i] = chainhash.DoubleHashH(binary.LittleEndian.AppendUint32(nil, uint32(i)))
+	}
+	level := append([]chainhash.Hash(nil), leaves...)
+	for len(level) > 1 {
+		var next []chainhash.Hash
+		for i := 0; i < len(level); i += 2 {
+			right := level[i]
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			next = append(next, sha256d(level[i], right))
+		}
+		level = next
+	}
+	if got := MerkleRootInPlace(leaves); got != level[0] {
+		t.Errorf("got %s, want %s", got, level[0])
+	}
+}
+
+// TestMerkleRootFixtureBlock checks the txid root against the header and
+// the witness root against the coinbase's BIP141 commitment
+func TestMerkleRootFixtureBlock(t *testing.T) {
+	block := readFixtureBlock(t)
+
+	if got := MerkleRoot(TxHashes(block.Transactions, false)); got != block.Header.MerkleRoot {
+		t.Errorf("merkle root: got %s, want %s", got, block.Header.MerkleRoot)
+	}
+
+	coinbase := block.Transactions[0]
+	var commitment []byte
+	for _, out := range coinbase.TxOut {
+		if len(out.PkScript) >= 38 && bytes.HasPrefix(out.PkScript, []byte{0x6a, 0x24, 0xaa, 0x21, 0xa9, 0xed}) {
+			commitment = out.PkScript[6:38]
+		}
+	}
+	if commitment == nil || len(coinbase.TxIn[0].Witness) != 1 {
+		t.Fatal("fixture block has no witness commitment")
+	}
+	root := WitnessMerkleRoot(TxHashes(block.Transactions, true))
+	var reserved chainhash.Hash
+	copy(reserved[:], coinbase.TxIn[0].Witness[0])
+	if got := sha256d(root, reserved); !bytes.Equal(got[:], commitment) {
+		t.Errorf("witness commitment: got %x, want %x", got[:], commitment)
+	}
+}
+
+func BenchmarkMerkleRoot4000(b *testing.B) {
+	leaves := make([]chainhash.Hash, 4000)
+	for i := range leaves {
+		leaves[i] = chainhash.DoubleHashH(binary.LittleEndian.AppendUint32(nil, uint32(i)))
+	}
+	level := make([]chainhash.Hash, len(leaves))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		copy(level, leaves)
+		MerkleRootInPlace(level)
+	}
+}
+
+// readFixtureBlock decodes the first block of fixtures/blocks/blk04330.dat.gz,
+// skipping the caller when the fixture is absent
+func readFixtureBlock(tb testing.TB) *wire.MsgBlock {
+	tb.Helper()
+	f, err := os.Open("../../fixtures/blocks/blk04330.dat.gz")
+	if err != nil {
+		tb.Skip("block fixture not available:", err)
+	}
+	defer f.Close()
+	key, err := os.ReadFile("../../fixtures/blocks/xor.dat")
+	if err != nil {
+		tb.Skip("xor key not available:", err)
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	// Each record is the network magic, the block size and the block
+	head := make([]byte, 8)
+	if _, err := io.ReadFull(zr, head); err != nil {
+		tb.Fatal(err)
+	}
+	head = XORDecode(head, key)
+	data := make([]byte, binary.LittleEndian.Uint32(head[4:]))
+	if _, err := io.ReadFull(zr, data); err != nil {
+		tb.Fatal(err)
+	}
+	data = XORDecodeAt(data, key, 8)
+
+	var block wire.MsgBlock
+	if err := block.Deserialize(bytes.NewReader(data)); err != nil {
+		tb.Fatal(err)
+	}
+	return &block
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Profiling stage names shared by the CLI and parser
+const (
+	StageReadFile    = "read_file"
+	StageDeserialize = "deserialize"
+	StageMerkle      = "merkle"
+	StageUndo        = "undo_parse"
+	StageClassify    = "classify"
+	StageDisassemble = "disassemble"
+	StageAddress     = "address_derivation"
+	StageJSONEncode  = "json_encode"
+)
+
+// StageTiming is the accumulated time spent in one pipeline stage
+type StageTiming struct {
+	Stage   string  `json:"stage"`
+	Calls   int64   `json:"calls"`
+	TotalMs float64 `json:"total_ms"`
+}
+
+type stageStats struct {
+	calls atomic.Int64
+	nanos atomic.Int64
+}
+
+var (
+	profiling atomic.Bool
+	stages    sync.Map // stage name -> *stageStats
+)
+
+// noopStop is returned by TimeStage while profiling is disabled
+func noopStop() {}
+
+// EnableProfiling turns on per-stage timing collection
+func EnableProfiling() {
+	profiling.Store(true)
+}
+
+// TimeStage starts timing a stage and returns the function that stops it:
+//
+//	defer utils.TimeStage(utils.StageDeserialize)()
+//
+// It costs a single atomic load when profiling is disabled. Safe for
+// concurrent use, so parallel block analysis accumulates correctly.
+func TimeStage(stage string) func() {
+	if !profiling.Load() {
+		return noopStop
+	}
+	start := time.Now()
+	return func() {
+		v, _ := stages.LoadOrStore(stage, &stageStats{})
+		s := v.(*stageStats)
+		s.calls.Add(1)
+		s.nanos.Add(int64(time.Since(start)))
+	}
+}
+
+// ProfileReport returns the accumulated stage timings, slowest first
+func ProfileReport() []StageTiming {
+	report := make([]StageTiming, 0)
+	stages.Range(func(k, v interface{}) bool {
+		s := v.(*stageStats)
+		report = append(report, StageTiming{
+			Stage:   k.(string),
+			Calls:   s.calls.Load(),
+			TotalMs: float64(s.nanos.Load()) / float64(time.Millisecond),
+		})
+		return true
+	})
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].TotalMs > report[j].TotalMs
+	})
+	return report
+}
@@ -89,6 +89,12 @@ func HexToBytes(hexStr string) ([]byte, error) {
 
 // XORDecode decodes XOR-obfuscated data (used for blk*.dat and rev*.dat)
 func XORDecode(data []byte, key []byte) []byte {
+	return XORDecodeAt(data, key, 0)
+}
+
+// XORDecodeAt decodes a slice of an XOR-obfuscated file that starts at the
+// given file offset; the key repeats from the start of the file
+func XORDecodeAt(data []byte, key []byte, offset int64) []byte {
 	if len(key) == 0 {
 		return data
 	}
@@ -104,8 +110,9 @@ func XORDecode(data []byte, key []byte) []byte {
 		return data
 	}
 	result := make([]byte, len(data))
+	k := int(offset % int64(len(key)))
 	for i := range data {
-		result[i] = data[i] ^ key[i%len(key)]
+		result[i] = data[i] ^ key[(k+i)%len(key)]
 	}
 	return result
 }
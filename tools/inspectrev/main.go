@@ -1,3 +1,7 @@
+// Command inspectrev prints the first bytes of the rev04330.dat fixture
+// decoded as CompactSize and CVarInt under different prefix assumptions,
+// from when the rev file layout was being worked out. Run it from the
+// repository root: go run ./tools/inspectrev
 package main
 
 import (
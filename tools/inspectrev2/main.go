@@ -1,6 +1,6 @@
-//go:build ignore
-// +build ignore
-
+// Command inspectrev2 prints the record prefix of the XOR-decoded undo
+// fixtures, from when the rev file layout was being worked out. Run it from
+// the repository root: go run ./tools/inspectrev2
 package main
 
 import (
@@ -4,12 +4,14 @@ import './App.css';
 import TransactionFlow from './components/TransactionFlow';
 import SegWitSavings from './components/SegWitSavings';
 import TechnicalDetails from './components/TechnicalDetails';
+import LiveFeed from './components/LiveFeed';
 
 function App() {
   const [fixtureInput, setFixtureInput] = useState('');
   const [result, setResult] = useState(null);
   const [loading, setLoading] = useState(false);
   const [error, setError] = useState(null);
+  const [view, setView] = useState('analyze');
 
   const analyzeTransaction = async () => {
     setLoading(true);
@@ -45,84 +47,104 @@ function App() {
       <header className="header">
         <h1>Chain Lens</h1>
         <p>Bitcoin Transaction Visualizer for Humans</p>
+        <div className="button-group view-switch">
+          <button onClick={() => setView('analyze')} className={view === 'analyze' ? '' : 'secondary'}>
+            🔍 Analyze
+          </button>
+          <button onClick={() => setView('live')} className={view === 'live' ? '' : 'secondary'}>
+            📡 Live Feed
+          </button>
+        </div>
       </header>
 
-      <div className="container">
-        <div className="input-section">
-          <h2>📝 Input Transaction</h2>
-          <textarea
-            value={fixtureInput}
-            onChange={(e) => setFixtureInput(e.target.value)}
-            placeholder='Paste fixture JSON here: {"network":"mainnet","raw_tx":"...","prevouts":[...]}'
-            rows={10}
-          />
-          <div className="button-group">
-            <button onClick={analyzeTransaction} disabled={loading}>
-              {loading ? '⏳ Analyzing...' : '🔍 Analyze Transaction'}
-            </button>
-            <button onClick={loadExample} className="secondary">
-              📄 Load Example
-            </button>
-          </div>
-          {error && <div className="error">❌ Error: {error}</div>}
+      {view === 'live' && (
+        <div className="container">
+          <LiveFeed />
         </div>
+      )}
 
-        {result && result.ok && (
-          <div className="results">
-            <div className="summary-card">
-              <h2>📊 Transaction Summary</h2>
-              <div className="summary-grid">
-                <div className="summary-item">
-                  <span className="label">Transaction ID</span>
-                  <span className="value mono">{result.txid.substring(0, 16)}...</span>
-                </div>
-                <div className="summary-item">
-                  <span className="label">Type</span>
-                  <span className="value">{result.segwit ? '⚡ SegWit' : '📜 Legacy'}</span>
-                </div>
-                <div className="summary-item">
-                  <span className="label">Fee</span>
-                  <span className="value">{result.fee_sats.toLocaleString()} sats</span>
-                </div>
-                <div className="summary-item">
-                  <span className="label">Fee Rate</span>
-                  <span className="value">{result.fee_rate_sat_vb.toFixed(2)} sat/vB</span>
-                </div>
-                <div className="summary-item">
-                  <span className="label">Size</span>
-                  <span className="value">{result.vbytes} vBytes</span>
-                </div>
-                <div className="summary-item">
-                  <span className="label">Weight</span>
-                  <span className="value">{result.weight.toLocaleString()} WU</span>
+      {view === 'analyze' && (
+        <div className="container">
+          <div className="input-section">
+            <h2>📝 Input Transaction</h2>
+            <textarea
+              value={fixtureInput}
+              onChange={(e) => setFixtureInput(e.target.value)}
+              placeholder='Paste fixture JSON here: {"network":"mainnet","raw_tx":"...","prevouts":[...]}'
+              rows={10}
+            />
+            <div className="button-group">
+              <button onClick={analyzeTransaction} disabled={loading}>
+                {loading ? '⏳ Analyzing...' : '🔍 Analyze Transaction'}
+              </button>
+              <button onClick={loadExample} className="secondary">
+                📄 Load Example
+              </button>
+            </div>
+            {error && <div className="error">❌ Error: {error}</div>}
+          </div>
+
+          {result && result.ok && (
+            <div className="results">
+              <div className="summary-card">
+                <h2>📊 Transaction Summary</h2>
+                <div className="summary-grid">
+                  <div className="summary-item">
+                    <span className="label">Transaction ID</span>
+                    <span className="value mono">{result.txid.substring(0, 16)}...</span>
+                  </div>
+                  <div className="summary-item">
+                    <span className="label">Type</span>
+                    <span className="value">{result.segwit ? '⚡ SegWit' : '📜 Legacy'}</span>
+                  </div>
+                  <div className="summary-item">
+                    <span className="label">Fee</span>
+                    <span className="value">{result.fee_sats.toLocaleString()} sats</span>
+                  </div>
+                  <div className="summary-item">
+                    <span className="label">Fee Rate</span>
+                    <span className="value">{result.fee_rate_sat_vb.toFixed(2)} sat/vB</span>
+                  </div>
+                  <div className="summary-item">
+                    <span className="label">Size</span>
+                    <span className="value">{result.vbytes} vBytes</span>
+                  </div>
+                  <div className="summary-item">
+                    <span className="label">Weight</span>
+                    <span className="value">{result.weight.toLocaleString()} WU</span>
+                  </div>
                 </div>
               </div>
-            </div>
 
-            <TransactionFlow result={result} />
+              <TransactionFlow result={result} />
 
-            {result.segwit && result.segwit_savings && (
-              <SegWitSavings savings={result.segwit_savings} />
-            )}
+              {result.segwit && result.segwit_savings && (
+                <SegWitSavings savings={result.segwit_savings} />
+              )}
 
-            {result.warnings && result.warnings.length > 0 && (
-              <div className="warnings-card">
-                <h2>⚠️ Warnings</h2>
-                {result.warnings.map((w, i) => (
-                  <div key={i} className="warning-item">
-                    {w.code === 'HIGH_FEE' && '💸 High fee detected'}
-                    {w.code === 'DUST_OUTPUT' && '🪙 Dust output detected'}
-                    {w.code === 'RBF_SIGNALING' && '🔄 Transaction is replaceable (RBF)'}
-                    {w.code === 'UNKNOWN_OUTPUT_SCRIPT' && '❓ Unknown script type'}
-                  </div>
-                ))}
-              </div>
-            )}
+              {result.warnings && result.warnings.length > 0 && (
+                <div className="warnings-card">
+                  <h2>⚠️ Warnings</h2>
+                  {result.warnings.map((w, i) => (
+                    <div key={i} className="warning-item">
+                      {w.code === 'HIGH_FEE' && '💸 High fee detected'}
+                      {w.code === 'LOW_FEE' && '🐢 Fee rate below the minimum relay fee'}
+                      {w.code === 'ABSURD_FEE' && `🤯 Fee is ${Math.round(w.context.fee_to_output_ratio * 100)}% of the value sent`}
+                      {w.code === 'DUST_OUTPUT' && '🪙 Dust output detected'}
+                      {w.code === 'RBF_SIGNALING' && '🔄 Transaction is replaceable (RBF)'}
+                      {w.code === 'UNKNOWN_OUTPUT_SCRIPT' && '❓ Unknown script type'}
+                      {w.code === 'NONSTANDARD_VERSION' && `🧬 Non-standard version ${w.context.version}`}
+                      {w.code === 'NONSTANDARD_WITNESS' && '🚧 Witness exceeds relay policy limits'}
+                    </div>
+                  ))}
+                </div>
+              )}
 
-            <TechnicalDetails result={result} />
-          </div>
-        )}
-      </div>
+              <TechnicalDetails result={result} />
+            </div>
+          )}
+        </div>
+      )}
     </div>
   );
 }
@@ -0,0 +1,116 @@
+import React, { useEffect, useState } from 'react';
+import './LiveFeed.css';
+
+const MAX_EVENTS = 100;
+
+function feedURL() {
+    const scheme = window.location.protocol === 'https:' ? 'wss' : 'ws';
+    return `${scheme}://${window.location.host}/api/ws`;
+}
+
+function shortId(id) {
+    return id ? id.slice(0, 12) + '…' + id.slice(-6) : '';
+}
+
+function StatCard({ label, value }) {
+    return (
+        <div className="feed-stat">
+            <span className="label">{label}</span>
+            <span className="value">{value}</span>
+        </div>
+    );
+}
+
+function FeedRow({ event }) {
+    const time = new Date(event.time).toLocaleTimeString();
+    const isBlock = event.kind === 'block';
+    return (
+        <div className={`feed-row ${isBlock ? 'feed-row-block' : ''}`}>
+            <span className="feed-kind">{isBlock ? '🧱 block' : '💱 tx'}</span>
+            <span className="feed-id mono">
+                {shortId(event.id)}
+                {isBlock && event.height > 0 && <span className="feed-height"> #{event.height.toLocaleString()}</span>}
+            </span>
+            <span className="feed-size">
+                {isBlock ? `${event.tx_count.toLocaleString()} txs` : `${(event.vbytes || 0).toLocaleString()} vB`}
+            </span>
+            <span className="feed-rate">
+                {event.fee_rate_sat_vb != null ? `${event.fee_rate_sat_vb.toFixed(2)} sat/vB` : '—'}
+            </span>
+            <span className="feed-source">{event.source}</span>
+            <span className="feed-time">{time}</span>
+        </div>
+    );
+}
+
+// LiveFeed shows the analyses made through this server as they happen,
+// streamed from /api/ws, newest first
+function LiveFeed() {
+    const [events, setEvents] = useState([]);
+    const [stats, setStats] = useState(null);
+    const [connected, setConnected] = useState(false);
+
+    useEffect(() => {
+        let ws;
+        let retry;
+        let closed = false;
+
+        const connect = () => {
+            ws = new WebSocket(feedURL());
+            ws.onopen = () => setConnected(true);
+            ws.onmessage = (e) => {
+                const msg = JSON.parse(e.data);
+                setStats(msg.stats);
+                if (msg.type === 'snapshot') {
+                    setEvents((msg.events || []).slice().reverse());
+                } else if (msg.event) {
+                    setEvents((prev) => [msg.event, ...prev].slice(0, MAX_EVENTS));
+                }
+            };
+            ws.onclose = () => {
+                setConnected(false);
+                if (!closed) retry = setTimeout(connect, 3000);
+            };
+        };
+        connect();
+
+        return () => {
+            closed = true;
+            clearTimeout(retry);
+            ws.close();
+        };
+    }, []);
+
+    return (
+        <div className="glass-card feed-card">
+            <div className="section-title">
+                Live Feed
+                <span className={`feed-status ${connected ? 'feed-status-on' : ''}`}>
+                    {connected ? '● live' : '○ reconnecting'}
+                </span>
+            </div>
+
+            {stats && (
+                <div className="feed-stats">
+                    <StatCard label="Transactions" value={stats.transactions.toLocaleString()} />
+                    <StatCard label="Blocks" value={stats.blocks.toLocaleString()} />
+                    <StatCard label="Fees Seen" value={`${stats.total_fees_sats.toLocaleString()} sats`} />
+                    <StatCard label="Avg Fee Rate" value={`${stats.avg_fee_rate_sat_vb.toFixed(2)} sat/vB`} />
+                    <StatCard label="Max Fee Rate" value={`${stats.max_fee_rate_sat_vb.toFixed(2)} sat/vB`} />
+                </div>
+            )}
+
+            {events.length === 0 ? (
+                <div className="feed-empty">
+                    Nothing analyzed yet. Transactions and block jobs submitted to this server appear here.
+                </div>
+            ) : (
+                <div className="feed-list">
+                    {events.map((ev, i) => <FeedRow key={`${ev.time}-${ev.id}-${i}`} event={ev} />)}
+                </div>
+            )}
+        </div>
+    );
+}
+
+export default LiveFeed;